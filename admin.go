@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// 管理 API 相關錯誤
+var (
+	ErrConnectionNotFound   = errors.New(ErrorConnectionNotFound)
+	ErrBroadcastRequired    = errors.New(ErrorBroadcastRequired)
+	ErrInvalidHistoryLimit  = errors.New(ErrorInvalidHistoryLimit)
+	ErrInvalidHideThreshold = errors.New(ErrorInvalidHideThreshold)
+	ErrInvalidRateLimits    = errors.New(ErrorInvalidRateLimits)
+)
+
+// RuntimeConfig 可在執行期間修改的設定
+//
+// Design considerations:
+// - 只包含修改後立即生效、不需要重新啟動的設定
+// - 欄位以 JSON 公開，PATCH 時只需提供要修改的欄位
+type RuntimeConfig struct {
+	HistoryLimit        int             `json:"historyLimit"`        // GET /api/messages 返回的歷史訊息數量
+	ReportHideThreshold int             `json:"reportHideThreshold"` // 自動隱藏訊息所需的檢舉者數量，0 表示不自動隱藏
+	RateLimits          RateLimitConfig `json:"rateLimits"`          // 訊息、登入與 WebSocket 連接的速率限制
+	MaskedWords         []string        `json:"maskedWords"`         // 遮蔽的詞彙
+	FlaggedWords        []string        `json:"flaggedWords"`        // 需要人工審核的詞彙
+	BlockedDomains      []string        `json:"blockedDomains"`      // 封鎖的連結網域
+}
+
+// defaultRuntimeConfig 返回以 config.go 預設值建立的執行期設定
+func defaultRuntimeConfig() RuntimeConfig {
+	return RuntimeConfig{
+		HistoryLimit:        DefaultHistoryLimit,
+		ReportHideThreshold: DefaultReportHideThreshold,
+		RateLimits:          DefaultRateLimits,
+		MaskedWords:         DefaultModerationMaskedWords,
+		FlaggedWords:        DefaultModerationFlaggedWords,
+		BlockedDomains:      DefaultModerationBlockedDomains,
+	}
+}
+
+// Validate 檢查執行期設定的範圍
+//
+// Returns:
+// - error: 歷史數量、檢舉門檻或速率限制不合法時返回對應錯誤
+func (c RuntimeConfig) Validate() error {
+	if c.HistoryLimit < 1 || c.HistoryLimit > DefaultMaxHistoryLimit {
+		return ErrInvalidHistoryLimit
+	}
+	if c.ReportHideThreshold < 0 {
+		return ErrInvalidHideThreshold
+	}
+	return validateRateLimits(c.RateLimits)
+}
+
+// validateRateLimits 檢查速率限制設定，任何數值為負時返回 ErrInvalidRateLimits
+func validateRateLimits(limits RateLimitConfig) error {
+	for _, limit := range []RateLimit{limits.MessagePerUser, limits.MessagePerChannel, limits.LoginPerIP, limits.WebSocketPerIP} {
+		if limit.Rate < 0 || limit.Burst < 0 {
+			return ErrInvalidRateLimits
+		}
+	}
+	if limits.LoginMaxFailures < 0 || limits.LoginFailureWindow < 0 || limits.LoginLockout < 0 {
+		return ErrInvalidRateLimits
+	}
+	return nil
+}
+
+// RuntimeSettings 保存目前生效的執行期設定
+//
+// Usage context:
+// - 全域 runtimeSettings 實例
+// - 管理 API 讀取與修改，getMessages 讀取歷史訊息數量
+type RuntimeSettings struct {
+	mu     sync.RWMutex
+	config RuntimeConfig
+}
+
+// NewRuntimeSettings 建立執行期設定
+//
+// Parameters:
+// - config: 初始設定
+//
+// Returns:
+// - *RuntimeSettings: 新的執行期設定實例
+func NewRuntimeSettings(config RuntimeConfig) *RuntimeSettings {
+	return &RuntimeSettings{config: config}
+}
+
+// Get 返回目前的設定副本，修改副本不會影響生效中的設定
+func (s *RuntimeSettings) Get() RuntimeConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	config := s.config
+	config.MaskedWords = append([]string(nil), s.config.MaskedWords...)
+	config.FlaggedWords = append([]string(nil), s.config.FlaggedWords...)
+	config.BlockedDomains = append([]string(nil), s.config.BlockedDomains...)
+	return config
+}
+
+// applyRuntimeConfig 驗證並套用執行期設定
+//
+// Design considerations:
+// - 呼叫者必須持有 ConfigManager 的 reloadMu（經由 UpdateRuntimeConfig 或 Reload），管理 API 的修改與重新載入不會交錯
+// - 驗證失敗時不做任何修改
+// - 設定與副作用在 runtimeSettings 的寫入鎖內一起套用，讀取者不會看到設定已更新但速率限制或過濾器尚未更新的狀態
+// - 速率限制有變更時原地修改全域的 rateLimits，保留令牌桶與登入鎖定，也不會與請求處理競爭全域變數
+// - 審核詞彙與網域以同名過濾器取代，保留洗版偵測的發送紀錄
+//
+// Parameters:
+// - config: 新的完整設定
+//
+// Returns:
+// - error: 設定不合法時返回 Validate 的錯誤
+func applyRuntimeConfig(config RuntimeConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	runtimeSettings.mu.Lock()
+	defer runtimeSettings.mu.Unlock()
+
+	previous := runtimeSettings.config
+	runtimeSettings.config = config
+	if config.RateLimits != previous.RateLimits {
+		rateLimits.Reconfigure(config.RateLimits)
+	}
+	reportQueue.SetHideThreshold(config.ReportHideThreshold)
+	moderationPipeline.Replace(NewLinkFilter(config.BlockedDomains, DefaultModerationMaxLinks))
+	moderationPipeline.Replace(NewWordListFilter(ModerationFilterProfanity, config.MaskedWords, ModerationActionModify))
+	moderationPipeline.Replace(NewWordListFilter(ModerationFilterWatchlist, config.FlaggedWords, ModerationActionFlag))
+	return nil
+}
+
+// clearChannel 清空頻道的歷史訊息並通知頻道內的客戶端
+//
+// Process flow:
+// 1. 從 MessageStore 清除頻道的所有訊息
+// 2. 清理被清除訊息的提及、投票與釘選，產生對應的 delete 與 pin 事件
+// 3. 寫入一則系統訊息說明頻道已被清空
+// 4. 依序廣播所有事件
+//
+// Parameters:
+// - admin: 執行者
+// - channel: 頻道名稱
+//
+// Returns:
+// - int: 被清除的訊息數量
+func clearChannel(admin *Account, channel string) int {
+	removed := messageStore.ClearChannel(channel)
+
+	var events []Message
+	for _, msg := range removed {
+		events = append(events, cleanupRemovedMessage(msg, DeletionReasonCleared)...)
+	}
+	notice := NewSystemMessage(fmt.Sprintf(SystemMessageChannelClearedTemplate, admin.Username), channel)
+	messageStore.AddMessage(notice)
+	broadcastEvents(append(events, notice))
+
+	log.Printf(LogChannelCleared, admin.Username, channel, len(removed))
+	return len(removed)
+}
+
+// broadcastServerMessage 在所有頻道發送伺服器公告
+//
+// Design considerations:
+// - 發送到 knownChannels 與目前有連接的頻道
+// - 公告是系統訊息，寫入各頻道的歷史
+//
+// Parameters:
+// - admin: 執行者
+// - content: 公告內容
+//
+// Returns:
+// - []string: 收到公告的頻道
+// - error: 內容為空或超過訊息長度上限時返回 ErrBroadcastRequired
+func broadcastServerMessage(admin *Account, content string) ([]string, error) {
+	content = strings.TrimSpace(content)
+	if content == "" || utf8.RuneCountInString(content) > DefaultMaxMessageLength {
+		return nil, ErrBroadcastRequired
+	}
+
+	channels := knownChannels()
+	for _, connection := range hub.connections() {
+		if !containsString(channels, connection.Channel) {
+			channels = append(channels, connection.Channel)
+		}
+	}
+	for _, channel := range channels {
+		publishMessage(NewSystemMessage(fmt.Sprintf(SystemMessageServerBroadcastTemplate, content), channel))
+	}
+
+	log.Printf(LogServerBroadcast, admin.Username, len(channels))
+	return channels, nil
+}
+
+// authenticateAdmin 驗證管理 API 請求的帳號與伺服器範圍權限
+//
+// Design considerations:
+// - 已驗證但權限不足的請求記錄為 denied 稽核紀錄，唯讀的查詢 API 不記錄
+//
+// Parameters:
+// - w: HTTP 回應寫入器，驗證失敗時寫入 401 或 403
+// - r: HTTP 請求
+// - action: 稽核動作，空字串表示不記錄
+//
+// Returns:
+// - *Account: 驗證成功的帳號
+// - bool: 是否可以繼續處理請求
+func authenticateAdmin(w http.ResponseWriter, r *http.Request, action string) (*Account, bool) {
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return nil, false
+	}
+	if !authorize(account, PermissionAdminister, RoleScopeServer) {
+		if action != "" {
+			auditLog.Record(AuditEntry{Actor: account.Username, Action: action, IP: clientIP(r), Outcome: AuditOutcomeDenied, Details: ErrorAdminForbidden})
+		}
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorAdminForbidden})
+		return nil, false
+	}
+	return account, true
+}
+
+// adminStatusCode 將管理操作錯誤轉換為 HTTP 狀態碼
+func adminStatusCode(err error) int {
+	if errors.Is(err, ErrConnectionNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
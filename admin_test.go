@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestAdminAPI 測試連接管理、頻道清空、伺服器公告與執行期設定
+func TestAdminAPI(t *testing.T) {
+	hub = *newTestHub(0)
+	messageStore = make(MessageStore)
+	messageIndex = NewSearchIndex()
+	presenceTracker = NewPresenceTracker(time.Minute)
+	moderationPipeline = newDefaultModerationPipeline()
+	runtimeSettings = NewRuntimeSettings(RuntimeConfig{HistoryLimit: DefaultHistoryLimit})
+	roleStore = NewRoleStore(append([]RoleGrant{{Username: "alice", Role: RoleAdmin}}, DefaultRoleGrants...))
+	defer func() {
+		roleStore = NewRoleStore(DefaultRoleGrants)
+		runtimeSettings = NewRuntimeSettings(defaultRuntimeConfig())
+		moderationPipeline = newDefaultModerationPipeline()
+	}()
+
+	request := func(method, path, username, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.SetBasicAuth(username, "password123")
+		rr := httptest.NewRecorder()
+		setupRoutes().ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("需要伺服器 admin", func(t *testing.T) {
+		for _, path := range []string{"/api/admin/connections", "/api/admin/config"} {
+			if rr := request("GET", path, "bob", ""); rr.Code != http.StatusForbidden {
+				t.Errorf("%s: expected 403 for non-admin, got %d", path, rr.Code)
+			}
+		}
+	})
+
+	t.Run("列出並中斷連接", func(t *testing.T) {
+		client := newTestClient("bob", "tech")
+		hub.register <- client
+		collectSystemMessages(client, 50*time.Millisecond)
+
+		rr := request("GET", "/api/admin/connections", "alice", "")
+		var response struct {
+			Connections []ConnectionInfo `json:"connections"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		if len(response.Connections) != 1 {
+			t.Fatalf("Expected one connection, got %+v", response.Connections)
+		}
+		connection := response.Connections[0]
+		if connection.ID == "" || connection.Username != "bob" || connection.ConnectedAt.IsZero() || connection.QueueCapacity != 64 {
+			t.Errorf("Expected connection details, got %+v", connection)
+		}
+
+		if rr := request("DELETE", "/api/admin/connections/"+connection.ID, "alice", ""); rr.Code != http.StatusNoContent {
+			t.Fatalf("Expected 204, got %d", rr.Code)
+		}
+		if event := <-client.send; event.Error == nil || event.Error.Code != ErrorCodeDisconnected {
+			t.Errorf("Expected disconnected error event, got %+v", event)
+		}
+		if _, open := <-client.send; open {
+			t.Error("Expected send queue to be closed")
+		}
+		if rr := request("DELETE", "/api/admin/connections/"+connection.ID, "alice", ""); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 for closed connection, got %d", rr.Code)
+		}
+	})
+
+	t.Run("清空頻道", func(t *testing.T) {
+		messageStore.AddMessage(NewMessage("alice", "第一則", "general"))
+		messageStore.AddMessage(NewMessage("charlie", "第二則", "general"))
+
+		rr := request("POST", "/api/admin/channels/general/clear", "alice", "")
+		var response struct {
+			Removed int `json:"removed"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		if rr.Code != http.StatusOK || response.Removed != 2 {
+			t.Fatalf("Expected 2 removed messages, got %d: %s", rr.Code, rr.Body.String())
+		}
+		messages := messageStore.GetRecentMessages("general", 10)
+		if len(messages) != 1 || !strings.Contains(messages[0].Content, "清空") {
+			t.Errorf("Expected only the clear notice, got %+v", messages)
+		}
+	})
+
+	t.Run("伺服器公告", func(t *testing.T) {
+		if rr := request("POST", "/api/admin/broadcast", "alice", `{"content":"  "}`); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for empty content, got %d", rr.Code)
+		}
+		if rr := request("POST", "/api/admin/broadcast", "alice", `{"content":"今晚維護"}`); rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		for _, channel := range []string{"general", "tech", "random"} {
+			messages := messageStore.GetRecentMessages(channel, 1)
+			if len(messages) != 1 || !messages[0].IsSystemMessage() || !strings.Contains(messages[0].Content, "今晚維護") {
+				t.Errorf("Expected announcement in %s, got %+v", channel, messages)
+			}
+		}
+	})
+
+	t.Run("修改執行期設定", func(t *testing.T) {
+		if rr := request("PATCH", "/api/admin/config", "alice", `{"historyLimit":0}`); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for invalid history limit, got %d", rr.Code)
+		}
+		if rr := request("PATCH", "/api/admin/config", "alice", `{"historyLimit":1,"maskedWords":["蘋果"]}`); rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+
+		rr := request("GET", "/api/messages?channel=general", "alice", "")
+		var messages []Message
+		json.Unmarshal(rr.Body.Bytes(), &messages)
+		if len(messages) != 1 {
+			t.Errorf("Expected history limit of 1, got %d messages", len(messages))
+		}
+
+		msg := NewMessage("bob", "我愛蘋果", "tech")
+		moderationPipeline.Moderate(&msg)
+		if strings.Contains(msg.Content, "蘋果") {
+			t.Errorf("Expected new masked word to apply, got %q", msg.Content)
+		}
+
+		rr = request("GET", "/api/admin/config", "alice", "")
+		var response struct {
+			Config RuntimeConfig `json:"config"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		if response.Config.HistoryLimit != 1 || len(response.Config.MaskedWords) != 1 {
+			t.Errorf("Expected updated config, got %+v", response.Config)
+		}
+	})
+}
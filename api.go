@@ -2,9 +2,14 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/gorilla/mux"
 )
 
 // getMessages 處理獲取歷史訊息的 API 請求
@@ -20,12 +25,13 @@ import (
 // - 空頻道時提供友好的歡迎訊息
 //
 // Process flow:
-// 1. 設置 CORS 標頭支援跨域請求
+// 1. 設置回應的 Content-Type（CORS 標頭由 corsMiddleware 統一設置）
 // 2. 檢查 channel 參數是否存在
-// 3. 獲取指定頻道的訊息列表
-// 4. 如果頻道為空則返回歡迎訊息
-// 5. 限制返回最近的訊息數量
-// 6. 序列化為 JSON 並返回
+// 3. 驗證帳號並確認具有頻道讀取權限
+// 4. 獲取指定頻道的訊息列表
+// 5. 如果頻道為空則返回歡迎訊息
+// 6. 限制返回最近的訊息數量
+// 7. 序列化為 JSON 並返回
 //
 // Usage context:
 // - 客戶端載入聊天歷史時調用
@@ -35,7 +41,6 @@ func getMessages(w http.ResponseWriter, r *http.Request) {
 	log.Print("收到 GET /api/messages 請求，channel: " + channel)
 
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	// 如果沒有指定 channel，返回錯誤
 	if channel == "" {
@@ -44,8 +49,20 @@ func getMessages(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+	if !authorize(account, PermissionReadChannel, channel) {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorChannelForbidden})
+		return
+	}
+
 	// 使用新的便利方法獲取最近訊息
-	recentMessages := messageStore.GetRecentMessages(channel, DefaultHistoryLimit)
+	recentMessages := messageStore.GetRecentMessages(channel, runtimeSettings.Get().HistoryLimit)
 	log.Printf("返回 channel %s 的 %d 條訊息 (總共 %d 條)", channel, len(recentMessages), messageStore.GetChannelMessageCount(channel))
 	json.NewEncoder(w).Encode(recentMessages)
 }
@@ -59,19 +76,20 @@ func getMessages(w http.ResponseWriter, r *http.Request) {
 // - 廣播訊息給 WebSocket 客戶端
 //
 // Design considerations:
-// - 支援 CORS 和 OPTIONS 預檢請求
 // - 要求必須指定 channel 參數
 // - 自動設置訊息 ID、時間戳等系統欄位
 // - 使用 goroutine 進行異步廣播避免阻塞回應
 //
 // Process flow:
-// 1. 設置 CORS 標頭並處理 OPTIONS 請求
-// 2. 解析 JSON 請求主體為 Message 結構
-// 3. 驗證必要的 channel 欄位
-// 4. 設置系統生成的欄位（ID、時間戳、用戶）
-// 5. 存儲訊息到對應頻道
-// 6. 立即回應客戶端表示成功
-// 7. 異步廣播訊息給 WebSocket 客戶端
+// 1. 設置回應的 Content-Type（CORS 標頭與 OPTIONS 預檢由 corsMiddleware 統一處理）
+// 2. 解析 JSON 請求主體為 Message 結構，只能由伺服器產生的類型（例如 system）返回 400
+// 3. 驗證必要的 channel 欄位，發送者為驗證的帳號，未驗證返回 401
+// 4. 以發送者檢查速率限制、封鎖與角色，斜線指令交給指令註冊表處理並直接回應結果
+// 5. 設置系統生成的欄位（ID、時間戳），非管理員檢查慢速模式
+// 6. 透過 prepareMessage 驗證附件與投票、檢查禁言並執行內容審核，被拒絕時返回錯誤代碼
+// 7. 存儲訊息到對應頻道
+// 8. 立即回應客戶端表示成功
+// 9. 異步廣播訊息給 WebSocket 客戶端並通知被提及的用戶
 //
 // Usage context:
 // - 客戶端透過 REST API 發送訊息時調用
@@ -80,15 +98,6 @@ func sendMessage(w http.ResponseWriter, r *http.Request) {
 	log.Print("收到 POST /api/messages 請求")
 
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-	if r.Method == "OPTIONS" {
-		log.Printf("處理 OPTIONS 請求")
-		w.WriteHeader(http.StatusOK)
-		return
-	}
 
 	var msg Message
 	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
@@ -108,13 +117,72 @@ func sendMessage(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("解析到訊息: %+v", msg)
 
+	// 只能由伺服器產生的類型（例如 system）會略過後續的檢查，在處理前拒絕
+	if err := checkClientMessageType(msg); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error(), "code": ErrorCodeInvalidType})
+		return
+	}
+
+	// 發送者以驗證的帳號為準，忽略請求主體的 user 欄位；沒有帳號就無法檢查角色，未驗證的請求一律拒絕
+	requester, authenticated := authenticateRequest(r)
+	if !authenticated {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+	msg.User = requester.Username
+
+	// 速率限制、封鎖、角色、慢速模式與禁言都以發送者檢查；被封鎖的用戶也不能執行指令
+	if err := checkSender(requester, msg.Channel); err != nil {
+		writeSendError(w, err)
+		return
+	}
+
+	// 斜線指令的執行者以驗證的帳號為準
+	if name, rawArgs, ok := extractCommand(&msg); ok {
+		if !authorize(requester, PermissionReadChannel, msg.Channel) {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{"error": ErrorChannelForbidden})
+			return
+		}
+
+		result, err := commandRegistry.Execute(CommandContext{
+			Account: requester,
+			Channel: msg.Channel,
+			Name:    name,
+			RawArgs: rawArgs,
+			Message: msg,
+			IP:      clientIP(r),
+		})
+		if err != nil {
+			w.WriteHeader(commandStatusCode(err))
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error(), "code": commandErrorCode(err)})
+			return
+		}
+		if result.Message == nil {
+			json.NewEncoder(w).Encode(map[string]string{"status": StatusCommandExecuted, "reply": result.Reply})
+			return
+		}
+		msg = *result.Message
+	}
+
 	msg.ID = generateMessageID()
 	msg.Timestamp = time.Now()
 
-	// 如果沒有指定用戶名，設定為預設值
-	if msg.User == "" {
-		msg.User = DefaultAPIUser
+	if err := checkSlowMode(requester, msg); err != nil {
+		writeSendError(w, err)
+		return
+	}
+
+	// 驗證、審核並補齊訊息內容
+	if err := prepareMessage(&msg); err != nil {
+		log.Printf(LogMessageRejected, msg.User, err)
+		w.WriteHeader(rejectionStatusCode(err))
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error(), "code": rejectionCode(err)})
+		return
 	}
+	recordSlowMode(requester, msg)
 
 	// 儲存訊息到對應 channel 的 messageStore
 	messageStore.AddMessage(msg)
@@ -135,6 +203,7 @@ func sendMessage(w http.ResponseWriter, r *http.Request) {
 		hub.broadcast <- msg
 		log.Print("訊息已廣播到 WebSocket 客戶端")
 	}()
+	notifyMentions(msg)
 }
 
 // getOnlineUsers 處理獲取在線用戶的 API 請求
@@ -148,11 +217,12 @@ func sendMessage(w http.ResponseWriter, r *http.Request) {
 // - 按頻道分組顯示用戶分佈情況
 // - 提供總用戶數和各頻道用戶數
 // - 即時反映當前連接狀態
+// - 同一用戶以多個裝置連接時只計算一次
 //
 // Process flow:
-// 1. 設置 CORS 標頭支援跨域請求
+// 1. 設置回應的 Content-Type（CORS 標頭由 corsMiddleware 統一設置）
 // 2. 遍歷 Hub 中的所有客戶端連接
-// 3. 按頻道將用戶名稱分組
+// 3. 按頻道將用戶名稱分組並去除重複
 // 4. 統計總用戶數和各頻道用戶數
 // 5. 序列化為 JSON 並返回
 //
@@ -161,19 +231,25 @@ func sendMessage(w http.ResponseWriter, r *http.Request) {
 // - 監控系統瞭解用戶分佈情況
 func getOnlineUsers(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
-	// 按 channel 分組用戶
+	// 按 channel 分組用戶，同一用戶的多個連接只列出一次
 	channelUsers := make(map[string][]string)
-	totalCount := 0
+	seen := make(map[string]map[string]bool)
+	uniqueUsers := make(map[string]bool)
 
 	for client := range hub.clients {
-		if channelUsers[client.channel] == nil {
+		if seen[client.channel] == nil {
+			seen[client.channel] = make(map[string]bool)
 			channelUsers[client.channel] = []string{}
 		}
+		if seen[client.channel][client.username] {
+			continue
+		}
+		seen[client.channel][client.username] = true
 		channelUsers[client.channel] = append(channelUsers[client.channel], client.username)
-		totalCount++
+		uniqueUsers[client.username] = true
 	}
+	totalCount := len(uniqueUsers)
 
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"channelUsers": channelUsers,
@@ -181,102 +257,2074 @@ func getOnlineUsers(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// getAccounts 處理獲取可用帳號的 API 請求
+// getPresence 處理查詢用戶在線狀態的 API 請求
 //
 // Responsible for:
-// - 處理 GET /api/accounts 的 HTTP 請求
-// - 返回所有可用的測試帳號資訊
-// - 過濾敏感資訊（如密碼）
+// - 處理 GET /api/presence?users=... 的 HTTP 請求
+// - 返回每位指定用戶彙整後的在線狀態
 //
 // Design considerations:
-// - 不包含密碼等敏感資訊在回應中
-// - 提供足夠資訊供客戶端顯示選項
-// - 保持 API 回應結構的一致性
+// - users 參數以逗號分隔多個用戶名
+// - 未指定 users 時返回所有測試帳號的狀態
+// - 未知或從未連線的用戶視為 offline
 //
 // Process flow:
-// 1. 設置 CORS 標頭支援跨域請求
-// 2. 遍歷所有預設測試帳號
-// 3. 建立只包含公開資訊的帳號列表
+// 1. 設置回應的 Content-Type（CORS 標頭由 corsMiddleware 統一設置）
+// 2. 解析 users 參數取得要查詢的用戶列表
+// 3. 從 PresenceTracker 取得每位用戶的狀態快照
 // 4. 序列化為 JSON 並返回
 //
 // Usage context:
-// - 客戶端登入頁面載入可用帳號選項
-// - 提供用戶選擇和瞭解可用帳號
-func getAccounts(w http.ResponseWriter, r *http.Request) {
+// - 客戶端顯示聯絡人在線狀態
+// - 查詢用戶最後在線時間
+func getPresence(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
-	// 只返回公開資訊，不包含密碼
-	testAccounts := getTestAccounts()
-	publicAccounts := make([]map[string]string, len(testAccounts))
-	for i, account := range testAccounts {
-		publicAccounts[i] = map[string]string{
-			"username": account.Username,
-			"channel":  account.Channel,
+	var usernames []string
+	for _, username := range strings.Split(r.URL.Query().Get("users"), ",") {
+		if username = strings.TrimSpace(username); username != "" {
+			usernames = append(usernames, username)
+		}
+	}
+	if len(usernames) == 0 {
+		for _, account := range getTestAccounts() {
+			usernames = append(usernames, account.Username)
 		}
 	}
 
+	presence := make([]UserPresence, 0, len(usernames))
+	for _, username := range usernames {
+		presence = append(presence, presenceTracker.Get(username))
+	}
+
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"accounts": publicAccounts,
+		"presence": presence,
 	})
 }
 
-// loginAccount 處理帳號登入驗證的 API 請求
+// getChannelPresence 處理查詢頻道在線狀態的 API 請求
 //
 // Responsible for:
-// - 處理 POST /api/login 的 HTTP 請求
-// - 驗證用戶提供的帳號憑證
-// - 返回驗證結果和帳號資訊
+// - 處理 GET /api/channels/{channel}/presence 的 HTTP 請求
+// - 返回該頻道中每位在線用戶的狀態（每位用戶一筆）
+//
+// Process flow:
+// 1. 設置回應的 Content-Type（CORS 標頭由 corsMiddleware 統一設置）
+// 2. 驗證帳號並確認具有頻道讀取權限
+// 3. 從 PresenceTracker 取得頻道在線列表
+// 4. 序列化為 JSON 並返回
+//
+// Usage context:
+// - 客戶端顯示頻道成員列表及其狀態
+func getChannelPresence(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	channel := mux.Vars(r)["channel"]
+	if !authorize(account, PermissionReadChannel, channel) {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorChannelForbidden})
+		return
+	}
+
+	users := presenceTracker.ChannelPresence(channel)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"channel": channel,
+		"users":   users,
+		"count":   len(users),
+	})
+}
+
+// getChannelSettings 處理查詢頻道設定的 API 請求
+//
+// Responsible for:
+// - 處理 GET /api/channels/{channel}/settings 的 HTTP 請求
+// - 返回頻道目前的設定（未設定過時為預設值）
+//
+// Usage context:
+// - 客戶端或管理工具查看頻道行為設定
+func getChannelSettings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	channel := mux.Vars(r)["channel"]
+	if !authorize(account, PermissionReadChannel, channel) {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorChannelForbidden})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"channel":  channel,
+		"settings": channelSettings.Get(channel),
+	})
+}
+
+// updateChannelSettings 處理修改頻道設定的 API 請求
+//
+// Responsible for:
+// - 處理 PUT /api/channels/{channel}/settings 的 HTTP 請求
+// - 只更新請求中提供的欄位
 //
 // Design considerations:
-// - 支援 CORS 和 OPTIONS 預檢請求
-// - 驗證失敗時返回適當的 HTTP 狀態碼
-// - 成功時返回帳號資訊但不包含密碼
+// - 使用 ChannelSettingsUpdate 進行部分更新
+// - 所有欄位（主題、存活時間、慢速模式、加入/離開記錄）都需要頻道管理員的帳號憑證
+// - 慢速模式變更時發布系統訊息
 //
 // Process flow:
-// 1. 設置 CORS 標頭並處理 OPTIONS 請求
-// 2. 解析 JSON 請求主體獲取帳號憑證
-// 3. 調用帳號驗證函式檢查憑證
-// 4. 根據驗證結果返回對應的回應
-// 5. 成功時包含帳號資訊，失敗時包含錯誤訊息
+// 1. 設置回應的 Content-Type（CORS 標頭與 OPTIONS 預檢由 corsMiddleware 統一處理）
+// 2. 驗證帳號憑證與頻道管理權限
+// 3. 解析 JSON 請求主體並驗證
+// 4. 套用更新並返回更新後的設定
 //
 // Usage context:
-// - 客戶端登入頁面驗證用戶憑證
-// - 提供統一的帳號驗證入口
-func loginAccount(w http.ResponseWriter, r *http.Request) {
+// - 關閉頻道加入/離開訊息的歷史記錄
+// - 設定頻道訊息的預設存活時間
+// - 開啟或關閉慢速模式
+func updateChannelSettings(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
+	// 頻道設定只有頻道管理員可以修改
+	channel := mux.Vars(r)["channel"]
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+	if !authorize(account, PermissionManageChannel, channel) {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorChannelSettingsDenied})
 		return
 	}
 
-	var loginData struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
+	var update ChannelSettingsUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidJSON})
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&loginData); err != nil {
+	if err := update.Validate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	before := channelSettings.Get(channel).SlowMode
+	settings := channelSettings.Apply(channel, update)
+	log.Printf(LogChannelSettingsUpdated, channel, settings)
+	announceSlowMode(account.Username, channel, before, settings.SlowMode)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"channel":  channel,
+		"settings": settings,
+	})
+}
+
+// getChannelPins 處理獲取頻道釘選訊息的 API 請求
+//
+// Responsible for:
+// - 處理 GET /api/channels/{channel}/pins 的 HTTP 請求
+// - 返回頻道的釘選列表（依釘選時間排列）
+//
+// Usage context:
+// - 客戶端進入頻道時載入釘選橫幅
+func getChannelPins(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	channel := mux.Vars(r)["channel"]
+	if !authorize(account, PermissionReadChannel, channel) {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorChannelForbidden})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"channel": channel,
+		"limit":   pinStore.Limit(),
+		"pins":    pinStore.List(channel),
+	})
+}
+
+// pinMessage 處理釘選訊息的 API 請求
+//
+// Responsible for:
+// - 處理 PUT /api/channels/{channel}/pins/{id} 的 HTTP 請求
+// - 釘選成功時發送系統訊息與 pin 事件
+//
+// Design considerations:
+// - 重複釘選返回目前列表，不再次通知
+//
+// Process flow:
+// 1. 設置回應的 Content-Type（CORS 標頭與 OPTIONS 預檢由 corsMiddleware 統一處理）
+// 2. 驗證帳號憑證
+// 3. 釘選訊息（檢查權限與上限）
+// 4. 通知頻道並返回釘選列表
+func pinMessage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	vars := mux.Vars(r)
+	pins, changed, err := pinStore.Pin(account, vars["channel"], vars["id"])
+	if err != nil {
+		w.WriteHeader(pinStatusCode(err))
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if changed {
+		announcePinChange(PinActionPin, account.Username, vars["channel"], vars["id"], pins)
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"channel": vars["channel"],
+		"pins":    pins,
+	})
+}
+
+// unpinMessage 處理取消釘選的 API 請求
+//
+// Responsible for:
+// - 處理 DELETE /api/channels/{channel}/pins/{id} 的 HTTP 請求
+// - 取消成功時發送系統訊息與 pin 事件
+func unpinMessage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	vars := mux.Vars(r)
+	pins, err := pinStore.Unpin(account, vars["channel"], vars["id"])
+	if err != nil {
+		w.WriteHeader(pinStatusCode(err))
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	announcePinChange(PinActionUnpin, account.Username, vars["channel"], vars["id"], pins)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"channel": vars["channel"],
+		"pins":    pins,
+	})
+}
+
+// getPoll 處理獲取投票結果的 API 請求
+//
+// Responsible for:
+// - 處理 GET /api/polls/{id} 的 HTTP 請求
+// - 返回目前的票數與呼叫者自己的選擇
+func getPoll(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	poll, channel, myVotes, err := pollStore.Get(mux.Vars(r)["id"], account.Username)
+	if err == nil && !authorize(account, PermissionReadChannel, channel) {
+		err = ErrPollNotFound
+	}
+	if err != nil {
+		w.WriteHeader(pollStatusCode(err))
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"poll":    poll,
+		"channel": channel,
+		"myVotes": myVotes,
+	})
+}
+
+// votePoll 處理投票的 API 請求
+//
+// Responsible for:
+// - 處理 POST /api/polls/{id}/votes 的 HTTP 請求
+// - 記錄呼叫者的選擇並廣播最新結果
+//
+// Design considerations:
+// - 與 WebSocket 的 vote 訊息使用相同的處理邏輯
+func votePoll(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	var vote PollVote
+	if err := json.NewDecoder(r.Body).Decode(&vote); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidJSON})
 		return
 	}
+	vote.PollID = mux.Vars(r)["id"]
+
+	poll, err := castVote(account.Username, readableChannels(account), &vote)
+	if err != nil {
+		w.WriteHeader(pollStatusCode(err))
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"poll": poll,
+	})
+}
+
+// closePoll 處理提前結束投票的 API 請求
+//
+// Responsible for:
+// - 處理 POST /api/polls/{id}/close 的 HTTP 請求
+// - 凍結結果並廣播最終結果
+//
+// Design considerations:
+// - 只有投票建立者或頻道管理員可以結束投票
+func closePoll(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
 
-	account, valid := validateAccount(loginData.Username, loginData.Password)
+	account, valid := authenticateRequest(r)
 	if !valid {
 		w.WriteHeader(http.StatusUnauthorized)
 		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
 		return
 	}
 
+	poll, channel, err := pollStore.Close(mux.Vars(r)["id"], account)
+	if err != nil {
+		w.WriteHeader(pollStatusCode(err))
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	log.Printf(LogPollClosed, poll.ID, channel)
+	announcePollUpdate(poll, channel)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"poll": poll,
+	})
+}
+
+// createScheduledMessage 處理建立排程訊息的 API 請求
+//
+// Responsible for:
+// - 處理 POST /api/scheduled 的 HTTP 請求
+// - 建立在 sendAt 時發送到呼叫者頻道的訊息
+//
+// Design considerations:
+// - sendAt 使用 RFC 3339 格式，必須晚於目前時間
+//
+// Process flow:
+// 1. 設置回應的 Content-Type（CORS 標頭與 OPTIONS 預檢由 corsMiddleware 統一處理）
+// 2. 驗證帳號憑證並解析請求主體
+// 3. 建立排程並返回 201
+func createScheduledMessage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	var request struct {
+		Content string    `json:"content"`
+		SendAt  time.Time `json:"sendAt"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidJSON})
+		return
+	}
+
+	job, err := scheduler.Schedule(account.Username, account.Channel, request.Content, request.SendAt)
+	if err != nil {
+		w.WriteHeader(scheduleStatusCode(err))
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	log.Printf(LogScheduleCreated, account.Username, job.ID, job.SendAt.Format(time.RFC3339), job.Channel)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"scheduled": job,
+	})
+}
+
+// listScheduledMessages 處理列出排程訊息的 API 請求
+//
+// Responsible for:
+// - 處理 GET /api/scheduled 的 HTTP 請求
+// - 返回呼叫者尚未發送的排程，依發送時間排序
+func listScheduledMessages(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"scheduled": scheduler.List(account.Username),
+	})
+}
+
+// updateScheduledMessage 處理修改排程訊息的 API 請求
+//
+// Responsible for:
+// - 處理 PUT /api/scheduled/{id} 的 HTTP 請求
+// - 只更新請求中提供的 content 或 sendAt
+//
+// Design considerations:
+// - 已發送或不屬於呼叫者的排程返回 404
+func updateScheduledMessage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	var update ScheduledMessageUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidJSON})
+		return
+	}
+
+	job, err := scheduler.Update(mux.Vars(r)["id"], account.Username, update)
+	if err != nil {
+		w.WriteHeader(scheduleStatusCode(err))
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"scheduled": job,
+	})
+}
+
+// cancelScheduledMessage 處理取消排程訊息的 API 請求
+//
+// Responsible for:
+// - 處理 DELETE /api/scheduled/{id} 的 HTTP 請求
+// - 刪除尚未發送的排程
+func cancelScheduledMessage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	if err := scheduler.Cancel(mux.Vars(r)["id"], account.Username); err != nil {
+		w.WriteHeader(scheduleStatusCode(err))
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// uploadFile 處理上傳檔案的 API 請求
+//
+// Responsible for:
+// - 處理 POST /api/uploads 的 multipart 請求
+// - 驗證請求者身份後將檔案交給 UploadStore 保存
+// - 返回附件中繼資料供後續發送 image/file 訊息使用
+//
+// Design considerations:
+// - 使用 MultipartReader 串流處理，不將整個檔案載入記憶體
+// - 請求主體大小以上傳上限加上表單額外空間限制
+//
+// Process flow:
+// 1. 設置回應的 Content-Type（CORS 標頭與 OPTIONS 預檢由 corsMiddleware 統一處理）
+// 2. 驗證帳號憑證
+// 3. 找到名為 file 的表單欄位
+// 4. 保存檔案並檢查大小與 MIME 類型
+// 5. 返回 201 與附件中繼資料
+//
+// Usage context:
+// - 客戶端發送圖片或檔案訊息前先上傳檔案
+func uploadFile(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, uploadStore.maxSize+(1<<20))
+	reader, err := r.MultipartReader()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorUploadFieldRequired})
+		return
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": ErrorUploadFieldRequired})
+			return
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+
+		attachment, err := uploadStore.Save(part.FileName(), part, account.Username)
+		part.Close()
+		if err != nil {
+			log.Printf(LogUploadError, err)
+			w.WriteHeader(uploadStatusCode(err))
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		log.Printf(LogUploadSaved, account.Username, attachment.Name, attachment.MIME, attachment.Size)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"attachment": attachment,
+		})
+		return
+	}
+}
+
+// downloadUpload 處理下載上傳檔案的 API 請求
+//
+// Responsible for:
+// - 處理 GET /api/uploads/{id} 的 HTTP 請求
+// - 驗證請求者身份後返回檔案內容
+//
+// Design considerations:
+// - 使用 http.ServeContent 支援 Range 與條件式請求
+// - 以偵測到的 MIME 類型回應，圖片使用 inline，其他檔案使用 attachment
+// - 內容以雜湊定址不會改變，可長時間快取
+//
+// Usage context:
+// - 客戶端顯示圖片或下載檔案
+func downloadUpload(w http.ResponseWriter, r *http.Request) {
+	if _, valid := authenticateRequest(r); !valid {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	file, attachment, err := uploadStore.Open(mux.Vars(r)["id"])
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(uploadStatusCode(err))
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", attachment.MIME)
+	w.Header().Set("Content-Disposition", contentDisposition(attachment))
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	w.Header().Set("ETag", `"`+attachment.Checksum+`"`)
+	http.ServeContent(w, r, attachment.Name, attachment.UploadedAt, file)
+}
+
+// downloadThumbnail 處理下載圖片縮圖的 API 請求
+//
+// Responsible for:
+// - 處理 GET /api/uploads/{id}/thumbnails/{size} 的 HTTP 請求
+// - 驗證請求者身份後返回指定尺寸的縮圖
+//
+// Usage context:
+// - 客戶端在訊息列表中顯示圖片縮圖
+func downloadThumbnail(w http.ResponseWriter, r *http.Request) {
+	if _, valid := authenticateRequest(r); !valid {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	vars := mux.Vars(r)
+	size, _ := strconv.Atoi(vars["size"])
+	file, thumbnail, attachment, err := uploadStore.OpenThumbnail(vars["id"], size)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(uploadStatusCode(err))
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", thumbnail.MIME)
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	http.ServeContent(w, r, "", attachment.UploadedAt, file)
+}
+
+// createUploadSession 處理建立分段上傳的 API 請求
+//
+// Responsible for:
+// - 處理 POST /api/uploads/sessions 的 HTTP 請求
+// - 建立新的工作階段並返回識別碼與初始進度
+//
+// Design considerations:
+// - checksum 可在此提供，也可延後到完成時提供
+//
+// Usage context:
+// - 客戶端上傳大型檔案前建立工作階段
+func createUploadSession(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	var request struct {
+		Name     string `json:"name"`
+		Size     int64  `json:"size"`
+		Checksum string `json:"checksum"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidJSON})
+		return
+	}
+
+	session, err := uploadSessions.Create(account.Username, request.Name, request.Size, request.Checksum)
+	if err != nil {
+		w.WriteHeader(uploadStatusCode(err))
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	log.Printf(LogUploadSessionCreated, account.Username, session.ID, session.Name, session.Size)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"session": session,
+	})
+}
+
+// getUploadSession 處理查詢分段上傳進度的 API 請求
+//
+// Responsible for:
+// - 處理 GET/HEAD /api/uploads/sessions/{id} 的 HTTP 請求
+// - 返回目前已接收的位元組數，客戶端中斷後由此處續傳
+//
+// Design considerations:
+// - 同時以 Upload-Offset 標頭返回進度，HEAD 請求只需讀取標頭
+func getUploadSession(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	session, err := uploadSessions.Get(mux.Vars(r)["id"], account.Username)
+	if err != nil {
+		w.WriteHeader(uploadStatusCode(err))
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(session.Size, 10))
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"session": session,
+	})
+}
+
+// uploadChunk 處理上傳分段內容的 API 請求
+//
+// Responsible for:
+// - 處理 PUT /api/uploads/sessions/{id} 的 HTTP 請求
+// - 依 Upload-Offset 標頭將請求主體追加到工作階段
+//
+// Design considerations:
+// - 偏移量必須等於伺服器目前的進度，不符時返回 409 與目前進度
+// - 單一分段大小受伺服器設定的 uploads.chunkMaxSize 限制
+// - 寫入中斷時已收到的部分仍會保留，客戶端查詢進度後續傳
+//
+// Process flow:
+// 1. 設置回應的 Content-Type（CORS 標頭與 OPTIONS 預檢由 corsMiddleware 統一處理）
+// 2. 驗證帳號憑證並解析 Upload-Offset
+// 3. 追加分段內容
+// 4. 返回更新後的進度
+func uploadChunk(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	offsetValue := r.Header.Get("Upload-Offset")
+	if offsetValue == "" {
+		offsetValue = r.URL.Query().Get("offset")
+	}
+	offset, err := strconv.ParseInt(offsetValue, 10, 64)
+	if err != nil || offset < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorUploadInvalidOffset})
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, configManager.Get().Uploads.ChunkMaxSize)
+	session, err := uploadSessions.WriteChunk(mux.Vars(r)["id"], account.Username, offset, r.Body)
+	if session.ID != "" {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+		w.Header().Set("Upload-Length", strconv.FormatInt(session.Size, 10))
+	}
+	if err != nil {
+		status := uploadStatusCode(err)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":   err.Error(),
+			"session": session,
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"session": session,
+	})
+}
+
+// completeUploadSession 處理完成分段上傳的 API 請求
+//
+// Responsible for:
+// - 處理 POST /api/uploads/sessions/{id}/complete 的 HTTP 請求
+// - 驗證雜湊後保存檔案並返回附件中繼資料
+// - 可選擇直接以 file 類型訊息發送到用戶的頻道
+//
+// Design considerations:
+// - 請求主體的 message 欄位存在時才發送訊息
+// - 訊息發送者與頻道取自驗證的帳號，而非請求內容
+//
+// Process flow:
+// 1. 設置回應的 Content-Type（CORS 標頭與 OPTIONS 預檢由 corsMiddleware 統一處理）
+// 2. 驗證帳號憑證並解析請求主體
+// 3. 要發送訊息時檢查速率限制、封鎖、發送權限與慢速模式，被拒絕時返回錯誤且不保存檔案
+// 4. 驗證進度與雜湊並保存檔案
+// 5. 需要時建立 file 訊息，通過 prepareMessage 驗證與審核後發送
+// 6. 返回 201 與附件（及訊息；訊息被拒絕時改為 error 與 code）
+func completeUploadSession(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	var request struct {
+		Checksum string `json:"checksum"`
+		Message  *struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidJSON})
+			return
+		}
+	}
+
+	// 要發送訊息時先以與 sendMessage 相同的規則檢查發送者，被拒絕時不完成上傳，工作階段保留供稍後重試
+	var msg Message
+	if request.Message != nil {
+		msg = NewMessage(account.Username, request.Message.Content, account.Channel)
+		msg.Type = MessageTypeFile
+		if err := checkSender(account, msg.Channel); err != nil {
+			writeSendError(w, err)
+			return
+		}
+		if err := checkSlowMode(account, msg); err != nil {
+			writeSendError(w, err)
+			return
+		}
+	}
+
+	attachment, err := uploadSessions.Finalize(mux.Vars(r)["id"], account.Username, request.Checksum)
+	if err != nil {
+		log.Printf(LogUploadError, err)
+		w.WriteHeader(uploadStatusCode(err))
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	log.Printf(LogUploadSaved, account.Username, attachment.Name, attachment.MIME, attachment.Size)
+
+	response := map[string]interface{}{
+		"attachment": attachment,
+	}
+	if request.Message != nil {
+		msg.Attachment = &attachment
+		if err := prepareMessage(&msg); err != nil {
+			// 檔案已保存，訊息被拒絕時仍返回附件，讓客戶端可以修改內容後重新發送
+			log.Printf(LogMessageRejected, account.Username, err)
+			response["error"] = err.Error()
+			response["code"] = rejectionCode(err)
+		} else {
+			recordSlowMode(account, msg)
+			publishMessage(msg)
+			response["message"] = msg
+		}
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// cancelUploadSession 處理取消分段上傳的 API 請求
+//
+// Responsible for:
+// - 處理 DELETE /api/uploads/sessions/{id} 的 HTTP 請求
+// - 刪除工作階段與已上傳的部分內容
+func cancelUploadSession(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	if err := uploadSessions.Cancel(mux.Vars(r)["id"], account.Username); err != nil {
+		w.WriteHeader(uploadStatusCode(err))
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// searchMessages 處理全文搜尋的 API 請求
+//
+// Responsible for:
+// - 處理 GET /api/search 的 HTTP 請求
+// - 解析搜尋文字、篩選條件與分頁參數
+// - 只返回呼叫者可讀取頻道的訊息
+//
+// Design considerations:
+// - 指定 channel 時必須是可讀取的頻道，否則返回 403
+// - from/to 接受 RFC 3339 或 YYYY-MM-DD，日期格式的 to 包含當天
+// - limit 超過上限時自動截斷為 DefaultSearchMaxLimit
+//
+// Process flow:
+// 1. 驗證帳號憑證
+// 2. 解析查詢參數並決定搜尋的頻道範圍
+// 3. 查詢搜尋索引並返回結果
+//
+// Usage context:
+// - 客戶端搜尋超出最近 50 條的歷史訊息
+func searchMessages(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	params := r.URL.Query()
+	query := SearchQuery{
+		Text:     strings.TrimSpace(params.Get("q")),
+		Channels: readableChannels(account),
+		User:     params.Get("user"),
+		Type:     params.Get("type"),
+		Limit:    DefaultSearchLimit,
+	}
+	if query.Text == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorSearchQueryRequired})
+		return
+	}
+
+	if channel := params.Get("channel"); channel != "" {
+		if !containsString(query.Channels, channel) {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{"error": ErrorChannelForbidden})
+			return
+		}
+		query.Channels = []string{channel}
+	}
+
+	var err error
+	if query.From, err = parseSearchTime(params.Get("from"), false); err == nil {
+		query.To, err = parseSearchTime(params.Get("to"), true)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorSearchInvalidDate})
+		return
+	}
+
+	if query.Limit, err = parseIntParam(params.Get("limit"), DefaultSearchLimit); err == nil {
+		query.Offset, err = parseIntParam(params.Get("offset"), 0)
+	}
+	if err != nil || query.Limit == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidPaging})
+		return
+	}
+	query.Limit = min(query.Limit, DefaultSearchMaxLimit)
+
+	result := messageIndex.Search(query)
+	log.Printf(LogSearchExecuted, account.Username, query.Text, result.Total)
+	json.NewEncoder(w).Encode(result)
+}
+
+// getMentions 處理獲取提及收件匣的 API 請求
+//
+// Responsible for:
+// - 處理 GET /api/mentions 的 HTTP 請求
+// - 返回提及呼叫者（包含 @channel）的訊息，由新到舊排列
+//
+// Design considerations:
+// - limit 預設為 DefaultHistoryLimit，最多為 DefaultMentionInboxLimit
+//
+// Usage context:
+// - 客戶端顯示「提及我的」列表
+func getMentions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	limit, err := parseIntParam(r.URL.Query().Get("limit"), DefaultHistoryLimit)
+	if err != nil || limit == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidPaging})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"username": account.Username,
+		"mentions": mentionInbox.List(account.Username, min(limit, DefaultMentionInboxLimit)),
+	})
+}
+
+// getFlaggedMessages 處理獲取審核佇列的 API 請求
+//
+// Responsible for:
+// - 處理 GET /api/moderation/flags 的 HTTP 請求
+// - 返回呼叫者可管理頻道中被標記的訊息
+//
+// Design considerations:
+// - 只有頻道管理員可以查看，非管理員返回 403
+// - status 參數預設為 pending，all 表示列出所有狀態
+func getFlaggedMessages(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	var channels []string
+	for _, channel := range readableChannels(account) {
+		if authorize(account, PermissionModerate, channel) {
+			channels = append(channels, channel)
+		}
+	}
+	if len(channels) == 0 {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorModerationForbidden})
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = FlagStatusPending
+	case "all":
+		status = ""
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"flags": moderationQueue.List(channels, status),
+	})
+}
+
+// reviewFlaggedMessage 處理審核被標記訊息的 API 請求
+//
+// Responsible for:
+// - 處理 POST /api/moderation/flags/{id} 的 HTTP 請求
+// - approve 保留訊息，remove 從歷史刪除訊息並通知客戶端
+//
+// Design considerations:
+// - 只有訊息所在頻道的管理員可以審核
+//
+// Process flow:
+// 1. 設置回應的 Content-Type（CORS 標頭與 OPTIONS 預檢由 corsMiddleware 統一處理）
+// 2. 驗證帳號憑證與管理權限
+// 3. 記錄審核結果，remove 時刪除訊息並廣播 delete 事件
+// 4. 返回更新後的審核紀錄
+func reviewFlaggedMessage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	var request struct {
+		Action string `json:"action"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidJSON})
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	flagged, ok := moderationQueue.Get(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorFlagNotFound})
+		return
+	}
+	if !authorize(account, PermissionModerate, flagged.Message.Channel) {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorModerationForbidden})
+		return
+	}
+
+	channel := flagged.Message.Channel
+	flagged, err := moderationQueue.Review(id, account.Username, request.Action)
+	auditLog.Record(AuditEntry{Actor: account.Username, Action: AuditActionFlagPrefix + request.Action, Target: id, Channel: channel, IP: clientIP(r), Outcome: auditOutcome(err, moderationStatusCode), Details: auditDetails("", err)})
+	if err != nil {
+		w.WriteHeader(moderationStatusCode(err))
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	log.Printf(LogFlagReviewed, account.Username, id, flagged.Status)
+	if flagged.Status == FlagStatusRemoved {
+		removeMessage(flagged.Message.Channel, id, DeletionReasonModerated)
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"flag": flagged,
+	})
+}
+
+// muteChannelUser 處理禁言用戶的 API 請求
+//
+// Responsible for:
+// - 處理 POST /api/channels/{channel}/mutes 的 HTTP 請求
+// - 在頻道中禁言用戶並發布系統訊息
+//
+// Design considerations:
+// - 請求主體為 {"username": "...", "minutes": 10}，minutes 省略時使用 DefaultMuteDuration
+// - 只有頻道管理員可以使用，且不能禁言自己
+func muteChannelUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	var request struct {
+		Username string `json:"username"`
+		Minutes  int    `json:"minutes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidJSON})
+		return
+	}
+	if request.Minutes == 0 {
+		request.Minutes = DefaultMuteDuration
+	}
+
+	channel := mux.Vars(r)["channel"]
+	mute, err := muteUser(account, channel, request.Username, request.Minutes)
+	auditLog.Record(AuditEntry{Actor: account.Username, Action: AuditActionMute, Target: request.Username, Channel: channel, IP: clientIP(r), Outcome: auditOutcome(err, sanctionStatusCode), Details: auditDetails("", err)})
+	if err != nil {
+		w.WriteHeader(sanctionStatusCode(err))
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"mute": mute,
+	})
+}
+
+// unmuteChannelUser 處理解除禁言的 API 請求
+//
+// Responsible for:
+// - 處理 DELETE /api/channels/{channel}/mutes/{username} 的 HTTP 請求
+// - 提前解除禁言並發布系統訊息
+func unmuteChannelUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	vars := mux.Vars(r)
+	err := unmuteUser(account, vars["channel"], vars["username"])
+	auditLog.Record(AuditEntry{Actor: account.Username, Action: AuditActionUnmute, Target: vars["username"], Channel: vars["channel"], IP: clientIP(r), Outcome: auditOutcome(err, sanctionStatusCode), Details: auditDetails("", err)})
+	if err != nil {
+		w.WriteHeader(sanctionStatusCode(err))
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// kickChannelUser 處理踢出用戶的 API 請求
+//
+// Responsible for:
+// - 處理 POST /api/channels/{channel}/kicks 的 HTTP 請求
+// - 關閉用戶在頻道內的所有連接並發布系統訊息
+//
+// Design considerations:
+// - 請求主體為 {"username": "...", "reason": "..."}，reason 可省略
+// - 用戶沒有連接時返回 404
+func kickChannelUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	var request struct {
+		Username string `json:"username"`
+		Reason   string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidJSON})
+		return
+	}
+
+	channel := mux.Vars(r)["channel"]
+	reason := strings.TrimSpace(request.Reason)
+	closed, err := kickUser(account, channel, request.Username, reason)
+	auditLog.Record(AuditEntry{Actor: account.Username, Action: AuditActionKick, Target: request.Username, Channel: channel, IP: clientIP(r), Outcome: auditOutcome(err, sanctionStatusCode), Details: auditDetails(reason, err)})
+	if err != nil {
+		w.WriteHeader(sanctionStatusCode(err))
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"channel":     channel,
+		"username":    request.Username,
+		"connections": closed,
+	})
+}
+
+// getBans 處理獲取封鎖名單的 API 請求
+//
+// Responsible for:
+// - 處理 GET /api/channels/{channel}/bans 與 GET /api/bans 的 HTTP 請求
+// - 返回頻道或全伺服器目前有效的封鎖
+//
+// Design considerations:
+// - 路徑沒有 channel 時代表全伺服器封鎖名單
+// - 頻道名單需要該頻道的管理權限，全伺服器名單開放給任何管理員
+func getBans(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	channel := mux.Vars(r)["channel"]
+	if !authorize(account, PermissionSanction, channel) {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorSanctionForbidden})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"channel": channel,
+		"bans":    banStore.List(channel),
+	})
+}
+
+// createBan 處理封鎖用戶的 API 請求
+//
+// Responsible for:
+// - 處理 POST /api/channels/{channel}/bans 與 POST /api/bans 的 HTTP 請求
+// - 封鎖用戶、發布系統訊息並關閉其連接
+//
+// Design considerations:
+// - 請求主體為 {"username": "...", "reason": "...", "minutes": 60}，minutes 省略或為 0 時永久封鎖
+// - 路徑沒有 channel 時封鎖整個伺服器
+func createBan(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	var request struct {
+		Username string `json:"username"`
+		Reason   string `json:"reason"`
+		Minutes  int    `json:"minutes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidJSON})
+		return
+	}
+
+	channel := mux.Vars(r)["channel"]
+	reason := strings.TrimSpace(request.Reason)
+	ban, err := banUser(account, channel, request.Username, reason, request.Minutes)
+	auditLog.Record(AuditEntry{Actor: account.Username, Action: AuditActionBan, Target: request.Username, Channel: channel, IP: clientIP(r), Outcome: auditOutcome(err, sanctionStatusCode), Details: auditDetails(reason, err)})
+	if err != nil {
+		w.WriteHeader(sanctionStatusCode(err))
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"ban": ban,
+	})
+}
+
+// deleteBan 處理解除封鎖的 API 請求
+//
+// Responsible for:
+// - 處理 DELETE /api/channels/{channel}/bans/{username} 與 DELETE /api/bans/{username} 的 HTTP 請求
+// - 解除封鎖並發布系統訊息
+func deleteBan(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	vars := mux.Vars(r)
+	err := unbanUser(account, vars["channel"], vars["username"])
+	auditLog.Record(AuditEntry{Actor: account.Username, Action: AuditActionUnban, Target: vars["username"], Channel: vars["channel"], IP: clientIP(r), Outcome: auditOutcome(err, sanctionStatusCode), Details: auditDetails("", err)})
+	if err != nil {
+		w.WriteHeader(sanctionStatusCode(err))
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getRoles 處理獲取角色名單的 API 請求
+//
+// Responsible for:
+// - 處理 GET /api/channels/{channel}/roles 與 GET /api/roles 的 HTTP 請求
+// - 返回指定範圍明確授予的角色
+//
+// Design considerations:
+// - 頻道角色需要該頻道的讀取權限，伺服器角色開放給所有已驗證的帳號
+// - 帳號在所屬頻道的預設 member 角色不列出，回應另附請求者的有效角色
+func getRoles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	channel := mux.Vars(r)["channel"]
+	if channel != RoleScopeServer && !authorize(account, PermissionReadChannel, channel) {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorChannelForbidden})
+		return
+	}
+
+	role := effectiveRole(account, channel)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"channel":     channel,
+		"roles":       roleStore.List(channel),
+		"role":        role,
+		"permissions": DefaultRolePermissions[role],
+	})
+}
+
+// updateRole 處理授予角色的 API 請求
+//
+// Responsible for:
+// - 處理 PUT /api/channels/{channel}/roles/{username} 與 PUT /api/roles/{username} 的 HTTP 請求
+// - 授予或取代用戶在指定範圍的角色
+//
+// Design considerations:
+// - 請求主體為 {"role": "moderator"}
+// - 路徑沒有 channel 時授予伺服器範圍的角色，在所有頻道生效
+// - 只能授予低於自己的角色，權限規則見 roleTarget
+func updateRole(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	var request struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidJSON})
+		return
+	}
+
+	vars := mux.Vars(r)
+	grant, err := grantUserRole(account, vars["channel"], vars["username"], request.Role)
+	auditLog.Record(AuditEntry{Actor: account.Username, Action: AuditActionRoleGrant, Target: vars["username"], Channel: vars["channel"], IP: clientIP(r), Outcome: auditOutcome(err, roleStatusCode), Details: auditDetails(request.Role, err)})
+	if err != nil {
+		w.WriteHeader(roleStatusCode(err))
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"role": grant,
+	})
+}
+
+// deleteRole 處理撤銷角色的 API 請求
+//
+// Responsible for:
+// - 處理 DELETE /api/channels/{channel}/roles/{username} 與 DELETE /api/roles/{username} 的 HTTP 請求
+// - 撤銷後用戶回到預設角色（所屬頻道為 member，其他頻道無權限）
+func deleteRole(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	vars := mux.Vars(r)
+	err := revokeUserRole(account, vars["channel"], vars["username"])
+	auditLog.Record(AuditEntry{Actor: account.Username, Action: AuditActionRoleRevoke, Target: vars["username"], Channel: vars["channel"], IP: clientIP(r), Outcome: auditOutcome(err, roleStatusCode), Details: auditDetails("", err)})
+	if err != nil {
+		w.WriteHeader(roleStatusCode(err))
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// reportMessage 處理檢舉訊息的 API 請求
+//
+// Responsible for:
+// - 處理 POST /api/messages/{id}/report 的 HTTP 請求
+// - 記錄檢舉並在不同用戶的檢舉數達到門檻時自動隱藏訊息
+//
+// Design considerations:
+// - 請求主體為 {"reason": "spam", "details": "..."}，reason 為 other 時必須附上 details
+// - 只能檢舉自己可讀取頻道中的訊息，不能檢舉系統訊息或自己的訊息
+// - 回應只包含自己的檢舉與訊息是否已隱藏，不透露其他檢舉者
+func reportMessage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	var request struct {
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidJSON})
+		return
+	}
+
+	msg, ok := findReportableMessage(account, mux.Vars(r)["id"])
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorMessageNotFound})
+		return
+	}
+
+	reported, err := submitReport(account, msg, request.Reason, request.Details)
+	if err != nil {
+		w.WriteHeader(reportStatusCode(err))
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"messageId": msg.ID,
+		"report":    reported.Reports[len(reported.Reports)-1],
+		"hidden":    reported.Hidden,
+	})
+}
+
+// getReports 處理獲取檢舉佇列的 API 請求
+//
+// Responsible for:
+// - 處理 GET /api/moderation/reports 的 HTTP 請求
+// - 返回呼叫者可管理頻道中的檢舉案件
+//
+// Design considerations:
+// - 只有頻道管理員可以查看，非管理員返回 403
+// - status 參數預設為 open，all 表示列出所有狀態
+func getReports(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	var channels []string
+	for _, channel := range readableChannels(account) {
+		if authorize(account, PermissionModerate, channel) {
+			channels = append(channels, channel)
+		}
+	}
+	if len(channels) == 0 {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorModerationForbidden})
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = ReportStatusOpen
+	case "all":
+		status = ""
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"reports": reportQueue.List(channels, status),
+	})
+}
+
+// getReport 處理獲取單一檢舉案件的 API 請求
+//
+// Responsible for:
+// - 處理 GET /api/moderation/reports/{id} 的 HTTP 請求
+// - 返回案件的所有檢舉與處理紀錄
+func getReport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	reported, ok := reportQueue.Get(mux.Vars(r)["id"])
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorReportNotFound})
+		return
+	}
+	if !authorize(account, PermissionModerate, reported.Message.Channel) {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorModerationForbidden})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"report": reported,
+	})
+}
+
+// reviewReport 處理認領、確認或駁回檢舉的 API 請求
+//
+// Responsible for:
+// - 處理 POST /api/moderation/reports/{id}/{claim|resolve|dismiss} 的 HTTP 請求
+// - resolve 刪除訊息，dismiss 恢復被自動隱藏的訊息
+//
+// Design considerations:
+// - 只有訊息所在頻道的管理員可以處理，已被其他管理員認領時返回 409
+// - 請求主體可選，{"note": "..."} 會記錄在處理紀錄中
+//
+// Process flow:
+// 1. 設置回應的 Content-Type（CORS 標頭與 OPTIONS 預檢由 corsMiddleware 統一處理）
+// 2. 驗證帳號憑證與管理權限
+// 3. 更新案件狀態並刪除或恢復訊息
+// 4. 返回更新後的案件
+func reviewReport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, valid := authenticateRequest(r)
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
+	var request struct {
+		Note string `json:"note"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidJSON})
+			return
+		}
+	}
+
+	vars := mux.Vars(r)
+	reported, ok := reportQueue.Get(vars["id"])
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorReportNotFound})
+		return
+	}
+	if !authorize(account, PermissionModerate, reported.Message.Channel) {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorModerationForbidden})
+		return
+	}
+
+	action := map[string]string{
+		"claim":   ReportActionClaimed,
+		"resolve": ReportActionResolved,
+		"dismiss": ReportActionDismissed,
+	}[vars["action"]]
+	channel := reported.Message.Channel
+	note := strings.TrimSpace(request.Note)
+	reported, err := decideReport(account.Username, vars["id"], action, note)
+	auditLog.Record(AuditEntry{Actor: account.Username, Action: AuditActionReportPrefix + vars["action"], Target: vars["id"], Channel: channel, IP: clientIP(r), Outcome: auditOutcome(err, reportStatusCode), Details: auditDetails(note, err)})
+	if err != nil {
+		w.WriteHeader(reportStatusCode(err))
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"report": reported,
+	})
+}
+
+// getMetrics 處理獲取伺服器運行指標的 API 請求
+//
+// Responsible for:
+// - 處理 GET /api/metrics 的 HTTP 請求
+// - 返回所有計數器目前的值
+//
+// Design considerations:
+// - 計數器只包含次數，不含用戶或 IP 等識別資訊，因此不需驗證
+func getMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"counters": metrics.Snapshot(),
+	})
+}
+
+// getAdminConnections 處理列出 WebSocket 連接的管理 API 請求
+//
+// Responsible for:
+// - 處理 GET /api/admin/connections 的 HTTP 請求
+// - 返回每個連接的 ID、用戶、頻道、連接時間與發送佇列深度
+//
+// Design considerations:
+// - 需要伺服器範圍的 PermissionAdminister 權限
+// - 佇列深度接近容量的連接代表客戶端讀取太慢，廣播時會被移除
+func getAdminConnections(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, ok := authenticateAdmin(w, r, ""); !ok {
+		return
+	}
+
+	connections := hub.connections()
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"connections": connections,
+		"total":       len(connections),
+	})
+}
+
+// deleteAdminConnection 處理強制中斷連接的管理 API 請求
+//
+// Responsible for:
+// - 處理 DELETE /api/admin/connections/{id} 的 HTTP 請求
+// - 發送 disconnected 錯誤事件後關閉連接，客戶端可以重新連接
+func deleteAdminConnection(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, ok := authenticateAdmin(w, r, AuditActionDisconnect)
+	if !ok {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	var target ConnectionInfo
+	for _, connection := range hub.connections() {
+		if connection.ID == id {
+			target = connection
+		}
+	}
+	if target.ID == "" || !hub.disconnectConnection(id, ErrorCodeDisconnected, ErrorForceDisconnected) {
+		auditLog.Record(AuditEntry{Actor: account.Username, Action: AuditActionDisconnect, Target: id, IP: clientIP(r), Outcome: AuditOutcomeFailure, Details: ErrorConnectionNotFound})
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorConnectionNotFound})
+		return
+	}
+
+	auditLog.Record(AuditEntry{Actor: account.Username, Action: AuditActionDisconnect, Target: id, Channel: target.Channel, IP: clientIP(r), Outcome: AuditOutcomeSuccess, Details: target.Username})
+	log.Printf(LogAdminDisconnect, account.Username, id, target.Username, target.Channel)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// clearAdminChannel 處理清空頻道的管理 API 請求
+//
+// Responsible for:
+// - 處理 POST /api/admin/channels/{channel}/clear 的 HTTP 請求
+// - 清空頻道歷史並發送系統訊息，返回被清除的訊息數量
+func clearAdminChannel(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, ok := authenticateAdmin(w, r, AuditActionClearChannel)
+	if !ok {
+		return
+	}
+
+	channel := mux.Vars(r)["channel"]
+	removed := clearChannel(account, channel)
+	auditLog.Record(AuditEntry{Actor: account.Username, Action: AuditActionClearChannel, Channel: channel, IP: clientIP(r), Outcome: AuditOutcomeSuccess, Details: strconv.Itoa(removed)})
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"channel": channel,
+		"removed": removed,
+	})
+}
+
+// postAdminBroadcast 處理發送伺服器公告的管理 API 請求
+//
+// Responsible for:
+// - 處理 POST /api/admin/broadcast 的 HTTP 請求
+// - 在所有頻道發送系統訊息
+//
+// Design considerations:
+// - 請求主體為 {"content": "..."}
+func postAdminBroadcast(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, ok := authenticateAdmin(w, r, AuditActionBroadcast)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidJSON})
+		return
+	}
+
+	channels, err := broadcastServerMessage(account, request.Content)
+	auditLog.Record(AuditEntry{Actor: account.Username, Action: AuditActionBroadcast, IP: clientIP(r), Outcome: auditOutcome(err, adminStatusCode), Details: auditDetails(strings.TrimSpace(request.Content), err)})
+	if err != nil {
+		w.WriteHeader(adminStatusCode(err))
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"channels": channels,
+	})
+}
+
+// getAdminConfig 處理獲取執行期設定的管理 API 請求
+//
+// Responsible for:
+// - 處理 GET /api/admin/config 的 HTTP 請求
+// - 返回目前生效的 RuntimeConfig
+func getAdminConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, ok := authenticateAdmin(w, r, ""); !ok {
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"config": runtimeSettings.Get(),
+	})
+}
+
+// patchAdminConfig 處理修改執行期設定的管理 API 請求
+//
+// Responsible for:
+// - 處理 PATCH /api/admin/config 的 HTTP 請求
+// - 驗證並套用修改，返回修改後的完整設定
+//
+// Design considerations:
+// - 請求主體以目前設定為基礎解碼，只需提供要修改的欄位（rateLimits 內的欄位也可以部分提供）
+// - 經由 configManager.UpdateRuntimeConfig 套用，與設定重新載入依序進行，不會覆蓋彼此的修改
+// - 詞彙清單提供時整個取代
+// - 驗證失敗時返回 400，設定維持不變
+func patchAdminConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	account, ok := authenticateAdmin(w, r, AuditActionUpdateConfig)
+	if !ok {
+		return
+	}
+
+	var patch json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil || json.Unmarshal(patch, &RuntimeConfig{}) != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidJSON})
+		return
+	}
+	config, err := configManager.UpdateRuntimeConfig(func(current RuntimeConfig) (RuntimeConfig, error) {
+		err := json.Unmarshal(patch, &current)
+		return current, err
+	})
+	auditLog.Record(AuditEntry{Actor: account.Username, Action: AuditActionUpdateConfig, IP: clientIP(r), Outcome: auditOutcome(err, adminStatusCode), Details: auditDetails("", err)})
+	if err != nil {
+		w.WriteHeader(adminStatusCode(err))
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	log.Printf(LogRuntimeConfigUpdated, account.Username, config)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"config": config,
+	})
+}
+
+// getAdminConfigVersion 處理獲取設定版本的管理 API 請求
+//
+// Responsible for:
+// - 處理 GET /api/admin/config/version 的 HTTP 請求
+// - 返回目前生效的設定版本、雜湊、載入時間與最近一次重新載入失敗的原因
+//
+// Usage context:
+// - 發送 SIGHUP 或修改設定檔後確認新設定是否已生效
+func getAdminConfigVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, ok := authenticateAdmin(w, r, ""); !ok {
+		return
+	}
+
+	json.NewEncoder(w).Encode(configManager.Status())
+}
+
+// parseAuditFilter 從查詢參數建立稽核日誌查詢條件
+//
+// Design considerations:
+// - from 與 to 的格式與搜尋 API 相同
+// - limit 預設為 DefaultAuditQueryLimit，最多為 DefaultAuditQueryMaxLimit
+//
+// Returns:
+// - AuditFilter: 查詢條件
+// - string: 參數錯誤時的錯誤訊息，成功時為空字串
+func parseAuditFilter(r *http.Request) (AuditFilter, string) {
+	params := r.URL.Query()
+	filter := AuditFilter{
+		Actor:   params.Get("actor"),
+		Action:  params.Get("action"),
+		Target:  params.Get("target"),
+		Channel: params.Get("channel"),
+		Outcome: params.Get("outcome"),
+	}
+
+	var err error
+	if filter.From, err = parseSearchTime(params.Get("from"), false); err == nil {
+		filter.To, err = parseSearchTime(params.Get("to"), true)
+	}
+	if err != nil {
+		return filter, ErrorSearchInvalidDate
+	}
+
+	if filter.Limit, err = parseIntParam(params.Get("limit"), DefaultAuditQueryLimit); err == nil {
+		filter.Offset, err = parseIntParam(params.Get("offset"), 0)
+	}
+	if err != nil || filter.Limit == 0 {
+		return filter, ErrorInvalidPaging
+	}
+	filter.Limit = min(filter.Limit, DefaultAuditQueryMaxLimit)
+	return filter, ""
+}
+
+// getAdminAudit 處理查詢稽核日誌的管理 API 請求
+//
+// Responsible for:
+// - 處理 GET /api/admin/audit 的 HTTP 請求
+// - 依 actor、action、target、channel、outcome 與時間範圍過濾，由新到舊分頁返回
+func getAdminAudit(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, ok := authenticateAdmin(w, r, ""); !ok {
+		return
+	}
+
+	filter, errMessage := parseAuditFilter(r)
+	if errMessage != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": errMessage})
+		return
+	}
+
+	entries, total := auditLog.Query(filter)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"entries": entries,
+		"total":   total,
+		"limit":   filter.Limit,
+		"offset":  filter.Offset,
+	})
+}
+
+// exportAdminAudit 處理匯出稽核日誌的管理 API 請求
+//
+// Responsible for:
+// - 處理 GET /api/admin/audit/export 的 HTTP 請求
+// - 以 JSON lines 格式依寫入順序輸出符合條件的紀錄
+//
+// Design considerations:
+// - 接受與查詢 API 相同的過濾參數，但忽略 limit 與 offset
+// - 未過濾時輸出與日誌檔案相同，可以離線驗證雜湊鏈
+func exportAdminAudit(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, ok := authenticateAdmin(w, r, ""); !ok {
+		return
+	}
+
+	filter, errMessage := parseAuditFilter(r)
+	if errMessage != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": errMessage})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+	if _, err := auditLog.Export(w, filter); err != nil {
+		log.Printf(LogAuditWriteError, "export", err)
+	}
+}
+
+// verifyAdminAudit 處理驗證稽核日誌雜湊鏈的管理 API 請求
+//
+// Responsible for:
+// - 處理 GET /api/admin/audit/verify 的 HTTP 請求
+// - 返回鏈結是否完整、紀錄總數與第一筆無效紀錄的流水號
+func verifyAdminAudit(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, ok := authenticateAdmin(w, r, ""); !ok {
+		return
+	}
+
+	brokenAt, valid := auditLog.Verify()
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"valid":    valid,
+		"entries":  auditLog.Len(),
+		"brokenAt": brokenAt,
+	})
+}
+
+// getAccounts 處理獲取可用帳號的 API 請求
+//
+// Responsible for:
+// - 處理 GET /api/accounts 的 HTTP 請求
+// - 返回所有可用的測試帳號資訊
+// - 過濾敏感資訊（如密碼）
+//
+// Design considerations:
+// - 不包含密碼等敏感資訊在回應中
+// - 提供足夠資訊供客戶端顯示選項
+// - 保持 API 回應結構的一致性
+//
+// Process flow:
+// 1. 設置回應的 Content-Type（CORS 標頭由 corsMiddleware 統一設置）
+// 2. 遍歷所有預設測試帳號
+// 3. 建立只包含公開資訊的帳號列表
+// 4. 序列化為 JSON 並返回
+//
+// Usage context:
+// - 客戶端登入頁面載入可用帳號選項
+// - 提供用戶選擇和瞭解可用帳號
+func getAccounts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// 只返回公開資訊，不包含密碼
+	testAccounts := getTestAccounts()
+	publicAccounts := make([]map[string]string, len(testAccounts))
+	for i, account := range testAccounts {
+		publicAccounts[i] = map[string]string{
+			"username": account.Username,
+			"channel":  account.Channel,
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"accounts": publicAccounts,
+	})
+}
+
+// loginAccount 處理帳號登入驗證的 API 請求
+//
+// Responsible for:
+// - 處理 POST /api/login 的 HTTP 請求
+// - 驗證用戶提供的帳號憑證
+// - 返回驗證結果和帳號資訊
+//
+// Design considerations:
+// - 驗證失敗時返回適當的 HTTP 狀態碼
+// - 成功時返回帳號資訊但不包含密碼
+//
+// Process flow:
+// 1. 設置回應的 Content-Type（CORS 標頭與 OPTIONS 預檢由 corsMiddleware 統一處理）
+// 2. 解析 JSON 請求主體獲取帳號憑證
+// 3. 調用帳號驗證函式檢查憑證
+// 4. 根據驗證結果返回對應的回應
+// 5. 成功時包含帳號資訊，失敗時包含錯誤訊息
+//
+// Usage context:
+// - 客戶端登入頁面驗證用戶憑證
+// - 提供統一的帳號驗證入口
+func loginAccount(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var loginData struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&loginData); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidJSON})
+		return
+	}
+
+	account, err := rateLimits.Login(clientIP(r), loginData.Username, loginData.Password)
+	var limited *RateLimitError
+	outcome := AuditOutcomeSuccess
+	switch {
+	case errors.As(err, &limited):
+		outcome = AuditOutcomeDenied
+	case err != nil:
+		outcome = AuditOutcomeFailure
+	}
+	auditLog.Record(AuditEntry{Actor: loginData.Username, Action: AuditActionLogin, IP: clientIP(r), Outcome: outcome, Details: auditDetails("", err)})
+	if limited != nil {
+		writeRateLimitError(w, limited)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": ErrorInvalidAuth})
+		return
+	}
+
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
 		"account": map[string]string{
 			"username": account.Username,
 			"channel":  account.Channel,
+			"role":     effectiveRole(account, account.Channel),
 		},
 	})
 }
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// AuditEntry 代表稽核日誌中的一筆紀錄
+//
+// Design considerations:
+// - Hash 為 PrevHash 與其餘欄位的 SHA-256，修改或刪除任何一筆都會使之後的鏈結失效
+// - 第一筆的 PrevHash 為空字串
+type AuditEntry struct {
+	Sequence  int64     `json:"seq"`               // 從 1 開始的流水號
+	Timestamp time.Time `json:"timestamp"`         // 記錄時間
+	Actor     string    `json:"actor"`             // 執行者，登入失敗時為嘗試的用戶名
+	Action    string    `json:"action"`            // 動作，AuditAction* 常數
+	Target    string    `json:"target,omitempty"`  // 對象，例如被封鎖的用戶或訊息 ID
+	Channel   string    `json:"channel,omitempty"` // 頻道，伺服器範圍的動作為空
+	IP        string    `json:"ip,omitempty"`      // 請求來源 IP
+	Outcome   string    `json:"outcome"`           // 結果，AuditOutcome* 常數
+	Details   string    `json:"details,omitempty"` // 補充說明，失敗時為錯誤訊息
+	PrevHash  string    `json:"prevHash"`          // 前一筆的 Hash
+	Hash      string    `json:"hash"`              // 本筆的雜湊值
+}
+
+// hashAuditEntry 計算紀錄的雜湊值
+//
+// Design considerations:
+// - 以 Hash 欄位清空後的 JSON 編碼計算，欄位順序由結構定義固定
+func hashAuditEntry(entry AuditEntry) string {
+	entry.Hash = ""
+	data, _ := json.Marshal(entry)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditFilter 稽核日誌的查詢條件
+//
+// Design considerations:
+// - 空字串與零值表示不限制
+// - From 包含、To 不包含，與搜尋 API 的時間範圍一致
+type AuditFilter struct {
+	Actor   string
+	Action  string
+	Target  string
+	Channel string
+	Outcome string
+	From    time.Time
+	To      time.Time
+	Limit   int
+	Offset  int
+}
+
+// matches 檢查紀錄是否符合查詢條件
+func (f AuditFilter) matches(entry AuditEntry) bool {
+	switch {
+	case f.Actor != "" && entry.Actor != f.Actor,
+		f.Action != "" && entry.Action != f.Action,
+		f.Target != "" && entry.Target != f.Target,
+		f.Channel != "" && entry.Channel != f.Channel,
+		f.Outcome != "" && entry.Outcome != f.Outcome,
+		!f.From.IsZero() && entry.Timestamp.Before(f.From),
+		!f.To.IsZero() && !entry.Timestamp.Before(f.To):
+		return false
+	}
+	return true
+}
+
+// AuditLog 只能附加的稽核日誌
+//
+// Responsible for:
+// - 記錄登入、連接、管理操作與管理 API 的結果
+// - 以雜湊鏈串連每筆紀錄，提供竄改證據
+// - 依條件查詢與匯出紀錄
+//
+// Design considerations:
+// - 沒有修改或刪除的方法，紀錄只能附加
+// - path 不為空時每筆紀錄同時附加到檔案（JSON lines），重新啟動後由 Load 恢復並延續雜湊鏈
+// - 記憶體只保留最近 limit 筆，延續雜湊鏈只需要最後一筆的 Hash
+// - path 不為空時查詢、匯出與驗證逐行讀取檔案，涵蓋所有紀錄；只保存在記憶體時只涵蓋保留的紀錄
+// - 寫入檔案失敗只記錄日誌，不影響被稽核的操作（該筆紀錄不會出現在之後的查詢中）
+// - 時鐘可注入，測試時可以固定時間
+//
+// Usage context:
+// - 全域 auditLog 實例
+// - 各 API 處理器、指令與 WebSocket 連接處理器寫入，管理 API 查詢
+type AuditLog struct {
+	mu       sync.RWMutex
+	path     string
+	entries  []AuditEntry // 最近的紀錄，最多 limit 筆
+	limit    int
+	count    int64  // 紀錄總數，即最後一筆的流水號
+	lastHash string // 最後一筆的 Hash
+	now      func() time.Time
+}
+
+// NewAuditLog 建立稽核日誌
+//
+// Parameters:
+// - path: 日誌檔案路徑，空字串表示只保存在記憶體
+//
+// Returns:
+// - *AuditLog: 空的稽核日誌，記憶體最多保留 DefaultAuditMemoryLimit 筆，需要時呼叫 Load 從檔案恢復
+func NewAuditLog(path string) *AuditLog {
+	return &AuditLog{path: path, limit: DefaultAuditMemoryLimit, now: time.Now}
+}
+
+// Load 從檔案恢復紀錄並驗證雜湊鏈
+//
+// Design considerations:
+// - 逐行讀取，記憶體只保留最近 limit 筆
+// - 雜湊鏈斷裂時仍然載入並返回錯誤，新紀錄接續最後一筆，斷裂處由 Verify 回報
+//
+// Returns:
+// - error: 讀取失敗或雜湊鏈斷裂時返回錯誤，檔案不存在時不視為錯誤
+func (l *AuditLog) Load() error {
+	if l.path == "" {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries, l.count, l.lastHash = nil, 0, ""
+	var chain auditChain
+	brokenAt := int64(0)
+	err := l.scanFile(func(entry AuditEntry) bool {
+		if !chain.next(entry) && brokenAt == 0 {
+			brokenAt = chain.count
+		}
+		l.keep(entry)
+		l.count = chain.count
+		l.lastHash = entry.Hash
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if brokenAt != 0 {
+		return fmt.Errorf(ErrorAuditChainBroken, brokenAt)
+	}
+	return nil
+}
+
+// Record 附加一筆紀錄
+//
+// Parameters:
+// - entry: 紀錄內容，Sequence、Timestamp、PrevHash 與 Hash 由此設置
+//
+// Returns:
+// - AuditEntry: 寫入的紀錄
+func (l *AuditLog) Record(entry AuditEntry) AuditEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry.Sequence = l.count + 1
+	entry.Timestamp = l.now()
+	entry.PrevHash = l.lastHash
+	entry.Hash = hashAuditEntry(entry)
+	l.keep(entry)
+	l.count = entry.Sequence
+	l.lastHash = entry.Hash
+
+	if l.path != "" {
+		if err := l.appendToFile(entry); err != nil {
+			log.Printf(LogAuditWriteError, l.path, err)
+		}
+	}
+	return entry
+}
+
+// keep 將紀錄加入記憶體，超過 limit 時捨棄最舊的紀錄，呼叫者需持有鎖
+//
+// Design considerations:
+// - 以重新切片捨棄，append 重新配置時只複製保留的紀錄，記憶體用量維持在 limit 的常數倍
+func (l *AuditLog) keep(entry AuditEntry) {
+	l.entries = append(l.entries, entry)
+	if l.limit > 0 && len(l.entries) > l.limit {
+		l.entries = l.entries[len(l.entries)-l.limit:]
+	}
+}
+
+// appendToFile 將紀錄附加到日誌檔案，呼叫者需持有鎖
+func (l *AuditLog) appendToFile(entry AuditEntry) error {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return json.NewEncoder(file).Encode(entry)
+}
+
+// scanFile 依寫入順序逐行讀取日誌檔案，呼叫者需持有鎖
+//
+// Parameters:
+// - fn: 處理每筆紀錄，返回 false 時停止讀取
+//
+// Returns:
+// - error: 讀取或解析失敗時返回錯誤，檔案不存在時不視為錯誤
+func (l *AuditLog) scanFile(fn func(AuditEntry) bool) error {
+	file, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return err
+		}
+		if !fn(entry) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// each 依寫入順序走訪所有可查詢的紀錄，呼叫者需持有鎖
+//
+// Design considerations:
+// - 有日誌檔案時讀取檔案，否則走訪記憶體中保留的紀錄
+func (l *AuditLog) each(fn func(AuditEntry) bool) error {
+	if l.path != "" {
+		return l.scanFile(fn)
+	}
+	for _, entry := range l.entries {
+		if !fn(entry) {
+			break
+		}
+	}
+	return nil
+}
+
+// Query 依條件查詢紀錄
+//
+// Design considerations:
+// - 只暫存最新的 Offset+Limit 筆符合的紀錄，查詢範圍大時也不會載入所有紀錄
+//
+// Parameters:
+// - filter: 查詢條件，Limit 為 0 時不限制數量
+//
+// Returns:
+// - []AuditEntry: 由新到舊排列的紀錄
+// - int: 符合條件的總數
+func (l *AuditLog) Query(filter AuditFilter) ([]AuditEntry, int) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	window := filter.Offset + filter.Limit
+	var matched []AuditEntry
+	total := 0
+	err := l.each(func(entry AuditEntry) bool {
+		if !filter.matches(entry) {
+			return true
+		}
+		total++
+		matched = append(matched, entry)
+		if filter.Limit > 0 && len(matched) > window {
+			matched = matched[len(matched)-window:]
+		}
+		return true
+	})
+	if err != nil {
+		log.Printf(LogAuditReadError, l.path, err)
+	}
+
+	// 轉為由新到舊，再套用分頁
+	result := []AuditEntry{}
+	for i := len(matched) - 1 - filter.Offset; i >= 0; i-- {
+		if filter.Limit > 0 && len(result) == filter.Limit {
+			break
+		}
+		result = append(result, matched[i])
+	}
+	return result, total
+}
+
+// Export 以 JSON lines 格式輸出符合條件的紀錄
+//
+// Design considerations:
+// - 依寫入順序輸出，與日誌檔案的格式相同，未過濾時可以直接驗證雜湊鏈
+// - 忽略 filter 的 Limit 與 Offset
+//
+// Parameters:
+// - w: 輸出目標
+// - filter: 查詢條件
+//
+// Returns:
+// - int: 輸出的紀錄數
+// - error: 讀取或寫入失敗時的錯誤
+func (l *AuditLog) Export(w io.Writer, filter AuditFilter) (int, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	encoder := json.NewEncoder(w)
+	exported := 0
+	var writeErr error
+	err := l.each(func(entry AuditEntry) bool {
+		if !filter.matches(entry) {
+			return true
+		}
+		if writeErr = encoder.Encode(entry); writeErr != nil {
+			return false
+		}
+		exported++
+		return true
+	})
+	if writeErr != nil {
+		return exported, writeErr
+	}
+	return exported, err
+}
+
+// Verify 驗證雜湊鏈
+//
+// Design considerations:
+// - 有日誌檔案時從第一筆驗證整個檔案
+// - 只保存在記憶體且已捨棄較舊的紀錄時，從保留的第一筆開始驗證
+//
+// Returns:
+// - int64: 第一筆無效紀錄的流水號，鏈結完整時為 0
+// - bool: 鏈結是否完整
+func (l *AuditLog) Verify() (int64, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var chain auditChain
+	if l.path == "" && len(l.entries) > 0 && int64(len(l.entries)) < l.count {
+		first := l.count - int64(len(l.entries))
+		chain = auditChain{count: first, prev: l.entries[0].PrevHash}
+	}
+	brokenAt := int64(0)
+	if err := l.each(func(entry AuditEntry) bool {
+		if !chain.next(entry) {
+			brokenAt = chain.count
+			return false
+		}
+		return true
+	}); err != nil {
+		log.Printf(LogAuditReadError, l.path, err)
+		return chain.count + 1, false
+	}
+	if brokenAt == 0 && chain.count != l.count {
+		// 檔案少了紀錄（例如被截斷或寫入失敗）
+		return chain.count + 1, false
+	}
+	return brokenAt, brokenAt == 0
+}
+
+// Len 返回紀錄總數
+func (l *AuditLog) Len() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return int(l.count)
+}
+
+// auditChain 依寫入順序逐筆驗證雜湊鏈
+type auditChain struct {
+	count int64  // 已驗證的紀錄數
+	prev  string // 前一筆的 Hash
+}
+
+// next 驗證下一筆紀錄的流水號、前一筆雜湊與本筆雜湊
+//
+// Returns:
+// - bool: 紀錄是否有效；無效時仍以該筆紀錄繼續串連
+func (c *auditChain) next(entry AuditEntry) bool {
+	c.count++
+	valid := entry.Sequence == c.count && entry.PrevHash == c.prev && entry.Hash == hashAuditEntry(entry)
+	c.prev = entry.Hash
+	return valid
+}
+
+// auditOutcome 將操作結果轉換為稽核結果
+//
+// Parameters:
+// - err: 操作返回的錯誤
+// - statusCode: 該領域的錯誤轉 HTTP 狀態碼函式
+//
+// Returns:
+// - string: 沒有錯誤時為 success，401、403 與 429 為 denied，其餘為 failure
+func auditOutcome(err error, statusCode func(error) int) string {
+	if err == nil {
+		return AuditOutcomeSuccess
+	}
+	switch statusCode(err) {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests:
+		return AuditOutcomeDenied
+	default:
+		return AuditOutcomeFailure
+	}
+}
+
+// auditDetails 返回稽核紀錄的補充說明，失敗時以錯誤訊息取代
+func auditDetails(details string, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return details
+}
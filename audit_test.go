@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestAuditLog 測試雜湊鏈、查詢條件與檔案持久化
+func TestAuditLog(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	newLog := func(path string) *AuditLog {
+		l := NewAuditLog(path)
+		l.now = func() time.Time { return now }
+		return l
+	}
+
+	t.Run("雜湊鏈串連每筆紀錄", func(t *testing.T) {
+		l := newLog("")
+		first := l.Record(AuditEntry{Actor: "alice", Action: AuditActionBan, Target: "bob", Outcome: AuditOutcomeSuccess})
+		second := l.Record(AuditEntry{Actor: "alice", Action: AuditActionUnban, Target: "bob", Outcome: AuditOutcomeSuccess})
+		if first.Sequence != 1 || first.PrevHash != "" || first.Hash == "" {
+			t.Errorf("Expected first entry to start the chain, got %+v", first)
+		}
+		if second.Sequence != 2 || second.PrevHash != first.Hash {
+			t.Errorf("Expected second entry to link to the first, got %+v", second)
+		}
+		if _, ok := l.Verify(); !ok {
+			t.Error("Expected intact chain to verify")
+		}
+	})
+
+	t.Run("偵測竄改", func(t *testing.T) {
+		l := newLog("")
+		for _, target := range []string{"bob", "charlie", "bob"} {
+			l.Record(AuditEntry{Actor: "alice", Action: AuditActionKick, Target: target, Outcome: AuditOutcomeSuccess})
+		}
+		l.entries[1].Target = "alice"
+		if seq, ok := l.Verify(); ok || seq != 2 {
+			t.Errorf("Expected chain broken at entry 2, got %d (valid=%v)", seq, ok)
+		}
+	})
+
+	t.Run("依條件查詢", func(t *testing.T) {
+		l := newLog("")
+		l.Record(AuditEntry{Actor: "alice", Action: AuditActionLogin, Outcome: AuditOutcomeSuccess})
+		now = now.Add(time.Hour)
+		l.Record(AuditEntry{Actor: "bob", Action: AuditActionLogin, Outcome: AuditOutcomeFailure})
+		l.Record(AuditEntry{Actor: "alice", Action: AuditActionMute, Target: "bob", Channel: "general", Outcome: AuditOutcomeSuccess})
+
+		entries, total := l.Query(AuditFilter{Actor: "alice"})
+		if total != 2 || entries[0].Action != AuditActionMute {
+			t.Errorf("Expected alice's entries newest first, got %d %+v", total, entries)
+		}
+		if _, total := l.Query(AuditFilter{Outcome: AuditOutcomeFailure}); total != 1 {
+			t.Errorf("Expected one failure, got %d", total)
+		}
+		if _, total := l.Query(AuditFilter{From: now}); total != 2 {
+			t.Errorf("Expected two entries in time range, got %d", total)
+		}
+		if entries, total := l.Query(AuditFilter{Limit: 1, Offset: 1}); total != 3 || len(entries) != 1 || entries[0].Actor != "bob" {
+			t.Errorf("Expected paged result, got %d %+v", total, entries)
+		}
+	})
+
+	t.Run("重新載入後延續雜湊鏈", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "audit", "audit.jsonl")
+		l := newLog(path)
+		l.Record(AuditEntry{Actor: "alice", Action: AuditActionLogin, Outcome: AuditOutcomeSuccess})
+		last := l.Record(AuditEntry{Actor: "alice", Action: AuditActionBroadcast, Outcome: AuditOutcomeSuccess})
+
+		reloaded := newLog(path)
+		if err := reloaded.Load(); err != nil || reloaded.Len() != 2 {
+			t.Fatalf("Expected 2 entries loaded, got %d (%v)", reloaded.Len(), err)
+		}
+		next := reloaded.Record(AuditEntry{Actor: "alice", Action: AuditActionLogin, Outcome: AuditOutcomeSuccess})
+		if next.Sequence != 3 || next.PrevHash != last.Hash {
+			t.Errorf("Expected chain to continue after reload, got %+v", next)
+		}
+
+		data, _ := os.ReadFile(path)
+		os.WriteFile(path, []byte(strings.Replace(string(data), AuditActionBroadcast, AuditActionClearChannel, 1)), 0600)
+		if err := newLog(path).Load(); err == nil {
+			t.Error("Expected tampered file to fail verification")
+		}
+	})
+
+	t.Run("記憶體只保留最近的紀錄", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+		l := newLog(path)
+		l.limit = 3
+		for _, actor := range []string{"alice", "bob", "charlie", "dave", "erin"} {
+			l.Record(AuditEntry{Actor: actor, Action: AuditActionLogin, Outcome: AuditOutcomeSuccess})
+		}
+		if len(l.entries) != 3 || l.entries[0].Actor != "charlie" || l.Len() != 5 {
+			t.Fatalf("Expected newest 3 of 5 entries in memory, got %d of %d", len(l.entries), l.Len())
+		}
+
+		if entries, total := l.Query(AuditFilter{Actor: "alice"}); total != 1 || len(entries) != 1 || entries[0].Sequence != 1 {
+			t.Errorf("Expected older entries to be queried from the file, got %d %+v", total, entries)
+		}
+		if entries, total := l.Query(AuditFilter{Limit: 2, Offset: 3}); total != 5 || len(entries) != 2 || entries[0].Actor != "bob" || entries[1].Actor != "alice" {
+			t.Errorf("Expected paged result from the file, got %d %+v", total, entries)
+		}
+		var exported strings.Builder
+		if n, err := l.Export(&exported, AuditFilter{}); err != nil || n != 5 {
+			t.Errorf("Expected all 5 entries exported, got %d (%v)", n, err)
+		}
+		if _, ok := l.Verify(); !ok {
+			t.Error("Expected full chain in the file to verify")
+		}
+
+		reloaded := newLog(path)
+		reloaded.limit = 3
+		if err := reloaded.Load(); err != nil || reloaded.Len() != 5 || len(reloaded.entries) != 3 {
+			t.Errorf("Expected reload to keep 3 of 5 entries, got %d of %d (%v)", len(reloaded.entries), reloaded.Len(), err)
+		}
+
+		memory := newLog("")
+		memory.limit = 2
+		for i := 0; i < 4; i++ {
+			memory.Record(AuditEntry{Actor: "alice", Action: AuditActionLogin, Outcome: AuditOutcomeSuccess})
+		}
+		if _, ok := memory.Verify(); !ok || len(memory.entries) != 2 {
+			t.Errorf("Expected retained tail to verify, got %d entries", len(memory.entries))
+		}
+		memory.entries[1].Target = "bob"
+		if seq, ok := memory.Verify(); ok || seq != 4 {
+			t.Errorf("Expected tampering in the tail to be detected at 4, got %d", seq)
+		}
+	})
+}
+
+// TestAuditAPI 測試管理操作的稽核紀錄與查詢、匯出、驗證 API
+func TestAuditAPI(t *testing.T) {
+	hub = *newTestHub(0)
+	auditLog = NewAuditLog("")
+	roleStore = NewRoleStore(append([]RoleGrant{{Username: "alice", Role: RoleAdmin}}, DefaultRoleGrants...))
+	defer func() {
+		roleStore = NewRoleStore(DefaultRoleGrants)
+		banStore = NewBanStore()
+		auditLog = NewAuditLog("")
+	}()
+
+	request := func(method, path, username, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.SetBasicAuth(username, "password123")
+		req.RemoteAddr = "192.0.2.1:1234"
+		rr := httptest.NewRecorder()
+		setupRoutes().ServeHTTP(rr, req)
+		return rr
+	}
+	query := func(path string) (response struct {
+		Entries []AuditEntry `json:"entries"`
+		Total   int          `json:"total"`
+	}) {
+		rr := request("GET", path, "alice", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", path, rr.Code)
+		}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		return response
+	}
+
+	t.Run("記錄登入結果", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/api/login", strings.NewReader(`{"username":"bob","password":"wrong"}`))
+		setupRoutes().ServeHTTP(httptest.NewRecorder(), req)
+
+		response := query("/api/admin/audit?action=" + AuditActionLogin + "&actor=bob")
+		if response.Total != 1 || response.Entries[0].Outcome != AuditOutcomeFailure {
+			t.Errorf("Expected failed login entry, got %+v", response.Entries)
+		}
+	})
+
+	t.Run("記錄管理操作與拒絕", func(t *testing.T) {
+		request("POST", "/api/channels/tech/bans", "alice", `{"username":"bob","reason":"洗版"}`)
+		request("POST", "/api/admin/broadcast", "bob", `{"content":"維護"}`)
+
+		response := query("/api/admin/audit?action=" + AuditActionBan)
+		if response.Total != 1 {
+			t.Fatalf("Expected one ban entry, got %+v", response.Entries)
+		}
+		entry := response.Entries[0]
+		if entry.Actor != "alice" || entry.Target != "bob" || entry.Channel != "tech" || entry.IP != "192.0.2.1" ||
+			entry.Outcome != AuditOutcomeSuccess || entry.Details != "洗版" {
+			t.Errorf("Expected ban entry details, got %+v", entry)
+		}
+
+		response = query("/api/admin/audit?outcome=" + AuditOutcomeDenied)
+		if response.Total != 1 || response.Entries[0].Actor != "bob" || response.Entries[0].Action != AuditActionBroadcast {
+			t.Errorf("Expected denied broadcast entry, got %+v", response.Entries)
+		}
+	})
+
+	t.Run("匯出 JSON lines", func(t *testing.T) {
+		rr := request("GET", "/api/admin/audit/export", "alice", "")
+		if ct := rr.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+			t.Errorf("Expected ndjson content type, got %q", ct)
+		}
+		var entries []AuditEntry
+		scanner := bufio.NewScanner(rr.Body)
+		for scanner.Scan() {
+			var entry AuditEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				t.Fatalf("Expected JSON line, got %q", scanner.Text())
+			}
+			entries = append(entries, entry)
+		}
+		if len(entries) != auditLog.Len() || entries[0].Sequence != 1 {
+			t.Errorf("Expected all entries in order, got %+v", entries)
+		}
+	})
+
+	t.Run("驗證雜湊鏈", func(t *testing.T) {
+		var response struct {
+			Valid   bool `json:"valid"`
+			Entries int  `json:"entries"`
+		}
+		json.Unmarshal(request("GET", "/api/admin/audit/verify", "alice", "").Body.Bytes(), &response)
+		if !response.Valid || response.Entries != auditLog.Len() {
+			t.Errorf("Expected valid chain, got %+v", response)
+		}
+	})
+
+	t.Run("參數錯誤與權限", func(t *testing.T) {
+		if rr := request("GET", "/api/admin/audit?from=yesterday", "alice", ""); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for invalid date, got %d", rr.Code)
+		}
+		if rr := request("GET", "/api/admin/audit", "bob", ""); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected 403 for non-admin, got %d", rr.Code)
+		}
+	})
+}
@@ -1,9 +1,13 @@
 package main
 
+import (
+	"net/http"
+)
+
 // getTestAccounts 獲取測試帳號列表
 //
 // Responsible for:
-// - 從設定檔獲取測試帳號資料
+// - 從 configManager 獲取測試帳號資料（預設值、設定檔、環境變數或命令列參數，可重新載入）
 // - 每個帳號關聯到特定的頻道
 //
 // Design considerations:
@@ -19,7 +23,7 @@ package main
 //
 //	[]Account: 從設定檔載入的測試帳號列表
 func getTestAccounts() []Account {
-	return DefaultTestAccounts
+	return configManager.Get().Accounts
 }
 
 // validateAccount 驗證用戶帳號和密碼
@@ -61,3 +65,48 @@ func validateAccount(username, password string) (*Account, bool) {
 	}
 	return nil, false
 }
+
+// authenticateRequest 驗證 HTTP 請求攜帶的帳號憑證
+//
+// Responsible for:
+// - 從 HTTP 請求中取得帳號憑證並驗證
+// - 返回請求者的帳號資訊
+//
+// Design considerations:
+// - 優先使用 HTTP Basic Auth，方便 Flutter 等 HTTP 客戶端設置標頭
+// - 沒有 Basic Auth 時退回使用 username/password 查詢參數（與 WebSocket 端點一致）
+// - 查詢參數讓 <img> 等無法設定標頭的情境也能通過驗證
+// - 經過 rateLimits 的登入保護，同一來源 IP 對某帳號連續失敗時，該 IP 在鎖定期間驗證失敗（帳號在其他 IP 不受影響）
+// - 每個 API 請求都會驗證，因此不計入 IP 的登入嘗試次數
+//
+// Parameters:
+//
+//	r: HTTP 請求
+//
+// Returns:
+//
+//	*Account: 驗證成功的帳號資訊，失敗時為 nil
+//	bool: 驗證是否成功
+func authenticateRequest(r *http.Request) (*Account, bool) {
+	username, password, ok := requestCredentials(r)
+	if !ok {
+		return nil, false
+	}
+	account, err := rateLimits.Authenticate(clientIP(r), username, password)
+	return account, err == nil
+}
+
+// requestCredentials 取得請求攜帶的帳號密碼，Basic Auth 優先，其次為 username/password 查詢參數
+//
+// Returns:
+// - string: 用戶名
+// - string: 密碼
+// - bool: 請求是否攜帶用戶名
+func requestCredentials(r *http.Request) (string, string, bool) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		username = r.URL.Query().Get("username")
+		password = r.URL.Query().Get("password")
+	}
+	return username, password, username != ""
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 封鎖、踢出與管理操作相關錯誤
+var (
+	ErrUserBanned            = errors.New(ErrorUserBanned)
+	ErrUserBannedFromServer  = errors.New(ErrorUserBannedFromServer)
+	ErrBanNotFound           = errors.New(ErrorBanNotFound)
+	ErrSanctionForbidden     = errors.New(ErrorSanctionForbidden)
+	ErrInvalidSanctionTarget = errors.New(ErrorInvalidSanctionTarget)
+	ErrInvalidBanDuration    = errors.New(ErrorInvalidBanDuration)
+	ErrInvalidSanctionReason = errors.New(ErrorInvalidSanctionReason)
+	ErrUserNotConnected      = errors.New(ErrorUserNotConnected)
+)
+
+// Ban 代表一筆封鎖紀錄
+type Ban struct {
+	Channel   string     `json:"channel,omitempty"`   // 頻道名稱，空字串表示全伺服器封鎖
+	Username  string     `json:"username"`            // 被封鎖的用戶
+	Reason    string     `json:"reason,omitempty"`    // 封鎖原因
+	BannedBy  string     `json:"bannedBy"`            // 執行封鎖的管理員
+	BannedAt  time.Time  `json:"bannedAt"`            // 封鎖時間
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"` // 到期時間，nil 表示永久封鎖
+}
+
+// activeAt 檢查封鎖在指定時間是否仍然有效
+func (b Ban) activeAt(now time.Time) bool {
+	return b.ExpiresAt == nil || b.ExpiresAt.After(now)
+}
+
+// BanStore 管理頻道與全伺服器的封鎖名單
+//
+// Responsible for:
+// - 記錄用戶在頻道或整個伺服器的封鎖
+// - 檢查用戶目前是否被封鎖
+//
+// Design considerations:
+// - 全伺服器封鎖以 BanScopeServer 為頻道鍵，與頻道封鎖共用同一個結構
+// - 到期的封鎖在查詢時立即視為不存在，由 Expire 統一清除並發布到期通知
+// - 重複封鎖以最後一次為準
+// - 時鐘可注入，測試時不需要實際等待
+//
+// Usage context:
+// - 全域 banStore 實例
+// - /ban 指令與封鎖 API 寫入，handleWebSocket、readPump 與 sendMessage 檢查
+type BanStore struct {
+	mu   sync.Mutex
+	bans map[string]map[string]Ban
+	now  func() time.Time
+}
+
+// NewBanStore 建立封鎖名單
+//
+// Returns:
+// - *BanStore: 空的封鎖名單
+func NewBanStore() *BanStore {
+	return &BanStore{
+		bans: make(map[string]map[string]Ban),
+		now:  time.Now,
+	}
+}
+
+// Ban 新增或取代封鎖
+//
+// Parameters:
+// - ban: 封鎖紀錄，BannedAt 與 ExpiresAt 由此設置
+// - duration: 封鎖時長，0 表示永久封鎖
+//
+// Returns:
+// - Ban: 已儲存的封鎖紀錄
+func (bs *BanStore) Ban(ban Ban, duration time.Duration) Ban {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	ban.BannedAt = bs.now()
+	ban.ExpiresAt = nil
+	if duration > 0 {
+		expiresAt := ban.BannedAt.Add(duration)
+		ban.ExpiresAt = &expiresAt
+	}
+	if bs.bans[ban.Channel] == nil {
+		bs.bans[ban.Channel] = make(map[string]Ban)
+	}
+	bs.bans[ban.Channel][ban.Username] = ban
+	return ban
+}
+
+// Unban 解除封鎖
+//
+// Parameters:
+// - channel: 頻道名稱，BanScopeServer 表示全伺服器封鎖
+// - username: 用戶名
+//
+// Returns:
+// - bool: 用戶原本是否被封鎖
+func (bs *BanStore) Unban(channel, username string) bool {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	ban, ok := bs.bans[channel][username]
+	delete(bs.bans[channel], username)
+	return ok && ban.activeAt(bs.now())
+}
+
+// Active 查詢用戶在頻道中目前有效的封鎖
+//
+// Design considerations:
+// - 全伺服器封鎖優先於頻道封鎖
+//
+// Parameters:
+// - channel: 頻道名稱
+// - username: 用戶名
+//
+// Returns:
+// - Ban: 有效的封鎖紀錄
+// - bool: 目前是否被封鎖
+func (bs *BanStore) Active(channel, username string) (Ban, bool) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	now := bs.now()
+	for _, scope := range []string{BanScopeServer, channel} {
+		if ban, ok := bs.bans[scope][username]; ok && ban.activeAt(now) {
+			return ban, true
+		}
+	}
+	return Ban{}, false
+}
+
+// List 列出頻道目前有效的封鎖
+//
+// Parameters:
+// - channel: 頻道名稱，BanScopeServer 表示列出全伺服器封鎖
+//
+// Returns:
+// - []Ban: 依封鎖時間排序（最早的在前）的封鎖紀錄
+func (bs *BanStore) List(channel string) []Ban {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	now := bs.now()
+	bans := make([]Ban, 0, len(bs.bans[channel]))
+	for _, ban := range bs.bans[channel] {
+		if ban.activeAt(now) {
+			bans = append(bans, ban)
+		}
+	}
+	sort.Slice(bans, func(i, j int) bool {
+		return bans[i].BannedAt.Before(bans[j].BannedAt)
+	})
+	return bans
+}
+
+// Expire 清除所有已到期的封鎖
+//
+// Returns:
+// - []Ban: 被清除的封鎖紀錄
+func (bs *BanStore) Expire() []Ban {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	now := bs.now()
+	var expired []Ban
+	for channel, users := range bs.bans {
+		for username, ban := range users {
+			if !ban.activeAt(now) {
+				expired = append(expired, ban)
+				delete(users, username)
+			}
+		}
+		if len(users) == 0 {
+			delete(bs.bans, channel)
+		}
+	}
+	return expired
+}
+
+// checkBan 檢查用戶是否被禁止進入頻道
+//
+// Parameters:
+// - channel: 頻道名稱
+// - username: 用戶名
+//
+// Returns:
+// - error: 被封鎖時返回 banError 的錯誤
+func checkBan(channel, username string) error {
+	if ban, banned := banStore.Active(channel, username); banned {
+		return banError(ban)
+	}
+	return nil
+}
+
+// banError 將封鎖紀錄轉換為回覆給用戶的錯誤
+//
+// Returns:
+// - error: ErrUserBanned 或 ErrUserBannedFromServer，限時封鎖附上到期時間
+func banError(ban Ban) error {
+	err := ErrUserBanned
+	if ban.Channel == BanScopeServer {
+		err = ErrUserBannedFromServer
+	}
+	if ban.ExpiresAt != nil {
+		return fmt.Errorf("%w until %s", err, ban.ExpiresAt.Format(time.RFC3339))
+	}
+	return err
+}
+
+// checkBanned 檢查訊息發送者是否被封鎖
+//
+// Design considerations:
+// - 系統訊息不受封鎖限制
+//
+// Parameters:
+// - msg: 要發送的訊息
+//
+// Returns:
+// - error: 發送者被封鎖時返回 checkBan 的錯誤
+func checkBanned(msg Message) error {
+	if msg.IsSystemMessage() {
+		return nil
+	}
+	return checkBan(msg.Channel, msg.User)
+}
+
+// sanctionTarget 檢查管理權限並找出被處置的用戶
+//
+// Parameters:
+// - moderator: 執行者
+// - channel: 頻道名稱，BanScopeServer 表示全伺服器
+// - username: 被處置的用戶，可帶 @ 前綴
+//
+// Returns:
+// - Account: 被處置的帳號
+// - error: 無權限、用戶不存在、處置自己或處置角色不低於自己的用戶時返回對應錯誤
+func sanctionTarget(moderator *Account, channel, username string) (Account, error) {
+	if !authorize(moderator, PermissionSanction, channel) {
+		return Account{}, ErrSanctionForbidden
+	}
+	target, ok := findAccount(strings.TrimPrefix(username, "@"))
+	if !ok {
+		return Account{}, ErrUserNotFound
+	}
+	if target.Username == moderator.Username {
+		return Account{}, ErrInvalidSanctionTarget
+	}
+	// 不能處置角色相同或更高的用戶
+	if roleRank(effectiveRole(&target, channel)) >= roleRank(effectiveRole(moderator, channel)) {
+		return Account{}, ErrSanctionForbidden
+	}
+	return target, nil
+}
+
+// validSanctionReason 檢查踢出與封鎖原因的長度
+func validSanctionReason(reason string) bool {
+	return utf8.RuneCountInString(reason) <= DefaultSanctionReasonMaxLength
+}
+
+// withReason 在系統訊息後附上原因
+func withReason(text, reason string) string {
+	if reason == "" {
+		return text
+	}
+	return fmt.Sprintf(SystemMessageReasonTemplate, text, reason)
+}
+
+// banAnnouncementChannel 取得發布封鎖系統訊息的頻道
+//
+// Design considerations:
+// - 全伺服器封鎖發布到被封鎖用戶所屬的頻道
+func banAnnouncementChannel(ban Ban) string {
+	if ban.Channel != BanScopeServer {
+		return ban.Channel
+	}
+	account, _ := findAccount(ban.Username)
+	return account.Channel
+}
+
+// kickUser 由頻道管理員踢出已連接的用戶
+//
+// Design considerations:
+// - 只關閉連接，不限制用戶重新連接；需要阻止重新連接時使用封鎖
+// - 用戶在頻道內沒有連接時返回 ErrUserNotConnected，不發布系統訊息
+//
+// Parameters:
+// - moderator: 執行者
+// - channel: 頻道名稱
+// - username: 被踢出的用戶
+// - reason: 踢出原因，可為空
+//
+// Returns:
+// - int: 被關閉的連接數
+// - error: 無權限、目標不合法或用戶未連接時返回對應錯誤
+func kickUser(moderator *Account, channel, username, reason string) (int, error) {
+	target, err := sanctionTarget(moderator, channel, username)
+	if err != nil {
+		return 0, err
+	}
+	if !validSanctionReason(reason) {
+		return 0, ErrInvalidSanctionReason
+	}
+
+	closed := hub.disconnect(target.Username, channel, ErrorCodeKicked, withReason(ErrorUserKicked, reason))
+	if closed == 0 {
+		return 0, ErrUserNotConnected
+	}
+	log.Printf(LogUserKicked, target.Username, moderator.Username, channel, closed)
+	publishMessage(NewSystemMessage(withReason(fmt.Sprintf(SystemMessageKickTemplate, target.Username, moderator.Username), reason), channel))
+	return closed, nil
+}
+
+// banUser 由管理員封鎖用戶並關閉其連接
+//
+// Process flow:
+// 1. 檢查權限、目標、時長與原因
+// 2. 寫入封鎖名單
+// 3. 發布系統訊息
+// 4. 關閉用戶在該頻道（全伺服器封鎖時為所有頻道）的連接
+//
+// Parameters:
+// - moderator: 執行者
+// - channel: 頻道名稱，BanScopeServer 表示全伺服器
+// - username: 被封鎖的用戶
+// - reason: 封鎖原因，可為空
+// - minutes: 封鎖時長（分鐘），0 表示永久封鎖
+//
+// Returns:
+// - Ban: 新的封鎖紀錄
+// - error: 無權限、目標不合法、時長或原因不合法時返回對應錯誤
+func banUser(moderator *Account, channel, username, reason string, minutes int) (Ban, error) {
+	target, err := sanctionTarget(moderator, channel, username)
+	if err != nil {
+		return Ban{}, err
+	}
+	if minutes < 0 || minutes > DefaultMaxBanDuration {
+		return Ban{}, ErrInvalidBanDuration
+	}
+	if !validSanctionReason(reason) {
+		return Ban{}, ErrInvalidSanctionReason
+	}
+
+	ban := banStore.Ban(Ban{
+		Channel:  channel,
+		Username: target.Username,
+		Reason:   reason,
+		BannedBy: moderator.Username,
+	}, time.Duration(minutes)*time.Minute)
+	log.Printf(LogUserBanned, target.Username, moderator.Username, channel, ban.ExpiresAt)
+
+	var text string
+	switch {
+	case channel == BanScopeServer && minutes == 0:
+		text = fmt.Sprintf(SystemMessagePermanentServerBanTemplate, target.Username, moderator.Username)
+	case channel == BanScopeServer:
+		text = fmt.Sprintf(SystemMessageServerBanTemplate, target.Username, moderator.Username, minutes)
+	case minutes == 0:
+		text = fmt.Sprintf(SystemMessagePermanentBanTemplate, target.Username, moderator.Username)
+	default:
+		text = fmt.Sprintf(SystemMessageBanTemplate, target.Username, moderator.Username, minutes)
+	}
+	publishMessage(NewSystemMessage(withReason(text, reason), banAnnouncementChannel(ban)))
+
+	hub.disconnect(target.Username, channel, ErrorCodeBanned, banError(ban).Error())
+	return ban, nil
+}
+
+// unbanUser 由管理員解除封鎖並發布系統訊息
+//
+// Parameters:
+// - moderator: 執行者
+// - channel: 頻道名稱，BanScopeServer 表示全伺服器
+// - username: 被封鎖的用戶
+//
+// Returns:
+// - error: 無權限或用戶未被封鎖時返回對應錯誤
+func unbanUser(moderator *Account, channel, username string) error {
+	target, err := sanctionTarget(moderator, channel, username)
+	if err != nil {
+		return err
+	}
+	if !banStore.Unban(channel, target.Username) {
+		return ErrBanNotFound
+	}
+	log.Printf(LogUserUnbanned, target.Username, moderator.Username, channel)
+
+	ban := Ban{Channel: channel, Username: target.Username}
+	publishMessage(NewSystemMessage(fmt.Sprintf(SystemMessageUnbanTemplate, target.Username, moderator.Username), banAnnouncementChannel(ban)))
+	return nil
+}
+
+// expireSanctions 清除到期的禁言與封鎖並發布到期通知
+//
+// Returns:
+// - int: 被清除的禁言與封鎖數量
+func expireSanctions() int {
+	mutes := muteStore.Expire()
+	for _, mute := range mutes {
+		publishMessage(NewSystemMessage(fmt.Sprintf(SystemMessageMuteExpiredTemplate, mute.Username), mute.Channel))
+	}
+	bans := banStore.Expire()
+	for _, ban := range bans {
+		publishMessage(NewSystemMessage(fmt.Sprintf(SystemMessageBanExpiredTemplate, ban.Username), banAnnouncementChannel(ban)))
+	}
+	return len(mutes) + len(bans)
+}
+
+// runSanctionExpiry 定期清除到期的禁言與封鎖
+//
+// Usage context:
+// - 程式啟動時在獨立 goroutine 中運行
+//
+// Parameters:
+// - interval: 檢查間隔
+func runSanctionExpiry(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if expired := expireSanctions(); expired > 0 {
+			log.Printf(LogSanctionsExpired, expired)
+		}
+	}
+}
+
+// sanctionStatusCode 將禁言、踢出與封鎖的錯誤對應到 HTTP 狀態碼
+func sanctionStatusCode(err error) int {
+	switch {
+	case errors.Is(err, ErrSanctionForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, ErrUserNotFound), errors.Is(err, ErrBanNotFound),
+		errors.Is(err, ErrMuteNotFound), errors.Is(err, ErrUserNotConnected):
+		return http.StatusNotFound
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestBanStore 測試封鎖的範圍、到期與清除
+func TestBanStore(t *testing.T) {
+	now := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	store := NewBanStore()
+	store.now = func() time.Time { return now }
+
+	store.Ban(Ban{Channel: "general", Username: "bob", BannedBy: "alice"}, time.Hour)
+	if _, banned := store.Active("general", "bob"); !banned {
+		t.Fatal("Expected bob to be banned from general")
+	}
+	if _, banned := store.Active("tech", "bob"); banned {
+		t.Error("Expected channel ban to be limited to general")
+	}
+
+	store.Ban(Ban{Channel: BanScopeServer, Username: "charlie", BannedBy: "alice"}, 0)
+	if ban, banned := store.Active("tech", "charlie"); !banned || ban.ExpiresAt != nil {
+		t.Errorf("Expected permanent server ban to apply to every channel, got %+v %v", ban, banned)
+	}
+
+	now = now.Add(time.Hour)
+	if _, banned := store.Active("general", "bob"); banned {
+		t.Error("Expected ban to expire")
+	}
+	if bans := store.List("general"); len(bans) != 0 {
+		t.Errorf("Expected expired ban to be hidden, got %+v", bans)
+	}
+	expired := store.Expire()
+	if len(expired) != 1 || expired[0].Username != "bob" {
+		t.Errorf("Expected bob's ban to be cleared, got %+v", expired)
+	}
+
+	if !store.Unban(BanScopeServer, "charlie") || store.Unban(BanScopeServer, "charlie") {
+		t.Error("Expected unban to succeed exactly once")
+	}
+}
+
+// TestKickCommand 測試踢出已連接的用戶
+func TestKickCommand(t *testing.T) {
+	hub = *newTestHub(0)
+	messageStore = make(MessageStore)
+	messageIndex = NewSearchIndex()
+	presenceTracker = NewPresenceTracker(time.Minute)
+	channelSettings = NewChannelSettingsStore()
+	banStore = NewBanStore()
+
+	client := newTestClient("bob", "general")
+	hub.register <- client
+	collectSystemMessages(client, 50*time.Millisecond)
+
+	if rr := postCommand("bob", "general", "/kick alice"); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for non-moderator, got %d", rr.Code)
+	}
+	if rr := postCommand("alice", "general", "/kick bob 洗版"); rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	event, ok := <-client.send
+	if !ok || event.Type != MessageTypeError || event.Error.Code != ErrorCodeKicked || !strings.Contains(event.Error.Message, "洗版") {
+		t.Errorf("Expected kicked error event with reason, got %+v", event)
+	}
+	if _, open := <-client.send; open {
+		t.Error("Expected send queue to be closed")
+	}
+	if client.closeReason != ErrorCodeKicked {
+		t.Errorf("Expected close reason %q, got %q", ErrorCodeKicked, client.closeReason)
+	}
+
+	messages := messageStore.GetRecentMessages("general", 1)
+	if len(messages) != 1 || !strings.Contains(messages[0].Content, "踢出頻道") {
+		t.Errorf("Expected kick announcement, got %+v", messages)
+	}
+	if rr := postCommand("alice", "general", "/kick bob"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected error when user is not connected, got %d", rr.Code)
+	}
+}
+
+// TestBanAPI 測試封鎖名單 API 與發送限制
+func TestBanAPI(t *testing.T) {
+	hub = *newTestHub(0)
+	messageStore = make(MessageStore)
+	messageIndex = NewSearchIndex()
+	presenceTracker = NewPresenceTracker(time.Minute)
+	channelSettings = NewChannelSettingsStore()
+	muteStore = NewMuteStore()
+	banStore = NewBanStore()
+	roleStore = NewRoleStore(append([]RoleGrant{{Channel: "general", Username: "bob", Role: RoleMember}}, DefaultRoleGrants...))
+	defer func() { roleStore = NewRoleStore(DefaultRoleGrants) }()
+
+	request := func(method, path, username, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.SetBasicAuth(username, "password123")
+		rr := httptest.NewRecorder()
+		setupRoutes().ServeHTTP(rr, req)
+		return rr
+	}
+	send := func(username, channel string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(Message{User: username, Channel: channel, Content: "哈囉"})
+		req, _ := http.NewRequest("POST", "/api/messages", bytes.NewReader(body))
+		req.SetBasicAuth(username, "password123")
+		rr := httptest.NewRecorder()
+		setupRoutes().ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("頻道封鎖", func(t *testing.T) {
+		client := newTestClient("bob", "general")
+		hub.register <- client
+		collectSystemMessages(client, 50*time.Millisecond)
+
+		if rr := request("POST", "/api/channels/general/bans", "bob", `{"username":"alice"}`); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected 403 for non-moderator, got %d", rr.Code)
+		}
+		rr := request("POST", "/api/channels/general/bans", "alice", `{"username":"bob","minutes":60,"reason":"廣告"}`)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+		}
+
+		if event := <-client.send; event.Error == nil || event.Error.Code != ErrorCodeBanned {
+			t.Errorf("Expected banned error event, got %+v", event)
+		}
+		if _, open := <-client.send; open {
+			t.Error("Expected banned client to be disconnected")
+		}
+
+		rr = send("bob", "general")
+		var response map[string]string
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		if rr.Code != http.StatusForbidden || response["code"] != ErrorCodeBanned {
+			t.Errorf("Expected 403 banned, got %d %v", rr.Code, response)
+		}
+		if rr := send("bob", "tech"); rr.Code != http.StatusOK {
+			t.Errorf("Expected channel ban to be limited to general, got %d", rr.Code)
+		}
+
+		rr = request("GET", "/api/channels/general/bans", "alice", "")
+		var list struct {
+			Bans []Ban `json:"bans"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &list)
+		if len(list.Bans) != 1 || list.Bans[0].Reason != "廣告" || list.Bans[0].ExpiresAt == nil {
+			t.Errorf("Expected one timed ban with reason, got %+v", list.Bans)
+		}
+
+		if rr := request("DELETE", "/api/channels/general/bans/bob", "alice", ""); rr.Code != http.StatusNoContent {
+			t.Fatalf("Expected 204, got %d", rr.Code)
+		}
+		if rr := send("bob", "general"); rr.Code != http.StatusOK {
+			t.Errorf("Expected bob to send after unban, got %d", rr.Code)
+		}
+		if rr := request("DELETE", "/api/channels/general/bans/bob", "alice", ""); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 for missing ban, got %d", rr.Code)
+		}
+	})
+
+	t.Run("伺服器封鎖", func(t *testing.T) {
+		if rr := request("POST", "/api/bans", "alice", `{"username":"charlie"}`); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected 403 without a server role, got %d", rr.Code)
+		}
+		roleStore.Grant(RoleGrant{Username: "alice", Role: RoleModerator})
+		if rr := request("POST", "/api/bans", "alice", `{"username":"charlie"}`); rr.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if rr := send("charlie", "general"); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected server ban to apply to general, got %d", rr.Code)
+		}
+		messages := messageStore.GetRecentMessages("random", 1)
+		if len(messages) != 1 || !strings.Contains(messages[0].Content, "永久封鎖") {
+			t.Errorf("Expected announcement in charlie's channel, got %+v", messages)
+		}
+		if rr := request("GET", "/api/bans", "bob", ""); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected 403 for non-moderator, got %d", rr.Code)
+		}
+	})
+
+	t.Run("禁言 API 與到期通知", func(t *testing.T) {
+		now := time.Now()
+		muteStore.now = func() time.Time { return now }
+		if rr := request("POST", "/api/channels/general/mutes", "alice", `{"username":"bob","minutes":5}`); rr.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if rr := send("bob", "general"); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected 403 while muted, got %d", rr.Code)
+		}
+
+		now = now.Add(5 * time.Minute)
+		if expired := expireSanctions(); expired != 1 {
+			t.Errorf("Expected one expired mute, got %d", expired)
+		}
+		messages := messageStore.GetRecentMessages("general", 1)
+		if len(messages) != 1 || !strings.Contains(messages[0].Content, "禁言已到期") {
+			t.Errorf("Expected expiry announcement, got %+v", messages)
+		}
+	})
+}
+
+// TestSendMessageIdentity 測試 REST 發送訊息以驗證的帳號為準，不能以 user 欄位繞過封鎖與禁言
+func TestSendMessageIdentity(t *testing.T) {
+	hub = *newTestHub(0)
+	messageStore = make(MessageStore)
+	messageIndex = NewSearchIndex()
+	channelSettings = NewChannelSettingsStore()
+	muteStore = NewMuteStore()
+	banStore = NewBanStore()
+	defer func() {
+		muteStore = NewMuteStore()
+		banStore = NewBanStore()
+	}()
+
+	post := func(body string, auth bool) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/messages", strings.NewReader(body))
+		if auth {
+			req.SetBasicAuth("bob", "password123")
+		}
+		rr := httptest.NewRecorder()
+		setupRoutes().ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("發送者以驗證的帳號為準", func(t *testing.T) {
+		if rr := post(`{"user":"charlie","channel":"tech","content":"我是誰？"}`, true); rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		messages := messageStore.GetRecentMessages("tech", 1)
+		if len(messages) != 1 || messages[0].User != "bob" {
+			t.Errorf("Expected message from bob, got %+v", messages)
+		}
+	})
+
+	t.Run("未驗證的請求", func(t *testing.T) {
+		for _, body := range []string{
+			`{"user":"alice","channel":"tech","content":"冒用"}`,
+			`{"user":"訪客","channel":"tech","content":"匿名"}`,
+			`{"channel":"tech","content":"沒有 user"}`,
+		} {
+			if rr := post(body, false); rr.Code != http.StatusUnauthorized {
+				t.Errorf("%s: expected 401 without credentials, got %d", body, rr.Code)
+			}
+		}
+	})
+
+	t.Run("沒有發送權限", func(t *testing.T) {
+		if rr := post(`{"channel":"general","content":"不是成員"}`, true); rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), ErrorCodeSendForbidden) {
+			t.Errorf("Expected 403 for non-member, got %d: %s", rr.Code, rr.Body.String())
+		}
+
+		roleStore = NewRoleStore(append([]RoleGrant{{Channel: "tech", Username: "bob", Role: RoleGuest}}, DefaultRoleGrants...))
+		defer func() { roleStore = NewRoleStore(DefaultRoleGrants) }()
+		if rr := post(`{"channel":"tech","content":"訪客"}`, true); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected 403 for guest, got %d", rr.Code)
+		}
+	})
+
+	t.Run("不能繞過封鎖", func(t *testing.T) {
+		banStore.Ban(Ban{Channel: "tech", Username: "bob", BannedBy: "alice"}, 0)
+		defer banStore.Unban("tech", "bob")
+
+		for _, body := range []string{
+			`{"channel":"tech","content":"沒有 user"}`,
+			`{"user":"charlie","channel":"tech","content":"其他用戶"}`,
+			`{"channel":"tech","content":"/nick 封鎖中"}`,
+		} {
+			if rr := post(body, true); rr.Code != http.StatusForbidden {
+				t.Errorf("%s: expected 403 while banned, got %d", body, rr.Code)
+			}
+		}
+		if nicknames.Get("bob") != "" {
+			t.Error("Expected command not to run while banned")
+		}
+	})
+
+	t.Run("不能繞過禁言", func(t *testing.T) {
+		muteStore.Mute("tech", "bob", 5*time.Minute)
+		defer muteStore.Unmute("tech", "bob")
+
+		for _, body := range []string{
+			`{"channel":"tech","content":"沒有 user"}`,
+			`{"user":"charlie","channel":"tech","content":"其他用戶"}`,
+		} {
+			if rr := post(body, true); rr.Code != http.StatusForbidden {
+				t.Errorf("%s: expected 403 while muted, got %d", body, rr.Code)
+			}
+		}
+	})
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"unicode/utf8"
+)
+
+// 頻道設定相關錯誤
+var (
+	ErrInvalidMessageTTL = errors.New(ErrorInvalidMessageTTL)
+	ErrInvalidTopic      = errors.New(ErrorInvalidTopic)
+)
+
+// ChannelSettings 代表單一頻道的可調整設定
+//
+// Responsible for:
+// - 存儲頻道層級的行為設定
+// - 提供 JSON 序列化供 API 讀取與修改
+//
+// Design considerations:
+// - 未設定過的頻道使用 defaultChannelSettings 的預設值
+// - 新的頻道層級設定直接加入此結構體
+//
+// Usage context:
+// - Hub 決定加入/離開訊息是否寫入歷史
+// - 新訊息未指定 ttl 時套用頻道預設存活時間
+// - /topic 指令讀取與修改頻道主題
+// - 非管理員發送訊息時檢查慢速模式
+// - GET/PUT /api/channels/{channel}/settings
+type ChannelSettings struct {
+	PersistPresence bool   `json:"persistPresence"` // 加入/離開訊息是否寫入歷史記錄
+	MessageTTL      int    `json:"messageTTL"`      // 訊息預設存活秒數，0 表示永久保存
+	Topic           string `json:"topic"`           // 頻道主題
+	SlowMode        int    `json:"slowMode"`        // 慢速模式間隔秒數，非管理員每段間隔只能發送一則訊息，0 表示關閉
+}
+
+// ChannelSettingsUpdate 代表頻道設定的部分更新
+//
+// Design considerations:
+// - 使用指標欄位區分「未提供」與「設為零值」
+type ChannelSettingsUpdate struct {
+	PersistPresence *bool   `json:"persistPresence"`
+	MessageTTL      *int    `json:"messageTTL"`
+	Topic           *string `json:"topic"`
+	SlowMode        *int    `json:"slowMode"`
+}
+
+// Validate 檢查更新內容是否合法
+//
+// Returns:
+// - error: messageTTL 為負數或超過 DefaultMaxMessageTTL 時返回 ErrInvalidMessageTTL；topic 超過 DefaultTopicMaxLength 個字元時返回 ErrInvalidTopic；slowMode 為負數或超過 DefaultMaxSlowMode 時返回 ErrInvalidSlowMode
+func (u ChannelSettingsUpdate) Validate() error {
+	if u.MessageTTL != nil && (*u.MessageTTL < 0 || *u.MessageTTL > DefaultMaxMessageTTL) {
+		return ErrInvalidMessageTTL
+	}
+	if u.Topic != nil && utf8.RuneCountInString(*u.Topic) > DefaultTopicMaxLength {
+		return ErrInvalidTopic
+	}
+	if u.SlowMode != nil && (*u.SlowMode < 0 || *u.SlowMode > DefaultMaxSlowMode) {
+		return ErrInvalidSlowMode
+	}
+	return nil
+}
+
+// defaultChannelSettings 返回頻道的預設設定
+func defaultChannelSettings() ChannelSettings {
+	return ChannelSettings{
+		PersistPresence: DefaultPersistPresenceMessages,
+	}
+}
+
+// ChannelSettingsStore 管理所有頻道的設定
+//
+// Responsible for:
+// - 保存各頻道的設定值
+// - 提供併發安全的讀取與更新
+//
+// Design considerations:
+// - 使用 RWMutex，讀取遠多於寫入
+// - Get 返回複本，呼叫者修改不會影響存儲內容
+//
+// Usage context:
+// - 全域 channelSettings 實例供 Hub 與 API 使用
+type ChannelSettingsStore struct {
+	mu       sync.RWMutex
+	settings map[string]ChannelSettings
+}
+
+// NewChannelSettingsStore 建立新的頻道設定存儲
+//
+// Returns:
+// - *ChannelSettingsStore: 空的設定存儲
+func NewChannelSettingsStore() *ChannelSettingsStore {
+	return &ChannelSettingsStore{
+		settings: make(map[string]ChannelSettings),
+	}
+}
+
+// Get 取得頻道設定
+//
+// Parameters:
+// - channel: 頻道名稱
+//
+// Returns:
+// - ChannelSettings: 頻道設定，未設定過時返回預設值
+func (s *ChannelSettingsStore) Get(channel string) ChannelSettings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if settings, ok := s.settings[channel]; ok {
+		return settings
+	}
+	return defaultChannelSettings()
+}
+
+// Apply 套用部分更新到頻道設定
+//
+// Parameters:
+// - channel: 頻道名稱
+// - update: 要套用的欄位
+//
+// Returns:
+// - ChannelSettings: 更新後的設定
+func (s *ChannelSettingsStore) Apply(channel string, update ChannelSettingsUpdate) ChannelSettings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	settings, ok := s.settings[channel]
+	if !ok {
+		settings = defaultChannelSettings()
+	}
+
+	if update.PersistPresence != nil {
+		settings.PersistPresence = *update.PersistPresence
+	}
+	if update.MessageTTL != nil {
+		settings.MessageTTL = *update.MessageTTL
+	}
+	if update.Topic != nil {
+		settings.Topic = *update.Topic
+	}
+	if update.SlowMode != nil {
+		settings.SlowMode = *update.SlowMode
+	}
+
+	s.settings[channel] = settings
+	return settings
+}
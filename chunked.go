@@ -0,0 +1,461 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 分段上傳相關錯誤
+var (
+	ErrUploadSessionNotFound  = errors.New(ErrorUploadSessionNotFound)
+	ErrUploadSessionBusy      = errors.New(ErrorUploadSessionBusy)
+	ErrUploadOffsetMismatch   = errors.New(ErrorUploadOffsetMismatch)
+	ErrUploadChunkOverflow    = errors.New(ErrorUploadChunkOverflow)
+	ErrUploadIncomplete       = errors.New(ErrorUploadIncomplete)
+	ErrUploadChecksumRequired = errors.New(ErrorUploadChecksumRequired)
+	ErrUploadChecksumMismatch = errors.New(ErrorUploadChecksumMismatch)
+	ErrUploadInvalidSize      = errors.New(ErrorUploadInvalidSize)
+)
+
+// UploadSession 代表一個進行中的分段上傳
+//
+// Responsible for:
+// - 記錄上傳目標檔案的名稱、總大小和預期雜湊
+// - 記錄目前已接收的位元組數（Offset）供客戶端續傳
+//
+// Design considerations:
+// - Offset 永遠等於暫存檔的實際大小，客戶端以此決定下一段的起點
+// - Checksum 可在建立時提供，也可在完成時提供
+// - ExpiresAt 每次寫入都會延長，閒置過久的工作階段會被回收
+//
+// Usage context:
+// - 分段上傳 API 的回應內容
+// - 以 JSON 保存在磁碟，伺服器重啟後仍可續傳
+type UploadSession struct {
+	ID        string    `json:"id"`                 // 工作階段識別碼
+	Owner     string    `json:"owner"`              // 建立者
+	Name      string    `json:"name"`               // 原始檔名
+	Size      int64     `json:"size"`               // 檔案總大小
+	Offset    int64     `json:"offset"`             // 已接收的位元組數
+	Checksum  string    `json:"checksum,omitempty"` // 預期的 SHA-256 雜湊值
+	CreatedAt time.Time `json:"createdAt"`          // 建立時間
+	ExpiresAt time.Time `json:"expiresAt"`          // 過期時間
+}
+
+// UploadSessionStore 管理所有分段上傳工作階段
+//
+// Responsible for:
+// - 建立工作階段並依偏移量追加分段內容
+// - 完成時驗證雜湊並轉交 UploadStore 保存
+// - 回收過期未完成的工作階段
+//
+// Design considerations:
+// - 每個工作階段有一個 .part 暫存檔和一個 .json 中繼資料檔
+// - 同一工作階段同時只允許一個寫入，避免分段交錯
+// - 只有建立者可以存取自己的工作階段，其他人視為不存在
+// - 時鐘可注入，測試時不需要實際等待
+//
+// Usage context:
+// - 全域 uploadSessions 實例供分段上傳 API 使用
+// - 程式啟動時啟動回收迴圈
+type UploadSessionStore struct {
+	mu       sync.Mutex
+	dir      string
+	maxSize  int64
+	ttl      time.Duration
+	sessions map[string]*UploadSession
+	busy     map[string]bool
+	now      func() time.Time
+}
+
+// NewUploadSessionStore 建立新的分段上傳工作階段存儲
+//
+// Parameters:
+// - dir: 工作階段檔案存放目錄
+// - maxSize: 單一檔案大小上限
+// - ttl: 閒置多久後過期
+//
+// Returns:
+// - *UploadSessionStore: 初始化完成的存儲
+func NewUploadSessionStore(dir string, maxSize int64, ttl time.Duration) *UploadSessionStore {
+	return &UploadSessionStore{
+		dir:      dir,
+		maxSize:  maxSize,
+		ttl:      ttl,
+		sessions: make(map[string]*UploadSession),
+		busy:     make(map[string]bool),
+		now:      time.Now,
+	}
+}
+
+// Load 從磁碟恢復未完成的工作階段
+//
+// Returns:
+// - error: 讀取目錄失敗時的錯誤，目錄不存在時不視為錯誤
+func (s *UploadSessionStore) Load() error {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			log.Printf(LogUploadMetadataError, entry.Name(), err)
+			continue
+		}
+
+		var session UploadSession
+		if err := json.Unmarshal(data, &session); err != nil {
+			log.Printf(LogUploadMetadataError, entry.Name(), err)
+			continue
+		}
+
+		// 以暫存檔實際大小為準，避免中繼資料落後於內容
+		if info, err := os.Stat(s.partPath(session.ID)); err == nil {
+			session.Offset = info.Size()
+		}
+		s.sessions[session.ID] = &session
+	}
+	return nil
+}
+
+// Create 建立新的分段上傳工作階段
+//
+// Parameters:
+// - owner: 建立者用戶名
+// - name: 原始檔名
+// - size: 檔案總大小
+// - checksum: 預期的 SHA-256 雜湊值，可為空
+//
+// Returns:
+// - UploadSession: 新建立的工作階段
+// - error: 大小不合法或寫入失敗時的錯誤
+func (s *UploadSessionStore) Create(owner, name string, size int64, checksum string) (UploadSession, error) {
+	if size <= 0 {
+		return UploadSession{}, ErrUploadInvalidSize
+	}
+	if size > s.maxSize {
+		return UploadSession{}, ErrUploadTooLarge
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return UploadSession{}, err
+	}
+
+	now := s.now()
+	session := &UploadSession{
+		ID:        generateUploadID(),
+		Owner:     owner,
+		Name:      sanitizeFileName(name),
+		Size:      size,
+		Checksum:  strings.ToLower(checksum),
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.ttl),
+	}
+
+	part, err := os.Create(s.partPath(session.ID))
+	if err != nil {
+		return UploadSession{}, err
+	}
+	part.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.persistLocked(session); err != nil {
+		os.Remove(s.partPath(session.ID))
+		return UploadSession{}, err
+	}
+	s.sessions[session.ID] = session
+	return *session, nil
+}
+
+// Get 取得工作階段的目前進度
+//
+// Parameters:
+// - id: 工作階段識別碼
+// - owner: 請求者用戶名
+//
+// Returns:
+// - UploadSession: 工作階段複本
+// - error: 不存在、已過期或不屬於請求者時返回 ErrUploadSessionNotFound
+func (s *UploadSessionStore) Get(id, owner string) (UploadSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, err := s.lookupLocked(id, owner)
+	if err != nil {
+		return UploadSession{}, err
+	}
+	return *session, nil
+}
+
+// WriteChunk 在指定偏移量追加分段內容
+//
+// Responsible for:
+// - 確認偏移量與目前已接收的大小一致
+// - 追加內容並更新進度與過期時間
+//
+// Design considerations:
+// - 偏移量不一致時返回目前進度，客戶端應從該位置重送
+// - 寫入過程不持有全域鎖，以 busy 標記防止同一工作階段併發寫入
+// - 寫入中斷時以暫存檔實際大小作為新的偏移量，已收到的部分不需重傳
+//
+// Parameters:
+// - id: 工作階段識別碼
+// - owner: 請求者用戶名
+// - offset: 此分段的起始位置
+// - r: 分段內容
+//
+// Returns:
+// - UploadSession: 更新後的工作階段
+// - error: 偏移量不符、超出總大小或寫入失敗時的錯誤
+func (s *UploadSessionStore) WriteChunk(id, owner string, offset int64, r io.Reader) (UploadSession, error) {
+	s.mu.Lock()
+	session, err := s.lookupLocked(id, owner)
+	if err != nil {
+		s.mu.Unlock()
+		return UploadSession{}, err
+	}
+	if s.busy[id] {
+		s.mu.Unlock()
+		return UploadSession{}, ErrUploadSessionBusy
+	}
+	if offset != session.Offset {
+		current := *session
+		s.mu.Unlock()
+		return current, ErrUploadOffsetMismatch
+	}
+	s.busy[id] = true
+	remaining := session.Size - session.Offset
+	s.mu.Unlock()
+
+	written, writeErr := s.appendPart(id, r, remaining)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.busy, id)
+
+	session.Offset += written
+	session.ExpiresAt = s.now().Add(s.ttl)
+	if err := s.persistLocked(session); err != nil && writeErr == nil {
+		writeErr = err
+	}
+	return *session, writeErr
+}
+
+// Finalize 完成分段上傳
+//
+// Responsible for:
+// - 確認所有內容都已接收
+// - 驗證 SHA-256 雜湊值
+// - 將檔案交給 UploadStore 保存並移除工作階段
+//
+// Parameters:
+// - id: 工作階段識別碼
+// - owner: 請求者用戶名
+// - checksum: 預期的雜湊值，為空時使用建立時提供的值
+//
+// Returns:
+// - Attachment: 保存後的附件中繼資料
+// - error: 未完成、缺少雜湊、雜湊不符或保存失敗時的錯誤
+func (s *UploadSessionStore) Finalize(id, owner, checksum string) (Attachment, error) {
+	s.mu.Lock()
+	session, err := s.lookupLocked(id, owner)
+	if err != nil {
+		s.mu.Unlock()
+		return Attachment{}, err
+	}
+	if s.busy[id] {
+		s.mu.Unlock()
+		return Attachment{}, ErrUploadSessionBusy
+	}
+	if session.Offset != session.Size {
+		s.mu.Unlock()
+		return Attachment{}, ErrUploadIncomplete
+	}
+	if checksum == "" {
+		checksum = session.Checksum
+	}
+	if checksum == "" {
+		s.mu.Unlock()
+		return Attachment{}, ErrUploadChecksumRequired
+	}
+	s.busy[id] = true
+	name := session.Name
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.busy, id)
+		s.mu.Unlock()
+	}()
+
+	actual, err := hashFile(s.partPath(id))
+	if err != nil {
+		return Attachment{}, err
+	}
+	if actual != strings.ToLower(checksum) {
+		return Attachment{}, ErrUploadChecksumMismatch
+	}
+
+	part, err := os.Open(s.partPath(id))
+	if err != nil {
+		return Attachment{}, err
+	}
+	attachment, err := uploadStore.saveWithLimit(name, part, owner, s.maxSize)
+	part.Close()
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	s.mu.Lock()
+	s.removeLocked(id)
+	s.mu.Unlock()
+	return attachment, nil
+}
+
+// Cancel 取消並刪除工作階段
+//
+// Parameters:
+// - id: 工作階段識別碼
+// - owner: 請求者用戶名
+//
+// Returns:
+// - error: 不存在或正在寫入時的錯誤
+func (s *UploadSessionStore) Cancel(id, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.lookupLocked(id, owner); err != nil {
+		return err
+	}
+	if s.busy[id] {
+		return ErrUploadSessionBusy
+	}
+	s.removeLocked(id)
+	return nil
+}
+
+// CollectExpired 回收所有過期且未在寫入中的工作階段
+//
+// Returns:
+// - int: 回收的工作階段數量
+func (s *UploadSessionStore) CollectExpired() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	removed := 0
+	for id, session := range s.sessions {
+		if !s.busy[id] && now.After(session.ExpiresAt) {
+			s.removeLocked(id)
+			removed++
+		}
+	}
+	return removed
+}
+
+// run 定期回收過期的工作階段
+//
+// Parameters:
+// - interval: 檢查間隔
+func (s *UploadSessionStore) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if removed := s.CollectExpired(); removed > 0 {
+			log.Printf(LogUploadSessionsCollected, removed)
+		}
+	}
+}
+
+// lookupLocked 查詢屬於請求者且未過期的工作階段，呼叫者需持有鎖
+func (s *UploadSessionStore) lookupLocked(id, owner string) (*UploadSession, error) {
+	session, ok := s.sessions[id]
+	if !ok || session.Owner != owner || s.now().After(session.ExpiresAt) {
+		return nil, ErrUploadSessionNotFound
+	}
+	return session, nil
+}
+
+// persistLocked 將工作階段中繼資料寫入磁碟，呼叫者需持有鎖
+func (s *UploadSessionStore) persistLocked(session *UploadSession) error {
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.dir, session.ID+".json"), data, 0o644)
+}
+
+// removeLocked 刪除工作階段與其檔案，呼叫者需持有鎖
+func (s *UploadSessionStore) removeLocked(id string) {
+	delete(s.sessions, id)
+	os.Remove(s.partPath(id))
+	os.Remove(filepath.Join(s.dir, id+".json"))
+}
+
+// appendPart 將內容追加到暫存檔，最多寫入 limit 位元組
+//
+// Returns:
+// - int64: 實際寫入的位元組數
+// - error: 超出剩餘大小或寫入失敗時的錯誤
+func (s *UploadSessionStore) appendPart(id string, r io.Reader, limit int64) (int64, error) {
+	part, err := os.OpenFile(s.partPath(id), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	defer part.Close()
+
+	written, err := io.Copy(part, io.LimitReader(r, limit))
+	if err != nil {
+		return written, err
+	}
+
+	// 還有剩餘內容表示分段超出宣告的總大小
+	var probe [1]byte
+	if n, _ := r.Read(probe[:]); n > 0 {
+		return written, ErrUploadChunkOverflow
+	}
+	return written, nil
+}
+
+// partPath 返回工作階段暫存檔路徑
+func (s *UploadSessionStore) partPath(id string) string {
+	return filepath.Join(s.dir, id+".part")
+}
+
+// hashFile 計算檔案的 SHA-256 雜湊值
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sha256Hex 計算內容的 SHA-256 十六進位字串
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// TestUploadSessionResume 測試分段上傳的續傳與完成
+func TestUploadSessionResume(t *testing.T) {
+	uploadStore = NewUploadStore(t.TempDir(), 1<<20, DefaultUploadAllowedMIMETypes)
+	sessions := NewUploadSessionStore(t.TempDir(), 1<<20, time.Hour)
+	content := []byte(strings.Repeat("chunked upload content ", 100))
+
+	session, err := sessions.Create("alice", "notes.txt", int64(len(content)), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session, err = sessions.WriteChunk(session.ID, "alice", 0, bytes.NewReader(content[:1000]))
+	if err != nil || session.Offset != 1000 {
+		t.Fatalf("Expected offset 1000, got %d (%v)", session.Offset, err)
+	}
+
+	// 客戶端以錯誤的偏移量重送時返回目前進度
+	session, err = sessions.WriteChunk(session.ID, "alice", 500, bytes.NewReader(content[500:]))
+	if !errors.Is(err, ErrUploadOffsetMismatch) || session.Offset != 1000 {
+		t.Fatalf("Expected offset mismatch at 1000, got %d (%v)", session.Offset, err)
+	}
+
+	// 其他用戶看不到此工作階段
+	if _, err := sessions.Get(session.ID, "bob"); !errors.Is(err, ErrUploadSessionNotFound) {
+		t.Errorf("Expected ErrUploadSessionNotFound for other user, got %v", err)
+	}
+
+	// 重啟後從磁碟恢復進度
+	reloaded := NewUploadSessionStore(sessions.dir, 1<<20, time.Hour)
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if progress, err := reloaded.Get(session.ID, "alice"); err != nil || progress.Offset != 1000 {
+		t.Fatalf("Expected reloaded offset 1000, got %d (%v)", progress.Offset, err)
+	}
+
+	if _, err := reloaded.Finalize(session.ID, "alice", sha256Hex(content)); !errors.Is(err, ErrUploadIncomplete) {
+		t.Errorf("Expected ErrUploadIncomplete, got %v", err)
+	}
+
+	if _, err := reloaded.WriteChunk(session.ID, "alice", 1000, bytes.NewReader(content[1000:])); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := reloaded.Finalize(session.ID, "alice", sha256Hex([]byte("other"))); !errors.Is(err, ErrUploadChecksumMismatch) {
+		t.Errorf("Expected ErrUploadChecksumMismatch, got %v", err)
+	}
+
+	attachment, err := reloaded.Finalize(session.ID, "alice", sha256Hex(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attachment.Size != int64(len(content)) || attachment.Checksum != sha256Hex(content) {
+		t.Errorf("Unexpected attachment: %+v", attachment)
+	}
+	if _, err := reloaded.Get(session.ID, "alice"); !errors.Is(err, ErrUploadSessionNotFound) {
+		t.Error("Expected session to be removed after finalize")
+	}
+}
+
+// TestUploadSessionOverflow 測試分段超出宣告大小
+func TestUploadSessionOverflow(t *testing.T) {
+	sessions := NewUploadSessionStore(t.TempDir(), 1<<20, time.Hour)
+	session, _ := sessions.Create("alice", "a.txt", 4, "")
+
+	session, err := sessions.WriteChunk(session.ID, "alice", 0, strings.NewReader("too long"))
+	if !errors.Is(err, ErrUploadChunkOverflow) {
+		t.Errorf("Expected ErrUploadChunkOverflow, got %v", err)
+	}
+	if session.Offset != 4 {
+		t.Errorf("Expected offset to stop at declared size, got %d", session.Offset)
+	}
+}
+
+// TestUploadSessionExpiry 測試過期工作階段的回收
+func TestUploadSessionExpiry(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sessions := NewUploadSessionStore(t.TempDir(), 1<<20, time.Hour)
+	sessions.now = func() time.Time { return now }
+
+	stale, _ := sessions.Create("alice", "a.txt", 10, "")
+	now = now.Add(30 * time.Minute)
+	active, _ := sessions.Create("alice", "b.txt", 10, "")
+	sessions.WriteChunk(active.ID, "alice", 0, strings.NewReader("12345"))
+
+	now = now.Add(45 * time.Minute)
+	if removed := sessions.CollectExpired(); removed != 1 {
+		t.Fatalf("Expected 1 expired session, got %d", removed)
+	}
+	if _, err := sessions.Get(stale.ID, "alice"); err == nil {
+		t.Error("Expected stale session to be collected")
+	}
+	if _, err := sessions.Get(active.ID, "alice"); err != nil {
+		t.Errorf("Expected active session to remain, got %v", err)
+	}
+}
+
+// TestUploadSessionAPI 測試分段上傳 API 並發送檔案訊息
+func TestUploadSessionAPI(t *testing.T) {
+	messageStore = make(MessageStore)
+	uploadStore = NewUploadStore(t.TempDir(), 1<<20, DefaultUploadAllowedMIMETypes)
+	uploadSessions = NewUploadSessionStore(t.TempDir(), 1<<20, time.Hour)
+	router := setupRoutes()
+	content := []byte("hello chunked world")
+
+	do := func(method, url, body string, offset string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.SetBasicAuth("bob", "password123")
+		if offset != "" {
+			req.Header.Set("Upload-Offset", offset)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do("POST", "/api/uploads/sessions", `{"name":"hello.txt","size":19,"checksum":"`+sha256Hex(content)+`"}`, "")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created struct {
+		Session UploadSession `json:"session"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	url := "/api/uploads/sessions/" + created.Session.ID
+
+	if rr = do("PUT", url, string(content[:10]), "0"); rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for first chunk, got %d", rr.Code)
+	}
+	if rr = do("HEAD", url, "", ""); rr.Header().Get("Upload-Offset") != "10" {
+		t.Fatalf("Expected Upload-Offset 10, got '%s'", rr.Header().Get("Upload-Offset"))
+	}
+	if rr = do("PUT", url, string(content[5:]), "5"); rr.Code != http.StatusConflict {
+		t.Fatalf("Expected 409 for wrong offset, got %d", rr.Code)
+	}
+	if rr = do("PUT", url, string(content[10:]), "10"); rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for last chunk, got %d", rr.Code)
+	}
+
+	rr = do("POST", url+"/complete", `{"message":{"content":"大檔案"}}`, "")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 on complete, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	stored := messageStore["tech"]
+	if len(stored) != 1 || stored[0].Type != MessageTypeFile || stored[0].Attachment == nil {
+		t.Fatalf("Expected a file message in tech, got %+v", stored)
+	}
+	if stored[0].Attachment.Size != int64(len(content)) {
+		t.Errorf("Expected attachment size %d, got %d", len(content), stored[0].Attachment.Size)
+	}
+}
+
+// TestUploadSessionCompleteSendChecks 測試完成上傳並發送訊息時套用與 sendMessage 相同的發送者檢查
+func TestUploadSessionCompleteSendChecks(t *testing.T) {
+	messageStore = make(MessageStore)
+	channelSettings = NewChannelSettingsStore()
+	slowMode = NewSlowModeTracker()
+	uploadStore = NewUploadStore(t.TempDir(), 1<<20, DefaultUploadAllowedMIMETypes)
+	uploadSessions = NewUploadSessionStore(t.TempDir(), 1<<20, time.Hour)
+	defer func() {
+		roleStore = NewRoleStore(DefaultRoleGrants)
+		channelSettings = NewChannelSettingsStore()
+		slowMode = NewSlowModeTracker()
+	}()
+	router := setupRoutes()
+	content := []byte("hello chunked world")
+
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.SetBasicAuth("bob", "password123")
+		req.Header.Set("Upload-Offset", "0")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	rr := do("POST", "/api/uploads/sessions", `{"name":"hello.txt","size":19}`)
+	var created struct {
+		Session UploadSession `json:"session"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	url := "/api/uploads/sessions/" + created.Session.ID
+	if rr = do("PUT", url, string(content)); rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for chunk, got %d", rr.Code)
+	}
+	complete := `{"checksum":"` + sha256Hex(content) + `","message":{"content":"大檔案"}}`
+
+	t.Run("訪客不能發送", func(t *testing.T) {
+		roleStore = NewRoleStore(append([]RoleGrant{{Channel: "tech", Username: "bob", Role: RoleGuest}}, DefaultRoleGrants...))
+		defer func() { roleStore = NewRoleStore(DefaultRoleGrants) }()
+
+		rr := do("POST", url+"/complete", complete)
+		if rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), ErrorCodeSendForbidden) {
+			t.Errorf("Expected 403 send_forbidden, got %d: %s", rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("慢速模式需要等待", func(t *testing.T) {
+		interval := 30
+		channelSettings.Apply("tech", ChannelSettingsUpdate{SlowMode: &interval})
+		slowMode.Record("tech", "bob")
+
+		rr := do("POST", url+"/complete", complete)
+		if rr.Code != http.StatusTooManyRequests || !strings.Contains(rr.Body.String(), ErrorCodeSlowMode) {
+			t.Errorf("Expected 429 slow_mode, got %d: %s", rr.Code, rr.Body.String())
+		}
+		slowMode.Reset("tech")
+	})
+
+	if len(messageStore["tech"]) != 0 {
+		t.Fatalf("Expected rejected completions not to publish, got %+v", messageStore["tech"])
+	}
+	if rr := do("POST", url+"/complete", complete); rr.Code != http.StatusCreated {
+		t.Fatalf("Expected session to remain completable, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if stored := messageStore["tech"]; len(stored) != 1 || stored[0].Type != MessageTypeFile {
+		t.Errorf("Expected a file message in tech, got %+v", stored)
+	}
+}
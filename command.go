@@ -0,0 +1,544 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 指令相關錯誤
+var (
+	ErrUnknownCommand   = errors.New(ErrorUnknownCommand)
+	ErrCommandUsage     = errors.New(ErrorCommandUsage)
+	ErrCommandForbidden = errors.New(ErrorCommandForbidden)
+	ErrUserNotFound     = errors.New(ErrorUserNotFound)
+	ErrInvalidNick      = errors.New(ErrorInvalidNick)
+)
+
+// CommandContext 代表一次指令執行的呼叫者與參數
+type CommandContext struct {
+	Account *Account // 執行者帳號
+	Channel string   // 執行指令的頻道
+	Name    string   // 指令名稱（不含 /，小寫）
+	Args    []string // 以空白分隔的參數
+	RawArgs string   // 指令名稱之後的原始文字（已去除前後空白）
+	Message Message  // 原始訊息，已設置 ID、時間戳、用戶與頻道
+	IP      string   // 請求來源 IP，用於稽核日誌
+}
+
+// CommandResult 代表指令的執行結果
+//
+// Design considerations:
+// - Message 不為 nil 時，呼叫者將其當作一般訊息繼續處理（存入歷史並廣播）
+// - Reply 只回覆給執行者，不寫入歷史
+// - 指令自行發布的系統訊息（例如主題變更）不透過結果返回
+type CommandResult struct {
+	Message *Message // 要發送到頻道的訊息
+	Reply   string   // 私人回覆內容
+}
+
+// CommandHandler 處理單一指令
+type CommandHandler func(ctx CommandContext) (CommandResult, error)
+
+// Command 代表一個已註冊的指令
+type Command struct {
+	Name        string         // 指令名稱（不含 /）
+	Usage       string         // 用法說明，參數錯誤時回覆給執行者
+	Description string         // 簡短說明，供 /help 列出
+	Handler     CommandHandler // 處理函數
+}
+
+// CommandRegistry 管理聊天輸入的斜線指令
+//
+// Responsible for:
+// - 註冊指令名稱與處理函數
+// - 將指令分派給對應的處理函數並補上用法說明
+//
+// Design considerations:
+// - 指令名稱不分大小寫
+// - 處理函數返回 ErrCommandUsage 時自動附上該指令的用法
+// - WebSocket 與 REST 共用同一個註冊表，回覆方式由呼叫者決定
+//
+// Usage context:
+// - 全域 commandRegistry 實例
+// - readPump 與 sendMessage 在訊息寫入歷史前攔截指令
+type CommandRegistry struct {
+	mu       sync.RWMutex
+	commands map[string]Command
+}
+
+// NewCommandRegistry 建立空的指令註冊表
+//
+// Returns:
+// - *CommandRegistry: 沒有任何指令的註冊表
+func NewCommandRegistry() *CommandRegistry {
+	return &CommandRegistry{
+		commands: make(map[string]Command),
+	}
+}
+
+// newDefaultCommandRegistry 建立包含內建指令的註冊表
+//
+// Returns:
+// - *CommandRegistry: 已註冊 /help、/me、/topic、/nick、/invite、/mute、/slowmode 與 /kick、/ban 等管理指令的註冊表
+func newDefaultCommandRegistry() *CommandRegistry {
+	r := NewCommandRegistry()
+	r.Register(Command{Name: "help", Usage: "/help", Description: "列出可用的指令", Handler: r.help})
+	r.Register(Command{Name: "me", Usage: "/me <動作>", Description: "以第三人稱描述動作", Handler: meCommand})
+	r.Register(Command{Name: "topic", Usage: "/topic [主題]", Description: "查看或設定頻道主題（設定需管理員）", Handler: topicCommand})
+	r.Register(Command{Name: "nick", Usage: "/nick [暱稱]", Description: "設定暱稱，不帶參數時清除", Handler: nickCommand})
+	r.Register(Command{Name: "invite", Usage: "/invite <用戶>", Description: "邀請用戶加入目前的頻道", Handler: inviteCommand})
+	r.Register(Command{Name: "mute", Usage: "/mute <用戶> [分鐘]", Description: "在頻道中禁言用戶（需管理員）", Handler: muteCommand})
+	r.Register(Command{Name: "unmute", Usage: "/unmute <用戶>", Description: "解除用戶的禁言（需管理員）", Handler: unmuteCommand})
+	r.Register(Command{Name: "kick", Usage: "/kick <用戶> [原因]", Description: "將用戶踢出頻道（需管理員）", Handler: kickCommand})
+	r.Register(Command{Name: "ban", Usage: "/ban <用戶> [分鐘] [原因]", Description: "在頻道中封鎖用戶，未指定分鐘時永久封鎖（需管理員）", Handler: banCommand})
+	r.Register(Command{Name: "unban", Usage: "/unban <用戶>", Description: "解除用戶的頻道封鎖（需管理員）", Handler: unbanCommand})
+	r.Register(Command{Name: "slowmode", Usage: "/slowmode [秒數|off]", Description: "查看或設定慢速模式（設定需管理員）", Handler: slowModeCommand})
+	return r
+}
+
+// Register 註冊指令，同名指令會被取代
+//
+// Parameters:
+// - cmd: 要註冊的指令
+func (r *CommandRegistry) Register(cmd Command) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.commands[strings.ToLower(cmd.Name)] = cmd
+}
+
+// Commands 列出所有已註冊的指令
+//
+// Returns:
+// - []Command: 依名稱排序的指令
+func (r *CommandRegistry) Commands() []Command {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	commands := make([]Command, 0, len(r.commands))
+	for _, cmd := range r.commands {
+		commands = append(commands, cmd)
+	}
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].Name < commands[j].Name
+	})
+	return commands
+}
+
+// Execute 執行指令
+//
+// Process flow:
+// 1. 依名稱查找指令，找不到時返回 ErrUnknownCommand
+// 2. 以空白分隔參數並呼叫處理函數
+// 3. 參數錯誤時附上用法說明
+//
+// Parameters:
+// - ctx: 執行者與參數，Args 由 RawArgs 自動產生
+//
+// Returns:
+// - CommandResult: 執行結果
+// - error: 指令不存在、參數錯誤、沒有權限或執行失敗時的錯誤
+func (r *CommandRegistry) Execute(ctx CommandContext) (CommandResult, error) {
+	r.mu.RLock()
+	cmd, ok := r.commands[ctx.Name]
+	r.mu.RUnlock()
+
+	if !ok {
+		return CommandResult{}, fmt.Errorf("%w: %s%s", ErrUnknownCommand, CommandPrefix, ctx.Name)
+	}
+
+	ctx.Args = strings.Fields(ctx.RawArgs)
+	result, err := cmd.Handler(ctx)
+	if errors.Is(err, ErrCommandUsage) {
+		err = fmt.Errorf("%w, usage: %s", err, cmd.Usage)
+	}
+	if err != nil {
+		log.Printf(LogCommandFailed, ctx.Account.Username, ctx.Name, err)
+		return CommandResult{}, err
+	}
+
+	log.Printf(LogCommandExecuted, ctx.Account.Username, ctx.Channel, ctx.Name)
+	return result, nil
+}
+
+// extractCommand 判斷訊息是否為指令並解析名稱與參數
+//
+// Design considerations:
+// - 只有文字訊息會被視為指令
+// - 以「//」開頭的內容視為跳脫，去掉一個斜線後當作一般文字發送
+// - 「/」後面沒有名稱（例如「/ 你好」）時當作一般文字
+//
+// Parameters:
+// - msg: 收到的訊息，跳脫時會直接修改其 Content
+//
+// Returns:
+// - string: 指令名稱（小寫）
+// - string: 指令參數的原始文字
+// - bool: 是否為指令
+func extractCommand(msg *Message) (string, string, bool) {
+	if msg.Type != "" && msg.Type != MessageTypeText {
+		return "", "", false
+	}
+	if !strings.HasPrefix(msg.Content, CommandPrefix) {
+		return "", "", false
+	}
+
+	body := strings.TrimPrefix(msg.Content, CommandPrefix)
+	if strings.HasPrefix(body, CommandPrefix) {
+		msg.Content = body
+		return "", "", false
+	}
+
+	name, rawArgs, _ := strings.Cut(body, " ")
+	if name == "" || strings.ContainsFunc(name, unicode.IsSpace) {
+		return "", "", false
+	}
+	return strings.ToLower(name), strings.TrimSpace(rawArgs), true
+}
+
+// commandErrorCode 將指令錯誤對應到 WebSocket error 事件代碼
+func commandErrorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrUnknownCommand):
+		return ErrorCodeUnknownCommand
+	case errors.Is(err, ErrCommandForbidden):
+		return ErrorCodeCommandForbidden
+	default:
+		return ErrorCodeInvalidCommand
+	}
+}
+
+// commandStatusCode 將指令錯誤對應到 HTTP 狀態碼
+func commandStatusCode(err error) int {
+	switch {
+	case errors.Is(err, ErrCommandForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, ErrUserNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// help 列出所有已註冊的指令
+func (r *CommandRegistry) help(ctx CommandContext) (CommandResult, error) {
+	commands := r.Commands()
+	lines := make([]string, 0, len(commands))
+	for _, cmd := range commands {
+		lines = append(lines, fmt.Sprintf(CommandReplyHelpTemplate, cmd.Usage, cmd.Description))
+	}
+	return CommandResult{Reply: strings.Join(lines, "\n")}, nil
+}
+
+// meCommand 將動作描述轉為 action 訊息發送到頻道
+func meCommand(ctx CommandContext) (CommandResult, error) {
+	if ctx.RawArgs == "" {
+		return CommandResult{}, ErrCommandUsage
+	}
+
+	msg := ctx.Message
+	msg.Type = MessageTypeAction
+	msg.Content = ctx.RawArgs
+	return CommandResult{Message: &msg}, nil
+}
+
+// topicCommand 查看或設定頻道主題
+//
+// Design considerations:
+// - 任何成員都可以查看主題，只有頻道管理員可以設定
+// - 設定後發布系統訊息通知頻道
+func topicCommand(ctx CommandContext) (CommandResult, error) {
+	if ctx.RawArgs == "" {
+		topic := channelSettings.Get(ctx.Channel).Topic
+		if topic == "" {
+			return CommandResult{Reply: CommandReplyNoTopic}, nil
+		}
+		return CommandResult{Reply: fmt.Sprintf(CommandReplyTopicTemplate, topic)}, nil
+	}
+
+	if !authorize(ctx.Account, PermissionManageChannel, ctx.Channel) {
+		return CommandResult{}, ErrCommandForbidden
+	}
+	update := ChannelSettingsUpdate{Topic: &ctx.RawArgs}
+	if err := update.Validate(); err != nil {
+		return CommandResult{}, err
+	}
+	settings := channelSettings.Apply(ctx.Channel, update)
+	log.Printf(LogChannelSettingsUpdated, ctx.Channel, settings)
+
+	publishMessage(NewSystemMessage(fmt.Sprintf(SystemMessageTopicTemplate, ctx.Account.Username, ctx.RawArgs), ctx.Channel))
+	return CommandResult{}, nil
+}
+
+// nickCommand 設定或清除執行者的暱稱
+//
+// Design considerations:
+// - 暱稱不可包含空白，也不可與其他帳號的用戶名相同，避免冒充
+// - 變更後發布系統訊息通知執行者所在的頻道
+func nickCommand(ctx CommandContext) (CommandResult, error) {
+	username := ctx.Account.Username
+	if ctx.RawArgs == "" {
+		nicknames.Set(username, "")
+		publishMessage(NewSystemMessage(fmt.Sprintf(SystemMessageNickClearedTemplate, username), ctx.Channel))
+		return CommandResult{}, nil
+	}
+
+	nick := ctx.RawArgs
+	if !validNick(nick, username) {
+		return CommandResult{}, ErrInvalidNick
+	}
+	nicknames.Set(username, nick)
+	publishMessage(NewSystemMessage(fmt.Sprintf(SystemMessageNickTemplate, username, nick), ctx.Channel))
+	return CommandResult{}, nil
+}
+
+// validNick 檢查暱稱是否合法
+func validNick(nick, username string) bool {
+	if utf8.RuneCountInString(nick) > DefaultNickMaxLength {
+		return false
+	}
+	for _, r := range nick {
+		if unicode.IsSpace(r) || !unicode.IsGraphic(r) {
+			return false
+		}
+	}
+	if account, ok := findAccount(nick); ok && account.Username != username {
+		return false
+	}
+	return true
+}
+
+// inviteCommand 推送邀請通知給指定用戶
+//
+// Design considerations:
+// - 邀請事件推送到被邀請者的所有連接，不限頻道，也不寫入歷史
+func inviteCommand(ctx CommandContext) (CommandResult, error) {
+	if len(ctx.Args) != 1 {
+		return CommandResult{}, ErrCommandUsage
+	}
+
+	target, ok := findAccount(strings.TrimPrefix(ctx.Args[0], "@"))
+	if !ok {
+		return CommandResult{}, ErrUserNotFound
+	}
+	if target.Username == ctx.Account.Username {
+		return CommandResult{}, ErrCommandUsage
+	}
+
+	event := NewInviteMessage(ctx.Account.Username, ctx.Channel)
+	go hub.sendToUser(target.Username, event)
+	return CommandResult{Reply: fmt.Sprintf(CommandReplyInviteTemplate, target.Username, ctx.Channel)}, nil
+}
+
+// muteCommand 在頻道中禁言用戶
+//
+// Design considerations:
+// - 只有頻道管理員可以使用，且不能禁言自己
+// - 時長以分鐘為單位，未指定時使用 DefaultMuteDuration
+func muteCommand(ctx CommandContext) (CommandResult, error) {
+	if len(ctx.Args) < 1 || len(ctx.Args) > 2 {
+		return CommandResult{}, ErrCommandUsage
+	}
+
+	minutes := DefaultMuteDuration
+	if len(ctx.Args) == 2 {
+		value, err := strconv.Atoi(ctx.Args[1])
+		if err != nil {
+			return CommandResult{}, ErrCommandUsage
+		}
+		minutes = value
+	}
+
+	_, err := muteUser(ctx.Account, ctx.Channel, ctx.Args[0], minutes)
+	return CommandResult{}, auditSanctionCommand(ctx, AuditActionMute, "", err)
+}
+
+// unmuteCommand 提前解除用戶的禁言
+func unmuteCommand(ctx CommandContext) (CommandResult, error) {
+	if len(ctx.Args) != 1 {
+		return CommandResult{}, ErrCommandUsage
+	}
+	return CommandResult{}, auditSanctionCommand(ctx, AuditActionUnmute, "", unmuteUser(ctx.Account, ctx.Channel, ctx.Args[0]))
+}
+
+// kickCommand 將已連接的用戶踢出頻道
+//
+// Design considerations:
+// - 用戶名之後的文字都視為原因
+func kickCommand(ctx CommandContext) (CommandResult, error) {
+	if len(ctx.Args) < 1 {
+		return CommandResult{}, ErrCommandUsage
+	}
+	reason := strings.Join(ctx.Args[1:], " ")
+	_, err := kickUser(ctx.Account, ctx.Channel, ctx.Args[0], reason)
+	return CommandResult{}, auditSanctionCommand(ctx, AuditActionKick, reason, err)
+}
+
+// banCommand 在頻道中封鎖用戶
+//
+// Design considerations:
+// - 用戶名之後的第一個參數是整數時視為分鐘數，其餘文字視為原因
+// - 未指定分鐘數時永久封鎖
+// - 全伺服器封鎖只能透過 API 執行
+func banCommand(ctx CommandContext) (CommandResult, error) {
+	if len(ctx.Args) < 1 {
+		return CommandResult{}, ErrCommandUsage
+	}
+
+	minutes := 0
+	reasonArgs := ctx.Args[1:]
+	if len(reasonArgs) > 0 {
+		if value, err := strconv.Atoi(reasonArgs[0]); err == nil {
+			minutes = value
+			reasonArgs = reasonArgs[1:]
+		}
+	}
+
+	reason := strings.Join(reasonArgs, " ")
+	_, err := banUser(ctx.Account, ctx.Channel, ctx.Args[0], reason, minutes)
+	return CommandResult{}, auditSanctionCommand(ctx, AuditActionBan, reason, err)
+}
+
+// unbanCommand 解除用戶的頻道封鎖
+func unbanCommand(ctx CommandContext) (CommandResult, error) {
+	if len(ctx.Args) != 1 {
+		return CommandResult{}, ErrCommandUsage
+	}
+	return CommandResult{}, auditSanctionCommand(ctx, AuditActionUnban, "", unbanUser(ctx.Account, ctx.Channel, ctx.Args[0]))
+}
+
+// auditSanctionCommand 記錄管理指令的稽核紀錄並轉換錯誤
+//
+// Parameters:
+// - ctx: 指令上下文，第一個參數為目標用戶
+// - action: AuditAction* 常數
+// - details: 補充說明，例如原因
+// - err: 管理操作返回的錯誤
+//
+// Returns:
+// - error: sanctionCommandError 轉換後的錯誤
+func auditSanctionCommand(ctx CommandContext, action, details string, err error) error {
+	auditLog.Record(AuditEntry{
+		Actor:   ctx.Account.Username,
+		Action:  action,
+		Target:  strings.TrimPrefix(ctx.Args[0], "@"),
+		Channel: ctx.Channel,
+		IP:      ctx.IP,
+		Outcome: auditOutcome(err, sanctionStatusCode),
+		Details: auditDetails(details, err),
+	})
+	return sanctionCommandError(err)
+}
+
+// sanctionCommandError 將管理操作的錯誤轉換為指令錯誤
+//
+// Design considerations:
+// - 無權限對應 ErrCommandForbidden，目標或時長不合法對應 ErrCommandUsage 以附上用法
+// - 其他錯誤（例如用戶不存在、未被封鎖）原樣返回
+func sanctionCommandError(err error) error {
+	switch {
+	case errors.Is(err, ErrSanctionForbidden):
+		return ErrCommandForbidden
+	case errors.Is(err, ErrInvalidSanctionTarget), errors.Is(err, ErrInvalidMuteDuration),
+		errors.Is(err, ErrInvalidBanDuration):
+		return ErrCommandUsage
+	default:
+		return err
+	}
+}
+
+// slowModeCommand 查看或設定頻道的慢速模式
+//
+// Design considerations:
+// - 任何成員都可以查看，只有頻道管理員可以設定
+// - off 或 0 表示關閉
+func slowModeCommand(ctx CommandContext) (CommandResult, error) {
+	if len(ctx.Args) == 0 {
+		interval := channelSettings.Get(ctx.Channel).SlowMode
+		if interval == 0 {
+			return CommandResult{Reply: CommandReplyNoSlowMode}, nil
+		}
+		return CommandResult{Reply: fmt.Sprintf(CommandReplySlowMode, interval)}, nil
+	}
+	if len(ctx.Args) > 1 {
+		return CommandResult{}, ErrCommandUsage
+	}
+
+	seconds := 0
+	if !strings.EqualFold(ctx.Args[0], "off") {
+		value, err := strconv.Atoi(ctx.Args[0])
+		if err != nil {
+			return CommandResult{}, ErrCommandUsage
+		}
+		seconds = value
+	}
+	if _, err := setSlowMode(ctx.Account, ctx.Channel, seconds); err != nil {
+		if errors.Is(err, ErrSlowModeNotPermitted) {
+			return CommandResult{}, ErrCommandForbidden
+		}
+		return CommandResult{}, err
+	}
+	return CommandResult{}, nil
+}
+
+// NicknameStore 保存用戶的暱稱
+//
+// Design considerations:
+// - 暱稱屬於用戶而非頻道，所有頻道共用
+// - 只存在記憶體中，與 MessageStore 一致
+//
+// Usage context:
+// - 全域 nicknames 實例
+// - /nick 指令寫入，新訊息的 nick 欄位讀取
+type NicknameStore struct {
+	mu    sync.RWMutex
+	names map[string]string
+}
+
+// NewNicknameStore 建立暱稱存儲
+//
+// Returns:
+// - *NicknameStore: 空的暱稱存儲
+func NewNicknameStore() *NicknameStore {
+	return &NicknameStore{
+		names: make(map[string]string),
+	}
+}
+
+// Get 取得用戶的暱稱
+//
+// Parameters:
+// - username: 用戶名
+//
+// Returns:
+// - string: 暱稱，未設定時為空字串
+func (ns *NicknameStore) Get(username string) string {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+
+	return ns.names[username]
+}
+
+// Set 設定用戶的暱稱
+//
+// Parameters:
+// - username: 用戶名
+// - nick: 暱稱，空字串表示清除
+func (ns *NicknameStore) Set(username, nick string) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	if nick == "" {
+		delete(ns.names, username)
+		return
+	}
+	ns.names[username] = nick
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// postCommand 以指定帳號透過 REST API 發送訊息
+func postCommand(username, channel, content string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(Message{User: username, Channel: channel, Content: content, Type: MessageTypeText})
+	req, _ := http.NewRequest("POST", "/api/messages", bytes.NewReader(body))
+	req.SetBasicAuth(username, "password123")
+	rr := httptest.NewRecorder()
+	setupRoutes().ServeHTTP(rr, req)
+	return rr
+}
+
+// TestExtractCommand 測試指令的辨識與解析
+func TestExtractCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		msg      Message
+		wantName string
+		wantArgs string
+		wantOK   bool
+		content  string
+	}{
+		{"一般文字", Message{Content: "你好"}, "", "", false, "你好"},
+		{"指令與參數", Message{Content: "/ME  揮揮手 "}, "me", "揮揮手", true, "/ME  揮揮手 "},
+		{"沒有參數", Message{Type: MessageTypeText, Content: "/help"}, "help", "", true, "/help"},
+		{"跳脫斜線", Message{Content: "//me 不是指令"}, "", "", false, "/me 不是指令"},
+		{"沒有名稱", Message{Content: "/ 你好"}, "", "", false, "/ 你好"},
+		{"非文字訊息", Message{Type: MessageTypeFile, Content: "/me"}, "", "", false, "/me"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			msg := test.msg
+			name, args, ok := extractCommand(&msg)
+			if name != test.wantName || args != test.wantArgs || ok != test.wantOK {
+				t.Errorf("Expected (%q, %q, %v), got (%q, %q, %v)", test.wantName, test.wantArgs, test.wantOK, name, args, ok)
+			}
+			if msg.Content != test.content {
+				t.Errorf("Expected content %q, got %q", test.content, msg.Content)
+			}
+		})
+	}
+}
+
+// TestCommandRegistryExecute 測試指令分派與錯誤
+func TestCommandRegistryExecute(t *testing.T) {
+	registry := newDefaultCommandRegistry()
+	account, _ := findAccount("bob")
+	ctx := CommandContext{Account: &account, Channel: "tech"}
+
+	t.Run("未知指令", func(t *testing.T) {
+		ctx.Name = "dance"
+		_, err := registry.Execute(ctx)
+		if commandErrorCode(err) != ErrorCodeUnknownCommand {
+			t.Errorf("Expected unknown_command, got %v", err)
+		}
+	})
+
+	t.Run("參數錯誤附上用法", func(t *testing.T) {
+		ctx.Name = "me"
+		_, err := registry.Execute(ctx)
+		if commandErrorCode(err) != ErrorCodeInvalidCommand || !strings.Contains(err.Error(), "/me <動作>") {
+			t.Errorf("Expected usage error, got %v", err)
+		}
+	})
+
+	t.Run("需要管理員", func(t *testing.T) {
+		ctx.Name = "mute"
+		ctx.RawArgs = "charlie"
+		_, err := registry.Execute(ctx)
+		if commandErrorCode(err) != ErrorCodeCommandForbidden {
+			t.Errorf("Expected command_forbidden, got %v", err)
+		}
+	})
+
+	t.Run("列出指令", func(t *testing.T) {
+		ctx.Name = "help"
+		ctx.RawArgs = ""
+		result, err := registry.Execute(ctx)
+		if err != nil || !strings.Contains(result.Reply, "/invite <用戶>") {
+			t.Errorf("Expected help listing, got %q (%v)", result.Reply, err)
+		}
+	})
+}
+
+// TestValidNick 測試暱稱規則
+func TestValidNick(t *testing.T) {
+	tests := []struct {
+		nick string
+		want bool
+	}{
+		{"小白", true},
+		{"Alice", true},
+		{"Bob", false},
+		{"兩個 字", false},
+		{strings.Repeat("a", DefaultNickMaxLength+1), false},
+	}
+
+	for _, test := range tests {
+		if got := validNick(test.nick, "alice"); got != test.want {
+			t.Errorf("validNick(%q) = %v, want %v", test.nick, got, test.want)
+		}
+	}
+}
+
+// TestCommandAPI 測試透過 REST API 執行指令
+func TestCommandAPI(t *testing.T) {
+	hub = *newTestHub(0)
+	messageStore = make(MessageStore)
+	messageIndex = NewSearchIndex()
+	channelSettings = NewChannelSettingsStore()
+	commandRegistry = newDefaultCommandRegistry()
+	nicknames = NewNicknameStore()
+	muteStore = NewMuteStore()
+
+	t.Run("需要驗證", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/api/messages", strings.NewReader(`{"channel":"general","content":"/help"}`))
+		rr := httptest.NewRecorder()
+		setupRoutes().ServeHTTP(rr, req)
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401, got %d", rr.Code)
+		}
+	})
+
+	t.Run("未知指令", func(t *testing.T) {
+		rr := postCommand("alice", "general", "/dance")
+		var response map[string]string
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		if rr.Code != http.StatusBadRequest || response["code"] != ErrorCodeUnknownCommand {
+			t.Errorf("Expected 400 unknown_command, got %d %v", rr.Code, response)
+		}
+	})
+
+	t.Run("動作訊息", func(t *testing.T) {
+		rr := postCommand("bob", "tech", "/me 揮揮手")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		messages := messageStore.GetRecentMessages("tech", 1)
+		if len(messages) != 1 || messages[0].Type != MessageTypeAction || messages[0].Content != "揮揮手" || messages[0].User != "bob" {
+			t.Errorf("Expected stored action message, got %+v", messages)
+		}
+	})
+
+	t.Run("設定主題", func(t *testing.T) {
+		if rr := postCommand("bob", "tech", "/topic 新主題"); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected 403 for non-moderator, got %d", rr.Code)
+		}
+
+		rr := postCommand("alice", "general", "/topic 週會討論")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if topic := channelSettings.Get("general").Topic; topic != "週會討論" {
+			t.Errorf("Expected topic to be set, got %q", topic)
+		}
+
+		rr = postCommand("alice", "general", "/topic")
+		var response map[string]string
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		if response["status"] != StatusCommandExecuted || !strings.Contains(response["reply"], "週會討論") {
+			t.Errorf("Expected private reply with topic, got %v", response)
+		}
+	})
+
+	t.Run("暱稱", func(t *testing.T) {
+		if rr := postCommand("bob", "tech", "/nick 小白"); rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rr.Code)
+		}
+		postCommand("bob", "tech", "一般訊息")
+		messages := messageStore.GetRecentMessages("tech", 1)
+		if len(messages) != 1 || messages[0].Nick != "小白" {
+			t.Errorf("Expected message to carry nick, got %+v", messages)
+		}
+	})
+
+	t.Run("邀請", func(t *testing.T) {
+		charlie := newTestClient("charlie", "random")
+		hub.register <- charlie
+		time.Sleep(10 * time.Millisecond)
+		for len(charlie.send) > 0 {
+			<-charlie.send
+		}
+
+		if rr := postCommand("bob", "tech", "/invite @charlie"); rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rr.Code)
+		}
+		select {
+		case event := <-charlie.send:
+			if event.Type != MessageTypeInvite || event.Channel != "tech" || event.User != "bob" {
+				t.Errorf("Expected invite event, got %+v", event)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected charlie to receive an invite")
+		}
+
+		if rr := postCommand("bob", "tech", "/invite nobody"); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 for unknown user, got %d", rr.Code)
+		}
+	})
+}
+
+// TestMuteCommand 測試禁言指令與發送限制
+func TestMuteCommand(t *testing.T) {
+	hub = *newTestHub(0)
+	messageStore = make(MessageStore)
+	messageIndex = NewSearchIndex()
+	muteStore = NewMuteStore()
+	now := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	muteStore.now = func() time.Time { return now }
+
+	if rr := postCommand("alice", "general", "/mute bob 5"); rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := postCommand("alice", "general", "/mute bob 0"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid duration, got %d", rr.Code)
+	}
+
+	if rr := postCommand("bob", "general", "我還能說話嗎？"); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 while muted, got %d", rr.Code)
+	}
+
+	if err := checkMuted(Message{User: "bob", Channel: "tech"}); err != nil {
+		t.Errorf("Expected mute to be limited to general, got %v", err)
+	}
+
+	now = now.Add(5 * time.Minute)
+	if err := checkMuted(Message{User: "bob", Channel: "general"}); err != nil {
+		t.Errorf("Expected mute to expire, got %v", err)
+	}
+}
@@ -1,11 +1,5 @@
 package main
 
-import (
-	"net/http"
-
-	"github.com/gorilla/websocket"
-)
-
 // 應用程式設定常數 - 單一來源，集中管理
 //
 // Responsible for:
@@ -27,6 +21,23 @@ const (
 	DefaultServerPort = 8080
 	DefaultServerHost = "localhost"
 
+	// TLS 設定預設值
+	DefaultTLSDevCertDir        = "./certs"      // 自簽開發憑證的快取目錄
+	DefaultTLSDevCertFile       = "dev-cert.pem" // 自簽開發憑證檔名
+	DefaultTLSDevKeyFile        = "dev-key.pem"  // 自簽開發憑證私鑰檔名
+	DefaultTLSDevCertValidity   = 365            // 自簽開發憑證的有效天數
+	DefaultTLSDevCertRenewAhead = 30             // 剩餘天數少於此值時重新產生
+	DefaultHTTPSPort            = 443            // 轉址時省略的 HTTPS 埠
+
+	// 啟動設定來源（設定檔、環境變數與命令列參數）
+	ConfigEnvPrefix = "CHAT_"       // 環境變數前綴，例如 CHAT_PORT
+	ConfigFileEnv   = "CHAT_CONFIG" // 設定檔路徑的環境變數，--config 優先
+
+	// 設定重新載入
+	DefaultConfigWatchInterval = 2 // 檢查設定檔是否修改的間隔（秒）
+	ConfigReloadSignal         = "SIGHUP"
+	ConfigReloadFileChange     = "file"
+
 	// WebSocket 設定預設值
 	DefaultReadLimit       = 512
 	DefaultReadTimeout     = 60
@@ -35,14 +46,19 @@ const (
 	DefaultPingPeriod      = 54
 	DefaultAllowAllOrigins = true
 
+	// CORS 設定預設值
+	DefaultCORSMaxAge           = 600 // 預檢結果的快取時間（秒）
+	DefaultCORSMaxAgeLimit      = 86400
+	DefaultCORSAllowCredentials = false
+
 	// 訊息處理設定預設值
 	DefaultHistoryLimit       = 50
+	DefaultMaxHistoryLimit    = 1000 // 執行期設定可調整的歷史訊息數量上限
 	DefaultClientSendBuffer   = 256
 	DefaultHubBroadcastBuffer = 256
 
 	// 預設使用者和類型
 	DefaultUsername    = "Anonymous"
-	DefaultAPIUser     = "Web User"
 	DefaultMessageType = "text"
 
 	// 訊息類型
@@ -50,19 +66,383 @@ const (
 	MessageTypeSystem = "system"
 	MessageTypeImage  = "image"
 	MessageTypeFile   = "file"
+	MessageTypePoll   = "poll"
+	MessageTypeAction = "action" // /me 指令產生的動作訊息
+
+	// 事件類型（僅即時推送，不寫入歷史）
+	MessageTypePresence     = "presence"
+	MessageTypeError        = "error"
+	MessageTypeMention      = "mention"
+	MessageTypePin          = "pin"
+	MessageTypeDelete       = "delete"
+	MessageTypeRestore      = "restore"
+	MessageTypePollUpdate   = "poll_update"
+	MessageTypeCommandReply = "command_reply"
+	MessageTypeInvite       = "invite"
+
+	// 客戶端指令類型（處理後不寫入歷史）
+	MessageTypeVote = "vote"
+
+	// 指令設定
+	CommandPrefix          = "/"
+	DefaultNickMaxLength   = 32
+	DefaultTopicMaxLength  = 200
+	DefaultMuteDuration    = 10          // 分鐘，/mute 未指定時長時的預設值
+	DefaultMaxMuteDuration = 7 * 24 * 60 // 分鐘，/mute 時長上限
+
+	// 封鎖與踢出設定
+	DefaultMaxBanDuration          = 365 * 24 * 60 // 分鐘，限時封鎖的時長上限，0 表示永久封鎖
+	DefaultSanctionReasonMaxLength = 200           // 踢出與封鎖原因的最大字數
+	DefaultSanctionExpiryInterval  = 30            // 秒，檢查到期禁言與封鎖的間隔
+	BanScopeServer                 = ""            // 全伺服器封鎖使用的頻道鍵
+
+	// 角色與權限設定（角色由低到高：guest < member < moderator < admin < owner）
+	RoleOwner               = "owner"
+	RoleAdmin               = "admin"
+	RoleModerator           = "moderator"
+	RoleMember              = "member"
+	RoleGuest               = "guest"
+	RoleScopeServer         = ""                 // 伺服器範圍角色使用的頻道鍵
+	PermissionReadChannel   = "channel.read"     // 讀取頻道訊息
+	PermissionSendMessage   = "message.send"     // 發送訊息與投票
+	PermissionModerate      = "message.moderate" // 審核訊息、處理檢舉、管理他人的釘選與投票
+	PermissionSanction      = "user.sanction"    // 禁言、踢出與封鎖用戶
+	PermissionManageChannel = "channel.manage"   // 修改頻道主題與慢速模式
+	PermissionManageRoles   = "role.manage"      // 授予與撤銷較低的角色
+	PermissionAdminister    = "server.admin"     // 使用 /api/admin 管理 API（僅伺服器範圍）
+
+	// 稽核日誌設定
+	DefaultAuditLogPath       = "./audit/audit.jsonl" // 稽核日誌檔案，每行一筆 JSON
+	DefaultAuditMemoryLimit   = 10000                 // 記憶體保留的最近紀錄數，較舊的紀錄只在日誌檔案中
+	DefaultAuditQueryLimit    = 50
+	DefaultAuditQueryMaxLimit = 500
+	AuditOutcomeSuccess       = "success"
+	AuditOutcomeFailure       = "failure" // 憑證錯誤或請求不合法
+	AuditOutcomeDenied        = "denied"  // 權限不足、被封鎖或觸發速率限制
+	AuditActionLogin          = "login"
+	AuditActionConnect        = "connect"
+	AuditActionMute           = "mute"
+	AuditActionUnmute         = "unmute"
+	AuditActionKick           = "kick"
+	AuditActionBan            = "ban"
+	AuditActionUnban          = "unban"
+	AuditActionRoleGrant      = "role.grant"
+	AuditActionRoleRevoke     = "role.revoke"
+	AuditActionFlagPrefix     = "flag."   // 加上審核動作，例如 flag.remove
+	AuditActionReportPrefix   = "report." // 加上處理動作，例如 report.resolve
+	AuditActionDisconnect     = "admin.disconnect"
+	AuditActionClearChannel   = "admin.clear"
+	AuditActionBroadcast      = "admin.broadcast"
+	AuditActionUpdateConfig   = "admin.config"
+	AuditActionConfigReload   = "config.reload"
+	AuditActorSystem          = "system" // 非由用戶觸發的動作，例如 SIGHUP 重新載入設定
+
+	// 訊息格式設定
+	EntityTypeBold         = "bold"
+	EntityTypeItalic       = "italic"
+	EntityTypeCode         = "code"
+	EntityTypePre          = "pre"
+	EntityTypeLink         = "link"
+	EntityTypeMention      = "mention"
+	MarkdownEscapableChars = "\\`*_[]()" // 可用反斜線跳脫的標記符號
+
+	// 內容審核設定
+	DefaultMaxMessageLength          = 2000 // 字元，訊息內容上限
+	DefaultModerationMaxRepeat       = 10   // 同一字元最多連續出現的次數
+	DefaultModerationMaxDuplicates   = 2    // 最多連續發送相同內容的次數
+	DefaultModerationDuplicateWindow = 30   // 秒，判斷重複內容的時間範圍
+	DefaultModerationMaxLinks        = 5    // 超過此連結數量的訊息標記待審核
+	ModerationActionAllow            = "allow"
+	ModerationActionModify           = "modify"
+	ModerationActionReject           = "reject"
+	ModerationActionFlag             = "flag"
+	ModerationFilterFlood            = "flood"
+	ModerationFilterMaxLength        = "max_length"
+	ModerationFilterLinks            = "links"
+	ModerationFilterProfanity        = "profanity"
+	ModerationFilterWatchlist        = "watchlist"
+	FlagStatusPending                = "pending"
+	FlagStatusApproved               = "approved"
+	FlagStatusRemoved                = "removed"
+	FlagReviewApprove                = "approve"
+	FlagReviewRemove                 = "remove"
+
+	// 用戶檢舉設定
+	DefaultReportHideThreshold    = 3   // 不同用戶檢舉達到此數量時自動隱藏訊息
+	DefaultReportDetailsMaxLength = 500 // 檢舉說明的最大字數
+	ReportReasonSpam              = "spam"
+	ReportReasonHarassment        = "harassment"
+	ReportReasonHate              = "hate"
+	ReportReasonViolence          = "violence"
+	ReportReasonSexual            = "sexual"
+	ReportReasonMisinformation    = "misinformation"
+	ReportReasonOther             = "other" // 必須附上說明
+	ReportStatusOpen              = "open"
+	ReportStatusClaimed           = "claimed"
+	ReportStatusResolved          = "resolved"
+	ReportStatusDismissed         = "dismissed"
+	ReportActionHidden            = "hidden"
+	ReportActionClaimed           = "claimed"
+	ReportActionResolved          = "resolved"
+	ReportActionDismissed         = "dismissed"
+
+	// 速率限制設定（各項限制見 DefaultRateLimits）
+	DefaultRateLimitPruneInterval = 60 // 秒，清除閒置令牌桶的間隔
+	RateLimitScopeMessageUser     = "message_user"
+	RateLimitScopeMessageChannel  = "message_channel"
+	RateLimitScopeLoginIP         = "login_ip"
+	RateLimitScopeLoginUser       = "login_user"
+	RateLimitScopeWebSocketIP     = "websocket_ip"
+	RateLimitScopeSlowMode        = "slow_mode"
+
+	// 慢速模式設定
+	DefaultMaxSlowMode = 6 * 60 * 60 // 秒，慢速模式間隔上限
+
+	// 指標名稱
+	MetricRateLimitedPrefix = "rate_limited." // 加上限制範圍，例如 rate_limited.message_user
+	MetricLoginFailures     = "login_failures"
+	MetricLoginLockouts     = "login_lockouts"
+	MetricMessagesReported  = "messages_reported"
+	MetricMessagesHidden    = "messages_hidden"
+
+	// 提及設定
+	MentionTypeUser          = "user"
+	MentionTypeChannel       = "channel"
+	MentionChannelKeyword    = "channel" // @channel 提及頻道內所有成員
+	DefaultMentionInboxLimit = 200       // 每位用戶保存的提及數量
+
+	// 在線狀態設定預設值
+	DefaultPresenceAwayTimeout      = 300 // 秒，無活動多久後標記為 away
+	DefaultPresenceSweepInterval    = 30  // 秒，閒置檢查間隔
+	DefaultPresenceSubscriberBuffer = 64
+	DefaultPresenceGracePeriod      = 10  // 秒，斷線後延遲發送離開訊息的寬限期
+	DefaultMaxPresenceGracePeriod   = 300 // 秒，伺服器設定可調整的寬限期上限
+	DefaultPersistPresenceMessages  = true
+
+	// 上傳設定預設值
+	DefaultUploadDir     = "./uploads"
+	DefaultUploadMaxSize = 10 << 20 // 10 MB
+
+	// 分段上傳設定預設值
+	DefaultUploadSessionDir        = "./uploads/sessions"
+	DefaultChunkedUploadMaxSize    = 1 << 30      // 1 GB
+	DefaultUploadChunkMaxSize      = 8 << 20      // 8 MB，單一分段上限
+	DefaultUploadSessionTTL        = 24 * 60 * 60 // 秒，閒置多久後回收
+	DefaultUploadSessionGCInterval = 10 * 60      // 秒，回收檢查間隔
+
+	// 縮圖設定預設值
+	DefaultThumbnailMaxPixels   = 40_000_000 // 超過此像素數的圖片不產生縮圖
+	DefaultThumbnailJPEGQuality = 80
+	DefaultBlurHashComponentsX  = 4
+	DefaultBlurHashComponentsY  = 3
+
+	// 釘選設定
+	DefaultPinLimit = 50 // 每個頻道最多釘選的訊息數
+	PinActionPin    = "pin"
+	PinActionUnpin  = "unpin"
+
+	// 訊息存活時間設定
+	DefaultMaxMessageTTL         = 7 * 24 * 60 * 60 // 秒，訊息存活時間上限
+	DefaultMessageExpiryInterval = 1                // 秒，檢查過期訊息的間隔
+	DeletionReasonExpired        = "expired"
+	DeletionReasonModerated      = "moderated"
+	DeletionReasonReported       = "reported"
+	DeletionReasonCleared        = "cleared"
+
+	// 投票設定預設值
+	DefaultPollMinOptions    = 2
+	DefaultPollMaxOptions    = 10
+	DefaultPollCloseInterval = 1 // 秒，檢查到期投票的間隔
+
+	// 排程訊息設定預設值
+	DefaultScheduleDir          = "./scheduled"
+	DefaultScheduleTickInterval = 1 // 秒，檢查到期排程的間隔
+
+	// 搜尋設定預設值
+	DefaultSearchLimit    = 20
+	DefaultSearchMaxLimit = 100
+
+	// 在線狀態
+	PresenceStatusOnline  = "online"
+	PresenceStatusAway    = "away"
+	PresenceStatusOffline = "offline"
 
 	// HTTP 回應訊息
-	StatusSent = "sent"
+	StatusSent            = "sent"
+	StatusCommandExecuted = "executed"
 
 	// 錯誤訊息
 	ErrorInvalidJSON     = "Invalid JSON"
 	ErrorChannelRequired = "channel is required"
 	ErrorInvalidAuth     = "Invalid username or password"
 
+	ErrorUploadEmpty             = "uploaded file is empty"
+	ErrorUploadTooLarge          = "uploaded file is too large"
+	ErrorUploadTypeNotAllowed    = "file type is not allowed"
+	ErrorUploadNotFound          = "upload not found"
+	ErrorUploadFieldRequired     = "multipart field 'file' is required"
+	ErrorAttachmentRequired      = "attachment id is required for image and file messages"
+	ErrorUploadSessionNotFound   = "upload session not found"
+	ErrorUploadSessionBusy       = "upload session is being written"
+	ErrorUploadOffsetMismatch    = "upload offset does not match"
+	ErrorUploadChunkOverflow     = "chunk exceeds declared upload size"
+	ErrorUploadIncomplete        = "upload is incomplete"
+	ErrorUploadChecksumRequired  = "checksum is required"
+	ErrorUploadChecksumMismatch  = "checksum does not match uploaded content"
+	ErrorUploadInvalidSize       = "size must be greater than zero"
+	ErrorUploadInvalidOffset     = "Upload-Offset header is required"
+	ErrorAttachmentNotImage      = "attachment is not an image"
+	ErrorSearchQueryRequired     = "q is required"
+	ErrorSearchInvalidDate       = "from and to must be RFC 3339 timestamps or YYYY-MM-DD dates"
+	ErrorInvalidPaging           = "limit and offset must be non-negative integers"
+	ErrorChannelForbidden        = "channel is not readable by this account"
+	ErrorMessageNotFound         = "message not found"
+	ErrorScheduleNotFound        = "scheduled message not found"
+	ErrorScheduleContentRequired = "content is required"
+	ErrorScheduleSendAtInPast    = "sendAt must be in the future"
+	ErrorInvalidMessageTTL       = "ttl must be between 0 and 604800 seconds"
+	ErrorPollRequired            = "poll is required for poll messages"
+	ErrorPollQuestionRequired    = "poll question is required"
+	ErrorPollInvalidOptions      = "poll needs 2 to 10 distinct non-empty options"
+	ErrorPollInvalidCloseTime    = "poll closesAt must be in the future"
+	ErrorPollNotFound            = "poll not found"
+	ErrorPollClosed              = "poll is closed"
+	ErrorPollInvalidVote         = "invalid poll options selected"
+	ErrorPollCloseNotPermitted   = "only the poll author or a moderator can close the poll"
+	ErrorPinNotFound             = "message is not pinned"
+	ErrorPinLimitReached         = "channel has reached the pin limit"
+	ErrorPinNotPermitted         = "only moderators can pin other users' messages or remove other users' pins"
+	ErrorUnknownCommand          = "unknown command"
+	ErrorCommandUsage            = "invalid command arguments"
+	ErrorCommandForbidden        = "only moderators can use this command"
+	ErrorUserNotFound            = "user not found"
+	ErrorInvalidNick             = "nick must be 1 to 32 characters without spaces and must not be another user's name"
+	ErrorInvalidTopic            = "topic must be at most 200 characters"
+	ErrorUserMuted               = "you are muted in this channel"
+	ErrorMessageRejected         = "message rejected"
+	ErrorFlagNotFound            = "flagged message not found"
+	ErrorFlagReviewed            = "flagged message has already been reviewed"
+	ErrorInvalidFlagReview       = "action must be approve or remove"
+	ErrorModerationForbidden     = "only moderators can review flagged messages"
+	ErrorRateLimited             = "rate limit exceeded"
+	ErrorInvalidSlowMode         = "slowMode must be between 0 and 21600 seconds"
+	ErrorSlowModeNotPermitted    = "only moderators can change slow mode"
+	ErrorChannelSettingsDenied   = "only moderators can change channel settings"
+	ErrorUserBanned              = "you are banned from this channel"
+	ErrorUserBannedFromServer    = "you are banned from this server"
+	ErrorBanNotFound             = "user is not banned"
+	ErrorMuteNotFound            = "user is not muted"
+	ErrorSanctionForbidden       = "only moderators can mute, kick or ban users"
+	ErrorInvalidSanctionTarget   = "moderators cannot mute, kick or ban themselves"
+	ErrorInvalidMuteDuration     = "minutes must be between 1 and 10080"
+	ErrorInvalidBanDuration      = "minutes must be between 0 and 525600"
+	ErrorInvalidSanctionReason   = "reason must be at most 200 characters"
+	ErrorUserNotConnected        = "user is not connected to this channel"
+	ErrorUserKicked              = "you have been kicked from this channel"
+	ErrorInvalidReportReason     = "reason must be one of spam, harassment, hate, violence, sexual, misinformation, other"
+	ErrorReportDetailsRequired   = "details are required when reason is other"
+	ErrorReportDetailsTooLong    = "details must be at most 500 characters"
+	ErrorCannotReport            = "system messages and your own messages cannot be reported"
+	ErrorAlreadyReported         = "you have already reported this message"
+	ErrorReportNotFound          = "report not found"
+	ErrorReportClosed            = "report has already been resolved or dismissed"
+	ErrorReportClaimed           = "report is claimed by another moderator"
+	ErrorInvalidRole             = "role must be one of owner, admin, moderator, member, guest"
+	ErrorRoleForbidden           = "you can only grant or revoke roles below your own"
+	ErrorRoleNotFound            = "user has no role in this scope"
+	ErrorInvalidRoleTarget       = "you cannot change your own role"
+	ErrorSendForbidden           = "your role cannot send messages in this channel"
+	ErrorServerOnlyMessageType   = "this message type can only be sent by the server"
+	ErrorAdminForbidden          = "only server admins can use the admin API"
+	ErrorConnectionNotFound      = "connection not found"
+	ErrorBroadcastRequired       = "content is required"
+	ErrorInvalidHistoryLimit     = "historyLimit must be between 1 and 1000"
+	ErrorInvalidHideThreshold    = "reportHideThreshold must not be negative"
+	ErrorInvalidRateLimits       = "rate limits must not be negative"
+	ErrorForceDisconnected       = "you have been disconnected by an administrator"
+	ErrorAuditChainBroken        = "audit log hash chain is broken at entry %d"
+	ErrorConfigFile              = "config file %s: %v"
+	ErrorConfigEnv               = "environment variable %s: %v"
+	ErrorConfigFlag              = "flag --%s: %v"
+	ErrorConfigInvalid           = "invalid configuration:\n%v"
+	ErrorConfigRange             = "%s must be between %d and %d (got %d)"
+	ErrorConfigPositive          = "%s must be positive (got %d)"
+	ErrorConfigNonNegative       = "%s must not be negative (got %d)"
+	ErrorConfigRequired          = "%s is required"
+	ErrorConfigAccountFormat     = "accounts must be comma-separated username:password:channel entries (got %q)"
+	ErrorConfigAccountIncomplete = "account %d must have a username, password and channel"
+	ErrorConfigAccountDuplicate  = "account %q is defined more than once"
+	ErrorConfigRoleFormat        = "roles must be comma-separated username:role or username:role:channel entries (got %q)"
+	ErrorConfigRoleInvalid       = "role %d must be one of owner, admin, moderator, member, guest (got %q)"
+	ErrorConfigRoleUsername      = "role %d must have a username"
+	ErrorConfigTLSPair           = "tls.certFile and tls.keyFile must be set together"
+	ErrorConfigCORSCredentials   = "cors.allowCredentials cannot be used with allowAllOrigins; set allowAllOrigins to false and list the trusted origins in allowedOrigins"
+	ErrorConfigTLSConflict       = "tls.dev cannot be combined with tls.certFile"
+	ErrorConfigTLSRedirect       = "tls.redirectPort requires tls.certFile or tls.dev"
+	ErrorConfigTLSRedirectPort   = "tls.redirectPort must differ from port (got %d)"
+	ErrorTLSCertificate          = "load TLS certificate: %w"
+	ErrorTLSDevCertificate       = "create development certificate in %s: %w"
+	ErrorCORSOriginForbidden     = "origin is not allowed"
+	ErrorCORSMethodForbidden     = "method is not allowed for cross-origin requests"
+
+	// 錯誤事件代碼（WebSocket error 事件的 code 欄位）
+	ErrorCodeInvalidAttachment = "invalid_attachment"
+	ErrorCodeInvalidTTL        = "invalid_ttl"
+	ErrorCodeInvalidPoll       = "invalid_poll"
+	ErrorCodeInvalidVote       = "invalid_vote"
+	ErrorCodeUnknownCommand    = "unknown_command"
+	ErrorCodeInvalidCommand    = "invalid_command"
+	ErrorCodeCommandForbidden  = "command_forbidden"
+	ErrorCodeMuted             = "muted"
+	ErrorCodeMessageRejected   = "message_rejected"
+	ErrorCodeRateLimited       = "rate_limited"
+	ErrorCodeSlowMode          = "slow_mode"
+	ErrorCodeBanned            = "banned"
+	ErrorCodeKicked            = "kicked"
+	ErrorCodeSendForbidden     = "send_forbidden"
+	ErrorCodeInvalidType       = "invalid_message_type"
+	ErrorCodeDisconnected      = "disconnected"
+
+	// 內容審核原因
+	ModerationReasonTooLong      = "message exceeds %d characters"
+	ModerationReasonBlockedLink  = "links to %s are not allowed"
+	ModerationReasonTooManyLinks = "message contains %d links"
+	ModerationReasonDuplicate    = "duplicate message sent too many times"
+	ModerationReasonWatchedWord  = "contains watched words: %s"
+
 	// 系統訊息模板
-	SystemMessageJoinTemplate  = "%s 加入了 %s 頻道"
-	SystemMessageLeaveTemplate = "%s 離開了 %s 頻道"
-	WelcomeMessageTemplate     = "歡迎來到 %s 頻道！開始你的第一條消息吧 👋"
+	SystemMessageJoinTemplate               = "%s 加入了 %s 頻道"
+	SystemMessageLeaveTemplate              = "%s 離開了 %s 頻道"
+	WelcomeMessageTemplate                  = "歡迎來到 %s 頻道！開始你的第一條消息吧 👋"
+	SystemMessagePinTemplate                = "%s 釘選了一則訊息"
+	SystemMessageUnpinTemplate              = "%s 取消釘選了一則訊息"
+	SystemMessageTopicTemplate              = "%s 將頻道主題設為：%s"
+	SystemMessageNickTemplate               = "%s 將暱稱改為 %s"
+	SystemMessageNickClearedTemplate        = "%s 清除了暱稱"
+	SystemMessageMuteTemplate               = "%s 已被 %s 禁言 %d 分鐘"
+	SystemMessageSlowModeOnTemplate         = "%s 開啟了慢速模式，每 %d 秒只能發送一則訊息"
+	SystemMessageSlowModeOffTemplate        = "%s 關閉了慢速模式"
+	SystemMessageUnmuteTemplate             = "%s 已被 %s 解除禁言"
+	SystemMessageMuteExpiredTemplate        = "%s 的禁言已到期"
+	SystemMessageKickTemplate               = "%s 已被 %s 踢出頻道"
+	SystemMessageBanTemplate                = "%s 已被 %s 封鎖 %d 分鐘"
+	SystemMessagePermanentBanTemplate       = "%s 已被 %s 永久封鎖"
+	SystemMessageServerBanTemplate          = "%s 已被 %s 從伺服器封鎖 %d 分鐘"
+	SystemMessagePermanentServerBanTemplate = "%s 已被 %s 從伺服器永久封鎖"
+	SystemMessageUnbanTemplate              = "%s 已被 %s 解除封鎖"
+	SystemMessageBanExpiredTemplate         = "%s 的封鎖已到期"
+	SystemMessageReasonTemplate             = "%s（原因：%s）"
+	SystemMessageChannelClearedTemplate     = "頻道訊息已由管理員 %s 清空"
+	SystemMessageServerBroadcastTemplate    = "📢 伺服器公告：%s"
+
+	// 指令回覆模板（只回覆給執行者）
+	CommandReplyTopicTemplate  = "目前的頻道主題：%s"
+	CommandReplyNoTopic        = "此頻道尚未設定主題"
+	CommandReplyInviteTemplate = "已邀請 %s 加入 %s 頻道"
+	CommandReplyHelpTemplate   = "%s - %s"
+	CommandReplySlowMode       = "慢速模式：每 %d 秒只能發送一則訊息"
+	CommandReplyNoSlowMode     = "此頻道未開啟慢速模式"
+	InviteMessageTemplate      = "%s 邀請你加入 %s 頻道"
 
 	// 日誌訊息模板
 	LogWebSocketUpgradeError = "WebSocket upgrade error: %v"
@@ -72,6 +452,7 @@ const (
 	LogReadJSONError         = "ReadJSON error: %v"
 	LogWriteJSONError        = "WriteJSON error: %v"
 	LogClientRemoved         = "客戶端 %s 發送失敗，已移除"
+	LogReconnectCoalesced    = "User %s reconnected to channel %s within grace period"
 
 	LogAPIMessageReceived = "收到 GET /api/messages 請求，channel: %s"
 	LogAPIMessagePost     = "收到 POST /api/messages 請求"
@@ -82,8 +463,65 @@ const (
 	LogBroadcastComplete  = "廣播完成，共發送給 %d 個客戶端"
 	LogBroadcastToChannel = "廣播訊息到頻道 %s: %s 說 '%s'"
 	LogMessageSentToUser  = "訊息已發送給用戶 %s (頻道: %s)"
+
+	LogChannelSettingsUpdated  = "頻道 %s 設定已更新: %+v"
+	LogUploadSaved             = "用戶 %s 上傳檔案 %s (%s, %d bytes)"
+	LogUploadError             = "上傳失敗: %v"
+	LogUploadMetadataError     = "讀取上傳中繼資料 %s 失敗: %v"
+	LogThumbnailError          = "產生縮圖 %s 失敗: %v"
+	LogUploadSessionCreated    = "用戶 %s 建立分段上傳 %s (%s, %d bytes)"
+	LogUploadSessionsCollected = "已回收 %d 個過期的分段上傳"
+	LogMessageRejected         = "用戶 %s 的訊息被拒絕: %v"
+	LogSearchExecuted          = "用戶 %s 搜尋 '%s'，共 %d 筆結果"
+	LogMentionNotified         = "通知用戶 %s：%s 在頻道 %s 提及了你"
+	LogPinChanged              = "用戶 %s %s 訊息 %s (頻道: %s)"
+	LogScheduleCreated         = "用戶 %s 排程訊息 %s 於 %s 發送到頻道 %s"
+	LogScheduleDelivered       = "排程訊息 %s 已發送 (用戶: %s, 頻道: %s)"
+	LogScheduleLoadError       = "讀取排程 %s 失敗: %v"
+	LogMessagesExpired         = "已刪除 %d 條過期訊息"
+	LogPollVoted               = "用戶 %s 在投票 %s 投票: %v"
+	LogPollClosed              = "投票 %s 已結束 (頻道: %s)"
+	LogCommandExecuted         = "用戶 %s 在頻道 %s 執行指令 /%s"
+	LogCommandFailed           = "用戶 %s 執行指令 /%s 失敗: %v"
+	LogMessageFlagged          = "訊息 %s (用戶: %s) 被標記待審核: %s"
+	LogFlagReviewed            = "用戶 %s 審核訊息 %s: %s"
+	LogScheduleRejected        = "排程訊息 %s 發送失敗: %v"
+	LogRateLimited             = "%s 觸發速率限制: %v"
+	LogLoginLocked             = "帳號 %s 登入失敗次數過多，暫時鎖定"
+	LogRequestAuthLocked       = "來源 %s 驗證帳號 %s 失敗次數過多，暫時鎖定"
+	LogUserKicked              = "用戶 %s 被 %s 踢出頻道 %s，共關閉 %d 個連接"
+	LogUserBanned              = "用戶 %s 被 %s 封鎖 (頻道: %q, 到期: %v)"
+	LogUserUnbanned            = "用戶 %s 被 %s 解除封鎖 (頻道: %q)"
+	LogSanctionsExpired        = "已解除 %d 個到期的禁言與封鎖"
+	LogMessageReported         = "用戶 %s 檢舉訊息 %s (原因: %s)，共 %d 位用戶檢舉"
+	LogReportedMessageHidden   = "訊息 %s 檢舉數達到 %d，已自動隱藏"
+	LogReportDecision          = "用戶 %s 處理檢舉 %s: %s"
+	LogBannedConnection        = "已封鎖的用戶 %s 嘗試連接頻道 %s"
+	LogRoleGranted             = "用戶 %s 被 %s 授予角色 %s (頻道: %q)"
+	LogRoleRevoked             = "用戶 %s 被 %s 撤銷角色 %s (頻道: %q)"
+	LogAdminDisconnect         = "管理員 %s 強制中斷連接 %s (%s 在頻道 %s)"
+	LogChannelCleared          = "管理員 %s 清空頻道 %s，共刪除 %d 則訊息"
+	LogServerBroadcast         = "管理員 %s 發送伺服器公告到 %d 個頻道"
+	LogRuntimeConfigUpdated    = "管理員 %s 更新執行期設定: %+v"
+	LogAuditLoadError          = "無法載入稽核日誌 %s: %v"
+	LogAuditWriteError         = "無法寫入稽核日誌 %s: %v"
+	LogAuditReadError          = "無法讀取稽核日誌 %s: %v"
+	LogConfigLoaded            = "已載入設定: 埠 %d，%d 個帳號"
+	LogConfigReloaded          = "已重新載入設定 (%s): 版本 %d，雜湊 %s"
+	LogConfigReloadFailed      = "重新載入設定失敗 (%s)，維持版本 %d: %v"
+	LogConfigRestartRequired   = "設定 %s 已修改，需要重新啟動才會生效"
+	LogConfigWatchError        = "無法檢查設定檔 %s: %v"
+	LogTLSDevCertGenerated     = "已產生自簽開發憑證 %s (%s)，SHA-256 指紋 %s"
+	LogTLSDevCertLoaded        = "使用快取的自簽開發憑證 %s (%s)，SHA-256 指紋 %s"
+	LogTLSRedirectListening    = "HTTP 轉址監聽在 :%d，轉到 HTTPS 埠 %d"
 )
 
+// ServerOnlyMessageTypes 只能由伺服器產生的訊息類型，客戶端發送時拒絕
+var ServerOnlyMessageTypes = []string{
+	MessageTypeSystem, MessageTypeAction, MessageTypePresence, MessageTypeError, MessageTypeMention,
+	MessageTypePin, MessageTypeDelete, MessageTypeRestore, MessageTypePollUpdate, MessageTypeCommandReply, MessageTypeInvite,
+}
+
 // 預設測試帳號
 var DefaultTestAccounts = []Account{
 	{Username: "alice", Password: "password123", Channel: "general"},
@@ -91,24 +529,145 @@ var DefaultTestAccounts = []Account{
 	{Username: "charlie", Password: "password123", Channel: "random"},
 }
 
+// DefaultRoleGrants 設定的 roles 預設值，帳號在所屬頻道預設為 member
+var DefaultRoleGrants = []RoleGrant{
+	{Channel: "general", Username: "alice", Role: RoleOwner},
+}
+
+// DefaultRoles 所有角色，由低到高排列
+var DefaultRoles = []string{RoleGuest, RoleMember, RoleModerator, RoleAdmin, RoleOwner}
+
+// DefaultRolePermissions 角色的權限矩陣
+var DefaultRolePermissions = map[string][]string{
+	RoleGuest:     {PermissionReadChannel},
+	RoleMember:    {PermissionReadChannel, PermissionSendMessage},
+	RoleModerator: {PermissionReadChannel, PermissionSendMessage, PermissionModerate, PermissionSanction, PermissionManageChannel},
+	RoleAdmin:     {PermissionReadChannel, PermissionSendMessage, PermissionModerate, PermissionSanction, PermissionManageChannel, PermissionManageRoles, PermissionAdminister},
+	RoleOwner:     {PermissionReadChannel, PermissionSendMessage, PermissionModerate, PermissionSanction, PermissionManageChannel, PermissionManageRoles, PermissionAdminister},
+}
+
+// DefaultCORSAllowedMethods 跨域請求允許的方法
+var DefaultCORSAllowedMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// DefaultCORSAllowedHeaders 跨域請求允許的標頭（Upload-Offset 用於分段上傳）
+var DefaultCORSAllowedHeaders = []string{"Content-Type", "Authorization", "Upload-Offset"}
+
+// DefaultCORSExposedHeaders 跨域回應中允許客戶端讀取的標頭
+var DefaultCORSExposedHeaders = []string{"Upload-Offset", "Upload-Length", "Retry-After"}
+
+// DefaultUploadAllowedMIMETypes 允許上傳的 MIME 類型（由檔案內容偵測）
+var DefaultUploadAllowedMIMETypes = []string{
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"image/webp",
+	"application/pdf",
+	"application/zip",
+	"text/plain",
+}
+
+// DefaultAllowedLinkSchemes 訊息中可以建立連結的網址協定
+var DefaultAllowedLinkSchemes = []string{"http", "https", "mailto"}
+
+// DefaultRateLimits 預設的速率限制
+var DefaultRateLimits = RateLimitConfig{
+	MessagePerUser:     RateLimit{Rate: 1, Burst: 10},
+	MessagePerChannel:  RateLimit{Rate: 20, Burst: 60},
+	LoginPerIP:         RateLimit{Rate: 0.2, Burst: 10},
+	WebSocketPerIP:     RateLimit{Rate: 0.5, Burst: 20},
+	LoginMaxFailures:   5,
+	LoginFailureWindow: 15 * 60,
+	LoginLockout:       5 * 60,
+}
+
+// DefaultModerationBlockedDomains 禁止在訊息中出現的連結網域（短網址會隱藏真正的目的地）
+var DefaultModerationBlockedDomains = []string{"bit.ly", "tinyurl.com", "t.co"}
+
+// DefaultModerationMaskedWords 以 * 遮蔽的詞彙
+var DefaultModerationMaskedWords = []string{"fuck", "shit", "bitch", "幹你娘"}
+
+// DefaultModerationFlaggedWords 需要人工審核的詞彙
+var DefaultModerationFlaggedWords = []string{"free money", "保證獲利", "加賴"}
+
+// DefaultReportReasons 用戶檢舉可選擇的原因
+var DefaultReportReasons = []string{
+	ReportReasonSpam,
+	ReportReasonHarassment,
+	ReportReasonHate,
+	ReportReasonViolence,
+	ReportReasonSexual,
+	ReportReasonMisinformation,
+	ReportReasonOther,
+}
+
+// DefaultThumbnailSizes 產生的縮圖長邊尺寸
+var DefaultThumbnailSizes = []int{160, 480}
+
 // 啟動訊息模板
-const DefaultStartupBanner = `🚀 服務器啟動在 http://%s:%d
-📱 手機端可連接: http://你的內網IP:%d
-💻 WebSocket 端點: ws://%s:%d/ws?username=帳號&password=密碼
+const DefaultStartupBanner = `🚀 服務器啟動在 %s://%s:%d
+📱 手機端可連接: %s://%s:%d
+💻 WebSocket 端點: %s://%s:%d/ws?username=帳號&password=密碼
 📡 API 端點:
    GET  /api/messages?channel=頻道 - 獲取指定頻道的歷史消息
    POST /api/messages - 發送消息
    GET  /api/users - 獲取按頻道分組的在線用戶
+   GET  /api/presence?users=用戶1,用戶2 - 獲取用戶在線狀態
+   GET  /api/channels/{channel}/presence - 獲取頻道在線狀態
+   GET  /api/channels/{channel}/settings - 獲取頻道設定
+   GET  /api/channels/{channel}/pins - 獲取頻道釘選訊息（需驗證）
+   PUT  /api/channels/{channel}/pins/{id} - 釘選訊息（需驗證）
+   DELETE /api/channels/{channel}/pins/{id} - 取消釘選訊息（需驗證）
+   PUT  /api/channels/{channel}/settings - 修改頻道設定
+   GET  /api/search?q=關鍵字 - 搜尋可讀取頻道的訊息（需驗證）
+   GET  /api/mentions - 獲取提及自己的訊息（需驗證）
+   GET  /api/polls/{id} - 獲取投票結果（需驗證）
+   POST /api/polls/{id}/votes - 投票（需驗證）
+   POST /api/polls/{id}/close - 結束投票（需驗證）
+   POST /api/scheduled - 排程訊息（需驗證）
+   GET  /api/scheduled - 獲取自己的排程訊息（需驗證）
+   PUT  /api/scheduled/{id} - 修改排程訊息（需驗證）
+   DELETE /api/scheduled/{id} - 取消排程訊息（需驗證）
+   POST /api/messages/{id}/report - 檢舉訊息（需驗證）
+   GET  /api/moderation/flags - 獲取待審核的訊息（需管理員）
+   POST /api/moderation/flags/{id} - 審核被標記的訊息（需管理員）
+   GET  /api/moderation/reports - 獲取檢舉佇列（需管理員）
+   GET  /api/moderation/reports/{id} - 獲取檢舉與處理紀錄（需管理員）
+   POST /api/moderation/reports/{id}/claim - 認領檢舉（需管理員）
+   POST /api/moderation/reports/{id}/resolve - 確認檢舉並刪除訊息（需管理員）
+   POST /api/moderation/reports/{id}/dismiss - 駁回檢舉並恢復訊息（需管理員）
+   GET  /api/metrics - 獲取伺服器運行指標
+   POST /api/channels/{channel}/mutes - 禁言用戶（需管理員）
+   DELETE /api/channels/{channel}/mutes/{username} - 解除禁言（需管理員）
+   POST /api/channels/{channel}/kicks - 踢出已連接的用戶（需管理員）
+   GET  /api/channels/{channel}/bans - 獲取頻道封鎖名單（需管理員）
+   POST /api/channels/{channel}/bans - 在頻道中封鎖用戶（需管理員）
+   DELETE /api/channels/{channel}/bans/{username} - 解除頻道封鎖（需管理員）
+   GET  /api/bans - 獲取伺服器封鎖名單（需管理員）
+   POST /api/bans - 從伺服器封鎖用戶（需管理員）
+   DELETE /api/bans/{username} - 解除伺服器封鎖（需管理員）
+   GET  /api/roles - 獲取伺服器範圍的角色（需驗證）
+   PUT  /api/roles/{username} - 授予伺服器範圍的角色（需 admin 以上）
+   DELETE /api/roles/{username} - 撤銷伺服器範圍的角色（需 admin 以上）
+   GET  /api/channels/{channel}/roles - 獲取頻道角色（需驗證）
+   PUT  /api/channels/{channel}/roles/{username} - 授予頻道角色（需 admin 以上）
+   DELETE /api/channels/{channel}/roles/{username} - 撤銷頻道角色（需 admin 以上）
+   GET  /api/admin/connections - 列出目前的 WebSocket 連接（需伺服器 admin）
+   DELETE /api/admin/connections/{id} - 強制中斷連接（需伺服器 admin）
+   POST /api/admin/channels/{channel}/clear - 清空頻道訊息（需伺服器 admin）
+   POST /api/admin/broadcast - 發送伺服器公告（需伺服器 admin）
+   GET  /api/admin/config - 獲取執行期設定（需伺服器 admin）
+   PATCH /api/admin/config - 修改執行期設定（需伺服器 admin）
+   GET  /api/admin/config/version - 獲取生效中的設定版本（需伺服器 admin）
+   GET  /api/admin/audit - 查詢稽核日誌（需伺服器 admin）
+   GET  /api/admin/audit/export - 匯出稽核日誌為 JSON lines（需伺服器 admin）
+   GET  /api/admin/audit/verify - 驗證稽核日誌的雜湊鏈（需伺服器 admin）
    GET  /api/accounts - 獲取可用的測試帳號
    POST /api/login - 驗證帳號登入
+   POST /api/uploads - 上傳圖片或檔案（需驗證）
+   GET  /api/uploads/{id} - 下載上傳的檔案（需驗證）
+   GET  /api/uploads/{id}/thumbnails/{size} - 下載圖片縮圖（需驗證）
+   POST /api/uploads/sessions - 建立分段上傳（需驗證）
 
 🧪 測試帳號:`
 
 const DefaultAccountInfoTemplate = "   用戶: %s, 密碼: %s, 頻道: %s"
-
-// WebSocket 升級器設定
-var WebSocketUpgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true // 允許跨域，測試用
-	},
-}
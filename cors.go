@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// corsMiddleware 統一處理所有路由的 CORS 標頭與預檢請求
+//
+// Responsible for:
+// - 為允許的 Origin 設置 Access-Control-Allow-Origin、Allow-Credentials 與 Expose-Headers
+// - 回應所有路由的 OPTIONS 請求，處理器不需要個別處理
+//
+// Design considerations:
+// - 每個請求讀取 configManager 目前的設定，重新載入後立即生效
+// - AllowAllOrigins 時回應 *；否則回應請求的 Origin 並加上 Vary: Origin
+// - 允許憑證時必須列出 Origin（ServerConfig.Validate 拒絕與 AllowAllOrigins 同時使用），避免任何網站都能帶著用戶的憑證讀取回應
+// - 不允許的 Origin 的一般請求照常處理但不加 CORS 標頭，由瀏覽器阻擋讀取回應；預檢請求直接返回 403
+// - 沒有 Origin 或 Access-Control-Request-Method 的 OPTIONS 不是預檢，返回 204 與 Allow 標頭
+//
+// Parameters:
+// - next: 路由器
+//
+// Returns:
+// - http.Handler: 包裝後的處理器
+func corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		config := configManager.Get()
+		cors := config.CORS
+		header := w.Header()
+
+		origin := r.Header.Get("Origin")
+		allowed := origin != "" && corsOriginAllowed(config, origin)
+		if allowed {
+			if config.AllowAllOrigins {
+				header.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				header.Set("Access-Control-Allow-Origin", origin)
+				header.Add("Vary", "Origin")
+			}
+			if cors.AllowCredentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if len(cors.ExposedHeaders) > 0 {
+				header.Set("Access-Control-Expose-Headers", strings.Join(cors.ExposedHeaders, ", "))
+			}
+		}
+
+		if r.Method != http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		requestMethod := r.Header.Get("Access-Control-Request-Method")
+		if origin == "" || requestMethod == "" {
+			header.Set("Allow", strings.Join(cors.AllowedMethods, ", "))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+		if !allowed || !containsFold(cors.AllowedMethods, requestMethod) {
+			message := ErrorCORSOriginForbidden
+			if allowed {
+				message = ErrorCORSMethodForbidden
+			}
+			header.Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{"error": message})
+			return
+		}
+
+		header.Set("Access-Control-Allow-Methods", strings.Join(cors.AllowedMethods, ", "))
+		if len(cors.AllowedHeaders) > 0 {
+			header.Set("Access-Control-Allow-Headers", strings.Join(cors.AllowedHeaders, ", "))
+		}
+		if cors.MaxAge > 0 {
+			header.Set("Access-Control-Max-Age", strconv.Itoa(cors.MaxAge))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// corsOriginAllowed 檢查 Origin 是否在允許清單中
+//
+// Design considerations:
+// - REST API 與 WebSocket 共用，WebSocket 另外接受同源的請求（見 originAllowed）
+// - 比對不分大小寫
+func corsOriginAllowed(config ServerConfig, origin string) bool {
+	return config.AllowAllOrigins || containsFold(config.AllowedOrigins, origin)
+}
+
+// containsFold 檢查列表是否包含指定字串，不分大小寫
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
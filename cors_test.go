@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestCORSMiddleware 測試 CORS 標頭與預檢請求
+func TestCORSMiddleware(t *testing.T) {
+	defer func() { configManager = NewConfigManager(defaultServerConfig(), nil) }()
+
+	request := func(method, path, origin, requestMethod string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if requestMethod != "" {
+			req.Header.Set("Access-Control-Request-Method", requestMethod)
+		}
+		rr := httptest.NewRecorder()
+		setupRoutes().ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("預設允許所有 Origin", func(t *testing.T) {
+		configManager = NewConfigManager(defaultServerConfig(), nil)
+		rr := request("GET", "/api/metrics", "http://any.example", "")
+		if rr.Code != http.StatusOK || rr.Header().Get("Access-Control-Allow-Origin") != "*" {
+			t.Errorf("Expected wildcard origin, got %d %q", rr.Code, rr.Header().Get("Access-Control-Allow-Origin"))
+		}
+		if !strings.Contains(rr.Header().Get("Access-Control-Expose-Headers"), "Upload-Offset") {
+			t.Errorf("Expected exposed headers, got %q", rr.Header().Get("Access-Control-Expose-Headers"))
+		}
+	})
+
+	t.Run("允許的預檢請求", func(t *testing.T) {
+		configManager = NewConfigManager(defaultServerConfig(), nil)
+		rr := request("OPTIONS", "/api/messages", "http://any.example", "POST")
+		if rr.Code != http.StatusNoContent {
+			t.Fatalf("Expected 204, got %d", rr.Code)
+		}
+		if !strings.Contains(rr.Header().Get("Access-Control-Allow-Methods"), "POST") ||
+			!strings.Contains(rr.Header().Get("Access-Control-Allow-Headers"), "Authorization") ||
+			rr.Header().Get("Access-Control-Max-Age") != "600" {
+			t.Errorf("Expected preflight headers, got %v", rr.Header())
+		}
+	})
+
+	t.Run("限制 Origin 並允許憑證", func(t *testing.T) {
+		config := defaultServerConfig()
+		config.AllowAllOrigins = false
+		config.AllowedOrigins = []string{"http://localhost:3000"}
+		config.CORS.AllowCredentials = true
+		config.CORS.AllowedMethods = []string{"GET", "POST"}
+		configManager = NewConfigManager(config, nil)
+
+		rr := request("GET", "/api/metrics", "http://LOCALHOST:3000", "")
+		if rr.Header().Get("Access-Control-Allow-Origin") != "http://LOCALHOST:3000" ||
+			rr.Header().Get("Access-Control-Allow-Credentials") != "true" ||
+			rr.Header().Get("Vary") != "Origin" {
+			t.Errorf("Expected echoed origin with credentials, got %v", rr.Header())
+		}
+
+		rr = request("GET", "/api/metrics", "http://evil.example", "")
+		if rr.Code != http.StatusOK || rr.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("Expected no CORS headers for other origin, got %d %v", rr.Code, rr.Header())
+		}
+
+		tests := []struct {
+			name   string
+			origin string
+			method string
+			want   string
+		}{
+			{"不允許的 Origin", "http://evil.example", "GET", ErrorCORSOriginForbidden},
+			{"不允許的方法", "http://localhost:3000", "DELETE", ErrorCORSMethodForbidden},
+		}
+		for _, tt := range tests {
+			rr := request("OPTIONS", "/api/messages", tt.origin, tt.method)
+			if rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), tt.want) {
+				t.Errorf("%s: expected 403 %q, got %d %s", tt.name, tt.want, rr.Code, rr.Body.String())
+			}
+		}
+	})
+
+	t.Run("允許所有 Origin 時不能允許憑證", func(t *testing.T) {
+		config := defaultServerConfig()
+		config.CORS.AllowCredentials = true
+		if err := config.Validate(); err == nil || !strings.Contains(err.Error(), ErrorConfigCORSCredentials) {
+			t.Errorf("Expected credentials with any origin to be rejected, got %v", err)
+		}
+		config.AllowAllOrigins = false
+		config.AllowedOrigins = []string{"http://localhost:3000"}
+		if err := config.Validate(); err != nil {
+			t.Errorf("Expected credentials with listed origins to be valid, got %v", err)
+		}
+	})
+
+	t.Run("非預檢的 OPTIONS 請求", func(t *testing.T) {
+		configManager = NewConfigManager(defaultServerConfig(), nil)
+		rr := request("OPTIONS", "/api/metrics", "", "")
+		if rr.Code != http.StatusNoContent || !strings.Contains(rr.Header().Get("Allow"), "GET") {
+			t.Errorf("Expected 204 with Allow header, got %d %v", rr.Code, rr.Header())
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// applyMessageTTL 設定訊息的過期時間
+//
+// Responsible for:
+// - 依訊息指定的 ttl 或頻道預設存活時間計算 ExpiresAt
+//
+// Design considerations:
+// - 客戶端指定的 ttl 優先於頻道預設值，0 表示使用頻道預設值
+// - 頻道預設值也是 0 時訊息永久保存
+// - 過期時間以訊息時間戳為基準，呼叫前需先設置 Timestamp
+//
+// Parameters:
+// - msg: 要處理的訊息，會直接修改其 TTL 與 ExpiresAt 欄位
+//
+// Returns:
+// - error: ttl 為負數或超過上限時返回 ErrInvalidMessageTTL
+func applyMessageTTL(msg *Message) error {
+	msg.ExpiresAt = nil
+	if msg.TTL < 0 || msg.TTL > DefaultMaxMessageTTL {
+		return ErrInvalidMessageTTL
+	}
+	if msg.TTL == 0 {
+		msg.TTL = channelSettings.Get(msg.Channel).MessageTTL
+	}
+	if msg.TTL == 0 {
+		return nil
+	}
+
+	expiresAt := msg.Timestamp.Add(time.Duration(msg.TTL) * time.Second)
+	msg.ExpiresAt = &expiresAt
+	return nil
+}
+
+// expireMessages 刪除過期訊息並通知客戶端
+//
+// Process flow:
+// 1. 從 MessageStore 與搜尋索引移除過期訊息
+// 2. 從提及收件匣、投票與釘選列表移除
+// 3. 廣播 delete 事件，釘選有變更時一併廣播 pin 事件
+//
+// Parameters:
+// - now: 判斷過期的時間
+//
+// Returns:
+// - int: 刪除的訊息數量
+func expireMessages(now time.Time) int {
+	expired := messageStore.RemoveExpired(now)
+
+	var events []Message
+	for _, msg := range expired {
+		events = append(events, cleanupRemovedMessage(msg, DeletionReasonExpired)...)
+	}
+	broadcastEvents(events)
+	return len(expired)
+}
+
+// removeMessage 刪除單一訊息並通知客戶端
+//
+// Usage context:
+// - 管理員在審核佇列中移除被標記的訊息
+//
+// Parameters:
+// - channel: 頻道名稱
+// - id: 訊息 ID
+// - reason: 刪除原因，會附在 delete 事件中
+//
+// Returns:
+// - bool: 是否找到並刪除訊息
+func removeMessage(channel, id, reason string) bool {
+	msg, ok := messageStore.RemoveMessage(channel, id)
+	if !ok {
+		return false
+	}
+	broadcastEvents(cleanupRemovedMessage(msg, reason))
+	return true
+}
+
+// cleanupRemovedMessage 清除已刪除訊息的提及、投票與釘選
+//
+// Parameters:
+// - msg: 已從 MessageStore 移除的訊息
+// - reason: 刪除原因
+//
+// Returns:
+// - []Message: 需要廣播的 delete 事件，釘選有變更時包含 pin 事件
+func cleanupRemovedMessage(msg Message, reason string) []Message {
+	mentionInbox.Remove(msg.ID)
+	pollStore.Remove(msg.ID)
+	events := []Message{NewDeleteMessage(msg, reason)}
+
+	if pins, changed := pinStore.Remove(msg.Channel, msg.ID); changed {
+		event := PinEvent{Action: PinActionUnpin, MessageID: msg.ID, Username: "System", Pins: pins}
+		events = append(events, NewPinMessage(event, msg.Channel))
+	}
+	return events
+}
+
+// broadcastEvents 在獨立 goroutine 中依序廣播事件，避免呼叫者阻塞
+func broadcastEvents(events []Message) {
+	if len(events) == 0 {
+		return
+	}
+	go func() {
+		for _, event := range events {
+			hub.broadcast <- event
+		}
+	}()
+}
+
+// runMessageExpiry 定期刪除過期訊息
+//
+// Parameters:
+// - interval: 檢查間隔
+func runMessageExpiry(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		if removed := expireMessages(now); removed > 0 {
+			log.Printf(LogMessagesExpired, removed)
+		}
+	}
+}
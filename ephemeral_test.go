@@ -0,0 +1,126 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestApplyMessageTTL 測試訊息存活時間與頻道預設值
+func TestApplyMessageTTL(t *testing.T) {
+	channelSettings = NewChannelSettingsStore()
+	ttl := 60
+	channelSettings.Apply("random", ChannelSettingsUpdate{MessageTTL: &ttl})
+	now := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		msg     Message
+		want    time.Duration
+		wantErr bool
+	}{
+		{"未設定", Message{Channel: "general"}, 0, false},
+		{"訊息指定", Message{Channel: "general", TTL: 10}, 10 * time.Second, false},
+		{"頻道預設值", Message{Channel: "random"}, time.Minute, false},
+		{"訊息優先於頻道", Message{Channel: "random", TTL: 5}, 5 * time.Second, false},
+		{"負數", Message{Channel: "general", TTL: -1}, 0, true},
+		{"超過上限", Message{Channel: "general", TTL: DefaultMaxMessageTTL + 1}, 0, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			msg := test.msg
+			msg.Timestamp = now
+			err := applyMessageTTL(&msg)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Expected error=%v, got %v", test.wantErr, err)
+			}
+			if test.want == 0 {
+				if msg.ExpiresAt != nil {
+					t.Errorf("Expected no expiry, got %v", msg.ExpiresAt)
+				}
+				return
+			}
+			if msg.ExpiresAt == nil || !msg.ExpiresAt.Equal(now.Add(test.want)) {
+				t.Errorf("Expected expiry %v, got %v", now.Add(test.want), msg.ExpiresAt)
+			}
+		})
+	}
+}
+
+// TestExpireMessages 測試過期訊息的刪除與通知
+func TestExpireMessages(t *testing.T) {
+	hub = *newTestHub(0)
+	messageStore = make(MessageStore)
+	messageIndex = NewSearchIndex()
+	mentionInbox = NewMentionInbox(DefaultMentionInboxLimit)
+	pinStore = NewPinStore(DefaultPinLimit)
+	channelSettings = NewChannelSettingsStore()
+
+	observer := newTestClient("alice", "general")
+	hub.register <- observer
+	time.Sleep(10 * time.Millisecond)
+	for len(observer.send) > 0 {
+		<-observer.send
+	}
+
+	now := time.Now()
+	ephemeral := NewMessage("alice", "短暫的秘密 @bob", "general")
+	ephemeral.TTL = 1
+	applyMessageTTL(&ephemeral)
+	resolveMentions(&ephemeral)
+	messageStore.AddMessage(ephemeral)
+	mentionInbox.Add("bob", ephemeral)
+	alice, _ := validateAccount("alice", "password123")
+	pinStore.Pin(alice, "general", ephemeral.ID)
+	messageStore.AddMessage(NewMessage("alice", "永久訊息", "general"))
+
+	later := now.Add(2 * time.Second)
+	before := messageStore.GetChannelMessageCount("general")
+
+	t.Run("過期但尚未清除時不返回", func(t *testing.T) {
+		stale := NewMessage("alice", "已過期", "general")
+		stale.ExpiresAt = &now
+		messageStore.AddMessage(stale)
+		before++
+
+		for _, msg := range messageStore.GetRecentMessages("general", DefaultHistoryLimit) {
+			if msg.ID == stale.ID {
+				t.Error("Expected expired message to be hidden before it is removed")
+			}
+		}
+	})
+
+	if removed := expireMessages(later); removed != 2 {
+		t.Fatalf("Expected 2 expired messages, got %d", removed)
+	}
+
+	if remaining := messageStore.GetChannelMessageCount("general"); remaining != before-2 {
+		t.Errorf("Expected %d remaining messages, got %d", before-2, remaining)
+	}
+	result := messageIndex.Search(SearchQuery{Text: "秘密", Channels: []string{"general"}, Limit: 10})
+	if result.Total != 0 {
+		t.Error("Expected expired message to be removed from the search index")
+	}
+	if len(mentionInbox.List("bob", 10)) != 0 {
+		t.Error("Expected expired message to be removed from the mentions inbox")
+	}
+	if len(pinStore.List("general")) != 0 {
+		t.Error("Expected expired message to be unpinned")
+	}
+
+	var gotDelete, gotUnpin bool
+	timeout := time.After(time.Second)
+	for !gotDelete || !gotUnpin {
+		select {
+		case event := <-observer.send:
+			switch event.Type {
+			case MessageTypeDelete:
+				gotDelete = event.Deletion.MessageID == ephemeral.ID && event.Deletion.Reason == DeletionReasonExpired
+			case MessageTypePin:
+				gotUnpin = event.Pin.Action == PinActionUnpin
+			}
+		case <-timeout:
+			t.Fatalf("Expected delete and unpin events, got delete=%v unpin=%v", gotDelete, gotUnpin)
+		}
+	}
+}
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
-	"net/http"
+	"os"
+	"time"
 )
 
 // 全域變數
@@ -23,13 +28,80 @@ var (
 	// messageStore 按 channel 分類存儲訊息
 	messageStore = make(MessageStore)
 
+	// messageIndex 訊息內容的全文搜尋索引
+	messageIndex = NewSearchIndex()
+
+	// mentionInbox 每位用戶的提及收件匣
+	mentionInbox = NewMentionInbox(DefaultMentionInboxLimit)
+
+	// configManager 由設定檔、環境變數與命令列參數載入的伺服器設定，可重新載入
+	configManager = NewConfigManager(defaultServerConfig(), nil)
+
+	// upgrader WebSocket 升級器，依目前設定檢查 Origin
+	upgrader = newWebSocketUpgrader()
+
 	// hub WebSocket 連接管理中心
-	hub = Hub{
-		clients:    make(map[*Client]bool),
-		broadcast:  make(chan Message, DefaultHubBroadcastBuffer),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
-	}
+	hub = *newHub(configManager.Get())
+
+	// pinStore 各頻道的釘選訊息
+	pinStore = NewPinStore(DefaultPinLimit)
+
+	// channelSettings 各頻道的設定
+	channelSettings = NewChannelSettingsStore()
+
+	// uploadStore 上傳檔案的本地磁碟存儲
+	uploadStore = NewUploadStore(DefaultUploadDir, DefaultUploadMaxSize, DefaultUploadAllowedMIMETypes)
+
+	// uploadSessions 進行中的分段上傳
+	uploadSessions = NewUploadSessionStore(DefaultUploadSessionDir, DefaultChunkedUploadMaxSize, DefaultUploadSessionTTL*time.Second)
+
+	// commandRegistry 聊天輸入的斜線指令
+	commandRegistry = newDefaultCommandRegistry()
+
+	// nicknames 用戶暱稱
+	nicknames = NewNicknameStore()
+
+	// muteStore 各頻道的禁言名單
+	muteStore = NewMuteStore()
+
+	// banStore 頻道與全伺服器的封鎖名單
+	banStore = NewBanStore()
+
+	// roleStore 伺服器與頻道範圍的角色
+	roleStore = NewRoleStore(DefaultRoleGrants)
+
+	// pollStore 投票狀態與票數
+	pollStore = NewPollStore()
+
+	// scheduler 排程訊息
+	scheduler = NewScheduler(DefaultScheduleDir)
+
+	// moderationPipeline 訊息寫入歷史前的內容審核
+	moderationPipeline = newDefaultModerationPipeline()
+
+	// moderationQueue 被標記待人工審核的訊息
+	moderationQueue = NewModerationQueue()
+
+	// reportQueue 用戶檢舉的審核佇列
+	reportQueue = NewReportQueue(DefaultReportHideThreshold)
+
+	// metrics 伺服器運行指標
+	metrics = NewMetrics()
+
+	// rateLimits 訊息、登入與 WebSocket 連接的速率限制
+	rateLimits = NewRateLimits(DefaultRateLimits)
+
+	// runtimeSettings 可由管理 API 修改的執行期設定
+	runtimeSettings = NewRuntimeSettings(defaultRuntimeConfig())
+
+	// auditLog 登入、連接與管理操作的稽核日誌
+	auditLog = NewAuditLog(DefaultAuditLogPath)
+
+	// slowMode 慢速模式頻道中各用戶最後發送訊息的時間
+	slowMode = NewSlowModeTracker()
+
+	// presenceTracker 以用戶為單位追蹤在線狀態
+	presenceTracker = NewPresenceTracker(DefaultPresenceAwayTimeout * time.Second)
 )
 
 // printStartupBanner 顯示伺服器啟動資訊
@@ -43,12 +115,22 @@ var (
 // - 使用統一的格式化字串確保輸出一致性
 // - 包含所有開發者需要的關鍵資訊
 // - 便於快速瞭解服務可用性
+// - 啟用 TLS 時顯示 https 與 wss 網址；手機端網址使用 lanIP 偵測到的內網 IP（與自簽開發憑證涵蓋的 IP 相同）
 //
 // Usage context:
 // - 伺服器成功啟動後調用
 // - 提供開發者和使用者快速參考
 func printStartupBanner() {
-	fmt.Printf(DefaultStartupBanner, DefaultServerHost, DefaultServerPort, DefaultServerPort, DefaultServerHost, DefaultServerPort)
+	config := configManager.Get()
+	httpScheme, wsScheme := "http", "ws"
+	if config.TLS.enabled() {
+		httpScheme, wsScheme = "https", "wss"
+	}
+	ip := lanIP()
+	if ip == "" {
+		ip = "你的內網IP"
+	}
+	fmt.Printf(DefaultStartupBanner, httpScheme, config.Host, config.Port, httpScheme, ip, config.Port, wsScheme, config.Host, config.Port)
 	fmt.Println()
 
 	testAccounts := getTestAccounts()
@@ -71,25 +153,103 @@ func printStartupBanner() {
 // - 啟動資訊清楚顯示服務狀態
 //
 // Process flow:
-// 1. 啟動 Hub 的事件迴圈處理 WebSocket 連接
-// 2. 設置所有 HTTP 路由（API 和 WebSocket 端點）
-// 3. 顯示啟動成功資訊和可用端點
-// 4. 啟動 HTTP 伺服器並監聽指定埠
+// 1. 載入並驗證伺服器設定，--print-config 時輸出後結束
+// 2. 依設定建立 Hub、釘選、在線狀態與上傳存儲並套用執行期設定，開始監看 SIGHUP 與設定檔，啟動 Hub 的事件迴圈及在線狀態追蹤
+// 3. 設置所有 HTTP 路由（API 和 WebSocket 端點）
+// 4. 建立 HTTP 或 HTTPS 伺服器（載入或產生憑證），顯示啟動成功資訊和可用端點
+// 5. 監聽指定埠，設定轉址埠時另外將 HTTP 轉址到 HTTPS
 //
 // Usage context:
 // - 程式啟動時的主要入口點
 // - 協調各個模組的初始化和啟動
 func main() {
+	// 載入設定，錯誤時列出所有問題並結束
+	config, printOnly, err := LoadServerConfig(os.Args[1:], os.Getenv, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if printOnly {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(config)
+		return
+	}
+	configManager = NewConfigManager(config, func() (ServerConfig, error) {
+		config, _, err := LoadServerConfig(os.Args[1:], os.Getenv, io.Discard)
+		return config, err
+	})
+	hub = *newHub(config)
+	roleStore = NewRoleStore(config.roleGrants())
+	pinStore = NewPinStore(config.PinLimit)
+	presenceTracker = NewPresenceTracker(time.Duration(config.PresenceAwayTimeout) * time.Second)
+	uploadStore = NewUploadStore(config.Uploads.Dir, config.Uploads.MaxSize, DefaultUploadAllowedMIMETypes)
+	uploadSessions = NewUploadSessionStore(config.Uploads.SessionDir, config.Uploads.ChunkedMaxSize, DefaultUploadSessionTTL*time.Second)
+	if _, err := configManager.UpdateRuntimeConfig(func(current RuntimeConfig) (RuntimeConfig, error) {
+		return config.runtimeConfig(current), nil
+	}); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf(LogConfigLoaded, config.Port, len(config.Accounts))
+
+	// 收到 SIGHUP 或設定檔修改時重新載入設定
+	go configManager.watchSignals()
+	if config.File != "" {
+		go configManager.watchFile(config.File, DefaultConfigWatchInterval*time.Second)
+	}
+
+	// 載入先前上傳的檔案與未完成的分段上傳
+	if err := uploadStore.Load(); err != nil {
+		log.Printf(LogUploadError, err)
+	}
+	if err := uploadSessions.Load(); err != nil {
+		log.Printf(LogUploadError, err)
+	}
+	go uploadSessions.run(DefaultUploadSessionGCInterval * time.Second)
+
 	// 啟動 Hub
 	go hub.run()
 
+	// 載入尚未發送的排程訊息並開始定期發送
+	if err := auditLog.Load(); err != nil {
+		log.Printf(LogAuditLoadError, DefaultAuditLogPath, err)
+	}
+
+	if err := scheduler.Load(); err != nil {
+		log.Printf(LogScheduleLoadError, DefaultScheduleDir, err)
+	}
+	go scheduler.run(DefaultScheduleTickInterval * time.Second)
+
+	// 定期結束到期的投票
+	go pollStore.run(DefaultPollCloseInterval * time.Second)
+
+	// 定期刪除過期的訊息
+	go runMessageExpiry(DefaultMessageExpiryInterval * time.Second)
+
+	// 啟動在線狀態追蹤並將狀態變更推送到頻道
+	go presenceTracker.run(DefaultPresenceSweepInterval * time.Second)
+	go relayPresenceEvents(presenceTracker.Subscribe())
+
+	// 定期清除閒置的速率限制狀態
+	go rateLimits.run(DefaultRateLimitPruneInterval * time.Second)
+
+	// 定期解除到期的禁言與封鎖
+	go runSanctionExpiry(DefaultSanctionExpiryInterval * time.Second)
+
 	// 設置路由
 	router := setupRoutes()
 
+	// 建立伺服器，啟用 TLS 時載入或產生憑證
+	server, err := newHTTPServer(config, router)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// 顯示啟動資訊
 	printStartupBanner()
 
 	// 啟動伺服器
-	serverAddr := fmt.Sprintf(":%d", DefaultServerPort)
-	log.Fatal(http.ListenAndServe(serverAddr, router))
+	log.Fatal(listenAndServe(server, config))
 }
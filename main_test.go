@@ -19,6 +19,12 @@ func TestMain(m *testing.M) {
 	fmt.Println("🚀 開始執行 Flutter 聊天室服務器完整測試套件")
 	fmt.Println("============================================================")
 
+	// 測試在短時間內大量發送訊息與登入，關閉全域速率限制；限制本身在 ratelimit_test.go 另外測試
+	rateLimits = NewRateLimits(RateLimitConfig{})
+
+	// 稽核日誌只保存在記憶體，避免測試寫入 ./audit
+	auditLog = NewAuditLog("")
+
 	// 執行所有測試
 	code := m.Run()
 
@@ -239,6 +245,7 @@ func TestUnitSendMessage(t *testing.T) {
 				t.Fatal(err)
 			}
 			req.Header.Set("Content-Type", "application/json")
+			req.SetBasicAuth("alice", "password123")
 
 			rr := httptest.NewRecorder()
 			handler := http.HandlerFunc(sendMessage)
@@ -285,28 +292,43 @@ func TestUnitGetMessages(t *testing.T) {
 
 	tests := []struct {
 		name           string
+		user           string
 		channel        string
 		expectedStatus int
 		expectedCount  int
 	}{
 		{
 			name:           "有效頻道",
+			user:           "alice",
 			channel:        "general",
 			expectedStatus: http.StatusOK,
 			expectedCount:  2,
 		},
 		{
 			name:           "空頻道",
+			user:           "bob",
 			channel:        "tech",
 			expectedStatus: http.StatusOK,
 			expectedCount:  1, // 應該返回歡迎訊息
 		},
 		{
 			name:           "缺少頻道參數",
+			user:           "alice",
 			channel:        "",
 			expectedStatus: http.StatusBadRequest,
 			expectedCount:  0,
 		},
+		{
+			name:           "未驗證",
+			channel:        "general",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "非頻道成員",
+			user:           "bob",
+			channel:        "general",
+			expectedStatus: http.StatusForbidden,
+		},
 	}
 
 	for _, test := range tests {
@@ -320,6 +342,9 @@ func TestUnitGetMessages(t *testing.T) {
 			if err != nil {
 				t.Fatal(err)
 			}
+			if test.user != "" {
+				req.SetBasicAuth(test.user, "password123")
+			}
 
 			rr := httptest.NewRecorder()
 			handler := http.HandlerFunc(getMessages)
@@ -533,6 +558,7 @@ func TestEvent_E004_RESTAPIMessageSending(t *testing.T) {
 	jsonBody, _ := json.Marshal(testMessage)
 	req, _ := http.NewRequest("POST", "/api/messages", bytes.NewBuffer(jsonBody))
 	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth("alice", "password123")
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(sendMessage)
@@ -595,6 +621,7 @@ func TestEvent_E005_HistoricalMessageLoading(t *testing.T) {
 		messageStore["general"] = testMessages
 
 		req, _ := http.NewRequest("GET", "/api/messages?channel=general", nil)
+		req.SetBasicAuth("alice", "password123")
 		rr := httptest.NewRecorder()
 
 		handler := http.HandlerFunc(getMessages)
@@ -617,6 +644,7 @@ func TestEvent_E005_HistoricalMessageLoading(t *testing.T) {
 
 	t.Run("載入空頻道（應返回歡迎訊息）", func(t *testing.T) {
 		req, _ := http.NewRequest("GET", "/api/messages?channel=tech", nil)
+		req.SetBasicAuth("bob", "password123")
 		rr := httptest.NewRecorder()
 
 		handler := http.HandlerFunc(getMessages)
@@ -862,6 +890,7 @@ func TestEvent_E010_ConcurrentProcessingCapability(t *testing.T) {
 				jsonBody, _ := json.Marshal(requestBody)
 				req, _ := http.NewRequest("POST", "/api/messages", bytes.NewBuffer(jsonBody))
 				req.Header.Set("Content-Type", "application/json")
+				req.SetBasicAuth("alice", "password123")
 
 				rr := httptest.NewRecorder()
 				handler := http.HandlerFunc(sendMessage)
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// TextEntity 代表格式化文字中的一段樣式
+//
+// Design considerations:
+// - Offset/Length 為 rune 位置，對應 FormattedText.Text 而非原始內容
+// - 實體之間只會互相包含、不會部分重疊，客戶端可以直接建立巢狀元素
+type TextEntity struct {
+	Type     string `json:"type"`               // 樣式類型（bold, italic, code, pre, link, mention）
+	Offset   int    `json:"offset"`             // 起始位置（rune）
+	Length   int    `json:"length"`             // 長度（rune）
+	URL      string `json:"url,omitempty"`      // 連結網址（僅 link 類型）
+	Language string `json:"language,omitempty"` // 程式語言（僅 pre 類型）
+	Username string `json:"username,omitempty"` // 被提及的用戶名（僅 mention 類型）
+}
+
+// FormattedText 代表訊息內容解析後的結構化形式
+//
+// Design considerations:
+// - Text 為移除 Markdown 標記後的純文字，不含任何 HTML
+// - 原始內容保留在 Message.Content，客戶端可自行選擇使用哪一種
+type FormattedText struct {
+	Text     string       `json:"text"`     // 移除標記後的純文字
+	Entities []TextEntity `json:"entities"` // 依位置排序的樣式
+}
+
+// formatBuilder 累積解析結果
+type formatBuilder struct {
+	text     []rune
+	entities []TextEntity
+}
+
+// parseMarkdown 解析訊息內容中的 Markdown 子集
+//
+// Responsible for:
+// - 支援 **粗體**、*斜體*、_斜體_、`程式碼`、```程式碼區塊``` 與 [文字](網址)
+// - 產生純文字與樣式實體，並標示 @提及
+//
+// Design considerations:
+// - 不認得或未閉合的標記保留為一般文字，解析不會失敗
+// - HTML 不會被解析，一律視為一般文字，由客戶端以純文字顯示
+// - 連結只接受 DefaultAllowedLinkSchemes 中的協定，其他網址（例如 javascript:）保留為一般文字
+// - 程式碼與程式碼區塊內的文字不再解析其他標記
+// - 反斜線可跳脫標記符號，例如「\*」顯示為「*」
+//
+// Parameters:
+// - raw: 原始訊息內容
+//
+// Returns:
+// - FormattedText: 解析後的純文字與樣式
+func parseMarkdown(raw string) FormattedText {
+	b := &formatBuilder{}
+	src := []rune(sanitizeText(raw))
+
+	for {
+		start, end, next, language, ok := findCodeBlock(src)
+		if !ok {
+			b.inline(src)
+			break
+		}
+		b.inline(src[:start])
+		b.wrap(TextEntity{Type: EntityTypePre, Language: language}, func() {
+			b.text = append(b.text, src[end[0]:end[1]]...)
+		})
+		src = src[next:]
+	}
+
+	b.addMentions()
+	sort.SliceStable(b.entities, func(i, j int) bool {
+		if b.entities[i].Offset != b.entities[j].Offset {
+			return b.entities[i].Offset < b.entities[j].Offset
+		}
+		return b.entities[i].Length > b.entities[j].Length
+	})
+
+	entities := b.entities
+	if entities == nil {
+		entities = []TextEntity{}
+	}
+	return FormattedText{Text: string(b.text), Entities: entities}
+}
+
+// sanitizeText 移除可能造成顯示問題的字元
+//
+// Design considerations:
+// - 統一換行為 \n
+// - 移除換行與 Tab 以外的控制字元
+// - 移除雙向文字覆寫字元，避免用來偽裝連結或檔名
+func sanitizeText(raw string) string {
+	raw = strings.ReplaceAll(raw, "\r\n", "\n")
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\t':
+			return r
+		case unicode.IsControl(r):
+			return -1
+		case (r >= '\u202a' && r <= '\u202e') || (r >= '\u2066' && r <= '\u2069'):
+			return -1
+		}
+		return r
+	}, raw)
+}
+
+// findCodeBlock 尋找第一個完整的 ``` 程式碼區塊
+//
+// Returns:
+// - int: 區塊開頭（``` 的位置）
+// - [2]int: 區塊內容的範圍
+// - int: 區塊結束後的位置（包含結尾 ``` 後的一個換行）
+// - string: 開頭 ``` 後標示的程式語言
+// - bool: 是否找到完整的區塊
+func findCodeBlock(src []rune) (int, [2]int, int, string, bool) {
+	fence := []rune("```")
+	for start := indexRunes(src, fence, 0); start >= 0; start = indexRunes(src, fence, start+1) {
+		newline := indexRunes(src, []rune("\n"), start+3)
+		if newline < 0 {
+			return 0, [2]int{}, 0, "", false
+		}
+		language := strings.TrimSpace(string(src[start+3 : newline]))
+		if strings.ContainsFunc(language, unicode.IsSpace) {
+			continue
+		}
+
+		closing := indexRunes(src, fence, newline+1)
+		if closing < 0 {
+			return 0, [2]int{}, 0, "", false
+		}
+		end := closing
+		if end > newline+1 && src[end-1] == '\n' {
+			end--
+		}
+		next := closing + 3
+		if next < len(src) && src[next] == '\n' {
+			next++
+		}
+		return start, [2]int{newline + 1, end}, next, language, true
+	}
+	return 0, [2]int{}, 0, "", false
+}
+
+// inline 解析單行樣式並寫入結果
+func (b *formatBuilder) inline(src []rune) {
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\\' && i+1 < len(src) && strings.ContainsRune(MarkdownEscapableChars, src[i+1]):
+			b.text = append(b.text, src[i+1])
+			i += 2
+			continue
+
+		case c == '`':
+			if j := indexRunes(src, []rune("`"), i+1); j > i+1 {
+				b.wrap(TextEntity{Type: EntityTypeCode}, func() {
+					b.text = append(b.text, src[i+1:j]...)
+				})
+				i = j + 1
+				continue
+			}
+
+		case c == '*' && i+1 < len(src) && src[i+1] == '*':
+			if j := indexRunes(src, []rune("**"), i+2); j > i+2 {
+				b.wrap(TextEntity{Type: EntityTypeBold}, func() {
+					b.inline(src[i+2 : j])
+				})
+				i = j + 2
+				continue
+			}
+
+		case c == '*' || c == '_':
+			if j := closingItalic(src, i); j > 0 {
+				b.wrap(TextEntity{Type: EntityTypeItalic}, func() {
+					b.inline(src[i+1 : j])
+				})
+				i = j + 1
+				continue
+			}
+
+		case c == '[':
+			if label, link, next, ok := parseLink(src, i); ok {
+				b.wrap(TextEntity{Type: EntityTypeLink, URL: link}, func() {
+					b.inline(label)
+				})
+				i = next
+				continue
+			}
+		}
+
+		b.text = append(b.text, c)
+		i++
+	}
+}
+
+// wrap 以 fill 寫入的文字建立樣式實體，沒有文字時不建立
+func (b *formatBuilder) wrap(entity TextEntity, fill func()) {
+	start := len(b.text)
+	fill()
+	if len(b.text) == start {
+		return
+	}
+	entity.Offset = start
+	entity.Length = len(b.text) - start
+	b.entities = append(b.entities, entity)
+}
+
+// addMentions 在解析後的文字中標示 @提及
+//
+// Design considerations:
+// - 程式碼中的 @ 不視為提及
+// - 與其他樣式部分重疊的提及會被略過，維持實體巢狀結構
+func (b *formatBuilder) addMentions() {
+	for _, mention := range parseMentions(string(b.text)) {
+		if mention.Type != MentionTypeUser {
+			continue
+		}
+		if b.nestable(mention.Start, mention.End) {
+			b.entities = append(b.entities, TextEntity{
+				Type:     EntityTypeMention,
+				Offset:   mention.Start,
+				Length:   mention.End - mention.Start,
+				Username: mention.Username,
+			})
+		}
+	}
+}
+
+// nestable 檢查範圍是否可以作為新實體加入
+func (b *formatBuilder) nestable(start, end int) bool {
+	for _, entity := range b.entities {
+		entityEnd := entity.Offset + entity.Length
+		if end <= entity.Offset || start >= entityEnd {
+			continue
+		}
+		if entity.Type == EntityTypeCode || entity.Type == EntityTypePre {
+			return false
+		}
+		if start < entity.Offset || end > entityEnd {
+			return false
+		}
+	}
+	return true
+}
+
+// closingItalic 尋找斜體的結尾標記
+//
+// Design considerations:
+// - 標記內側不可緊接空白，例如「a * b * c」不是斜體
+// - 底線前後緊接英數字時不視為標記，避免把 snake_case 當成斜體
+// - 星號結尾不可是 ** 的一部分，讓斜體內可以包含粗體
+//
+// Returns:
+// - int: 結尾標記的位置，找不到時為 -1
+func closingItalic(src []rune, i int) int {
+	marker := src[i]
+	if i+1 >= len(src) || unicode.IsSpace(src[i+1]) {
+		return -1
+	}
+	if marker == '_' && i > 0 && isWordRune(src[i-1]) {
+		return -1
+	}
+
+	for j := i + 2; j < len(src); j++ {
+		if src[j] != marker || src[j-1] == '\\' || unicode.IsSpace(src[j-1]) {
+			continue
+		}
+		if marker == '*' && (src[j-1] == '*' || (j+1 < len(src) && src[j+1] == '*')) {
+			continue
+		}
+		if marker == '_' && j+1 < len(src) && isWordRune(src[j+1]) {
+			continue
+		}
+		return j
+	}
+	return -1
+}
+
+// parseLink 解析 [文字](網址) 格式的連結
+//
+// Returns:
+// - []rune: 連結文字
+// - string: 網址
+// - int: 連結結束後的位置
+// - bool: 是否為合法且安全的連結
+func parseLink(src []rune, i int) ([]rune, string, int, bool) {
+	closeLabel := indexRunes(src, []rune("]"), i+1)
+	if closeLabel <= i+1 || closeLabel+1 >= len(src) || src[closeLabel+1] != '(' {
+		return nil, "", 0, false
+	}
+	label := src[i+1 : closeLabel]
+	if strings.ContainsRune(string(label), '\n') {
+		return nil, "", 0, false
+	}
+
+	closeURL := indexRunes(src, []rune(")"), closeLabel+2)
+	if closeURL < 0 {
+		return nil, "", 0, false
+	}
+	link := strings.TrimSpace(string(src[closeLabel+2 : closeURL]))
+	if !safeLinkURL(link) {
+		return nil, "", 0, false
+	}
+	return label, link, closeURL + 1, true
+}
+
+// safeLinkURL 檢查網址是否可以作為連結
+//
+// Parameters:
+// - link: 網址
+//
+// Returns:
+// - bool: 協定在允許清單中，且 http/https 網址包含主機名稱
+func safeLinkURL(link string) bool {
+	if link == "" || strings.ContainsFunc(link, unicode.IsSpace) {
+		return false
+	}
+	parsed, err := url.Parse(link)
+	if err != nil || !containsString(DefaultAllowedLinkSchemes, strings.ToLower(parsed.Scheme)) {
+		return false
+	}
+	if parsed.Scheme != "mailto" && parsed.Host == "" {
+		return false
+	}
+	return true
+}
+
+// isWordRune 檢查字元是否為英數字
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// indexRunes 從 from 開始尋找 sub 第一次出現的位置
+//
+// Returns:
+// - int: 出現的位置，找不到時為 -1
+func indexRunes(src, sub []rune, from int) int {
+	for i := from; i+len(sub) <= len(src); i++ {
+		match := true
+		for k := range sub {
+			if src[i+k] != sub[k] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
+
+// resolveFormatting 解析用戶訊息的格式並寫入訊息
+//
+// Design considerations:
+// - 格式一律由伺服器解析，忽略客戶端送來的 formatted 欄位
+// - 系統訊息與空白內容不解析
+//
+// Parameters:
+// - msg: 要處理的訊息，會直接修改其 Formatted 欄位
+func resolveFormatting(msg *Message) {
+	msg.Formatted = nil
+	if msg.IsSystemMessage() || msg.Content == "" {
+		return
+	}
+	formatted := parseMarkdown(msg.Content)
+	msg.Formatted = &formatted
+}
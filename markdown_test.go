@@ -0,0 +1,70 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestParseMarkdown 測試 Markdown 子集的解析
+func TestParseMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		text     string
+		entities []TextEntity
+	}{
+		{"純文字", "你好", "你好", []TextEntity{}},
+		{"粗體", "這很**重要**", "這很重要", []TextEntity{{Type: EntityTypeBold, Offset: 2, Length: 2}}},
+		{"斜體", "*a* 與 _b_", "a 與 b", []TextEntity{
+			{Type: EntityTypeItalic, Offset: 0, Length: 1},
+			{Type: EntityTypeItalic, Offset: 4, Length: 1},
+		}},
+		{"斜體包含粗體", "*a **b** c*", "a b c", []TextEntity{
+			{Type: EntityTypeItalic, Offset: 0, Length: 5},
+			{Type: EntityTypeBold, Offset: 2, Length: 1},
+		}},
+		{"snake_case 不是斜體", "run my_long_name", "run my_long_name", []TextEntity{}},
+		{"程式碼不解析標記", "用 `**x**`", "用 **x**", []TextEntity{{Type: EntityTypeCode, Offset: 2, Length: 5}}},
+		{"程式碼區塊", "看：\n```go\nfmt.Println(1)\n```\n結束", "看：\nfmt.Println(1)結束", []TextEntity{
+			{Type: EntityTypePre, Offset: 3, Length: 14, Language: "go"},
+		}},
+		{"連結", "[文件](https://go.dev/doc)", "文件", []TextEntity{{Type: EntityTypeLink, Offset: 0, Length: 2, URL: "https://go.dev/doc"}}},
+		{"危險連結保留為文字", "[點我](javascript:alert(1))", "[點我](javascript:alert(1))", []TextEntity{}},
+		{"HTML 視為文字", "<script>alert(1)</script>", "<script>alert(1)</script>", []TextEntity{}},
+		{"跳脫", `\*不是斜體\*`, "*不是斜體*", []TextEntity{}},
+		{"未閉合", "**一半", "**一半", []TextEntity{}},
+		{"移除控制字元", "a\x00b\u202ec\r\n", "abc\n", []TextEntity{}},
+		{"提及", "**@bob** 看 `@alice`", "@bob 看 @alice", []TextEntity{
+			{Type: EntityTypeBold, Offset: 0, Length: 4},
+			{Type: EntityTypeMention, Offset: 0, Length: 4, Username: "bob"},
+			{Type: EntityTypeCode, Offset: 7, Length: 6},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := parseMarkdown(test.raw)
+			if got.Text != test.text {
+				t.Errorf("Expected text %q, got %q", test.text, got.Text)
+			}
+			if !reflect.DeepEqual(got.Entities, test.entities) {
+				t.Errorf("Expected entities %+v, got %+v", test.entities, got.Entities)
+			}
+		})
+	}
+}
+
+// TestResolveFormatting 測試格式只由伺服器產生
+func TestResolveFormatting(t *testing.T) {
+	msg := Message{Content: "**hi**", Formatted: &FormattedText{Text: "偽造"}}
+	resolveFormatting(&msg)
+	if msg.Formatted == nil || msg.Formatted.Text != "hi" || msg.Content != "**hi**" {
+		t.Errorf("Expected server-parsed format with raw content kept, got %+v", msg.Formatted)
+	}
+
+	system := NewSystemMessage("**bob** 加入了", "general")
+	resolveFormatting(&system)
+	if system.Formatted != nil {
+		t.Error("Expected system messages not to be formatted")
+	}
+}
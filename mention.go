@@ -0,0 +1,287 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Mention 代表訊息內容中的一個 @提及
+//
+// Design considerations:
+// - Start/End 為 rune 位置，涵蓋 @ 符號，客戶端可直接用於標示
+// - Type 為 channel 時 Username 為空，代表提及頻道內所有成員
+type Mention struct {
+	Type     string `json:"type"`               // 提及類型（user, channel）
+	Username string `json:"username,omitempty"` // 被提及的用戶名（僅 user 類型）
+	Start    int    `json:"start"`              // 起始位置（rune）
+	End      int    `json:"end"`                // 結束位置（rune，不含）
+}
+
+// MentionEvent 代表推送給被提及用戶的通知內容
+type MentionEvent struct {
+	Kind    string  `json:"kind"`    // 被通知的原因（user, channel）
+	Message Message `json:"message"` // 提及用戶的原始訊息
+}
+
+// isMentionRune 檢查字元是否可以出現在 @ 之後的名稱中
+func isMentionRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.'
+}
+
+// parseMentions 解析訊息內容中的 @提及
+//
+// Responsible for:
+// - 找出 @username 與 @channel
+// - 只保留存在的帳號，未知的名稱視為一般文字
+//
+// Design considerations:
+// - @ 前面緊接英數字時不視為提及，避免把 email 地址當成提及
+// - 名稱結尾的句點視為標點符號，例如「@bob.」提及 bob
+// - 用戶名比對不分大小寫，結果使用帳號的原始名稱
+//
+// Parameters:
+// - content: 訊息內容
+//
+// Returns:
+// - []Mention: 解析出的提及，沒有時為 nil
+func parseMentions(content string) []Mention {
+	runes := []rune(content)
+	var mentions []Mention
+
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '@' || (i > 0 && isMentionRune(runes[i-1])) {
+			continue
+		}
+
+		end := i + 1
+		for end < len(runes) && isMentionRune(runes[end]) {
+			end++
+		}
+		for end > i+1 && runes[end-1] == '.' {
+			end--
+		}
+		name := string(runes[i+1 : end])
+		if name == "" {
+			continue
+		}
+
+		if strings.EqualFold(name, MentionChannelKeyword) {
+			mentions = append(mentions, Mention{Type: MentionTypeChannel, Start: i, End: end})
+		} else if account, ok := findAccount(name); ok {
+			mentions = append(mentions, Mention{Type: MentionTypeUser, Username: account.Username, Start: i, End: end})
+		}
+		i = end - 1
+	}
+	return mentions
+}
+
+// findAccount 依用戶名查找帳號（不分大小寫）
+func findAccount(username string) (Account, bool) {
+	for _, account := range getTestAccounts() {
+		if strings.EqualFold(account.Username, username) {
+			return account, true
+		}
+	}
+	return Account{}, false
+}
+
+// resolveMentions 解析用戶訊息的提及並寫入訊息
+//
+// Design considerations:
+// - 提及資料一律由伺服器解析，忽略客戶端送來的 mentions 欄位
+// - 系統訊息不解析提及
+//
+// Parameters:
+// - msg: 要處理的訊息，會直接修改其 Mentions 欄位
+func resolveMentions(msg *Message) {
+	msg.Mentions = nil
+	if msg.IsSystemMessage() {
+		return
+	}
+	msg.Mentions = parseMentions(msg.Content)
+}
+
+// mentionRecipients 計算需要通知的用戶
+//
+// Design considerations:
+// - @channel 通知可讀取該頻道的所有帳號
+// - 同一用戶被提及多次只通知一次，並以 user 類型優先
+// - 不通知發送者自己
+//
+// Parameters:
+// - msg: 已解析提及的訊息
+//
+// Returns:
+// - map[string]string: 用戶名對應通知原因
+func mentionRecipients(msg Message) map[string]string {
+	recipients := make(map[string]string)
+	for _, mention := range msg.Mentions {
+		switch mention.Type {
+		case MentionTypeUser:
+			recipients[mention.Username] = MentionTypeUser
+		case MentionTypeChannel:
+			for _, account := range getTestAccounts() {
+				if _, ok := recipients[account.Username]; !ok && authorize(&account, PermissionReadChannel, msg.Channel) {
+					recipients[account.Username] = MentionTypeChannel
+				}
+			}
+		}
+	}
+	delete(recipients, msg.User)
+	return recipients
+}
+
+// notifyMentions 記錄提及並通知被提及的用戶
+//
+// Process flow:
+// 1. 計算需要通知的用戶
+// 2. 將訊息加入每位用戶的提及收件匣
+// 3. 透過 Hub 推送 mention 事件到用戶的所有連接（不限頻道）
+//
+// Usage context:
+// - 用戶訊息儲存並廣播之後調用
+//
+// Parameters:
+// - msg: 已儲存的訊息
+func notifyMentions(msg Message) {
+	recipients := mentionRecipients(msg)
+	if len(recipients) == 0 {
+		return
+	}
+
+	for username, kind := range recipients {
+		mentionInbox.Add(username, msg)
+		log.Printf(LogMentionNotified, username, msg.User, msg.Channel)
+
+		event := NewMentionMessage(msg, kind)
+		go hub.sendToUser(username, event)
+	}
+}
+
+// MentionInbox 每位用戶的提及收件匣
+//
+// Responsible for:
+// - 保存提及用戶的訊息，供 GET /api/mentions 查詢
+//
+// Design considerations:
+// - 每位用戶最多保存 limit 筆，超過時捨棄最舊的
+// - 只存在記憶體中，與 MessageStore 一致
+// - 過期訊息不會返回，並在刪除時從收件匣移除
+//
+// Usage context:
+// - 全域 mentionInbox 實例
+type MentionInbox struct {
+	mu      sync.RWMutex
+	limit   int
+	entries map[string][]Message
+}
+
+// NewMentionInbox 建立提及收件匣
+//
+// Parameters:
+// - limit: 每位用戶保存的最大筆數
+//
+// Returns:
+// - *MentionInbox: 空的收件匣
+func NewMentionInbox(limit int) *MentionInbox {
+	return &MentionInbox{
+		limit:   limit,
+		entries: make(map[string][]Message),
+	}
+}
+
+// Add 將訊息加入用戶的收件匣
+//
+// Parameters:
+// - username: 被提及的用戶名
+// - msg: 提及該用戶的訊息
+func (mi *MentionInbox) Add(username string, msg Message) {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+
+	entries := append(mi.entries[username], msg)
+	if len(entries) > mi.limit {
+		entries = entries[len(entries)-mi.limit:]
+	}
+	mi.entries[username] = entries
+}
+
+// List 取得用戶最近被提及的訊息
+//
+// Parameters:
+// - username: 用戶名
+// - limit: 最多返回的筆數
+//
+// Returns:
+// - []Message: 由新到舊排列的訊息
+func (mi *MentionInbox) List(username string, limit int) []Message {
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+
+	now := time.Now()
+	entries := mi.entries[username]
+	result := make([]Message, 0, min(limit, len(entries)))
+	for i := len(entries) - 1; i >= 0 && len(result) < limit; i-- {
+		if !entries[i].IsExpired(now) {
+			result = append(result, entries[i])
+		}
+	}
+	return result
+}
+
+// Remove 從所有收件匣移除指定訊息
+//
+// Parameters:
+// - messageID: 已刪除的訊息 ID
+//
+// Returns:
+// - []string: 收件匣中有這則訊息的用戶，可用於 Restore
+func (mi *MentionInbox) Remove(messageID string) []string {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+
+	var usernames []string
+	for username, entries := range mi.entries {
+		kept := entries[:0]
+		for _, msg := range entries {
+			if msg.ID != messageID {
+				kept = append(kept, msg)
+			}
+		}
+		if len(kept) != len(entries) {
+			usernames = append(usernames, username)
+		}
+		mi.entries[username] = kept
+	}
+	return usernames
+}
+
+// Restore 將先前移除的訊息放回用戶的收件匣
+//
+// Design considerations:
+// - 依訊息時間插入，收件匣維持由舊到新的順序
+// - 超過上限時捨棄最舊的
+//
+// Parameters:
+// - usernames: Remove 返回的用戶
+// - msg: 要恢復的訊息
+func (mi *MentionInbox) Restore(usernames []string, msg Message) {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+
+	for _, username := range usernames {
+		entries := mi.entries[username]
+		i := sort.Search(len(entries), func(i int) bool {
+			return entries[i].Timestamp.After(msg.Timestamp)
+		})
+		entries = append(entries[:i:i], append([]Message{msg}, entries[i:]...)...)
+		if len(entries) > mi.limit {
+			entries = entries[len(entries)-mi.limit:]
+		}
+		mi.entries[username] = entries
+	}
+}
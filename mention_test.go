@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestParseMentions 測試 @提及解析
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []Mention
+	}{
+		{"提及用戶", "@bob 看一下", []Mention{{Type: MentionTypeUser, Username: "bob", Start: 0, End: 4}}},
+		{"不分大小寫", "嗨 @Alice.", []Mention{{Type: MentionTypeUser, Username: "alice", Start: 2, End: 8}}},
+		{"提及頻道", "請注意 @channel", []Mention{{Type: MentionTypeChannel, Start: 4, End: 12}}},
+		{"未知用戶", "@nobody 你好", nil},
+		{"email 不是提及", "寄到 bob@example.com", nil},
+		{"只有 @", "@ 123", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := parseMentions(test.content)
+			if len(got) != len(test.want) {
+				t.Fatalf("Expected %v, got %v", test.want, got)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Errorf("Expected %+v, got %+v", test.want[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+// TestMentionRecipients 測試通知對象的計算
+func TestMentionRecipients(t *testing.T) {
+	msg := Message{User: "alice", Channel: "general", Content: "@alice @bob @channel"}
+	resolveMentions(&msg)
+
+	recipients := mentionRecipients(msg)
+	if len(recipients) != 1 || recipients["bob"] != MentionTypeUser {
+		t.Errorf("Expected only bob via user mention, got %v", recipients)
+	}
+
+	system := NewSystemMessage("@bob", "general")
+	resolveMentions(&system)
+	if system.Mentions != nil {
+		t.Error("Expected system messages not to carry mentions")
+	}
+}
+
+// TestMentionNotification 測試提及事件推送到其他頻道的連接並寫入收件匣
+func TestMentionNotification(t *testing.T) {
+	hub = *newTestHub(0)
+	messageStore = make(MessageStore)
+	messageIndex = NewSearchIndex()
+	mentionInbox = NewMentionInbox(2)
+
+	bob := newTestClient("bob", "tech")
+	hub.register <- bob
+	time.Sleep(10 * time.Millisecond)
+	for len(bob.send) > 0 {
+		<-bob.send
+	}
+
+	body, _ := json.Marshal(Message{User: "alice", Channel: "general", Content: "@bob 幫忙看 PR"})
+	req, _ := http.NewRequest("POST", "/api/messages", bytes.NewReader(body))
+	req.SetBasicAuth("alice", "password123")
+	rr := httptest.NewRecorder()
+	setupRoutes().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+
+	select {
+	case event := <-bob.send:
+		if event.Type != MessageTypeMention || event.Mention == nil {
+			t.Fatalf("Expected mention event, got %+v", event)
+		}
+		if event.Mention.Message.Channel != "general" || len(event.Mention.Message.Mentions) != 1 {
+			t.Errorf("Expected original general message with mentions, got %+v", event.Mention.Message)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected bob to receive a mention event")
+	}
+
+	stored := messageStore["general"][0]
+	if len(stored.Mentions) != 1 || stored.Mentions[0].Username != "bob" {
+		t.Errorf("Expected stored message to carry mention metadata, got %+v", stored.Mentions)
+	}
+
+	t.Run("收件匣", func(t *testing.T) {
+		mentionInbox.Add("bob", Message{ID: "2"})
+		mentionInbox.Add("bob", Message{ID: "3"})
+
+		req, _ := http.NewRequest("GET", "/api/mentions", nil)
+		req.SetBasicAuth("bob", "password123")
+		rr := httptest.NewRecorder()
+		setupRoutes().ServeHTTP(rr, req)
+
+		var response struct {
+			Mentions []Message `json:"mentions"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		if len(response.Mentions) != 2 || response.Mentions[0].ID != "3" {
+			t.Errorf("Expected newest 2 mentions, got %+v", response.Mentions)
+		}
+	})
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"crypto/rand"
 	"fmt"
+	"sort"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -28,8 +30,40 @@ type Message struct {
 	User      string    `json:"user"`      // 發送者用戶名
 	Content   string    `json:"content"`   // 訊息內容
 	Timestamp time.Time `json:"timestamp"` // 發送時間
-	Type      string    `json:"type"`      // 訊息類型（text, system, image, file）
+	Type      string    `json:"type"`      // 訊息類型（text, system, image, file, poll, action 及各種事件）
 	Channel   string    `json:"channel"`   // 所屬頻道
+
+	Nick       string         `json:"nick,omitempty"`       // 發送者的暱稱（由 /nick 設定）
+	Attachment *Attachment    `json:"attachment,omitempty"` // 附件資訊（僅 image, file 類型）
+	Presence   *PresenceEvent `json:"presence,omitempty"`   // 在線狀態變更事件（僅 presence 類型）
+	Error      *MessageError  `json:"error,omitempty"`      // 錯誤詳情（僅 error 類型）
+	Mentions   []Mention      `json:"mentions,omitempty"`   // 內容中的 @提及（由伺服器解析）
+	Formatted  *FormattedText `json:"formatted,omitempty"`  // 解析 Markdown 後的純文字與樣式（由伺服器解析）
+	Mention    *MentionEvent  `json:"mention,omitempty"`    // 提及通知（僅 mention 類型）
+	Pin        *PinEvent      `json:"pin,omitempty"`        // 釘選變更事件（僅 pin 類型）
+	Deletion   *DeletionEvent `json:"deletion,omitempty"`   // 刪除事件（僅 delete 類型）
+	Restored   *Message       `json:"restored,omitempty"`   // 恢復的訊息（僅 restore 類型）
+	Poll       *Poll          `json:"poll,omitempty"`       // 投票內容與結果（僅 poll, poll_update 類型）
+	Vote       *PollVote      `json:"vote,omitempty"`       // 投票（僅客戶端送出的 vote 類型）
+
+	TTL       int        `json:"ttl,omitempty"`       // 存活秒數（客戶端指定，未指定時套用頻道預設值）
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"` // 過期時間，過期後自動刪除
+}
+
+// DeletionEvent 代表訊息被刪除的事件內容
+type DeletionEvent struct {
+	MessageID string `json:"messageId"` // 被刪除的訊息 ID
+	Reason    string `json:"reason"`    // 刪除原因（expired、moderated、reported、cleared）
+}
+
+// MessageError 代表回覆給發送者的結構化錯誤
+//
+// Design considerations:
+// - Code 為穩定的機器可讀代碼，Message 為可顯示的說明
+type MessageError struct {
+	Code       string `json:"code"`                 // 錯誤代碼
+	Message    string `json:"message"`              // 錯誤說明
+	RetryAfter int    `json:"retryAfter,omitempty"` // 秒，可以重試前需要等待的時間（速率限制與慢速模式）
 }
 
 // messageIDCounter 用於生成唯一 ID 的計數器
@@ -157,6 +191,215 @@ func NewWelcomeMessage(channel string) Message {
 	return NewSystemMessage(content, channel)
 }
 
+// NewPresenceMessage 建立在線狀態變更事件訊息
+//
+// Responsible for:
+// - 將 PresenceEvent 包裝為可透過 Hub 廣播的訊息
+// - 事件訊息只做即時推送，不寫入歷史記錄
+//
+// Parameters:
+// - event: 在線狀態變更事件
+// - channel: 要推送的頻道
+//
+// Returns:
+// - Message: presence 類型的事件訊息
+func NewPresenceMessage(event PresenceEvent, channel string) Message {
+	return Message{
+		ID:        generateMessageID(),
+		User:      "System",
+		Content:   event.Username + " " + event.Status,
+		Timestamp: event.Timestamp,
+		Type:      MessageTypePresence,
+		Channel:   channel,
+		Presence:  &event,
+	}
+}
+
+// NewErrorMessage 建立回覆給發送者的錯誤事件訊息
+//
+// Responsible for:
+// - 將處理失敗的原因以結構化格式回覆給 WebSocket 客戶端
+// - 錯誤事件只發送給發送者，不寫入歷史
+//
+// Parameters:
+// - code: 錯誤代碼
+// - text: 錯誤說明
+// - channel: 所屬頻道
+//
+// Returns:
+// - Message: error 類型的事件訊息
+func NewErrorMessage(code, text, channel string) Message {
+	return Message{
+		ID:        generateMessageID(),
+		User:      "System",
+		Content:   text,
+		Timestamp: time.Now(),
+		Type:      MessageTypeError,
+		Channel:   channel,
+		Error:     &MessageError{Code: code, Message: text},
+	}
+}
+
+// NewMentionMessage 建立推送給被提及用戶的通知事件
+//
+// Responsible for:
+// - 包裝提及用戶的原始訊息，讓客戶端在其他頻道也能顯示通知
+// - 通知事件只推送給被提及的用戶，不寫入歷史
+//
+// Parameters:
+// - msg: 提及用戶的原始訊息
+// - kind: 被通知的原因（user, channel）
+//
+// Returns:
+// - Message: mention 類型的事件訊息
+func NewMentionMessage(msg Message, kind string) Message {
+	return Message{
+		ID:        generateMessageID(),
+		User:      msg.User,
+		Content:   msg.Content,
+		Timestamp: msg.Timestamp,
+		Type:      MessageTypeMention,
+		Channel:   msg.Channel,
+		Mention:   &MentionEvent{Kind: kind, Message: msg},
+	}
+}
+
+// NewPinMessage 建立釘選變更事件訊息
+//
+// Parameters:
+// - event: 釘選變更事件
+// - channel: 所屬頻道
+//
+// Returns:
+// - Message: pin 類型的事件訊息
+func NewPinMessage(event PinEvent, channel string) Message {
+	return Message{
+		ID:        generateMessageID(),
+		User:      "System",
+		Content:   event.Action + " " + event.MessageID,
+		Timestamp: time.Now(),
+		Type:      MessageTypePin,
+		Channel:   channel,
+		Pin:       &event,
+	}
+}
+
+// NewDeleteMessage 建立訊息刪除事件
+//
+// Responsible for:
+// - 通知頻道內的客戶端移除已刪除的訊息
+// - 刪除事件只即時推送，不寫入歷史
+//
+// Parameters:
+// - msg: 被刪除的訊息
+// - reason: 刪除原因
+//
+// Returns:
+// - Message: delete 類型的事件訊息
+func NewDeleteMessage(msg Message, reason string) Message {
+	return Message{
+		ID:        generateMessageID(),
+		User:      "System",
+		Content:   msg.ID,
+		Timestamp: time.Now(),
+		Type:      MessageTypeDelete,
+		Channel:   msg.Channel,
+		Deletion:  &DeletionEvent{MessageID: msg.ID, Reason: reason},
+	}
+}
+
+// NewRestoreMessage 建立訊息恢復事件
+//
+// Responsible for:
+// - 通知頻道內的客戶端把先前被隱藏的訊息放回原本的位置
+// - 與新訊息區分，客戶端不會把恢復的訊息當作新訊息通知
+//
+// Parameters:
+// - msg: 恢復的訊息
+//
+// Returns:
+// - Message: restore 類型的事件訊息
+func NewRestoreMessage(msg Message) Message {
+	return Message{
+		ID:        generateMessageID(),
+		User:      "System",
+		Content:   msg.ID,
+		Timestamp: time.Now(),
+		Type:      MessageTypeRestore,
+		Channel:   msg.Channel,
+		Restored:  &msg,
+	}
+}
+
+// NewPollUpdateMessage 建立投票結果更新事件
+//
+// Parameters:
+// - poll: 最新的投票結果
+// - channel: 投票所屬頻道
+//
+// Returns:
+// - Message: poll_update 類型的事件訊息
+func NewPollUpdateMessage(poll Poll, channel string) Message {
+	return Message{
+		ID:        generateMessageID(),
+		User:      "System",
+		Content:   poll.Question,
+		Timestamp: time.Now(),
+		Type:      MessageTypePollUpdate,
+		Channel:   channel,
+		Poll:      &poll,
+	}
+}
+
+// NewCommandReplyMessage 建立只回覆給指令執行者的訊息
+//
+// Parameters:
+// - text: 回覆內容
+// - channel: 所屬頻道
+//
+// Returns:
+// - Message: command_reply 類型的事件訊息
+func NewCommandReplyMessage(text, channel string) Message {
+	return Message{
+		ID:        generateMessageID(),
+		User:      "System",
+		Content:   text,
+		Timestamp: time.Now(),
+		Type:      MessageTypeCommandReply,
+		Channel:   channel,
+	}
+}
+
+// NewInviteMessage 建立推送給被邀請者的邀請事件
+//
+// Parameters:
+// - inviter: 邀請者
+// - channel: 邀請加入的頻道
+//
+// Returns:
+// - Message: invite 類型的事件訊息
+func NewInviteMessage(inviter, channel string) Message {
+	return Message{
+		ID:        generateMessageID(),
+		User:      inviter,
+		Content:   fmt.Sprintf(InviteMessageTemplate, inviter, channel),
+		Timestamp: time.Now(),
+		Type:      MessageTypeInvite,
+		Channel:   channel,
+	}
+}
+
+// IsExpired 檢查訊息在指定時間是否已過期
+//
+// Parameters:
+// - now: 比較的時間
+//
+// Returns:
+// - bool: true 如果訊息設有過期時間且已到期
+func (m Message) IsExpired(now time.Time) bool {
+	return m.ExpiresAt != nil && !m.ExpiresAt.After(now)
+}
+
 // IsSystemMessage 檢查是否為系統訊息
 //
 // Returns:
@@ -188,27 +431,39 @@ func (m Message) BelongsToChannel(channel string) bool {
 }
 
 // MessageStore 提供訊息存儲的便利方法
+//
+// Design considerations:
+// - 過期訊息由背景 goroutine 刪除，所有方法都透過 messageStoreMu 保護
 type MessageStore map[string][]Message
 
+// messageStoreMu 保護 MessageStore 的併發存取
+var messageStoreMu sync.RWMutex
+
 // AddMessage 將訊息添加到指定頻道
 //
 // Responsible for:
 // - 將訊息存儲到對應頻道
 // - 自動初始化頻道存儲（如果不存在）
+// - 同步更新全文搜尋索引
 //
 // Parameters:
 // - message: 要存儲的訊息
 func (ms MessageStore) AddMessage(message Message) {
+	messageStoreMu.Lock()
 	if ms[message.Channel] == nil {
 		ms[message.Channel] = []Message{}
 	}
 	ms[message.Channel] = append(ms[message.Channel], message)
+	messageStoreMu.Unlock()
+
+	messageIndex.Index(message)
 }
 
 // GetRecentMessages 獲取頻道的最近訊息
 //
 // Responsible for:
 // - 獲取指定頻道的最近 N 條訊息
+// - 略過已過期但尚未被清除的訊息
 // - 如果頻道無訊息則返回歡迎訊息
 //
 // Parameters:
@@ -218,7 +473,16 @@ func (ms MessageStore) AddMessage(message Message) {
 // Returns:
 // - []Message: 最近的訊息列表
 func (ms MessageStore) GetRecentMessages(channel string, limit int) []Message {
-	channelMessages := ms[channel]
+	messageStoreMu.RLock()
+	defer messageStoreMu.RUnlock()
+
+	now := time.Now()
+	channelMessages := make([]Message, 0, len(ms[channel]))
+	for _, message := range ms[channel] {
+		if !message.IsExpired(now) {
+			channelMessages = append(channelMessages, message)
+		}
+	}
 	
 	// 如果沒有訊息，返回歡迎訊息
 	if len(channelMessages) == 0 {
@@ -234,6 +498,130 @@ func (ms MessageStore) GetRecentMessages(channel string, limit int) []Message {
 	return channelMessages[start:]
 }
 
+// FindMessage 依 ID 查找頻道中的訊息
+//
+// Parameters:
+// - channel: 頻道名稱
+// - id: 訊息 ID
+//
+// Returns:
+// - Message: 找到的訊息
+// - bool: 是否找到
+func (ms MessageStore) FindMessage(channel, id string) (Message, bool) {
+	messageStoreMu.RLock()
+	defer messageStoreMu.RUnlock()
+
+	for _, message := range ms[channel] {
+		if message.ID == id && !message.IsExpired(time.Now()) {
+			return message, true
+		}
+	}
+	return Message{}, false
+}
+
+// UpdateMessage 修改頻道中的訊息
+//
+// Parameters:
+// - channel: 頻道名稱
+// - id: 訊息 ID
+// - update: 修改訊息的函式，在持有鎖時調用
+//
+// Returns:
+// - bool: 是否找到訊息
+func (ms MessageStore) UpdateMessage(channel, id string, update func(*Message)) bool {
+	messageStoreMu.Lock()
+	defer messageStoreMu.Unlock()
+
+	for i := range ms[channel] {
+		if ms[channel][i].ID == id {
+			update(&ms[channel][i])
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveMessage 刪除頻道中的指定訊息
+//
+// Parameters:
+// - channel: 頻道名稱
+// - id: 訊息 ID
+//
+// Returns:
+// - Message: 被刪除的訊息
+// - bool: 是否找到訊息
+func (ms MessageStore) RemoveMessage(channel, id string) (Message, bool) {
+	messageStoreMu.Lock()
+	var removed Message
+	found := false
+	for i, message := range ms[channel] {
+		if message.ID == id {
+			removed = message
+			found = true
+			ms[channel] = append(ms[channel][:i], ms[channel][i+1:]...)
+			break
+		}
+	}
+	messageStoreMu.Unlock()
+
+	if found {
+		messageIndex.Remove(id)
+	}
+	return removed, found
+}
+
+// RestoreMessage 將先前刪除的訊息依時間順序放回頻道
+//
+// Design considerations:
+// - 依時間戳插入原本的位置，歷史記錄的順序維持不變
+// - 同步恢復全文搜尋索引
+//
+// Parameters:
+// - message: 要恢復的訊息
+func (ms MessageStore) RestoreMessage(message Message) {
+	messageStoreMu.Lock()
+	messages := ms[message.Channel]
+	i := sort.Search(len(messages), func(i int) bool {
+		return messages[i].Timestamp.After(message.Timestamp)
+	})
+	messages = append(messages, Message{})
+	copy(messages[i+1:], messages[i:])
+	messages[i] = message
+	ms[message.Channel] = messages
+	messageStoreMu.Unlock()
+
+	messageIndex.Index(message)
+}
+
+// RemoveExpired 刪除所有已過期的訊息
+//
+// Parameters:
+// - now: 判斷過期的時間
+//
+// Returns:
+// - []Message: 被刪除的訊息
+func (ms MessageStore) RemoveExpired(now time.Time) []Message {
+	messageStoreMu.Lock()
+	var expired []Message
+	for channel, messages := range ms {
+		kept := messages[:0]
+		for _, message := range messages {
+			if message.IsExpired(now) {
+				expired = append(expired, message)
+			} else {
+				kept = append(kept, message)
+			}
+		}
+		ms[channel] = kept
+	}
+	messageStoreMu.Unlock()
+
+	for _, message := range expired {
+		messageIndex.Remove(message.ID)
+	}
+	return expired
+}
+
 // GetChannelMessageCount 獲取頻道的訊息總數
 //
 // Parameters:
@@ -242,20 +630,36 @@ func (ms MessageStore) GetRecentMessages(channel string, limit int) []Message {
 // Returns:
 // - int: 訊息總數
 func (ms MessageStore) GetChannelMessageCount(channel string) int {
+	messageStoreMu.RLock()
+	defer messageStoreMu.RUnlock()
+
 	return len(ms[channel])
 }
 
 // Clear 清空所有訊息
 func (ms MessageStore) Clear() {
+	messageStoreMu.Lock()
 	for channel := range ms {
 		delete(ms, channel)
 	}
+	messageStoreMu.Unlock()
+
+	messageIndex.Reset()
 }
 
 // ClearChannel 清空指定頻道的訊息
 //
 // Parameters:
 // - channel: 要清空的頻道名稱
-func (ms MessageStore) ClearChannel(channel string) {
+//
+// Returns:
+// - []Message: 被清除的訊息，供呼叫者清理提及、投票與釘選
+func (ms MessageStore) ClearChannel(channel string) []Message {
+	messageStoreMu.Lock()
+	removed := ms[channel]
 	delete(ms, channel)
+	messageStoreMu.Unlock()
+
+	messageIndex.RemoveChannel(channel)
+	return removed
 }
\ No newline at end of file
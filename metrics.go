@@ -0,0 +1,65 @@
+package main
+
+import (
+	"sync"
+)
+
+// Metrics 伺服器運行指標的計數器
+//
+// Responsible for:
+// - 累計各種事件的發生次數（例如觸發速率限制、登入失敗）
+// - 提供一致的快照供 API 查詢
+//
+// Design considerations:
+// - 計數器以名稱區分，第一次累加時自動建立
+// - 只在記憶體中累計，重新啟動後歸零
+type Metrics struct {
+	mu       sync.Mutex
+	counters map[string]int64
+}
+
+// NewMetrics 建立空的指標計數器
+//
+// Returns:
+// - *Metrics: 新的指標實例
+func NewMetrics() *Metrics {
+	return &Metrics{counters: make(map[string]int64)}
+}
+
+// Inc 將計數器加一
+//
+// Parameters:
+// - name: 計數器名稱
+func (m *Metrics) Inc(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[name]++
+}
+
+// Get 取得計數器目前的值
+//
+// Parameters:
+// - name: 計數器名稱
+//
+// Returns:
+// - int64: 目前的值，不存在時為 0
+func (m *Metrics) Get(name string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counters[name]
+}
+
+// Snapshot 取得所有計數器的複本
+//
+// Returns:
+// - map[string]int64: 計數器名稱對應目前的值
+func (m *Metrics) Snapshot() map[string]int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := make(map[string]int64, len(m.counters))
+	for name, value := range m.counters {
+		snapshot[name] = value
+	}
+	return snapshot
+}
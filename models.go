@@ -1,6 +1,8 @@
 package main
 
 import (
+	"time"
+
 	"github.com/gorilla/websocket"
 )
 
@@ -20,10 +22,9 @@ import (
 type Account struct {
 	Username string `json:"username"` // 用戶名稱
 	Password string `json:"password"` // 登入密碼
-	Channel  string `json:"channel"`  // 所屬頻道
+	Channel  string `json:"channel"`  // 所屬頻道，帳號在此頻道預設為 member
 }
 
-
 // Client 代表 WebSocket 客戶端連接
 //
 // Responsible for:
@@ -51,6 +52,11 @@ type Client struct {
 	send     chan Message    // 訊息發送佇列
 	username string          // 用戶名稱
 	channel  string          // 所屬頻道
+
+	closeReason string    // 被 Hub 強制斷線時的關閉原因，由 Hub 在關閉 send 前設置
+	id          string    // 連接 ID，由 Hub 在註冊時設置
+	connectedAt time.Time // 連接時間，由 Hub 在註冊時設置
+	ip          string    // 連接來源 IP，用於稽核日誌
 }
 
 // Hub 管理所有 WebSocket 連接
@@ -69,17 +75,27 @@ type Client struct {
 // Process flow:
 // 1. 啟動時開始運行事件迴圈
 // 2. 監聽 register、unregister、broadcast 三個 channel
-// 3. 註冊時將客戶端加入 clients map 並發送歡迎訊息
-// 4. 取消註冊時移除客戶端並發送離開訊息
+// 3. 註冊時將客戶端加入 clients map，第一個裝置連接時發送加入訊息
+// 4. 取消註冊時移除客戶端，最後一個裝置離線且寬限期結束後發送離開訊息
 // 5. 廣播時只發送給相同頻道的客戶端
+// 6. 私人訊息只發送給指定的連接或用戶
+// 7. 強制斷線時通知被踢出的連接並關閉其發送佇列
+// 8. 查詢連接列表時在事件迴圈中建立快照，避免與註冊操作競爭
 //
 // Usage context:
 // - 程式啟動時在獨立 goroutine 中運行
 // - WebSocket 連接建立/斷開時進行註冊操作
 // - 收到新訊息時進行廣播
 type Hub struct {
-	clients    map[*Client]bool // 已註冊的客戶端
-	broadcast  chan Message     // 廣播訊息佇列
-	register   chan *Client     // 客戶端註冊佇列
-	unregister chan *Client     // 客戶端取消註冊佇列
+	clients    map[*Client]bool           // 已註冊的客戶端
+	broadcast  chan Message               // 廣播訊息佇列
+	register   chan *Client               // 客戶端註冊佇列
+	unregister chan *Client               // 客戶端取消註冊佇列
+	direct     chan directMessage         // 指定接收者的私人訊息佇列
+	kick       chan kickRequest           // 強制斷線請求佇列
+	inspect    chan chan []ConnectionInfo // 連接列表查詢佇列
+
+	leaveGracePeriod time.Duration                 // 離開通知的寬限期，0 表示立即發送
+	pendingLeaves    map[presenceKey]*pendingLeave // 等待寬限期結束的離開通知
+	leaveExpired     chan *pendingLeave            // 寬限期到期通知佇列
 }
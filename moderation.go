@@ -0,0 +1,596 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrMessageRejected   = errors.New(ErrorMessageRejected)
+	ErrFlagNotFound      = errors.New(ErrorFlagNotFound)
+	ErrFlagReviewed      = errors.New(ErrorFlagReviewed)
+	ErrInvalidFlagReview = errors.New(ErrorInvalidFlagReview)
+)
+
+// linkPattern 辨識訊息內容中的網址
+var linkPattern = regexp.MustCompile(`(?i)\bhttps?://[^\s<>()\[\]]+|\bwww\.[^\s<>()\[\]]+`)
+
+// ModerationResult 代表單一過濾器對訊息的判斷
+//
+// Design considerations:
+// - Action 為 allow、modify、reject 或 flag 其中之一
+// - modify 時 Content 為修改後的內容，其他動作忽略 Content
+// - reject 的 Reason 會回覆給發送者，flag 的 Reason 顯示在審核佇列
+type ModerationResult struct {
+	Action  string
+	Content string
+	Reason  string
+}
+
+// ModerationFilter 內容審核過濾器
+//
+// Design considerations:
+// - 過濾器只判斷不修改訊息，由 ModerationPipeline 套用結果
+// - Check 可能被多個 goroutine 同時調用，有狀態的過濾器需自行加鎖
+type ModerationFilter interface {
+	// Name 返回過濾器名稱，用於日誌與審核佇列
+	Name() string
+	// Check 檢查訊息並返回判斷結果
+	Check(msg Message) ModerationResult
+}
+
+// ModerationFlag 代表訊息被標記待審核的原因
+type ModerationFlag struct {
+	Filter string `json:"filter"` // 標記的過濾器名稱
+	Reason string `json:"reason"` // 標記原因
+}
+
+// ModerationPipeline 依序執行內容審核過濾器
+//
+// Responsible for:
+// - 在訊息寫入歷史前依序執行所有過濾器
+// - 套用過濾器修改後的內容，並收集標記原因
+//
+// Design considerations:
+// - 過濾器按註冊順序執行，後面的過濾器看到的是前面修改後的內容
+// - 任一過濾器拒絕時立即停止，訊息不會被儲存
+// - 系統訊息與空白內容不審核
+type ModerationPipeline struct {
+	mu      sync.RWMutex
+	filters []ModerationFilter
+}
+
+// NewModerationPipeline 建立內容審核流程
+//
+// Parameters:
+// - filters: 依序執行的過濾器
+//
+// Returns:
+// - *ModerationPipeline: 新的審核流程實例
+func NewModerationPipeline(filters ...ModerationFilter) *ModerationPipeline {
+	return &ModerationPipeline{filters: filters}
+}
+
+// newDefaultModerationPipeline 建立包含預設過濾器的審核流程
+//
+// Design considerations:
+// - 洗版過濾器最先執行，先壓縮重複字元再檢查長度
+// - 遮蔽髒話後才檢查觀察名單，避免同一個字被重複處理
+func newDefaultModerationPipeline() *ModerationPipeline {
+	return NewModerationPipeline(
+		NewFloodFilter(DefaultModerationMaxRepeat, DefaultModerationMaxDuplicates, DefaultModerationDuplicateWindow*time.Second),
+		NewMaxLengthFilter(DefaultMaxMessageLength),
+		NewLinkFilter(DefaultModerationBlockedDomains, DefaultModerationMaxLinks),
+		NewWordListFilter(ModerationFilterProfanity, DefaultModerationMaskedWords, ModerationActionModify),
+		NewWordListFilter(ModerationFilterWatchlist, DefaultModerationFlaggedWords, ModerationActionFlag),
+	)
+}
+
+// Use 在流程最後加入過濾器
+//
+// Parameters:
+// - filter: 要加入的過濾器
+func (p *ModerationPipeline) Use(filter ModerationFilter) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.filters = append(p.filters, filter)
+}
+
+// Replace 以同名的過濾器取代流程中的過濾器
+//
+// Design considerations:
+// - 保留其他過濾器的狀態（例如洗版偵測的發送紀錄），只更新指定的過濾器
+//
+// Parameters:
+// - filter: 新的過濾器
+//
+// Returns:
+// - bool: 是否找到同名的過濾器
+func (p *ModerationPipeline) Replace(filter ModerationFilter) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, existing := range p.filters {
+		if existing.Name() == filter.Name() {
+			p.filters[i] = filter
+			return true
+		}
+	}
+	return false
+}
+
+// Moderate 審核訊息內容
+//
+// Process flow:
+// 1. 略過系統訊息與空白內容
+// 2. 依序執行過濾器，modify 直接改寫訊息內容
+// 3. reject 時返回包含原因的錯誤，flag 時記錄標記原因並繼續
+//
+// Parameters:
+// - msg: 要審核的訊息，可能被修改 Content
+//
+// Returns:
+// - []ModerationFlag: 需要人工審核的原因，沒有標記時為 nil
+// - error: 被拒絕時返回 ErrMessageRejected
+func (p *ModerationPipeline) Moderate(msg *Message) ([]ModerationFlag, error) {
+	if msg.IsSystemMessage() || msg.Content == "" {
+		return nil, nil
+	}
+
+	p.mu.RLock()
+	filters := append([]ModerationFilter(nil), p.filters...)
+	p.mu.RUnlock()
+
+	var flags []ModerationFlag
+	for _, filter := range filters {
+		result := filter.Check(*msg)
+		switch result.Action {
+		case ModerationActionModify:
+			msg.Content = result.Content
+		case ModerationActionReject:
+			return nil, fmt.Errorf("%w: %s", ErrMessageRejected, result.Reason)
+		case ModerationActionFlag:
+			flags = append(flags, ModerationFlag{Filter: filter.Name(), Reason: result.Reason})
+		}
+	}
+	return flags, nil
+}
+
+// MaxLengthFilter 拒絕過長的訊息
+type MaxLengthFilter struct {
+	max int
+}
+
+// NewMaxLengthFilter 建立長度過濾器
+//
+// Parameters:
+// - max: 訊息內容的字元數上限
+func NewMaxLengthFilter(max int) *MaxLengthFilter {
+	return &MaxLengthFilter{max: max}
+}
+
+// Name 返回過濾器名稱
+func (f *MaxLengthFilter) Name() string {
+	return ModerationFilterMaxLength
+}
+
+// Check 內容超過字元數上限時拒絕
+func (f *MaxLengthFilter) Check(msg Message) ModerationResult {
+	if utf8.RuneCountInString(msg.Content) > f.max {
+		return ModerationResult{Action: ModerationActionReject, Reason: fmt.Sprintf(ModerationReasonTooLong, f.max)}
+	}
+	return ModerationResult{Action: ModerationActionAllow}
+}
+
+// LinkFilter 封鎖指定網域的連結並標記連結過多的訊息
+//
+// Design considerations:
+// - 封鎖網域也涵蓋其子網域，例如封鎖 bit.ly 也會封鎖 www.bit.ly
+// - 連結數量超過上限的訊息常見於廣告，標記而非拒絕
+type LinkFilter struct {
+	blocked  []string
+	maxLinks int
+}
+
+// NewLinkFilter 建立連結過濾器
+//
+// Parameters:
+// - blocked: 封鎖的網域
+// - maxLinks: 不需標記的連結數量上限
+func NewLinkFilter(blocked []string, maxLinks int) *LinkFilter {
+	domains := make([]string, len(blocked))
+	for i, domain := range blocked {
+		domains[i] = strings.ToLower(domain)
+	}
+	return &LinkFilter{blocked: domains, maxLinks: maxLinks}
+}
+
+// Name 返回過濾器名稱
+func (f *LinkFilter) Name() string {
+	return ModerationFilterLinks
+}
+
+// Check 檢查訊息中的網址
+func (f *LinkFilter) Check(msg Message) ModerationResult {
+	links := linkPattern.FindAllString(msg.Content, -1)
+	for _, link := range links {
+		if host := linkHost(link); f.isBlocked(host) {
+			return ModerationResult{Action: ModerationActionReject, Reason: fmt.Sprintf(ModerationReasonBlockedLink, host)}
+		}
+	}
+	if len(links) > f.maxLinks {
+		return ModerationResult{Action: ModerationActionFlag, Reason: fmt.Sprintf(ModerationReasonTooManyLinks, len(links))}
+	}
+	return ModerationResult{Action: ModerationActionAllow}
+}
+
+// isBlocked 檢查主機是否屬於封鎖的網域
+func (f *LinkFilter) isBlocked(host string) bool {
+	for _, domain := range f.blocked {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// linkHost 取得網址的主機名稱（小寫）
+func linkHost(link string) string {
+	if !strings.Contains(link, "://") {
+		link = "http://" + link
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+// FloodFilter 處理洗版訊息
+//
+// Responsible for:
+// - 拒絕同一用戶在短時間內於同一頻道連續發送的相同內容
+// - 將連續重複的字元壓縮到上限，例如「哈哈哈哈…」
+//
+// Design considerations:
+// - 相同內容的比較忽略大小寫與前後空白
+// - 被拒絕的重複訊息也會延長計時，持續洗版時一直被拒絕
+type FloodFilter struct {
+	mu            sync.Mutex
+	maxRepeat     int
+	maxDuplicates int
+	window        time.Duration
+	recent        map[string]*recentContent
+	now           func() time.Time
+}
+
+// recentContent 記錄用戶在頻道中最後發送的內容
+type recentContent struct {
+	content string
+	count   int
+	at      time.Time
+}
+
+// NewFloodFilter 建立洗版過濾器
+//
+// Parameters:
+// - maxRepeat: 同一字元最多連續出現的次數
+// - maxDuplicates: 時間範圍內最多連續發送相同內容的次數
+// - window: 判斷重複內容的時間範圍
+func NewFloodFilter(maxRepeat, maxDuplicates int, window time.Duration) *FloodFilter {
+	return &FloodFilter{
+		maxRepeat:     maxRepeat,
+		maxDuplicates: maxDuplicates,
+		window:        window,
+		recent:        make(map[string]*recentContent),
+		now:           time.Now,
+	}
+}
+
+// Name 返回過濾器名稱
+func (f *FloodFilter) Name() string {
+	return ModerationFilterFlood
+}
+
+// Check 拒絕重複內容或壓縮重複字元
+func (f *FloodFilter) Check(msg Message) ModerationResult {
+	if f.isDuplicate(msg) {
+		return ModerationResult{Action: ModerationActionReject, Reason: ModerationReasonDuplicate}
+	}
+	if collapsed, changed := collapseRepeats(msg.Content, f.maxRepeat); changed {
+		return ModerationResult{Action: ModerationActionModify, Content: collapsed}
+	}
+	return ModerationResult{Action: ModerationActionAllow}
+}
+
+// isDuplicate 記錄訊息內容並檢查是否超過重複次數
+func (f *FloodFilter) isDuplicate(msg Message) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.now()
+	key := msg.User + "\x00" + msg.Channel
+	content := strings.ToLower(strings.TrimSpace(msg.Content))
+
+	last, ok := f.recent[key]
+	if !ok || last.content != content || now.Sub(last.at) > f.window {
+		f.recent[key] = &recentContent{content: content, count: 1, at: now}
+		return false
+	}
+	last.count++
+	last.at = now
+	return last.count > f.maxDuplicates
+}
+
+// collapseRepeats 將連續重複超過 max 次的字元壓縮為 max 個
+//
+// Returns:
+// - string: 壓縮後的內容
+// - bool: 內容是否有變更
+func collapseRepeats(content string, max int) (string, bool) {
+	var b strings.Builder
+	changed := false
+	var prev rune
+	run := 0
+	for _, r := range content {
+		if r == prev {
+			run++
+		} else {
+			prev, run = r, 1
+		}
+		if run > max {
+			changed = true
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String(), changed
+}
+
+// WordListFilter 依詞彙清單遮蔽或標記訊息
+//
+// Design considerations:
+// - 比對不分大小寫；英數字詞彙需要完整單字才符合，避免誤判包含該字的其他單字
+// - action 為 modify 時以 * 遮蔽符合的詞彙，為 flag 時保留內容並標記待審核
+type WordListFilter struct {
+	name   string
+	words  [][]rune
+	action string
+}
+
+// NewWordListFilter 建立詞彙過濾器
+//
+// Parameters:
+// - name: 過濾器名稱
+// - words: 詞彙清單
+// - action: 符合時的動作（modify 或 flag）
+func NewWordListFilter(name string, words []string, action string) *WordListFilter {
+	f := &WordListFilter{name: name, action: action}
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			f.words = append(f.words, []rune(strings.ToLower(word)))
+		}
+	}
+	return f
+}
+
+// Name 返回過濾器名稱
+func (f *WordListFilter) Name() string {
+	return f.name
+}
+
+// Check 檢查內容是否包含清單中的詞彙
+func (f *WordListFilter) Check(msg Message) ModerationResult {
+	content := []rune(msg.Content)
+	lower := make([]rune, len(content))
+	for i, r := range content {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	var matched []string
+	for _, word := range f.words {
+		for start := indexRunes(lower, word, 0); start >= 0; start = indexRunes(lower, word, start+1) {
+			end := start + len(word)
+			if !wordBoundary(lower, start, end) {
+				continue
+			}
+			matched = append(matched, string(word))
+			for i := start; i < end; i++ {
+				content[i] = '*'
+			}
+		}
+	}
+
+	switch {
+	case len(matched) == 0:
+		return ModerationResult{Action: ModerationActionAllow}
+	case f.action == ModerationActionModify:
+		return ModerationResult{Action: ModerationActionModify, Content: string(content)}
+	default:
+		return ModerationResult{Action: f.action, Reason: fmt.Sprintf(ModerationReasonWatchedWord, strings.Join(matched, ", "))}
+	}
+}
+
+// wordBoundary 檢查符合範圍兩側是否為單字邊界
+//
+// Design considerations:
+// - 只有 ASCII 英數字需要邊界，中文等沒有空白分詞的文字直接視為符合
+func wordBoundary(src []rune, start, end int) bool {
+	isASCIIWord := func(r rune) bool {
+		return r < utf8.RuneSelf && isWordRune(r)
+	}
+	if start > 0 && isASCIIWord(src[start]) && isASCIIWord(src[start-1]) {
+		return false
+	}
+	if end < len(src) && isASCIIWord(src[end-1]) && isASCIIWord(src[end]) {
+		return false
+	}
+	return true
+}
+
+// FlaggedMessage 代表審核佇列中的訊息
+type FlaggedMessage struct {
+	Message    Message          `json:"message"`              // 被標記的訊息（審核時的內容）
+	Flags      []ModerationFlag `json:"flags"`                // 標記原因
+	Status     string           `json:"status"`               // pending、approved 或 removed
+	FlaggedAt  time.Time        `json:"flaggedAt"`            // 標記時間
+	ReviewedBy string           `json:"reviewedBy,omitempty"` // 審核者
+	ReviewedAt *time.Time       `json:"reviewedAt,omitempty"` // 審核時間
+}
+
+// ModerationQueue 待人工審核的訊息佇列
+//
+// Design considerations:
+// - 以訊息 ID 為索引，每則訊息只有一筆紀錄
+// - 審核後保留紀錄，可依狀態查詢歷史
+type ModerationQueue struct {
+	mu    sync.RWMutex
+	items map[string]*FlaggedMessage
+	now   func() time.Time
+}
+
+// NewModerationQueue 建立審核佇列
+//
+// Returns:
+// - *ModerationQueue: 空的審核佇列
+func NewModerationQueue() *ModerationQueue {
+	return &ModerationQueue{
+		items: make(map[string]*FlaggedMessage),
+		now:   time.Now,
+	}
+}
+
+// Add 將訊息加入審核佇列
+//
+// Parameters:
+// - msg: 被標記的訊息
+// - flags: 標記原因，為空時不加入
+func (q *ModerationQueue) Add(msg Message, flags []ModerationFlag) {
+	if len(flags) == 0 {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.items[msg.ID] = &FlaggedMessage{
+		Message:   msg,
+		Flags:     flags,
+		Status:    FlagStatusPending,
+		FlaggedAt: q.now(),
+	}
+
+	reasons := make([]string, len(flags))
+	for i, flag := range flags {
+		reasons[i] = flag.Filter + ": " + flag.Reason
+	}
+	log.Printf(LogMessageFlagged, msg.ID, msg.User, strings.Join(reasons, "; "))
+}
+
+// Get 依訊息 ID 取得審核紀錄
+//
+// Returns:
+// - FlaggedMessage: 審核紀錄
+// - bool: 是否存在
+func (q *ModerationQueue) Get(id string) (FlaggedMessage, bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	item, ok := q.items[id]
+	if !ok {
+		return FlaggedMessage{}, false
+	}
+	return *item, true
+}
+
+// List 列出指定頻道的審核紀錄
+//
+// Parameters:
+// - channels: 要列出的頻道
+// - status: 要列出的狀態，空字串表示全部
+//
+// Returns:
+// - []FlaggedMessage: 依標記時間由舊到新排序的紀錄
+func (q *ModerationQueue) List(channels []string, status string) []FlaggedMessage {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	items := []FlaggedMessage{}
+	for _, item := range q.items {
+		if !containsString(channels, item.Message.Channel) {
+			continue
+		}
+		if status != "" && item.Status != status {
+			continue
+		}
+		items = append(items, *item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].FlaggedAt.Before(items[j].FlaggedAt)
+	})
+	return items
+}
+
+// Review 記錄審核結果
+//
+// Design considerations:
+// - 每則訊息只能審核一次
+// - 只更新佇列狀態，刪除訊息由呼叫者處理
+//
+// Parameters:
+// - id: 訊息 ID
+// - reviewer: 審核者用戶名
+// - decision: approve 或 remove
+//
+// Returns:
+// - FlaggedMessage: 更新後的紀錄
+// - error: 找不到、已審核或 decision 無效時返回錯誤
+func (q *ModerationQueue) Review(id, reviewer, decision string) (FlaggedMessage, error) {
+	var status string
+	switch decision {
+	case FlagReviewApprove:
+		status = FlagStatusApproved
+	case FlagReviewRemove:
+		status = FlagStatusRemoved
+	default:
+		return FlaggedMessage{}, ErrInvalidFlagReview
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	item, ok := q.items[id]
+	if !ok {
+		return FlaggedMessage{}, ErrFlagNotFound
+	}
+	if item.Status != FlagStatusPending {
+		return FlaggedMessage{}, ErrFlagReviewed
+	}
+	reviewedAt := q.now()
+	item.Status = status
+	item.ReviewedBy = reviewer
+	item.ReviewedAt = &reviewedAt
+	return *item, nil
+}
+
+// moderationStatusCode 將審核相關錯誤對應到 HTTP 狀態碼
+func moderationStatusCode(err error) int {
+	switch {
+	case errors.Is(err, ErrFlagNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrFlagReviewed):
+		return http.StatusConflict
+	case errors.Is(err, ErrInvalidFlagReview):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestModerationFilters 測試各個內容過濾器的判斷
+func TestModerationFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  ModerationFilter
+		content string
+		action  string
+		result  string
+	}{
+		{"長度上限內", NewMaxLengthFilter(5), "你好世界！", ModerationActionAllow, ""},
+		{"超過長度上限", NewMaxLengthFilter(5), "你好世界！！", ModerationActionReject, ""},
+		{"封鎖網域", NewLinkFilter([]string{"bit.ly"}, 5), "看 https://bit.ly/abc", ModerationActionReject, ""},
+		{"封鎖子網域", NewLinkFilter([]string{"bit.ly"}, 5), "看 www.BIT.ly/abc", ModerationActionReject, ""},
+		{"允許其他網域", NewLinkFilter([]string{"bit.ly"}, 5), "[文件](https://go.dev/doc)", ModerationActionAllow, ""},
+		{"連結過多", NewLinkFilter(nil, 1), "https://a.com https://b.com", ModerationActionFlag, ""},
+		{"壓縮重複字元", NewFloodFilter(3, 2, time.Minute), "哈哈哈哈哈！", ModerationActionModify, "哈哈哈！"},
+		{"遮蔽詞彙", NewWordListFilter("profanity", []string{"shit"}, ModerationActionModify), "Oh SHIT!", ModerationActionModify, "Oh ****!"},
+		{"英文需要完整單字", NewWordListFilter("profanity", []string{"shit"}, ModerationActionModify), "shitake", ModerationActionAllow, ""},
+		{"中文不需要邊界", NewWordListFilter("profanity", []string{"幹你娘"}, ModerationActionModify), "真的幹你娘啦", ModerationActionModify, "真的***啦"},
+		{"標記詞彙", NewWordListFilter("watchlist", []string{"保證獲利"}, ModerationActionFlag), "這個保證獲利", ModerationActionFlag, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := test.filter.Check(Message{User: "bob", Channel: "tech", Content: test.content})
+			if result.Action != test.action {
+				t.Fatalf("Expected action %s, got %+v", test.action, result)
+			}
+			if test.action == ModerationActionModify && result.Content != test.result {
+				t.Errorf("Expected content %q, got %q", test.result, result.Content)
+			}
+			if (test.action == ModerationActionReject || test.action == ModerationActionFlag) && result.Reason == "" {
+				t.Error("Expected a reason")
+			}
+		})
+	}
+}
+
+// TestFloodFilterDuplicates 測試重複內容的判斷
+func TestFloodFilterDuplicates(t *testing.T) {
+	now := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	filter := NewFloodFilter(10, 2, 30*time.Second)
+	filter.now = func() time.Time { return now }
+	msg := Message{User: "bob", Channel: "tech", Content: "在嗎"}
+
+	for i := 0; i < 2; i++ {
+		if result := filter.Check(msg); result.Action != ModerationActionAllow {
+			t.Fatalf("Expected message %d to be allowed, got %+v", i+1, result)
+		}
+	}
+	if result := filter.Check(Message{User: "bob", Channel: "tech", Content: " 在嗎 "}); result.Action != ModerationActionReject {
+		t.Errorf("Expected third duplicate to be rejected, got %+v", result)
+	}
+	if result := filter.Check(Message{User: "bob", Channel: "random", Content: "在嗎"}); result.Action != ModerationActionAllow {
+		t.Errorf("Expected other channels to be independent, got %+v", result)
+	}
+
+	now = now.Add(31 * time.Second)
+	if result := filter.Check(msg); result.Action != ModerationActionAllow {
+		t.Errorf("Expected duplicate after window to be allowed, got %+v", result)
+	}
+}
+
+// TestModerationPipeline 測試過濾器的執行順序與結果套用
+func TestModerationPipeline(t *testing.T) {
+	pipeline := NewModerationPipeline(
+		NewWordListFilter("profanity", []string{"shit"}, ModerationActionModify),
+		NewWordListFilter("watchlist", []string{"free money"}, ModerationActionFlag),
+		NewMaxLengthFilter(30),
+	)
+
+	t.Run("修改後繼續審核", func(t *testing.T) {
+		msg := Message{Content: "shit, free money!"}
+		flags, err := pipeline.Moderate(&msg)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if msg.Content != "****, free money!" {
+			t.Errorf("Expected masked content, got %q", msg.Content)
+		}
+		if len(flags) != 1 || flags[0].Filter != "watchlist" {
+			t.Errorf("Expected watchlist flag, got %+v", flags)
+		}
+	})
+
+	t.Run("拒絕", func(t *testing.T) {
+		msg := Message{Content: strings.Repeat("長", 31)}
+		if _, err := pipeline.Moderate(&msg); err == nil || !strings.Contains(err.Error(), "30") {
+			t.Errorf("Expected rejection with reason, got %v", err)
+		}
+	})
+
+	t.Run("略過系統訊息", func(t *testing.T) {
+		msg := NewSystemMessage(strings.Repeat("長", 31), "general")
+		if _, err := pipeline.Moderate(&msg); err != nil {
+			t.Errorf("Expected system messages to skip moderation, got %v", err)
+		}
+	})
+}
+
+// TestModerationAPI 測試訊息審核與審核佇列 API
+func TestModerationAPI(t *testing.T) {
+	hub = *newTestHub(0)
+	messageStore = make(MessageStore)
+	messageIndex = NewSearchIndex()
+	muteStore = NewMuteStore()
+	moderationPipeline = newDefaultModerationPipeline()
+	moderationQueue = NewModerationQueue()
+
+	getFlags := func(username string) (*httptest.ResponseRecorder, []FlaggedMessage) {
+		req, _ := http.NewRequest("GET", "/api/moderation/flags", nil)
+		req.SetBasicAuth(username, "password123")
+		rr := httptest.NewRecorder()
+		setupRoutes().ServeHTTP(rr, req)
+		var response struct {
+			Flags []FlaggedMessage `json:"flags"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		return rr, response.Flags
+	}
+	review := func(username, id, action string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/moderation/flags/"+id, strings.NewReader(`{"action":"`+action+`"}`))
+		req.SetBasicAuth(username, "password123")
+		rr := httptest.NewRecorder()
+		setupRoutes().ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("拒絕時回覆原因", func(t *testing.T) {
+		rr := postCommand("alice", "general", "快看 https://bit.ly/x")
+		var response map[string]string
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		if rr.Code != http.StatusBadRequest || response["code"] != ErrorCodeMessageRejected || !strings.Contains(response["error"], "bit.ly") {
+			t.Errorf("Expected 400 message_rejected, got %d %v", rr.Code, response)
+		}
+		if count := messageStore.GetChannelMessageCount("general"); count != 0 {
+			t.Errorf("Expected rejected message not to be stored, got %d", count)
+		}
+	})
+
+	t.Run("修改後儲存", func(t *testing.T) {
+		postCommand("alice", "general", "shit happens")
+		messages := messageStore.GetRecentMessages("general", 1)
+		if messages[0].Content != "**** happens" {
+			t.Errorf("Expected masked content to be stored, got %q", messages[0].Content)
+		}
+	})
+
+	var flaggedID string
+	t.Run("標記後進入審核佇列", func(t *testing.T) {
+		if rr := postCommand("alice", "general", "免費課程保證獲利"); rr.Code != http.StatusOK {
+			t.Fatalf("Expected flagged message to be delivered, got %d", rr.Code)
+		}
+		if rr, _ := getFlags("charlie"); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected 403 for non-moderator, got %d", rr.Code)
+		}
+		rr, flags := getFlags("alice")
+		if rr.Code != http.StatusOK || len(flags) != 1 || flags[0].Status != FlagStatusPending {
+			t.Fatalf("Expected one pending flag, got %d %+v", rr.Code, flags)
+		}
+		flaggedID = flags[0].Message.ID
+	})
+
+	t.Run("移除被標記的訊息", func(t *testing.T) {
+		observer := newTestClient("alice", "general")
+		hub.register <- observer
+		time.Sleep(10 * time.Millisecond)
+		for len(observer.send) > 0 {
+			<-observer.send
+		}
+
+		if rr := review("alice", flaggedID, "delete"); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for invalid action, got %d", rr.Code)
+		}
+		if rr := review("alice", flaggedID, FlagReviewRemove); rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if _, ok := messageStore.FindMessage("general", flaggedID); ok {
+			t.Error("Expected message to be removed")
+		}
+		select {
+		case event := <-observer.send:
+			if event.Type != MessageTypeDelete || event.Deletion.Reason != DeletionReasonModerated {
+				t.Errorf("Expected moderated delete event, got %+v", event)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected delete event")
+		}
+
+		if rr := review("alice", flaggedID, FlagReviewApprove); rr.Code != http.StatusConflict {
+			t.Errorf("Expected 409 for reviewed flag, got %d", rr.Code)
+		}
+		if _, flags := getFlags("alice"); len(flags) != 0 {
+			t.Errorf("Expected no pending flags, got %+v", flags)
+		}
+	})
+}
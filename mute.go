@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 禁言相關錯誤
+var (
+	ErrUserMuted           = errors.New(ErrorUserMuted)
+	ErrMuteNotFound        = errors.New(ErrorMuteNotFound)
+	ErrInvalidMuteDuration = errors.New(ErrorInvalidMuteDuration)
+)
+
+// MuteEntry 代表一筆禁言紀錄
+type MuteEntry struct {
+	Channel  string    `json:"channel"`  // 頻道名稱
+	Username string    `json:"username"` // 被禁言的用戶
+	Until    time.Time `json:"until"`    // 禁言結束時間
+}
+
+// MuteStore 管理各頻道的禁言名單
+//
+// Responsible for:
+// - 記錄用戶在頻道中的禁言結束時間
+// - 檢查用戶目前是否被禁言
+//
+// Design considerations:
+// - 禁言以頻道為範圍，不影響用戶在其他頻道發言
+// - 到期的禁言在查詢時立即視為不存在，由 Expire 統一清除並發布到期通知
+// - 重複禁言以最後一次的結束時間為準
+// - 時鐘可注入，測試時不需要實際等待
+//
+// Usage context:
+// - 全域 muteStore 實例
+// - /mute 指令與禁言 API 寫入，readPump 與 sendMessage 發送前檢查
+type MuteStore struct {
+	mu    sync.Mutex
+	mutes map[string]map[string]time.Time
+	now   func() time.Time
+}
+
+// NewMuteStore 建立禁言名單
+//
+// Returns:
+// - *MuteStore: 空的禁言名單
+func NewMuteStore() *MuteStore {
+	return &MuteStore{
+		mutes: make(map[string]map[string]time.Time),
+		now:   time.Now,
+	}
+}
+
+// Mute 在頻道中禁言用戶
+//
+// Parameters:
+// - channel: 頻道名稱
+// - username: 被禁言的用戶
+// - duration: 禁言時長
+//
+// Returns:
+// - time.Time: 禁言結束時間
+func (ms *MuteStore) Mute(channel, username string, duration time.Duration) time.Time {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	until := ms.now().Add(duration)
+	if ms.mutes[channel] == nil {
+		ms.mutes[channel] = make(map[string]time.Time)
+	}
+	ms.mutes[channel][username] = until
+	return until
+}
+
+// MutedUntil 查詢用戶在頻道中的禁言結束時間
+//
+// Parameters:
+// - channel: 頻道名稱
+// - username: 用戶名
+//
+// Returns:
+// - time.Time: 禁言結束時間
+// - bool: 目前是否被禁言
+func (ms *MuteStore) MutedUntil(channel, username string) (time.Time, bool) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	until, ok := ms.mutes[channel][username]
+	if !ok || !until.After(ms.now()) {
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// Unmute 提前解除用戶在頻道中的禁言
+//
+// Parameters:
+// - channel: 頻道名稱
+// - username: 用戶名
+//
+// Returns:
+// - bool: 用戶原本是否被禁言
+func (ms *MuteStore) Unmute(channel, username string) bool {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	until, ok := ms.mutes[channel][username]
+	delete(ms.mutes[channel], username)
+	return ok && until.After(ms.now())
+}
+
+// Expire 清除所有已到期的禁言
+//
+// Returns:
+// - []MuteEntry: 被清除的禁言紀錄
+func (ms *MuteStore) Expire() []MuteEntry {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := ms.now()
+	var expired []MuteEntry
+	for channel, users := range ms.mutes {
+		for username, until := range users {
+			if !until.After(now) {
+				expired = append(expired, MuteEntry{Channel: channel, Username: username, Until: until})
+				delete(users, username)
+			}
+		}
+		if len(users) == 0 {
+			delete(ms.mutes, channel)
+		}
+	}
+	return expired
+}
+
+// checkMuted 檢查訊息發送者是否被禁言
+//
+// Design considerations:
+// - 系統訊息不受禁言限制
+//
+// Parameters:
+// - msg: 要發送的訊息
+//
+// Returns:
+// - error: 發送者被禁言時返回包含結束時間的 ErrUserMuted
+func checkMuted(msg Message) error {
+	if msg.IsSystemMessage() {
+		return nil
+	}
+	if until, muted := muteStore.MutedUntil(msg.Channel, msg.User); muted {
+		return fmt.Errorf("%w until %s", ErrUserMuted, until.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// muteUser 由頻道管理員在頻道中禁言用戶並發布系統訊息
+//
+// Parameters:
+// - moderator: 執行者
+// - channel: 頻道名稱
+// - username: 被禁言的用戶
+// - minutes: 禁言時長（分鐘），必須在 1 到 DefaultMaxMuteDuration 之間
+//
+// Returns:
+// - MuteEntry: 新的禁言紀錄
+// - error: 無權限、目標不合法或時長不合法時返回對應錯誤
+func muteUser(moderator *Account, channel, username string, minutes int) (MuteEntry, error) {
+	target, err := sanctionTarget(moderator, channel, username)
+	if err != nil {
+		return MuteEntry{}, err
+	}
+	if minutes <= 0 || minutes > DefaultMaxMuteDuration {
+		return MuteEntry{}, ErrInvalidMuteDuration
+	}
+
+	until := muteStore.Mute(channel, target.Username, time.Duration(minutes)*time.Minute)
+	publishMessage(NewSystemMessage(fmt.Sprintf(SystemMessageMuteTemplate, target.Username, moderator.Username, minutes), channel))
+	return MuteEntry{Channel: channel, Username: target.Username, Until: until}, nil
+}
+
+// unmuteUser 由頻道管理員提前解除禁言並發布系統訊息
+//
+// Parameters:
+// - moderator: 執行者
+// - channel: 頻道名稱
+// - username: 被禁言的用戶
+//
+// Returns:
+// - error: 無權限或用戶未被禁言時返回對應錯誤
+func unmuteUser(moderator *Account, channel, username string) error {
+	target, err := sanctionTarget(moderator, channel, username)
+	if err != nil {
+		return err
+	}
+	if !muteStore.Unmute(channel, target.Username) {
+		return ErrMuteNotFound
+	}
+	publishMessage(NewSystemMessage(fmt.Sprintf(SystemMessageUnmuteTemplate, target.Username, moderator.Username), channel))
+	return nil
+}
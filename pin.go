@@ -0,0 +1,273 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 釘選相關錯誤
+var (
+	ErrMessageNotFound  = errors.New(ErrorMessageNotFound)
+	ErrPinNotFound      = errors.New(ErrorPinNotFound)
+	ErrPinLimitReached  = errors.New(ErrorPinLimitReached)
+	ErrPinNotPermitted  = errors.New(ErrorPinNotPermitted)
+	ErrChannelForbidden = errors.New(ErrorChannelForbidden)
+)
+
+// Pin 代表頻道中被釘選的訊息
+type Pin struct {
+	Message  Message   `json:"message"`  // 被釘選的訊息
+	PinnedBy string    `json:"pinnedBy"` // 釘選者
+	PinnedAt time.Time `json:"pinnedAt"` // 釘選時間
+}
+
+// PinEvent 代表釘選變更事件的內容
+//
+// Design considerations:
+// - 附上變更後的完整釘選列表，客戶端直接取代顯示即可
+type PinEvent struct {
+	Action    string `json:"action"`    // 變更動作（pin, unpin）
+	MessageID string `json:"messageId"` // 變更的訊息 ID
+	Username  string `json:"username"`  // 操作者
+	Pins      []Pin  `json:"pins"`      // 變更後的釘選列表
+}
+
+// PinStore 管理各頻道的釘選訊息
+//
+// Responsible for:
+// - 保存各頻道的釘選列表（依釘選時間排列）
+// - 檢查釘選數量上限與操作權限
+//
+// Design considerations:
+// - 重複釘選同一則訊息不會改變狀態，也不會再次通知
+// - 權限規則：必須能讀取頻道；一般成員只能釘選自己的訊息、取消自己的釘選，管理員不受限制
+//
+// Usage context:
+// - 全域 pinStore 實例
+// - /api/channels/{channel}/pins 相關處理器
+type PinStore struct {
+	mu    sync.RWMutex
+	limit int
+	pins  map[string][]Pin
+	now   func() time.Time
+}
+
+// NewPinStore 建立釘選存儲
+//
+// Parameters:
+// - limit: 每個頻道最多可釘選的訊息數
+//
+// Returns:
+// - *PinStore: 空的釘選存儲
+func NewPinStore(limit int) *PinStore {
+	return &PinStore{
+		limit: limit,
+		pins:  make(map[string][]Pin),
+		now:   time.Now,
+	}
+}
+
+// Limit 返回每個頻道最多可釘選的訊息數
+func (ps *PinStore) Limit() int {
+	return ps.limit
+}
+
+// List 取得頻道的釘選列表
+//
+// Parameters:
+// - channel: 頻道名稱
+//
+// Returns:
+// - []Pin: 釘選列表的複本
+func (ps *PinStore) List(channel string) []Pin {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	return append([]Pin{}, ps.pins[channel]...)
+}
+
+// Pin 釘選頻道中的訊息
+//
+// Process flow:
+// 1. 檢查帳號可讀取頻道
+// 2. 從 MessageStore 查找訊息
+// 3. 檢查權限與數量上限
+// 4. 加入釘選列表
+//
+// Parameters:
+// - account: 操作者帳號
+// - channel: 頻道名稱
+// - messageID: 要釘選的訊息 ID
+//
+// Returns:
+// - []Pin: 釘選後的列表
+// - bool: 是否有變更（已釘選時為 false）
+// - error: 找不到訊息、沒有權限或超過上限時返回錯誤
+func (ps *PinStore) Pin(account *Account, channel, messageID string) ([]Pin, bool, error) {
+	if !authorize(account, PermissionReadChannel, channel) {
+		return nil, false, ErrChannelForbidden
+	}
+
+	msg, ok := messageStore.FindMessage(channel, messageID)
+	if !ok {
+		return nil, false, ErrMessageNotFound
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	pins := ps.pins[channel]
+	for _, pin := range pins {
+		if pin.Message.ID == messageID {
+			return append([]Pin{}, pins...), false, nil
+		}
+	}
+
+	if !authorize(account, PermissionModerate, channel) && msg.User != account.Username {
+		return nil, false, ErrPinNotPermitted
+	}
+	if len(pins) >= ps.limit {
+		return nil, false, ErrPinLimitReached
+	}
+
+	pins = append(pins, Pin{Message: msg, PinnedBy: account.Username, PinnedAt: ps.now()})
+	ps.pins[channel] = pins
+	return append([]Pin{}, pins...), true, nil
+}
+
+// Unpin 取消釘選頻道中的訊息
+//
+// Parameters:
+// - account: 操作者帳號
+// - channel: 頻道名稱
+// - messageID: 要取消釘選的訊息 ID
+//
+// Returns:
+// - []Pin: 取消後的列表
+// - error: 沒有釘選或沒有權限時返回錯誤
+func (ps *PinStore) Unpin(account *Account, channel, messageID string) ([]Pin, error) {
+	if !authorize(account, PermissionReadChannel, channel) {
+		return nil, ErrChannelForbidden
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	pins := ps.pins[channel]
+	for i, pin := range pins {
+		if pin.Message.ID != messageID {
+			continue
+		}
+		if !authorize(account, PermissionModerate, channel) && pin.PinnedBy != account.Username {
+			return nil, ErrPinNotPermitted
+		}
+
+		pins = append(pins[:i:i], pins[i+1:]...)
+		ps.pins[channel] = pins
+		return append([]Pin{}, pins...), nil
+	}
+	return nil, ErrPinNotFound
+}
+
+// Remove 移除已刪除訊息的釘選（不檢查權限）
+//
+// Parameters:
+// - channel: 頻道名稱
+// - messageID: 訊息 ID
+//
+// Returns:
+// - []Pin: 移除後的列表
+// - bool: 是否有變更
+func (ps *PinStore) Remove(channel, messageID string) ([]Pin, bool) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	pins := ps.pins[channel]
+	for i, pin := range pins {
+		if pin.Message.ID == messageID {
+			pins = append(pins[:i:i], pins[i+1:]...)
+			ps.pins[channel] = pins
+			return append([]Pin{}, pins...), true
+		}
+	}
+	return nil, false
+}
+
+// Restore 恢復先前被移除的釘選（不檢查權限與上限）
+//
+// Design considerations:
+// - 依原本的釘選時間插入，釘選列表的順序維持不變
+// - 已經釘選時不做任何變更
+//
+// Parameters:
+// - channel: 頻道名稱
+// - pin: 要恢復的釘選
+//
+// Returns:
+// - []Pin: 恢復後的列表
+// - bool: 是否有變更
+func (ps *PinStore) Restore(channel string, pin Pin) ([]Pin, bool) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	pins := ps.pins[channel]
+	for _, existing := range pins {
+		if existing.Message.ID == pin.Message.ID {
+			return nil, false
+		}
+	}
+	i := sort.Search(len(pins), func(i int) bool {
+		return pins[i].PinnedAt.After(pin.PinnedAt)
+	})
+	pins = append(pins[:i:i], append([]Pin{pin}, pins[i:]...)...)
+	ps.pins[channel] = pins
+	return append([]Pin{}, pins...), true
+}
+
+// announcePinChange 通知頻道釘選變更
+//
+// Responsible for:
+// - 寫入並廣播「某人釘選/取消釘選了訊息」的系統訊息
+// - 廣播 pin 事件讓客戶端更新釘選橫幅
+//
+// Design considerations:
+// - 系統訊息與 pin 事件由 broadcastEvents 在同一個 goroutine 依序廣播，客戶端一定先收到系統訊息
+//
+// Parameters:
+// - action: 變更動作（pin, unpin）
+// - username: 操作者
+// - channel: 頻道名稱
+// - messageID: 變更的訊息 ID
+// - pins: 變更後的釘選列表
+func announcePinChange(action, username, channel, messageID string, pins []Pin) {
+	template := SystemMessagePinTemplate
+	if action == PinActionUnpin {
+		template = SystemMessageUnpinTemplate
+	}
+	log.Printf(LogPinChanged, username, action, messageID, channel)
+
+	announcement := NewSystemMessage(fmt.Sprintf(template, username), channel)
+	messageStore.AddMessage(announcement)
+
+	event := NewPinMessage(PinEvent{Action: action, MessageID: messageID, Username: username, Pins: pins}, channel)
+	broadcastEvents([]Message{announcement, event})
+}
+
+// pinStatusCode 將釘選錯誤對應到 HTTP 狀態碼
+func pinStatusCode(err error) int {
+	switch {
+	case errors.Is(err, ErrMessageNotFound), errors.Is(err, ErrPinNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrPinNotPermitted), errors.Is(err, ErrChannelForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, ErrPinLimitReached):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestPinStore 測試釘選的權限與數量上限
+func TestPinStore(t *testing.T) {
+	messageStore = make(MessageStore)
+	messageIndex = NewSearchIndex()
+	own := NewMessage("alice", "部署步驟", "general")
+	other := NewMessage("Web User", "會議連結", "general")
+	third := NewMessage("alice", "FAQ", "general")
+	for _, msg := range []Message{own, other, third} {
+		messageStore.AddMessage(msg)
+	}
+
+	alice, _ := validateAccount("alice", "password123")
+	member := &Account{Username: "dave", Channel: "general"}
+	bob, _ := validateAccount("bob", "password123")
+	store := NewPinStore(2)
+
+	t.Run("一般成員只能釘選自己的訊息", func(t *testing.T) {
+		if _, _, err := store.Pin(member, "general", other.ID); !errors.Is(err, ErrPinNotPermitted) {
+			t.Errorf("Expected ErrPinNotPermitted, got %v", err)
+		}
+	})
+
+	t.Run("不可讀取的頻道", func(t *testing.T) {
+		if _, _, err := store.Pin(bob, "general", own.ID); !errors.Is(err, ErrChannelForbidden) {
+			t.Errorf("Expected ErrChannelForbidden, got %v", err)
+		}
+	})
+
+	t.Run("管理員釘選與上限", func(t *testing.T) {
+		if _, changed, err := store.Pin(alice, "general", other.ID); err != nil || !changed {
+			t.Fatalf("Expected pin to succeed, got changed=%v err=%v", changed, err)
+		}
+		if _, changed, _ := store.Pin(alice, "general", other.ID); changed {
+			t.Error("Expected repeated pin to be a no-op")
+		}
+		store.Pin(alice, "general", own.ID)
+		if _, _, err := store.Pin(alice, "general", third.ID); !errors.Is(err, ErrPinLimitReached) {
+			t.Errorf("Expected ErrPinLimitReached, got %v", err)
+		}
+	})
+
+	t.Run("取消釘選", func(t *testing.T) {
+		if _, err := store.Unpin(member, "general", other.ID); !errors.Is(err, ErrPinNotPermitted) {
+			t.Errorf("Expected ErrPinNotPermitted for another user's pin, got %v", err)
+		}
+		pins, err := store.Unpin(alice, "general", other.ID)
+		if err != nil || len(pins) != 1 || pins[0].Message.ID != own.ID {
+			t.Errorf("Expected only own message to remain pinned, got %v (%v)", pins, err)
+		}
+		if _, err := store.Unpin(alice, "general", other.ID); !errors.Is(err, ErrPinNotFound) {
+			t.Errorf("Expected ErrPinNotFound, got %v", err)
+		}
+	})
+}
+
+// TestPinAPI 測試釘選 API 發送系統訊息與 pin 事件
+func TestPinAPI(t *testing.T) {
+	hub = *newTestHub(0)
+	messageStore = make(MessageStore)
+	messageIndex = NewSearchIndex()
+	pinStore = NewPinStore(DefaultPinLimit)
+	msg := NewMessage("alice", "請看這裡", "general")
+	messageStore.AddMessage(msg)
+
+	observer := newTestClient("alice", "general")
+	hub.register <- observer
+	time.Sleep(10 * time.Millisecond)
+	for len(observer.send) > 0 {
+		<-observer.send
+	}
+
+	req, _ := http.NewRequest("PUT", "/api/channels/general/pins/"+msg.ID, nil)
+	req.SetBasicAuth("alice", "password123")
+	rr := httptest.NewRecorder()
+	setupRoutes().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// 系統訊息一定在 pin 事件之前
+	var received []Message
+	timeout := time.After(time.Second)
+	for len(received) < 2 {
+		select {
+		case event := <-observer.send:
+			received = append(received, event)
+		case <-timeout:
+			t.Fatalf("Expected system message and pin event, got %+v", received)
+		}
+	}
+	if received[0].Type != MessageTypeSystem || received[1].Type != MessageTypePin ||
+		received[1].Pin.Action != PinActionPin || len(received[1].Pin.Pins) != 1 {
+		t.Errorf("Expected system message followed by pin event, got %s then %s", received[0].Type, received[1].Type)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/channels/general/pins", nil)
+	req.SetBasicAuth("bob", "password123")
+	rr = httptest.NewRecorder()
+	setupRoutes().ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for unreadable channel, got %d", rr.Code)
+	}
+}
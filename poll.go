@@ -0,0 +1,423 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 投票相關錯誤
+var (
+	ErrPollRequired          = errors.New(ErrorPollRequired)
+	ErrPollQuestionRequired  = errors.New(ErrorPollQuestionRequired)
+	ErrPollInvalidOptions    = errors.New(ErrorPollInvalidOptions)
+	ErrPollInvalidCloseTime  = errors.New(ErrorPollInvalidCloseTime)
+	ErrPollNotFound          = errors.New(ErrorPollNotFound)
+	ErrPollClosed            = errors.New(ErrorPollClosed)
+	ErrPollInvalidVote       = errors.New(ErrorPollInvalidVote)
+	ErrPollCloseNotPermitted = errors.New(ErrorPollCloseNotPermitted)
+)
+
+// Poll 代表投票訊息的內容與目前結果
+//
+// Design considerations:
+// - ID 與投票訊息的 ID 相同
+// - 票數由伺服器統計，客戶端建立投票時提供的票數會被忽略
+// - Closed 為 true 後結果凍結，不再接受投票
+type Poll struct {
+	ID             string       `json:"id"`                 // 投票 ID（等於訊息 ID）
+	Question       string       `json:"question"`           // 問題
+	Options        []PollOption `json:"options"`            // 選項與票數
+	MultipleChoice bool         `json:"multipleChoice"`     // 是否可複選
+	ClosesAt       *time.Time   `json:"closesAt,omitempty"` // 自動結束時間
+	Closed         bool         `json:"closed"`             // 是否已結束
+	TotalVoters    int          `json:"totalVoters"`        // 已投票人數
+}
+
+// PollOption 代表投票的一個選項
+type PollOption struct {
+	Text  string `json:"text"`  // 選項文字
+	Votes int    `json:"votes"` // 得票數
+}
+
+// PollVote 代表一次投票
+//
+// Design considerations:
+// - Options 為選項索引，重新投票會取代先前的選擇，空陣列表示撤回
+type PollVote struct {
+	PollID  string `json:"pollId"`  // 投票 ID
+	Options []int  `json:"options"` // 選擇的選項索引
+}
+
+// pollState 代表投票的內部狀態
+type pollState struct {
+	poll    Poll
+	channel string
+	author  string
+	votes   map[string][]int
+}
+
+// PollStore 管理所有投票的狀態與票數
+//
+// Responsible for:
+// - 驗證並登記新的投票訊息
+// - 記錄每位用戶的選擇並統計票數
+// - 到期時結束投票並凍結結果
+//
+// Design considerations:
+// - 每位用戶只有一組選擇，重新投票時先扣除舊的票數
+// - 只存在記憶體中，與 MessageStore 一致
+// - 時鐘可注入，測試時不需要實際等待
+//
+// Usage context:
+// - 全域 pollStore 實例
+// - WebSocket vote 訊息與 /api/polls 相關處理器
+type PollStore struct {
+	mu    sync.Mutex
+	polls map[string]*pollState
+	now   func() time.Time
+}
+
+// NewPollStore 建立投票存儲
+//
+// Returns:
+// - *PollStore: 空的投票存儲
+func NewPollStore() *PollStore {
+	return &PollStore{
+		polls: make(map[string]*pollState),
+		now:   time.Now,
+	}
+}
+
+// Create 驗證投票訊息並登記投票
+//
+// Process flow:
+// 1. 檢查問題、選項數量與結束時間
+// 2. 清理選項文字並將票數歸零
+// 3. 以訊息 ID 登記投票，並將正規化後的投票寫回訊息
+//
+// Parameters:
+// - msg: 已設置 ID、用戶與頻道的 poll 類型訊息
+//
+// Returns:
+// - error: 投票內容不合法時的錯誤
+func (ps *PollStore) Create(msg *Message) error {
+	if msg.Poll == nil {
+		return ErrPollRequired
+	}
+
+	question := strings.TrimSpace(msg.Poll.Question)
+	if question == "" {
+		return ErrPollQuestionRequired
+	}
+
+	options := make([]PollOption, 0, len(msg.Poll.Options))
+	seen := make(map[string]bool)
+	for _, option := range msg.Poll.Options {
+		text := strings.TrimSpace(option.Text)
+		if text == "" || seen[text] {
+			return ErrPollInvalidOptions
+		}
+		seen[text] = true
+		options = append(options, PollOption{Text: text})
+	}
+	if len(options) < DefaultPollMinOptions || len(options) > DefaultPollMaxOptions {
+		return ErrPollInvalidOptions
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if closesAt := msg.Poll.ClosesAt; closesAt != nil && !closesAt.After(ps.now()) {
+		return ErrPollInvalidCloseTime
+	}
+
+	poll := Poll{
+		ID:             msg.ID,
+		Question:       question,
+		Options:        options,
+		MultipleChoice: msg.Poll.MultipleChoice,
+		ClosesAt:       msg.Poll.ClosesAt,
+	}
+	ps.polls[msg.ID] = &pollState{
+		poll:    poll,
+		channel: msg.Channel,
+		author:  msg.User,
+		votes:   make(map[string][]int),
+	}
+
+	msg.Poll = poll.clone()
+	if strings.TrimSpace(msg.Content) == "" {
+		msg.Content = question
+	}
+	return nil
+}
+
+// Get 取得投票目前的結果
+//
+// Parameters:
+// - id: 投票 ID
+// - username: 查詢者，用於返回其目前的選擇
+//
+// Returns:
+// - Poll: 投票結果
+// - string: 投票所屬頻道
+// - []int: 查詢者目前的選擇
+// - error: 投票不存在時返回 ErrPollNotFound
+func (ps *PollStore) Get(id, username string) (Poll, string, []int, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	state, ok := ps.polls[id]
+	if !ok {
+		return Poll{}, "", nil, ErrPollNotFound
+	}
+	return *state.poll.clone(), state.channel, append([]int{}, state.votes[username]...), nil
+}
+
+// Vote 記錄用戶的選擇並更新票數
+//
+// Parameters:
+// - username: 投票者
+// - channels: 投票者可讀取的頻道
+// - vote: 投票內容
+//
+// Returns:
+// - Poll: 更新後的結果
+// - string: 投票所屬頻道
+// - error: 投票不存在、已結束或選擇不合法時的錯誤
+func (ps *PollStore) Vote(username string, channels []string, vote PollVote) (Poll, string, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	state, ok := ps.polls[vote.PollID]
+	if !ok || !containsString(channels, state.channel) {
+		return Poll{}, "", ErrPollNotFound
+	}
+	if state.poll.Closed {
+		return Poll{}, "", ErrPollClosed
+	}
+	if !state.poll.MultipleChoice && len(vote.Options) > 1 {
+		return Poll{}, "", ErrPollInvalidVote
+	}
+
+	selected := make(map[int]bool)
+	for _, index := range vote.Options {
+		if index < 0 || index >= len(state.poll.Options) || selected[index] {
+			return Poll{}, "", ErrPollInvalidVote
+		}
+		selected[index] = true
+	}
+
+	// 先扣除先前的選擇，再計入新的選擇
+	if previous, ok := state.votes[username]; ok {
+		for _, index := range previous {
+			state.poll.Options[index].Votes--
+		}
+		delete(state.votes, username)
+	}
+	if len(vote.Options) > 0 {
+		for _, index := range vote.Options {
+			state.poll.Options[index].Votes++
+		}
+		state.votes[username] = append([]int{}, vote.Options...)
+	}
+	state.poll.TotalVoters = len(state.votes)
+
+	return *state.poll.clone(), state.channel, nil
+}
+
+// Close 提前結束投票
+//
+// Parameters:
+// - id: 投票 ID
+// - account: 操作者帳號，必須是投票建立者或頻道管理員
+//
+// Returns:
+// - Poll: 最終結果
+// - string: 投票所屬頻道
+// - error: 投票不存在、已結束或沒有權限時的錯誤
+func (ps *PollStore) Close(id string, account *Account) (Poll, string, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	state, ok := ps.polls[id]
+	if !ok || !authorize(account, PermissionReadChannel, state.channel) {
+		return Poll{}, "", ErrPollNotFound
+	}
+	if state.poll.Closed {
+		return Poll{}, "", ErrPollClosed
+	}
+	if state.author != account.Username && !authorize(account, PermissionModerate, state.channel) {
+		return Poll{}, "", ErrPollCloseNotPermitted
+	}
+
+	state.poll.Closed = true
+	return *state.poll.clone(), state.channel, nil
+}
+
+// CloseDue 結束所有到期的投票
+//
+// Returns:
+// - map[string]Poll: 頻道對應的已結束投票列表
+func (ps *PollStore) CloseDue() map[string][]Poll {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	now := ps.now()
+	closed := make(map[string][]Poll)
+	for _, state := range ps.polls {
+		if !state.poll.Closed && state.poll.ClosesAt != nil && !state.poll.ClosesAt.After(now) {
+			state.poll.Closed = true
+			closed[state.channel] = append(closed[state.channel], *state.poll.clone())
+		}
+	}
+	return closed
+}
+
+// Remove 移除已刪除訊息的投票
+//
+// Parameters:
+// - id: 投票 ID
+func (ps *PollStore) Remove(id string) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	delete(ps.polls, id)
+}
+
+// Detach 移除投票並返回其狀態，供之後以 Reattach 恢復
+//
+// Usage context:
+// - 訊息因檢舉被隱藏時保存票數，駁回檢舉後恢復
+//
+// Parameters:
+// - id: 投票 ID
+//
+// Returns:
+// - *pollState: 投票狀態，不存在時為 nil
+func (ps *PollStore) Detach(id string) *pollState {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	state := ps.polls[id]
+	delete(ps.polls, id)
+	return state
+}
+
+// Reattach 恢復先前以 Detach 移除的投票
+//
+// Parameters:
+// - id: 投票 ID
+// - state: Detach 返回的狀態，nil 時不做任何事
+func (ps *PollStore) Reattach(id string, state *pollState) {
+	if state == nil {
+		return
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	ps.polls[id] = state
+}
+
+// run 定期結束到期的投票並廣播最終結果
+//
+// Parameters:
+// - interval: 檢查間隔
+func (ps *PollStore) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for channel, polls := range ps.CloseDue() {
+			for _, poll := range polls {
+				log.Printf(LogPollClosed, poll.ID, channel)
+				announcePollUpdate(poll, channel)
+			}
+		}
+	}
+}
+
+// clone 複製投票，避免外部修改影響內部狀態
+func (p Poll) clone() *Poll {
+	p.Options = append([]PollOption{}, p.Options...)
+	return &p
+}
+
+// resolvePoll 驗證並登記投票訊息
+//
+// Design considerations:
+// - 只處理 poll 類型，其他類型的 poll 欄位會被清除
+// - 需在訊息 ID 設置後、儲存前調用
+//
+// Parameters:
+// - msg: 要處理的訊息
+//
+// Returns:
+// - error: 投票內容不合法時的錯誤
+func resolvePoll(msg *Message) error {
+	if msg.Type != MessageTypePoll {
+		msg.Poll = nil
+		return nil
+	}
+	return pollStore.Create(msg)
+}
+
+// castVote 處理來自 WebSocket 或 REST 的投票
+//
+// Parameters:
+// - username: 投票者
+// - channels: 投票者可讀取的頻道
+// - vote: 投票內容
+//
+// Returns:
+// - Poll: 更新後的結果
+// - error: 投票失敗時的錯誤
+func castVote(username string, channels []string, vote *PollVote) (Poll, error) {
+	if vote == nil {
+		return Poll{}, ErrPollInvalidVote
+	}
+
+	poll, channel, err := pollStore.Vote(username, channels, *vote)
+	if err != nil {
+		return Poll{}, err
+	}
+	log.Printf(LogPollVoted, username, poll.ID, vote.Options)
+
+	announcePollUpdate(poll, channel)
+	return poll, nil
+}
+
+// announcePollUpdate 更新歷史中的投票訊息並廣播最新結果
+//
+// Parameters:
+// - poll: 最新的投票結果
+// - channel: 投票所屬頻道
+func announcePollUpdate(poll Poll, channel string) {
+	messageStore.UpdateMessage(channel, poll.ID, func(msg *Message) {
+		msg.Poll = poll.clone()
+	})
+
+	event := NewPollUpdateMessage(poll, channel)
+	go func() {
+		hub.broadcast <- event
+	}()
+}
+
+// pollStatusCode 將投票錯誤對應到 HTTP 狀態碼
+func pollStatusCode(err error) int {
+	switch {
+	case errors.Is(err, ErrPollNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrPollClosed):
+		return http.StatusConflict
+	case errors.Is(err, ErrPollCloseNotPermitted):
+		return http.StatusForbidden
+	default:
+		return http.StatusBadRequest
+	}
+}
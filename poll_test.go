@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestPoll 建立測試用的投票訊息
+func newTestPoll(id string, multiple bool, options ...string) Message {
+	poll := &Poll{Question: "午餐吃什麼？", MultipleChoice: multiple}
+	for _, option := range options {
+		poll.Options = append(poll.Options, PollOption{Text: option})
+	}
+	return Message{ID: id, User: "bob", Channel: "tech", Type: MessageTypePoll, Poll: poll}
+}
+
+// TestPollCreate 測試投票建立時的驗證
+func TestPollCreate(t *testing.T) {
+	now := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Minute)
+
+	tests := []struct {
+		name    string
+		msg     Message
+		wantErr error
+	}{
+		{"合法投票", newTestPoll("1", false, "拉麵", "咖哩"), nil},
+		{"缺少投票", Message{ID: "2", Type: MessageTypePoll}, ErrPollRequired},
+		{"選項太少", newTestPoll("3", false, "拉麵"), ErrPollInvalidOptions},
+		{"重複選項", newTestPoll("4", false, "拉麵", " 拉麵 "), ErrPollInvalidOptions},
+		{"空白選項", newTestPoll("5", false, "拉麵", " "), ErrPollInvalidOptions},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := NewPollStore()
+			store.now = func() time.Time { return now }
+			msg := test.msg
+			if err := store.Create(&msg); err != test.wantErr {
+				t.Fatalf("Expected %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+
+	t.Run("結束時間已過", func(t *testing.T) {
+		store := NewPollStore()
+		store.now = func() time.Time { return now }
+		msg := newTestPoll("6", false, "拉麵", "咖哩")
+		msg.Poll.ClosesAt = &past
+		if err := store.Create(&msg); err != ErrPollInvalidCloseTime {
+			t.Errorf("Expected ErrPollInvalidCloseTime, got %v", err)
+		}
+	})
+
+	t.Run("忽略客戶端票數", func(t *testing.T) {
+		store := NewPollStore()
+		msg := newTestPoll("7", false, "拉麵", "咖哩")
+		msg.Poll.Options[0].Votes = 99
+		if err := store.Create(&msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Poll.Options[0].Votes != 0 || msg.Poll.ID != "7" || msg.Content != "午餐吃什麼？" {
+			t.Errorf("Expected normalized poll, got %+v (content %q)", msg.Poll, msg.Content)
+		}
+	})
+}
+
+// TestPollVote 測試投票、重新投票與撤回
+func TestPollVote(t *testing.T) {
+	store := NewPollStore()
+	single := newTestPoll("single", false, "拉麵", "咖哩", "便當")
+	multiple := newTestPoll("multi", true, "拉麵", "咖哩", "便當")
+	store.Create(&single)
+	store.Create(&multiple)
+	tech := []string{"tech"}
+
+	t.Run("單選", func(t *testing.T) {
+		store.Vote("bob", tech, PollVote{PollID: "single", Options: []int{0}})
+		poll, _, err := store.Vote("alice", tech, PollVote{PollID: "single", Options: []int{0}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if poll.Options[0].Votes != 2 || poll.TotalVoters != 2 {
+			t.Errorf("Expected 2 votes from 2 voters, got %+v", poll)
+		}
+		if _, _, err := store.Vote("bob", tech, PollVote{PollID: "single", Options: []int{0, 1}}); err != ErrPollInvalidVote {
+			t.Errorf("Expected ErrPollInvalidVote for multiple options, got %v", err)
+		}
+	})
+
+	t.Run("重新投票取代先前選擇", func(t *testing.T) {
+		poll, _, _ := store.Vote("bob", tech, PollVote{PollID: "single", Options: []int{2}})
+		if poll.Options[0].Votes != 1 || poll.Options[2].Votes != 1 || poll.TotalVoters != 2 {
+			t.Errorf("Expected bob's vote to move, got %+v", poll)
+		}
+
+		poll, _, _ = store.Vote("bob", tech, PollVote{PollID: "single"})
+		if poll.Options[2].Votes != 0 || poll.TotalVoters != 1 {
+			t.Errorf("Expected bob's vote to be retracted, got %+v", poll)
+		}
+	})
+
+	t.Run("複選", func(t *testing.T) {
+		poll, _, err := store.Vote("bob", tech, PollVote{PollID: "multi", Options: []int{0, 2}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if poll.Options[0].Votes != 1 || poll.Options[2].Votes != 1 || poll.TotalVoters != 1 {
+			t.Errorf("Expected two options counted once each, got %+v", poll)
+		}
+		if _, _, err := store.Vote("bob", tech, PollVote{PollID: "multi", Options: []int{1, 1}}); err != ErrPollInvalidVote {
+			t.Errorf("Expected ErrPollInvalidVote for duplicate options, got %v", err)
+		}
+		if _, _, err := store.Vote("bob", tech, PollVote{PollID: "multi", Options: []int{3}}); err != ErrPollInvalidVote {
+			t.Errorf("Expected ErrPollInvalidVote for out of range option, got %v", err)
+		}
+	})
+
+	t.Run("無法讀取的頻道", func(t *testing.T) {
+		if _, _, err := store.Vote("alice", []string{"general"}, PollVote{PollID: "single", Options: []int{0}}); err != ErrPollNotFound {
+			t.Errorf("Expected ErrPollNotFound, got %v", err)
+		}
+	})
+}
+
+// TestPollCloseDue 測試到期結束後結果凍結
+func TestPollCloseDue(t *testing.T) {
+	now := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	closesAt := now.Add(time.Minute)
+
+	store := NewPollStore()
+	store.now = func() time.Time { return now }
+	msg := newTestPoll("1", false, "拉麵", "咖哩")
+	msg.Poll.ClosesAt = &closesAt
+	store.Create(&msg)
+	store.Vote("bob", []string{"tech"}, PollVote{PollID: "1", Options: []int{1}})
+
+	if closed := store.CloseDue(); len(closed) != 0 {
+		t.Fatalf("Expected no polls closed yet, got %v", closed)
+	}
+
+	now = closesAt
+	closed := store.CloseDue()
+	if len(closed["tech"]) != 1 || !closed["tech"][0].Closed || closed["tech"][0].Options[1].Votes != 1 {
+		t.Fatalf("Expected final result for tech, got %v", closed)
+	}
+
+	if _, _, err := store.Vote("bob", []string{"tech"}, PollVote{PollID: "1", Options: []int{0}}); err != ErrPollClosed {
+		t.Errorf("Expected ErrPollClosed, got %v", err)
+	}
+	if closed := store.CloseDue(); len(closed) != 0 {
+		t.Errorf("Expected poll to close only once, got %v", closed)
+	}
+}
+
+// TestPollAPI 測試投票訊息與投票 API
+func TestPollAPI(t *testing.T) {
+	hub = *newTestHub(0)
+	messageStore = make(MessageStore)
+	messageIndex = NewSearchIndex()
+	pollStore = NewPollStore()
+
+	observer := newTestClient("charlie", "tech")
+	hub.register <- observer
+	time.Sleep(10 * time.Millisecond)
+	for len(observer.send) > 0 {
+		<-observer.send
+	}
+
+	msg := newTestPoll("", false, "拉麵", "咖哩")
+	body, _ := json.Marshal(msg)
+	req, _ := http.NewRequest("POST", "/api/messages", bytes.NewReader(body))
+	req.SetBasicAuth("bob", "password123")
+	rr := httptest.NewRecorder()
+	setupRoutes().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created Message
+	select {
+	case created = <-observer.send:
+	case <-time.After(time.Second):
+		t.Fatal("Expected poll message to be broadcast")
+	}
+	if created.Poll == nil || created.Poll.ID != created.ID {
+		t.Fatalf("Expected poll id to equal message id, got %+v", created.Poll)
+	}
+
+	t.Run("投票並廣播結果", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/api/polls/"+created.ID+"/votes", bytes.NewBufferString(`{"options":[1]}`))
+		req.SetBasicAuth("bob", "password123")
+		rr := httptest.NewRecorder()
+		setupRoutes().ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+
+		select {
+		case event := <-observer.send:
+			if event.Type != MessageTypePollUpdate || event.Poll == nil || event.Poll.Options[1].Votes != 1 {
+				t.Errorf("Expected poll_update with 1 vote, got %+v", event)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected poll_update event")
+		}
+
+		stored, _ := messageStore.FindMessage("tech", created.ID)
+		if stored.Poll.Options[1].Votes != 1 {
+			t.Errorf("Expected stored poll to be updated, got %+v", stored.Poll)
+		}
+	})
+
+	t.Run("查詢自己的選擇", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/api/polls/"+created.ID, nil)
+		req.SetBasicAuth("bob", "password123")
+		rr := httptest.NewRecorder()
+		setupRoutes().ServeHTTP(rr, req)
+
+		var response struct {
+			Poll    Poll  `json:"poll"`
+			MyVotes []int `json:"myVotes"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		if len(response.MyVotes) != 1 || response.MyVotes[0] != 1 || response.Poll.TotalVoters != 1 {
+			t.Errorf("Expected bob's vote in response, got %+v", response)
+		}
+
+		req, _ = http.NewRequest("GET", "/api/polls/"+created.ID, nil)
+		req.SetBasicAuth("alice", "password123")
+		rr = httptest.NewRecorder()
+		setupRoutes().ServeHTTP(rr, req)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 for other channel, got %d", rr.Code)
+		}
+	})
+
+	t.Run("結束後凍結結果", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/api/polls/"+created.ID+"/close", nil)
+		req.SetBasicAuth("bob", "password123")
+		rr := httptest.NewRecorder()
+		setupRoutes().ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+
+		req, _ = http.NewRequest("POST", "/api/polls/"+created.ID+"/votes", bytes.NewBufferString(`{"options":[0]}`))
+		req.SetBasicAuth("bob", "password123")
+		rr = httptest.NewRecorder()
+		setupRoutes().ServeHTTP(rr, req)
+		if rr.Code != http.StatusConflict {
+			t.Errorf("Expected 409 after close, got %d", rr.Code)
+		}
+	})
+}
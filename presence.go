@@ -0,0 +1,380 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// UserPresence 代表單一用戶的在線狀態快照
+//
+// Responsible for:
+// - 彙整同一用戶所有裝置連接的在線狀態
+// - 提供最後活動時間與最後在線時間
+//
+// Design considerations:
+// - 一個用戶可能同時從手機與網頁連接，Devices 記錄連接數
+// - Channels 為該用戶目前連接中的頻道（已排序）
+// - LastSeen 在離線時表示最後一次斷線的時間
+//
+// Usage context:
+// - GET /api/presence 與頻道在線列表的回應內容
+// - 在線狀態變更事件的附帶資料
+type UserPresence struct {
+	Username   string    `json:"username"`   // 用戶名稱
+	Status     string    `json:"status"`     // 在線狀態（online, away, offline）
+	Devices    int       `json:"devices"`    // 目前連接的裝置數
+	Channels   []string  `json:"channels"`   // 目前所在的頻道
+	LastActive time.Time `json:"lastActive"` // 最後一次活動時間
+	LastSeen   time.Time `json:"lastSeen"`   // 最後在線時間
+}
+
+// PresenceEvent 代表一次在線狀態變更
+//
+// Responsible for:
+// - 描述用戶狀態由何者變為何者
+// - 攜帶變更當下的狀態快照供訂閱者使用
+//
+// Usage context:
+// - PresenceTracker 發送給所有訂閱者
+// - 轉換為 presence 類型訊息廣播給頻道客戶端
+type PresenceEvent struct {
+	Username       string       `json:"username"`       // 用戶名稱
+	Status         string       `json:"status"`         // 新狀態
+	PreviousStatus string       `json:"previousStatus"` // 變更前狀態
+	Presence       UserPresence `json:"presence"`       // 變更後的狀態快照
+	Channels       []string     `json:"channels"`       // 受此變更影響的頻道
+	Timestamp      time.Time    `json:"timestamp"`      // 變更時間
+}
+
+// presenceEntry 是 PresenceTracker 內部對單一用戶的追蹤資料
+type presenceEntry struct {
+	connections map[*Client]bool
+	status      string
+	lastActive  time.Time
+	lastSeen    time.Time
+}
+
+// PresenceTracker 管理所有用戶的在線狀態
+//
+// Responsible for:
+// - 以用戶為單位彙整多個裝置的連接
+// - 根據閒置時間在 online 與 away 之間切換
+// - 在狀態變更時通知所有訂閱者
+//
+// Design considerations:
+// - 使用 mutex 保護內部狀態，可由 Hub 與 HTTP 處理器同時存取
+// - 時鐘可注入，測試時不需要實際等待
+// - 訂閱者 channel 使用緩衝區，滿載時丟棄事件避免阻塞 Hub
+//
+// Process flow:
+// 1. Hub 註冊客戶端時呼叫 Connect
+// 2. 客戶端發送訊息時呼叫 Touch 更新活動時間
+// 3. 定期 Sweep 將閒置用戶標記為 away
+// 4. Hub 取消註冊時呼叫 Disconnect，最後一個裝置離線時標記為 offline
+//
+// Usage context:
+// - 程式啟動時建立全域實例並啟動 run 迴圈
+// - REST API 查詢在線狀態
+type PresenceTracker struct {
+	mu          sync.RWMutex
+	users       map[string]*presenceEntry
+	subscribers map[chan PresenceEvent]bool
+	awayTimeout time.Duration
+	now         func() time.Time
+}
+
+// NewPresenceTracker 建立新的在線狀態追蹤器
+//
+// Parameters:
+// - awayTimeout: 無活動多久後標記為 away
+//
+// Returns:
+// - *PresenceTracker: 初始化完成的追蹤器
+func NewPresenceTracker(awayTimeout time.Duration) *PresenceTracker {
+	return &PresenceTracker{
+		users:       make(map[string]*presenceEntry),
+		subscribers: make(map[chan PresenceEvent]bool),
+		awayTimeout: awayTimeout,
+		now:         time.Now,
+	}
+}
+
+// Subscribe 訂閱在線狀態變更事件
+//
+// Returns:
+// - chan PresenceEvent: 接收事件的 channel，取消訂閱時會被關閉
+func (p *PresenceTracker) Subscribe() chan PresenceEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ch := make(chan PresenceEvent, DefaultPresenceSubscriberBuffer)
+	p.subscribers[ch] = true
+	return ch
+}
+
+// Unsubscribe 取消訂閱並關閉事件 channel
+//
+// Parameters:
+// - ch: Subscribe 返回的 channel
+func (p *PresenceTracker) Unsubscribe(ch chan PresenceEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.subscribers[ch] {
+		delete(p.subscribers, ch)
+		close(ch)
+	}
+}
+
+// Connect 記錄客戶端連接
+//
+// Responsible for:
+// - 將客戶端加入該用戶的連接集合
+// - 用戶由 offline/away 變為 online 時發送事件
+//
+// Parameters:
+// - client: 新連接的客戶端
+func (p *PresenceTracker) Connect(client *Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	entry := p.entryLocked(client.username)
+	entry.connections[client] = true
+	entry.lastActive = now
+	entry.lastSeen = now
+	p.setStatusLocked(client.username, entry, PresenceStatusOnline, client.channel)
+}
+
+// Disconnect 記錄客戶端斷線
+//
+// Responsible for:
+// - 從該用戶的連接集合移除客戶端
+// - 最後一個裝置斷線時將用戶標記為 offline
+//
+// Parameters:
+// - client: 斷線的客戶端
+//
+// Returns:
+// - bool: true 表示該用戶已無任何連接
+func (p *PresenceTracker) Disconnect(client *Client) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry, ok := p.users[client.username]
+	if !ok {
+		return true
+	}
+
+	delete(entry.connections, client)
+	entry.lastSeen = p.now()
+	if len(entry.connections) > 0 {
+		return false
+	}
+
+	p.setStatusLocked(client.username, entry, PresenceStatusOffline, client.channel)
+	return true
+}
+
+// Touch 更新用戶的最後活動時間
+//
+// Responsible for:
+// - 記錄用戶仍在活動中
+// - away 用戶重新活動時恢復為 online
+//
+// Parameters:
+// - username: 活動的用戶名
+func (p *PresenceTracker) Touch(username string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry, ok := p.users[username]
+	if !ok || len(entry.connections) == 0 {
+		return
+	}
+
+	now := p.now()
+	entry.lastActive = now
+	entry.lastSeen = now
+	if entry.status == PresenceStatusAway {
+		p.setStatusLocked(username, entry, PresenceStatusOnline, "")
+	}
+}
+
+// Sweep 將閒置超過 awayTimeout 的在線用戶標記為 away
+func (p *PresenceTracker) Sweep() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	for username, entry := range p.users {
+		if entry.status == PresenceStatusOnline && now.Sub(entry.lastActive) >= p.awayTimeout {
+			p.setStatusLocked(username, entry, PresenceStatusAway, "")
+		}
+	}
+}
+
+// Get 取得指定用戶的在線狀態
+//
+// Parameters:
+// - username: 用戶名稱
+//
+// Returns:
+// - UserPresence: 狀態快照，未知用戶視為 offline
+func (p *PresenceTracker) Get(username string) UserPresence {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	entry, ok := p.users[username]
+	if !ok {
+		return UserPresence{Username: username, Status: PresenceStatusOffline, Channels: []string{}}
+	}
+	return entry.snapshot(username)
+}
+
+// ChannelPresence 取得指定頻道中所有在線用戶的狀態
+//
+// Parameters:
+// - channel: 頻道名稱
+//
+// Returns:
+// - []UserPresence: 依用戶名排序的狀態列表，每位用戶僅出現一次
+func (p *PresenceTracker) ChannelPresence(channel string) []UserPresence {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	result := []UserPresence{}
+	for username, entry := range p.users {
+		for client := range entry.connections {
+			if client.channel == channel {
+				result = append(result, entry.snapshot(username))
+				break
+			}
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Username < result[j].Username
+	})
+	return result
+}
+
+// run 定期執行閒置檢查
+//
+// Parameters:
+// - interval: 檢查間隔
+func (p *PresenceTracker) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		p.Sweep()
+	}
+}
+
+// relayPresenceEvents 將在線狀態變更轉發給相關頻道的客戶端
+//
+// Responsible for:
+// - 持續讀取 PresenceTracker 的事件
+// - 為每個受影響的頻道建立 presence 訊息並交給 Hub 廣播
+//
+// Design considerations:
+// - 在獨立 goroutine 中運行，避免 Hub 在處理註冊時阻塞於自身的 broadcast channel
+// - presence 訊息不寫入 messageStore
+//
+// Parameters:
+// - events: Subscribe 返回的事件 channel
+func relayPresenceEvents(events <-chan PresenceEvent) {
+	for event := range events {
+		for _, channel := range event.Channels {
+			hub.broadcast <- NewPresenceMessage(event, channel)
+		}
+	}
+}
+
+// entryLocked 取得或建立用戶的追蹤資料，呼叫者需持有寫鎖
+func (p *PresenceTracker) entryLocked(username string) *presenceEntry {
+	entry, ok := p.users[username]
+	if !ok {
+		entry = &presenceEntry{
+			connections: make(map[*Client]bool),
+			status:      PresenceStatusOffline,
+		}
+		p.users[username] = entry
+	}
+	return entry
+}
+
+// setStatusLocked 更新用戶狀態並在狀態改變時通知訂閱者，呼叫者需持有寫鎖
+//
+// Parameters:
+// - username: 用戶名稱
+// - entry: 用戶的追蹤資料
+// - status: 新狀態
+// - channel: 觸發變更的連接所在頻道，斷線時該頻道已不在快照中，需額外帶入
+func (p *PresenceTracker) setStatusLocked(username string, entry *presenceEntry, status, channel string) {
+	if entry.status == status {
+		return
+	}
+
+	previous := entry.status
+	entry.status = status
+	snapshot := entry.snapshot(username)
+
+	channels := snapshot.Channels
+	if channel != "" && !containsString(channels, channel) {
+		channels = append(append([]string{}, channels...), channel)
+		sort.Strings(channels)
+	}
+
+	event := PresenceEvent{
+		Username:       username,
+		Status:         status,
+		PreviousStatus: previous,
+		Presence:       snapshot,
+		Channels:       channels,
+		Timestamp:      p.now(),
+	}
+
+	for ch := range p.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// 訂閱者處理過慢時丟棄事件，避免阻塞呼叫者
+		}
+	}
+}
+
+// snapshot 建立用戶狀態快照
+func (e *presenceEntry) snapshot(username string) UserPresence {
+	channelSet := make(map[string]bool)
+	for client := range e.connections {
+		channelSet[client.channel] = true
+	}
+
+	channels := make([]string, 0, len(channelSet))
+	for channel := range channelSet {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+
+	return UserPresence{
+		Username:   username,
+		Status:     e.status,
+		Devices:    len(e.connections),
+		Channels:   channels,
+		LastActive: e.lastActive,
+		LastSeen:   e.lastSeen,
+	}
+}
+
+// containsString 檢查字串是否在切片中
+func containsString(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
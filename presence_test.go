@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestPresenceTracker 建立使用可控時鐘的在線狀態追蹤器
+func newTestPresenceTracker(now *time.Time) *PresenceTracker {
+	tracker := NewPresenceTracker(5 * time.Minute)
+	tracker.now = func() time.Time { return *now }
+	return tracker
+}
+
+// TestPresenceTrackerMultipleDevices 測試多裝置連接的彙整
+func TestPresenceTrackerMultipleDevices(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := newTestPresenceTracker(&now)
+	events := tracker.Subscribe()
+
+	phone := &Client{username: "alice", channel: "general"}
+	web := &Client{username: "alice", channel: "general"}
+
+	tracker.Connect(phone)
+	tracker.Connect(web)
+
+	presence := tracker.Get("alice")
+	if presence.Status != PresenceStatusOnline {
+		t.Errorf("Expected status '%s', got '%s'", PresenceStatusOnline, presence.Status)
+	}
+	if presence.Devices != 2 {
+		t.Errorf("Expected 2 devices, got %d", presence.Devices)
+	}
+	if len(tracker.ChannelPresence("general")) != 1 {
+		t.Errorf("Expected alice to be listed once in general")
+	}
+
+	if offline := tracker.Disconnect(phone); offline {
+		t.Error("Expected alice to stay online while web is connected")
+	}
+	if tracker.Get("alice").Status != PresenceStatusOnline {
+		t.Error("Expected alice to remain online")
+	}
+
+	now = now.Add(time.Minute)
+	if offline := tracker.Disconnect(web); !offline {
+		t.Error("Expected alice to be offline after last device disconnects")
+	}
+
+	presence = tracker.Get("alice")
+	if presence.Status != PresenceStatusOffline {
+		t.Errorf("Expected status '%s', got '%s'", PresenceStatusOffline, presence.Status)
+	}
+	if !presence.LastSeen.Equal(now) {
+		t.Errorf("Expected lastSeen %v, got %v", now, presence.LastSeen)
+	}
+
+	// 只有 offline -> online -> offline 兩次變更
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 presence events, got %d", len(events))
+	}
+	<-events
+	last := <-events
+	if last.Status != PresenceStatusOffline || last.PreviousStatus != PresenceStatusOnline {
+		t.Errorf("Unexpected event transition %s -> %s", last.PreviousStatus, last.Status)
+	}
+	if len(last.Channels) != 1 || last.Channels[0] != "general" {
+		t.Errorf("Expected offline event to target general, got %v", last.Channels)
+	}
+}
+
+// TestPresenceTrackerAway 測試閒置後轉為 away 並在活動後恢復
+func TestPresenceTrackerAway(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := newTestPresenceTracker(&now)
+	tracker.Connect(&Client{username: "bob", channel: "tech"})
+
+	now = now.Add(4 * time.Minute)
+	tracker.Sweep()
+	if status := tracker.Get("bob").Status; status != PresenceStatusOnline {
+		t.Errorf("Expected bob to still be online, got '%s'", status)
+	}
+
+	now = now.Add(2 * time.Minute)
+	tracker.Sweep()
+	if status := tracker.Get("bob").Status; status != PresenceStatusAway {
+		t.Errorf("Expected bob to be away, got '%s'", status)
+	}
+
+	tracker.Touch("bob")
+	if status := tracker.Get("bob").Status; status != PresenceStatusOnline {
+		t.Errorf("Expected bob to be online after activity, got '%s'", status)
+	}
+}
+
+// TestPresenceTrackerUnknownUser 測試未知用戶視為 offline
+func TestPresenceTrackerUnknownUser(t *testing.T) {
+	tracker := NewPresenceTracker(time.Minute)
+	presence := tracker.Get("nobody")
+	if presence.Status != PresenceStatusOffline {
+		t.Errorf("Expected unknown user to be offline, got '%s'", presence.Status)
+	}
+}
+
+// TestGetPresenceAPI 測試在線狀態查詢 API
+func TestGetPresenceAPI(t *testing.T) {
+	savedPresenceTracker := presenceTracker
+	t.Cleanup(func() { presenceTracker = savedPresenceTracker })
+	presenceTracker = NewPresenceTracker(time.Minute)
+	presenceTracker.Connect(&Client{username: "alice", channel: "general"})
+	presenceTracker.Connect(&Client{username: "alice", channel: "general"})
+
+	req, _ := http.NewRequest("GET", "/api/presence?users=alice,bob", nil)
+	rr := httptest.NewRecorder()
+	setupRoutes().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+
+	var response struct {
+		Presence []UserPresence `json:"presence"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Presence) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(response.Presence))
+	}
+	if response.Presence[0].Devices != 2 || response.Presence[1].Status != PresenceStatusOffline {
+		t.Errorf("Unexpected presence response: %+v", response.Presence)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/channels/general/presence", nil)
+	req.SetBasicAuth("alice", "password123")
+	rr = httptest.NewRecorder()
+	setupRoutes().ServeHTTP(rr, req)
+
+	var channelResponse struct {
+		Users []UserPresence `json:"users"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &channelResponse)
+	if len(channelResponse.Users) != 1 {
+		t.Errorf("Expected 1 user in general, got %d", len(channelResponse.Users))
+	}
+
+	req, _ = http.NewRequest("GET", "/api/channels/general/presence", nil)
+	req.SetBasicAuth("bob", "password123")
+	rr = httptest.NewRecorder()
+	setupRoutes().ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for non-member, got %d", rr.Code)
+	}
+}
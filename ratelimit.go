@@ -0,0 +1,579 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrRateLimited        = errors.New(ErrorRateLimited)
+	ErrInvalidCredentials = errors.New(ErrorInvalidAuth)
+)
+
+// RateLimit 代表令牌桶的補充速率與容量
+//
+// Design considerations:
+// - Rate 或 Burst 為 0 時不限制
+type RateLimit struct {
+	Rate  float64 `json:"rate"`  // 每秒補充的令牌數
+	Burst int     `json:"burst"` // 令牌桶容量，即允許的瞬間數量
+}
+
+// RateLimitConfig 所有速率限制的設定
+type RateLimitConfig struct {
+	MessagePerUser     RateLimit `json:"messagePerUser"`     // 每位用戶發送訊息
+	MessagePerChannel  RateLimit `json:"messagePerChannel"`  // 每個頻道的訊息總量
+	LoginPerIP         RateLimit `json:"loginPerIP"`         // 每個 IP 的登入嘗試
+	WebSocketPerIP     RateLimit `json:"webSocketPerIP"`     // 每個 IP 的 WebSocket 連接
+	LoginMaxFailures   int       `json:"loginMaxFailures"`   // 鎖定帳號前允許的連續登入失敗次數
+	LoginFailureWindow int       `json:"loginFailureWindow"` // 秒，累計登入失敗的時間範圍
+	LoginLockout       int       `json:"loginLockout"`       // 秒，帳號鎖定時間
+}
+
+// RateLimitError 代表觸發速率限制
+//
+// Design considerations:
+// - errors.Is(err, ErrRateLimited) 可判斷是否為速率限制
+// - RetryAfter 讓客戶端知道多久後可以重試
+type RateLimitError struct {
+	Scope      string
+	RetryAfter time.Duration
+}
+
+// Error 返回包含限制範圍與重試秒數的錯誤訊息
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s (%s), retry after %ds", ErrorRateLimited, e.Scope, e.RetrySeconds())
+}
+
+// Is 讓 errors.Is 可以比對 ErrRateLimited
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// Code 返回回覆給客戶端的錯誤代碼
+//
+// Design considerations:
+// - 慢速模式是頻道設定而非伺服器的保護機制，使用獨立的 slow_mode 代碼方便客戶端顯示倒數
+func (e *RateLimitError) Code() string {
+	if e.Scope == RateLimitScopeSlowMode {
+		return ErrorCodeSlowMode
+	}
+	return ErrorCodeRateLimited
+}
+
+// RetrySeconds 返回無條件進位的重試秒數，至少為 1
+func (e *RateLimitError) RetrySeconds() int {
+	seconds := int(math.Ceil(e.RetryAfter.Seconds()))
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
+
+// tokenBucket 單一鍵值的令牌桶狀態
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter 以鍵值（用戶、頻道或 IP）區分的令牌桶限制器
+//
+// Design considerations:
+// - 令牌依經過時間補充，不需要背景 goroutine
+// - 已補滿的令牌桶與新建的相同，由 Prune 定期清除以控制記憶體用量
+// - 限制可以由 SetLimit 在執行期修改，現有的令牌桶保留
+type RateLimiter struct {
+	mu      sync.Mutex
+	limit   RateLimit
+	buckets map[string]*tokenBucket
+	now     func() time.Time
+}
+
+// NewRateLimiter 建立令牌桶限制器
+//
+// Parameters:
+// - limit: 補充速率與容量
+//
+// Returns:
+// - *RateLimiter: 新的限制器實例
+func NewRateLimiter(limit RateLimit) *RateLimiter {
+	return &RateLimiter{
+		limit:   limit,
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// Allow 嘗試取用一個令牌
+//
+// Parameters:
+// - key: 限制的對象
+//
+// Returns:
+// - bool: 是否允許
+// - time.Duration: 不允許時需要等待的時間
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.limit.Rate <= 0 || l.limit.Burst <= 0 {
+		return true, 0
+	}
+
+	now := l.now()
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(l.limit.Burst), last: now}
+		l.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(float64(l.limit.Burst), bucket.tokens+now.Sub(bucket.last).Seconds()*l.limit.Rate)
+	bucket.last = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	wait := (1 - bucket.tokens) / l.limit.Rate
+	return false, time.Duration(wait * float64(time.Second))
+}
+
+// SetLimit 修改補充速率與容量
+//
+// Design considerations:
+// - 保留現有的令牌桶，剩餘令牌在下次取用時以新的容量為上限
+//
+// Parameters:
+// - limit: 新的補充速率與容量
+func (l *RateLimiter) SetLimit(limit RateLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+}
+
+// Prune 清除已補滿的令牌桶
+func (l *RateLimiter) Prune() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.limit.Rate <= 0 {
+		return
+	}
+
+	now := l.now()
+	for key, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*l.limit.Rate >= float64(l.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// loginFailures 單一帳號的登入失敗紀錄
+type loginFailures struct {
+	count       int
+	first       time.Time
+	lockedUntil time.Time
+}
+
+// LoginGuard 防止暴力破解密碼
+//
+// Responsible for:
+// - 以令牌桶限制每個 IP 的登入嘗試次數
+// - 帳號在時間範圍內連續登入失敗達上限時暫時鎖定
+// - 每個請求的 Basic Auth 與 WebSocket 驗證以來源 IP 與帳號為單位鎖定
+//
+// Design considerations:
+// - 帳號以小寫用戶名為鍵值，不存在的帳號同樣計算，避免透過回應差異探測帳號
+// - 帳號層級的鎖定只由 POST /api/login 觸發與檢查
+// - 其他端點的驗證失敗只鎖定該來源 IP，避免任何人送出幾次錯誤的 Basic Auth 就讓帳號擁有者無法使用 API 與 WebSocket
+// - 登入成功時清除失敗紀錄
+type LoginGuard struct {
+	mu              sync.Mutex
+	ip              *RateLimiter
+	maxFailures     int
+	window          time.Duration
+	lockout         time.Duration
+	failures        map[string]*loginFailures // 帳號層級，鍵值為小寫用戶名
+	requestFailures map[string]*loginFailures // 請求驗證，鍵值見 requestFailureKey
+	now             func() time.Time
+}
+
+// NewLoginGuard 建立登入保護
+//
+// Parameters:
+// - config: 速率限制設定
+//
+// Returns:
+// - *LoginGuard: 新的登入保護實例
+func NewLoginGuard(config RateLimitConfig) *LoginGuard {
+	return &LoginGuard{
+		ip:              NewRateLimiter(config.LoginPerIP),
+		maxFailures:     config.LoginMaxFailures,
+		window:          time.Duration(config.LoginFailureWindow) * time.Second,
+		lockout:         time.Duration(config.LoginLockout) * time.Second,
+		failures:        make(map[string]*loginFailures),
+		requestFailures: make(map[string]*loginFailures),
+		now:             time.Now,
+	}
+}
+
+// Reconfigure 修改登入嘗試限制與鎖定設定，保留現有的失敗紀錄與鎖定
+//
+// Parameters:
+// - config: 速率限制設定
+func (g *LoginGuard) Reconfigure(config RateLimitConfig) {
+	g.ip.SetLimit(config.LoginPerIP)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.maxFailures = config.LoginMaxFailures
+	g.window = time.Duration(config.LoginFailureWindow) * time.Second
+	g.lockout = time.Duration(config.LoginLockout) * time.Second
+}
+
+// requestFailureKey 返回請求驗證失敗紀錄的鍵值（來源 IP 與小寫用戶名）
+func requestFailureKey(ip, username string) string {
+	return ip + "\x00" + strings.ToLower(username)
+}
+
+// CheckAttempt 檢查是否允許登入嘗試
+//
+// Parameters:
+// - ip: 請求來源 IP，空字串表示不限制 IP（例如每個 API 請求的 Basic Auth）
+// - username: 嘗試登入的用戶名
+//
+// Returns:
+// - error: IP 嘗試過多或帳號被鎖定時返回 *RateLimitError
+func (g *LoginGuard) CheckAttempt(ip, username string) error {
+	if ip != "" {
+		if ok, wait := g.ip.Allow(ip); !ok {
+			return &RateLimitError{Scope: RateLimitScopeLoginIP, RetryAfter: wait}
+		}
+	}
+
+	return g.locked(g.failures, strings.ToLower(username))
+}
+
+// RecordFailure 記錄登入失敗，達到上限時鎖定帳號
+//
+// Parameters:
+// - username: 登入失敗的用戶名
+//
+// Returns:
+// - bool: 這次失敗是否導致帳號被鎖定
+func (g *LoginGuard) RecordFailure(username string) bool {
+	return g.recordFailure(g.failures, strings.ToLower(username))
+}
+
+// RecordSuccess 登入成功時清除失敗紀錄
+//
+// Parameters:
+// - username: 登入成功的用戶名
+func (g *LoginGuard) RecordSuccess(username string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.failures, strings.ToLower(username))
+}
+
+// CheckRequest 檢查來源 IP 是否因為這個帳號驗證失敗過多而被暫時鎖定
+//
+// Design considerations:
+// - 不檢查帳號層級的鎖定，也不計入 IP 的登入嘗試次數（每個 API 請求都會驗證）
+//
+// Parameters:
+// - ip: 請求來源 IP
+// - username: 驗證的用戶名
+//
+// Returns:
+// - error: 被鎖定時返回 *RateLimitError
+func (g *LoginGuard) CheckRequest(ip, username string) error {
+	return g.locked(g.requestFailures, requestFailureKey(ip, username))
+}
+
+// RecordRequestFailure 記錄請求驗證失敗，達到上限時鎖定這個來源 IP 與帳號的組合
+//
+// Returns:
+// - bool: 這次失敗是否導致鎖定
+func (g *LoginGuard) RecordRequestFailure(ip, username string) bool {
+	return g.recordFailure(g.requestFailures, requestFailureKey(ip, username))
+}
+
+// RecordRequestSuccess 請求驗證成功時清除這個來源 IP 與帳號的失敗紀錄
+func (g *LoginGuard) RecordRequestSuccess(ip, username string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.requestFailures, requestFailureKey(ip, username))
+}
+
+// locked 檢查失敗紀錄是否處於鎖定期間
+func (g *LoginGuard) locked(failures map[string]*loginFailures, key string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if record, ok := failures[key]; ok {
+		if wait := record.lockedUntil.Sub(g.now()); wait > 0 {
+			return &RateLimitError{Scope: RateLimitScopeLoginUser, RetryAfter: wait}
+		}
+	}
+	return nil
+}
+
+// recordFailure 累計失敗次數，達到上限時鎖定並重新計算
+func (g *LoginGuard) recordFailure(failures map[string]*loginFailures, key string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.maxFailures <= 0 {
+		return false
+	}
+
+	now := g.now()
+	record, ok := failures[key]
+	if !ok || now.Sub(record.first) > g.window {
+		record = &loginFailures{first: now}
+		failures[key] = record
+	}
+	record.count++
+	if record.count < g.maxFailures {
+		return false
+	}
+	record.count = 0
+	record.first = now
+	record.lockedUntil = now.Add(g.lockout)
+	return true
+}
+
+// Prune 清除過期的失敗紀錄與已補滿的令牌桶
+func (g *LoginGuard) Prune() {
+	g.ip.Prune()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	for _, failures := range []map[string]*loginFailures{g.failures, g.requestFailures} {
+		for key, record := range failures {
+			if now.Sub(record.first) > g.window && !record.lockedUntil.After(now) {
+				delete(failures, key)
+			}
+		}
+	}
+}
+
+// RateLimits 伺服器使用的所有速率限制
+//
+// Responsible for:
+// - 集中訊息、登入與 WebSocket 連接的速率限制
+// - 觸發限制時累加 metrics 計數器並記錄日誌
+//
+// Design considerations:
+// - 全域只有一個實例，重新載入設定時以 Reconfigure 原地修改，不替換全域變數
+// - 請求處理與 run 的清除迴圈因此不會讀到替換中的實例，令牌桶與登入鎖定也不會被清空
+type RateLimits struct {
+	messageUser    *RateLimiter
+	messageChannel *RateLimiter
+	webSocketIP    *RateLimiter
+	login          *LoginGuard
+}
+
+// NewRateLimits 依設定建立速率限制
+//
+// Parameters:
+// - config: 速率限制設定
+//
+// Returns:
+// - *RateLimits: 新的速率限制實例
+func NewRateLimits(config RateLimitConfig) *RateLimits {
+	return &RateLimits{
+		messageUser:    NewRateLimiter(config.MessagePerUser),
+		messageChannel: NewRateLimiter(config.MessagePerChannel),
+		webSocketIP:    NewRateLimiter(config.WebSocketPerIP),
+		login:          NewLoginGuard(config),
+	}
+}
+
+// Reconfigure 套用新的速率限制設定
+//
+// Design considerations:
+// - 每個限制器各自加鎖修改，可以與請求處理同時執行
+// - 保留現有的令牌桶、登入失敗紀錄與鎖定
+//
+// Parameters:
+// - config: 新的速率限制設定
+func (rl *RateLimits) Reconfigure(config RateLimitConfig) {
+	rl.messageUser.SetLimit(config.MessagePerUser)
+	rl.messageChannel.SetLimit(config.MessagePerChannel)
+	rl.webSocketIP.SetLimit(config.WebSocketPerIP)
+	rl.login.Reconfigure(config)
+}
+
+// AllowMessage 檢查用戶是否可以在頻道發送訊息
+//
+// Design considerations:
+// - 先檢查用戶再檢查頻道，單一用戶洗版不會耗盡整個頻道的額度
+//
+// Parameters:
+// - username: 發送者
+// - channel: 頻道名稱
+//
+// Returns:
+// - error: 超過限制時返回 *RateLimitError
+func (rl *RateLimits) AllowMessage(username, channel string) error {
+	if ok, wait := rl.messageUser.Allow(username); !ok {
+		return rl.reject(username, &RateLimitError{Scope: RateLimitScopeMessageUser, RetryAfter: wait})
+	}
+	if ok, wait := rl.messageChannel.Allow(channel); !ok {
+		return rl.reject(username, &RateLimitError{Scope: RateLimitScopeMessageChannel, RetryAfter: wait})
+	}
+	return nil
+}
+
+// AllowWebSocket 檢查 IP 是否可以建立 WebSocket 連接
+//
+// Parameters:
+// - ip: 請求來源 IP
+//
+// Returns:
+// - error: 超過限制時返回 *RateLimitError
+func (rl *RateLimits) AllowWebSocket(ip string) error {
+	if ok, wait := rl.webSocketIP.Allow(ip); !ok {
+		return rl.reject(ip, &RateLimitError{Scope: RateLimitScopeWebSocketIP, RetryAfter: wait})
+	}
+	return nil
+}
+
+// Login 在登入保護下驗證帳號密碼
+//
+// Process flow:
+// 1. 檢查 IP 嘗試次數與帳號是否被鎖定
+// 2. 驗證帳號密碼
+// 3. 失敗時記錄並在達到上限時鎖定帳號，成功時清除失敗紀錄
+//
+// Parameters:
+// - ip: 請求來源 IP，空字串表示不限制 IP
+// - username: 用戶名
+// - password: 密碼
+//
+// Returns:
+// - *Account: 驗證成功的帳號資訊
+// - error: 被限制時返回 *RateLimitError，帳密錯誤時返回 ErrInvalidCredentials
+func (rl *RateLimits) Login(ip, username, password string) (*Account, error) {
+	if err := rl.login.CheckAttempt(ip, username); err != nil {
+		return nil, rl.reject(username, err.(*RateLimitError))
+	}
+
+	account, valid := validateAccount(username, password)
+	if !valid {
+		metrics.Inc(MetricLoginFailures)
+		if rl.login.RecordFailure(username) {
+			metrics.Inc(MetricLoginLockouts)
+			log.Printf(LogLoginLocked, username)
+		}
+		return nil, ErrInvalidCredentials
+	}
+	rl.login.RecordSuccess(username)
+	return account, nil
+}
+
+// Authenticate 驗證每個請求攜帶的帳號密碼（Basic Auth、查詢參數與 WebSocket 連接）
+//
+// Design considerations:
+// - 失敗以來源 IP 與帳號為單位鎖定，不影響其他 IP 上的同一個帳號
+// - 不受 POST /api/login 的帳號層級鎖定影響
+//
+// Parameters:
+// - ip: 請求來源 IP
+// - username: 用戶名
+// - password: 密碼
+//
+// Returns:
+// - *Account: 驗證成功的帳號
+// - error: 被鎖定時返回 *RateLimitError，帳號密碼錯誤時返回 ErrInvalidCredentials
+func (rl *RateLimits) Authenticate(ip, username, password string) (*Account, error) {
+	if err := rl.login.CheckRequest(ip, username); err != nil {
+		return nil, rl.reject(username, err.(*RateLimitError))
+	}
+
+	account, valid := validateAccount(username, password)
+	if !valid {
+		metrics.Inc(MetricLoginFailures)
+		if rl.login.RecordRequestFailure(ip, username) {
+			metrics.Inc(MetricLoginLockouts)
+			log.Printf(LogRequestAuthLocked, ip, username)
+		}
+		return nil, ErrInvalidCredentials
+	}
+	rl.login.RecordRequestSuccess(ip, username)
+	return account, nil
+}
+
+// reject 記錄觸發的速率限制
+func (rl *RateLimits) reject(subject string, err *RateLimitError) error {
+	metrics.Inc(MetricRateLimitedPrefix + err.Scope)
+	log.Printf(LogRateLimited, subject, err)
+	return err
+}
+
+// run 定期清除閒置的令牌桶與過期的登入失敗紀錄
+//
+// Parameters:
+// - interval: 清除間隔
+func (rl *RateLimits) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		rl.messageUser.Prune()
+		rl.messageChannel.Prune()
+		rl.webSocketIP.Prune()
+		rl.login.Prune()
+	}
+}
+
+// clientIP 取得請求的來源 IP
+//
+// Design considerations:
+// - 只使用連線的遠端位址，不信任可被偽造的 X-Forwarded-For 標頭
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// writeRateLimitError 以 429 回應觸發速率限制的請求
+//
+// Parameters:
+// - w: HTTP 回應
+// - err: 速率限制錯誤
+func writeRateLimitError(w http.ResponseWriter, err *RateLimitError) {
+	w.Header().Set("Retry-After", strconv.Itoa(err.RetrySeconds()))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":      err.Error(),
+		"code":       err.Code(),
+		"scope":      err.Scope,
+		"retryAfter": err.RetrySeconds(),
+	})
+}
+
+// newRateLimitMessage 建立回覆給 WebSocket 發送者的速率限制錯誤事件
+//
+// Parameters:
+// - err: 速率限制錯誤
+// - channel: 頻道名稱
+//
+// Returns:
+// - Message: error 事件，retryAfter 為需要等待的秒數
+func newRateLimitMessage(err *RateLimitError, channel string) Message {
+	event := NewErrorMessage(err.Code(), err.Error(), channel)
+	event.Error.RetryAfter = err.RetrySeconds()
+	return event
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestRateLimiter 測試令牌桶的取用與補充
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(RateLimit{Rate: 2, Burst: 3})
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := limiter.Allow("bob"); !ok {
+			t.Fatalf("Expected request %d within burst to be allowed", i+1)
+		}
+	}
+	ok, wait := limiter.Allow("bob")
+	if ok || wait != 500*time.Millisecond {
+		t.Errorf("Expected rejection with 500ms wait, got %v %v", ok, wait)
+	}
+	if ok, _ := limiter.Allow("alice"); !ok {
+		t.Error("Expected keys to have separate buckets")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if ok, _ := limiter.Allow("bob"); !ok {
+		t.Error("Expected token to be refilled")
+	}
+
+	now = now.Add(time.Hour)
+	limiter.Prune()
+	if len(limiter.buckets) != 0 {
+		t.Errorf("Expected full buckets to be pruned, got %d", len(limiter.buckets))
+	}
+
+	t.Run("零表示不限制", func(t *testing.T) {
+		unlimited := NewRateLimiter(RateLimit{})
+		for i := 0; i < 100; i++ {
+			if ok, _ := unlimited.Allow("bob"); !ok {
+				t.Fatal("Expected zero limit to allow everything")
+			}
+		}
+	})
+}
+
+// TestLoginGuard 測試登入失敗鎖定
+func TestLoginGuard(t *testing.T) {
+	now := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	guard := NewLoginGuard(RateLimitConfig{
+		LoginPerIP:         RateLimit{Rate: 1, Burst: 10},
+		LoginMaxFailures:   3,
+		LoginFailureWindow: 60,
+		LoginLockout:       300,
+	})
+	guard.now = func() time.Time { return now }
+	guard.ip.now = guard.now
+
+	guard.RecordFailure("bob")
+	guard.RecordSuccess("Bob")
+	guard.RecordFailure("bob")
+	guard.RecordFailure("bob")
+	if err := guard.CheckAttempt("", "bob"); err != nil {
+		t.Fatalf("Expected success to reset failures, got %v", err)
+	}
+	if !guard.RecordFailure("BOB") {
+		t.Fatal("Expected third failure to lock the account")
+	}
+
+	err := guard.CheckAttempt("", "bob")
+	var limited *RateLimitError
+	if !errors.As(err, &limited) || limited.Scope != RateLimitScopeLoginUser || limited.RetrySeconds() != 300 {
+		t.Errorf("Expected login_user lockout for 300s, got %v", err)
+	}
+	if err := guard.CheckAttempt("", "alice"); err != nil {
+		t.Errorf("Expected other accounts to be unaffected, got %v", err)
+	}
+
+	now = now.Add(5 * time.Minute)
+	if err := guard.CheckAttempt("", "bob"); err != nil {
+		t.Errorf("Expected lockout to expire, got %v", err)
+	}
+
+	t.Run("請求驗證以來源 IP 與帳號鎖定", func(t *testing.T) {
+		guard.RecordRequestFailure("192.0.2.7", "dave")
+		guard.RecordRequestFailure("192.0.2.7", "dave")
+		if !guard.RecordRequestFailure("192.0.2.7", "Dave") {
+			t.Fatal("Expected third failure to lock the IP")
+		}
+		if err := guard.CheckRequest("192.0.2.7", "dave"); !errors.Is(err, ErrRateLimited) {
+			t.Errorf("Expected IP to be locked for dave, got %v", err)
+		}
+		if err := guard.CheckRequest("192.0.2.8", "dave"); err != nil {
+			t.Errorf("Expected other IPs to be unaffected, got %v", err)
+		}
+		if err := guard.CheckAttempt("", "dave"); err != nil {
+			t.Errorf("Expected account-wide login to be unaffected, got %v", err)
+		}
+	})
+
+	t.Run("IP 嘗試次數", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			guard.CheckAttempt("192.0.2.1", "charlie")
+		}
+		if err := guard.CheckAttempt("192.0.2.1", "charlie"); !errors.Is(err, ErrRateLimited) {
+			t.Errorf("Expected IP to be rate limited, got %v", err)
+		}
+	})
+}
+
+// TestRateLimitsReconfigure 測試執行期修改速率限制時保留狀態，並可與請求處理同時執行
+func TestRateLimitsReconfigure(t *testing.T) {
+	config := RateLimitConfig{
+		MessagePerUser:     RateLimit{Rate: 0.1, Burst: 1},
+		LoginMaxFailures:   1,
+		LoginFailureWindow: 60,
+		LoginLockout:       60,
+	}
+	limits := NewRateLimits(config)
+	limits.AllowMessage("bob", "tech")
+	limits.Authenticate("192.0.2.7", "dave", "wrong")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				limits.AllowMessage("charlie", "random")
+				limits.AllowWebSocket("192.0.2.9")
+				limits.Authenticate("192.0.2.9", "charlie", "wrong")
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		config.WebSocketPerIP.Burst = i
+		limits.Reconfigure(config)
+	}
+	wg.Wait()
+
+	if err := limits.AllowMessage("bob", "tech"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected message bucket to survive reconfiguration, got %v", err)
+	}
+	if _, err := limits.Authenticate("192.0.2.7", "dave", "wrong"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected lockout to survive reconfiguration, got %v", err)
+	}
+
+	limits.Reconfigure(RateLimitConfig{})
+	if err := limits.AllowMessage("bob", "tech"); err != nil {
+		t.Errorf("Expected new limits to apply, got %v", err)
+	}
+}
+
+// TestRateLimitAPI 測試 API 回應速率限制錯誤並累計指標
+func TestRateLimitAPI(t *testing.T) {
+	hub = *newTestHub(0)
+	messageStore = make(MessageStore)
+	messageIndex = NewSearchIndex()
+	metrics = NewMetrics()
+	original := rateLimits
+	defer func() { rateLimits = original }()
+	rateLimits = NewRateLimits(RateLimitConfig{
+		MessagePerUser:     RateLimit{Rate: 0.1, Burst: 2},
+		LoginPerIP:         RateLimit{Rate: 0.1, Burst: 100},
+		LoginMaxFailures:   2,
+		LoginFailureWindow: 60,
+		LoginLockout:       60,
+	})
+
+	t.Run("訊息", func(t *testing.T) {
+		postCommand("bob", "tech", "第一則")
+		postCommand("bob", "tech", "第二則")
+		rr := postCommand("bob", "tech", "第三則")
+		if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") == "" {
+			t.Fatalf("Expected 429 with Retry-After, got %d", rr.Code)
+		}
+		var response map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		if response["code"] != ErrorCodeRateLimited || response["scope"] != RateLimitScopeMessageUser {
+			t.Errorf("Expected structured rate limit error, got %v", response)
+		}
+		if count := messageStore.GetChannelMessageCount("tech"); count != 2 {
+			t.Errorf("Expected 2 stored messages, got %d", count)
+		}
+	})
+
+	t.Run("登入鎖定", func(t *testing.T) {
+		login := func(password string) int {
+			body, _ := json.Marshal(map[string]string{"username": "charlie", "password": password})
+			req := httptest.NewRequest("POST", "/api/login", bytes.NewReader(body))
+			rr := httptest.NewRecorder()
+			setupRoutes().ServeHTTP(rr, req)
+			return rr.Code
+		}
+
+		login("wrong")
+		login("wrong")
+		if code := login("password123"); code != http.StatusTooManyRequests {
+			t.Errorf("Expected locked account to get 429, got %d", code)
+		}
+	})
+
+	t.Run("指標", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/api/metrics", nil)
+		rr := httptest.NewRecorder()
+		setupRoutes().ServeHTTP(rr, req)
+		var response struct {
+			Counters map[string]int64 `json:"counters"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		if response.Counters[MetricRateLimitedPrefix+RateLimitScopeMessageUser] != 1 {
+			t.Errorf("Expected one message_user violation, got %v", response.Counters)
+		}
+		if response.Counters[MetricLoginFailures] != 2 || response.Counters[MetricLoginLockouts] != 1 {
+			t.Errorf("Expected login failures and lockout to be counted, got %v", response.Counters)
+		}
+	})
+
+	t.Run("API 驗證失敗只鎖定來源 IP", func(t *testing.T) {
+		request := func(ip, password string) int {
+			req := httptest.NewRequest("GET", "/api/mentions", nil)
+			req.RemoteAddr = ip + ":40000"
+			req.SetBasicAuth("alice", password)
+			rr := httptest.NewRecorder()
+			setupRoutes().ServeHTTP(rr, req)
+			return rr.Code
+		}
+
+		request("198.51.100.7", "wrong")
+		request("198.51.100.7", "wrong")
+		if code := request("198.51.100.7", "password123"); code != http.StatusUnauthorized {
+			t.Errorf("Expected attacking IP to be locked out, got %d", code)
+		}
+		if code := request("203.0.113.5", "password123"); code != http.StatusOK {
+			t.Errorf("Expected owner on another IP to be unaffected, got %d", code)
+		}
+
+		body, _ := json.Marshal(map[string]string{"username": "alice", "password": "password123"})
+		req := httptest.NewRequest("POST", "/api/login", bytes.NewReader(body))
+		rr := httptest.NewRecorder()
+		setupRoutes().ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Errorf("Expected login to be unaffected by API lockout, got %d", rr.Code)
+		}
+	})
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ConfigStatus 目前生效的設定版本
+type ConfigStatus struct {
+	Version       int       `json:"version"`                 // 從 1 開始，每次成功重新載入加一
+	Hash          string    `json:"hash"`                    // 生效設定的 SHA-256 前 12 碼
+	File          string    `json:"file,omitempty"`          // 設定檔路徑
+	LoadedAt      time.Time `json:"loadedAt"`                // 生效時間
+	LastError     string    `json:"lastError,omitempty"`     // 最近一次重新載入失敗的原因，成功後清除
+	LastAttemptAt time.Time `json:"lastAttemptAt,omitempty"` // 最近一次嘗試重新載入的時間
+}
+
+// ConfigManager 保存生效中的伺服器設定並處理重新載入
+//
+// Responsible for:
+// - 提供處理器讀取目前的 ServerConfig
+// - 在 SIGHUP 或設定檔修改時重新載入可以即時生效的設定
+// - 記錄設定版本供管理 API 查詢
+//
+// Design considerations:
+// - 重新載入使用與啟動相同的來源與優先順序（設定檔、環境變數、啟動時的命令列參數）
+// - 先完整驗證再套用，不合法的設定整個被拒絕，生效中的設定不變
+// - 只套用 reloadServerConfig 列出的欄位；埠號、TLS、讀取限制、緩衝區、在線狀態、釘選與上傳設定只記錄需要重新啟動
+// - 重新載入與管理 API 的執行期設定修改之間以 reloadMu 序列化，連接不會中斷
+//
+// Usage context:
+// - 全域 configManager 實例
+// - main 啟動 watchSignals 與 watchFile
+type ConfigManager struct {
+	mu       sync.RWMutex
+	reloadMu sync.Mutex
+	config   ServerConfig
+	status   ConfigStatus
+	load     func() (ServerConfig, error)
+	now      func() time.Time
+}
+
+// NewConfigManager 建立設定管理器
+//
+// Parameters:
+// - config: 啟動時載入的設定
+// - load: 重新載入時讀取設定的函式，nil 表示不支援重新載入
+//
+// Returns:
+// - *ConfigManager: 版本為 1 的設定管理器
+func NewConfigManager(config ServerConfig, load func() (ServerConfig, error)) *ConfigManager {
+	m := &ConfigManager{config: config, load: load, now: time.Now}
+	m.status = ConfigStatus{Version: 1, Hash: hashServerConfig(config), File: config.File, LoadedAt: m.now()}
+	return m
+}
+
+// Get 返回目前生效的設定
+func (m *ConfigManager) Get() ServerConfig {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.config
+}
+
+// Status 返回目前的設定版本
+func (m *ConfigManager) Status() ConfigStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.status
+}
+
+// Reload 重新載入並套用設定
+//
+// Process flow:
+// 1. 以 load 讀取並驗證新設定
+// 2. 將可重新載入的欄位合併到目前設定，其餘有變更的欄位記錄需要重新啟動
+// 3. 套用執行期設定（歷史數量、檢舉隱藏門檻、速率限制、審核詞彙）
+// 4. 替換生效中的設定並增加版本
+// 5. 記錄日誌與稽核紀錄
+//
+// Parameters:
+// - trigger: 觸發來源，ConfigReloadSignal 或 ConfigReloadFileChange
+//
+// Returns:
+// - ConfigStatus: 重新載入後的設定版本
+// - error: 讀取或驗證失敗時返回錯誤，生效中的設定不變
+func (m *ConfigManager) Reload(trigger string) (ConfigStatus, error) {
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+
+	if m.load == nil {
+		return m.Status(), nil
+	}
+	next, err := m.load()
+	if err == nil {
+		next = reloadServerConfig(m.Get(), next)
+		err = applyRuntimeConfig(next.runtimeConfig(runtimeSettings.Get()))
+	}
+
+	m.mu.Lock()
+	m.status.LastAttemptAt = m.now()
+	if err != nil {
+		m.status.LastError = err.Error()
+	} else {
+		m.config = next
+		m.status.Version++
+		m.status.Hash = hashServerConfig(next)
+		m.status.File = next.File
+		m.status.LoadedAt = m.status.LastAttemptAt
+		m.status.LastError = ""
+	}
+	status := m.status
+	m.mu.Unlock()
+
+	if err != nil {
+		log.Printf(LogConfigReloadFailed, trigger, status.Version, err)
+	} else {
+		log.Printf(LogConfigReloaded, trigger, status.Version, status.Hash)
+	}
+	auditLog.Record(AuditEntry{
+		Actor:   AuditActorSystem,
+		Action:  AuditActionConfigReload,
+		Target:  status.File,
+		Outcome: auditOutcome(err, adminStatusCode),
+		Details: auditDetails(trigger, err),
+	})
+	return status, err
+}
+
+// UpdateRuntimeConfig 以 update 修改並套用執行期設定
+//
+// Design considerations:
+// - 與 Reload 共用 reloadMu，管理 API 的修改與重新載入依序套用，不會互相覆蓋
+// - update 收到持有鎖時的目前設定，讀取、修改與套用之間不會插入其他修改
+//
+// Parameters:
+// - update: 以目前設定產生新設定的函式
+//
+// Returns:
+// - RuntimeConfig: 套用後的設定
+// - error: update 或 applyRuntimeConfig 的錯誤，生效中的設定不變
+func (m *ConfigManager) UpdateRuntimeConfig(update func(RuntimeConfig) (RuntimeConfig, error)) (RuntimeConfig, error) {
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+
+	config, err := update(runtimeSettings.Get())
+	if err == nil {
+		err = applyRuntimeConfig(config)
+	}
+	if err != nil {
+		return RuntimeConfig{}, err
+	}
+	return runtimeSettings.Get(), nil
+}
+
+// reloadServerConfig 將新設定中可重新載入的欄位合併到目前設定
+//
+// Design considerations:
+// - 歷史數量、檢舉隱藏門檻、速率限制、審核詞彙、Origin、CORS 與帳號立即生效
+// - 其他欄位維持目前的值，有變更時記錄需要重新啟動
+//
+// Parameters:
+// - current: 生效中的設定
+// - next: 重新載入的設定
+//
+// Returns:
+// - ServerConfig: 合併後的設定
+func reloadServerConfig(current, next ServerConfig) ServerConfig {
+	var restart []string
+	for _, field := range []struct {
+		name    string
+		changed bool
+	}{
+		{"host", current.Host != next.Host},
+		{"port", current.Port != next.Port},
+		{"readLimit", current.ReadLimit != next.ReadLimit},
+		{"readTimeout", current.ReadTimeout != next.ReadTimeout},
+		{"clientSendBuffer", current.ClientSendBuffer != next.ClientSendBuffer},
+		{"hubBroadcastBuffer", current.HubBroadcastBuffer != next.HubBroadcastBuffer},
+		{"presenceAwayTimeout", current.PresenceAwayTimeout != next.PresenceAwayTimeout},
+		{"presenceGracePeriod", current.PresenceGracePeriod != next.PresenceGracePeriod},
+		{"pinLimit", current.PinLimit != next.PinLimit},
+		{"uploads", current.Uploads != next.Uploads},
+		{"tls", current.TLS != next.TLS},
+		{"roles", !slices.Equal(current.Roles, next.Roles)},
+	} {
+		if field.changed {
+			restart = append(restart, field.name)
+		}
+	}
+	if len(restart) > 0 {
+		log.Printf(LogConfigRestartRequired, strings.Join(restart, ", "))
+	}
+
+	merged := current
+	merged.HistoryLimit = next.HistoryLimit
+	merged.ReportHideThreshold = next.ReportHideThreshold
+	merged.RateLimits = next.RateLimits
+	merged.MaskedWords = next.MaskedWords
+	merged.FlaggedWords = next.FlaggedWords
+	merged.BlockedDomains = next.BlockedDomains
+	merged.AllowAllOrigins = next.AllowAllOrigins
+	merged.AllowedOrigins = next.AllowedOrigins
+	merged.CORS = next.CORS
+	merged.Accounts = next.Accounts
+	merged.File = next.File
+	return merged
+}
+
+// hashServerConfig 返回設定 JSON 編碼的 SHA-256 前 12 碼，用於比對版本內容
+func hashServerConfig(config ServerConfig) string {
+	data, _ := json.Marshal(config)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// watchSignals 收到 SIGHUP 時重新載入設定
+func (m *ConfigManager) watchSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		m.Reload(ConfigReloadSignal)
+	}
+}
+
+// watchFile 定期檢查設定檔，修改時間或大小改變時重新載入
+//
+// Design considerations:
+// - 以輪詢實作，不需要額外的檔案監看套件
+// - 編輯器先寫入暫存檔再改名也能偵測到
+// - 讀取失敗（例如檔案暫時不存在）只在第一次記錄日誌，下次檢查再試
+//
+// Parameters:
+// - path: 設定檔路徑
+// - interval: 檢查間隔
+func (m *ConfigManager) watchFile(path string, interval time.Duration) {
+	info, err := os.Stat(path)
+	if err != nil {
+		log.Printf(LogConfigWatchError, path, err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		next, statErr := os.Stat(path)
+		if statErr != nil {
+			if err == nil {
+				log.Printf(LogConfigWatchError, path, statErr)
+			}
+			err = statErr
+			continue
+		}
+		err = nil
+		if info != nil && next.ModTime().Equal(info.ModTime()) && next.Size() == info.Size() {
+			continue
+		}
+		info = next
+		m.Reload(ConfigReloadFileChange)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestConfigReload 測試重新載入設定的合併、驗證與版本
+func TestConfigReload(t *testing.T) {
+	savedRateLimits := rateLimits
+	defer func() {
+		configManager = NewConfigManager(defaultServerConfig(), nil)
+		runtimeSettings = NewRuntimeSettings(defaultRuntimeConfig())
+		moderationPipeline = newDefaultModerationPipeline()
+		reportQueue = NewReportQueue(DefaultReportHideThreshold)
+		rateLimits = savedRateLimits
+	}()
+	rateLimits = NewRateLimits(RateLimitConfig{})
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfig := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	load := func() (ServerConfig, error) {
+		config, _, err := LoadServerConfig([]string{"--config", path}, func(string) string { return "" }, io.Discard)
+		return config, err
+	}
+
+	writeConfig(`{}`)
+	initial, err := load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	configManager = NewConfigManager(initial, load)
+	runtimeSettings = NewRuntimeSettings(defaultRuntimeConfig())
+
+	t.Run("套用可重新載入的設定", func(t *testing.T) {
+		writeConfig(`{
+			"port": 9999,
+			"historyLimit": 20,
+			"allowAllOrigins": false,
+			"allowedOrigins": ["http://localhost:3000"],
+			"accounts": [{"username": "dave", "password": "secret", "channel": "ops"}],
+			"maskedWords": ["壞話"],
+			"reportHideThreshold": 5,
+			"pinLimit": 10,
+			"uploads": {"dir": "./other"}
+		}`)
+		status, err := configManager.Reload(ConfigReloadSignal)
+		if err != nil || status.Version != 2 || status.Hash == "" {
+			t.Fatalf("Expected version 2, got %+v (%v)", status, err)
+		}
+
+		config := configManager.Get()
+		if config.Port != DefaultServerPort || config.PinLimit != DefaultPinLimit || config.Uploads.Dir != DefaultUploadDir {
+			t.Errorf("Expected port, pin limit and uploads to require restart, got %+v", config)
+		}
+		if config.AllowAllOrigins || len(config.AllowedOrigins) != 1 {
+			t.Errorf("Expected origins to reload, got %+v", config)
+		}
+		if _, ok := validateAccount("dave", "secret"); !ok {
+			t.Error("Expected reloaded account to log in")
+		}
+		if _, ok := validateAccount("alice", "password123"); ok {
+			t.Error("Expected removed account to be rejected")
+		}
+		if runtime := runtimeSettings.Get(); runtime.ReportHideThreshold != 5 || reportQueue.hideThreshold != 5 {
+			t.Errorf("Expected report hide threshold to reload, got %d", runtime.ReportHideThreshold)
+		}
+		if runtime := runtimeSettings.Get(); runtime.HistoryLimit != 20 || len(runtime.MaskedWords) != 1 {
+			t.Errorf("Expected runtime config to reload, got %+v", runtime)
+		}
+		msg := NewMessage("dave", "這是壞話", "ops")
+		if _, err := moderationPipeline.Moderate(&msg); err != nil || msg.Content != "這是**" {
+			t.Errorf("Expected reloaded word list to mask, got %q (%v)", msg.Content, err)
+		}
+	})
+
+	t.Run("拒絕不合法的設定", func(t *testing.T) {
+		before := configManager.Status()
+		for _, content := range []string{`{"historyLimit": 0}`, `{"historyLimit": `, `{"rateLimits": {"loginLockout": -1}}`} {
+			writeConfig(content)
+			status, err := configManager.Reload(ConfigReloadFileChange)
+			if err == nil {
+				t.Errorf("%s: expected reload to fail", content)
+			}
+			if status.Version != before.Version || status.Hash != before.Hash || status.LastError == "" {
+				t.Errorf("%s: expected version to stay %d with error, got %+v", content, before.Version, status)
+			}
+		}
+		if runtimeSettings.Get().HistoryLimit != 20 || configManager.Get().Accounts[0].Username != "dave" {
+			t.Error("Expected active config to be unchanged after failed reload")
+		}
+	})
+
+	t.Run("管理 API 顯示生效版本", func(t *testing.T) {
+		writeConfig(`{"accounts": [{"username": "alice", "password": "password123", "channel": "general"}]}`)
+		if _, err := configManager.Reload(ConfigReloadSignal); err != nil {
+			t.Fatal(err)
+		}
+		roleStore = NewRoleStore([]RoleGrant{{Username: "alice", Role: RoleAdmin}})
+		defer func() { roleStore = NewRoleStore(DefaultRoleGrants) }()
+
+		req, _ := http.NewRequest("GET", "/api/admin/config/version", nil)
+		req.SetBasicAuth("alice", "password123")
+		rr := httptest.NewRecorder()
+		setupRoutes().ServeHTTP(rr, req)
+
+		var status ConfigStatus
+		json.Unmarshal(rr.Body.Bytes(), &status)
+		if rr.Code != http.StatusOK || status.Version != 3 || status.File != path || status.LastError != "" {
+			t.Errorf("Expected version 3 from %s, got %d %+v", path, rr.Code, status)
+		}
+	})
+
+	t.Run("管理 API 與重新載入依序套用", func(t *testing.T) {
+		writeConfig(`{"historyLimit": 30}`)
+		done := make(chan error, 1)
+		config, err := configManager.UpdateRuntimeConfig(func(current RuntimeConfig) (RuntimeConfig, error) {
+			go func() {
+				_, err := configManager.Reload(ConfigReloadSignal)
+				done <- err
+			}()
+			select {
+			case <-done:
+				t.Error("Expected reload to wait for the runtime config update")
+			case <-time.After(50 * time.Millisecond):
+			}
+			current.HistoryLimit = 10
+			return current, nil
+		})
+		if err != nil || config.HistoryLimit != 10 {
+			t.Fatalf("Expected history limit 10, got %+v (%v)", config, err)
+		}
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+		if runtime := runtimeSettings.Get(); runtime.HistoryLimit != 30 {
+			t.Errorf("Expected reload to apply after the update, got history limit %d", runtime.HistoryLimit)
+		}
+	})
+}
@@ -0,0 +1,520 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 檢舉相關錯誤
+var (
+	ErrInvalidReportReason   = errors.New(ErrorInvalidReportReason)
+	ErrReportDetailsRequired = errors.New(ErrorReportDetailsRequired)
+	ErrReportDetailsTooLong  = errors.New(ErrorReportDetailsTooLong)
+	ErrCannotReport          = errors.New(ErrorCannotReport)
+	ErrAlreadyReported       = errors.New(ErrorAlreadyReported)
+	ErrReportNotFound        = errors.New(ErrorReportNotFound)
+	ErrReportClosed          = errors.New(ErrorReportClosed)
+	ErrReportClaimed         = errors.New(ErrorReportClaimed)
+)
+
+// Report 代表一位用戶對訊息的檢舉
+type Report struct {
+	Reporter   string    `json:"reporter"`          // 檢舉者
+	Reason     string    `json:"reason"`            // 檢舉原因分類
+	Details    string    `json:"details,omitempty"` // 補充說明
+	ReportedAt time.Time `json:"reportedAt"`        // 檢舉時間
+}
+
+// ReportAction 代表檢舉處理紀錄中的一筆操作
+//
+// Design considerations:
+// - 自動隱藏由 System 執行，其餘操作記錄執行的管理員
+// - 只會附加，不會修改或刪除，作為管理決策的稽核紀錄
+type ReportAction struct {
+	Action string    `json:"action"`         // hidden、claimed、resolved 或 dismissed
+	Actor  string    `json:"actor"`          // 執行者
+	Note   string    `json:"note,omitempty"` // 管理員備註
+	At     time.Time `json:"at"`             // 操作時間
+}
+
+// ReportCase 代表一則訊息的所有檢舉與處理狀態
+type ReportCase struct {
+	Message   Message        `json:"message"`             // 被檢舉的訊息（第一次檢舉時的內容）
+	Reports   []Report       `json:"reports"`             // 所有檢舉，每位用戶一筆
+	Status    string         `json:"status"`              // open、claimed、resolved 或 dismissed
+	Hidden    bool           `json:"hidden"`              // 訊息目前是否因檢舉而隱藏
+	ClaimedBy string         `json:"claimedBy,omitempty"` // 認領的管理員
+	History   []ReportAction `json:"history"`             // 處理紀錄
+	CreatedAt time.Time      `json:"createdAt"`           // 第一次被檢舉的時間
+}
+
+// closed 檢查檢舉是否已處理完畢
+func (c *ReportCase) closed() bool {
+	return c.Status == ReportStatusResolved || c.Status == ReportStatusDismissed
+}
+
+// record 附加一筆處理紀錄
+func (c *ReportCase) record(action, actor, note string, at time.Time) {
+	c.History = append(c.History, ReportAction{Action: action, Actor: actor, Note: note, At: at})
+}
+
+// clone 返回不與佇列共用切片的複本
+func (c *ReportCase) clone() ReportCase {
+	dup := *c
+	dup.Reports = append([]Report(nil), c.Reports...)
+	dup.History = append([]ReportAction(nil), c.History...)
+	return dup
+}
+
+// hiddenMessage 保存因檢舉而隱藏的訊息，以及隱藏前的投票、釘選與提及狀態
+//
+// Design considerations:
+// - 隱藏時訊息會從歷史與搜尋中移除，相關狀態一併清除，駁回檢舉時依此完整恢復
+type hiddenMessage struct {
+	message  Message
+	poll     *pollState
+	pin      *Pin
+	mentions []string
+}
+
+// ReportQueue 用戶檢舉的審核佇列
+//
+// Responsible for:
+// - 收集用戶對訊息的檢舉，每則訊息一個案件
+// - 不同用戶的檢舉數達到門檻時決定自動隱藏
+// - 記錄管理員認領、確認與駁回的處理紀錄
+//
+// Design considerations:
+// - 同一用戶對同一訊息只能檢舉一次，門檻以不同檢舉者計算
+// - 只更新案件狀態，實際刪除或恢復訊息由呼叫者處理；隱藏的訊息與其狀態保存在佇列中直到結案
+// - 已認領的案件只有認領者可以處理
+// - 時鐘可注入，測試時可以固定時間
+//
+// Usage context:
+// - 全域 reportQueue 實例
+// - 檢舉 API 寫入，審核 API 讀取與處理
+type ReportQueue struct {
+	mu            sync.RWMutex
+	cases         map[string]*ReportCase
+	hidden        map[string]hiddenMessage
+	hideThreshold int
+	now           func() time.Time
+}
+
+// NewReportQueue 建立檢舉佇列
+//
+// Parameters:
+// - hideThreshold: 自動隱藏訊息所需的檢舉者數量，0 表示不自動隱藏
+//
+// Returns:
+// - *ReportQueue: 空的檢舉佇列
+func NewReportQueue(hideThreshold int) *ReportQueue {
+	return &ReportQueue{
+		cases:         make(map[string]*ReportCase),
+		hidden:        make(map[string]hiddenMessage),
+		hideThreshold: hideThreshold,
+		now:           time.Now,
+	}
+}
+
+// SetHideThreshold 修改自動隱藏訊息所需的檢舉者數量，只影響之後的檢舉
+func (q *ReportQueue) SetHideThreshold(threshold int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.hideThreshold = threshold
+}
+
+// validateReport 檢查檢舉原因與說明
+//
+// Parameters:
+// - reason: 檢舉原因分類
+// - details: 補充說明
+//
+// Returns:
+// - error: 原因不在 DefaultReportReasons 中、other 缺少說明或說明過長時返回錯誤
+func validateReport(reason, details string) error {
+	if !containsString(DefaultReportReasons, reason) {
+		return ErrInvalidReportReason
+	}
+	if reason == ReportReasonOther && details == "" {
+		return ErrReportDetailsRequired
+	}
+	if utf8.RuneCountInString(details) > DefaultReportDetailsMaxLength {
+		return ErrReportDetailsTooLong
+	}
+	return nil
+}
+
+// Report 記錄用戶對訊息的檢舉
+//
+// Process flow:
+// 1. 驗證原因並拒絕檢舉系統訊息或自己的訊息
+// 2. 找出或建立訊息的案件，已處理的案件不再接受檢舉
+// 3. 同一用戶重複檢舉時返回 ErrAlreadyReported
+// 4. 檢舉者數量達到門檻且訊息尚未隱藏時標記為隱藏
+//
+// Parameters:
+// - msg: 被檢舉的訊息
+// - reporter: 檢舉者用戶名
+// - reason: 檢舉原因分類
+// - details: 補充說明
+//
+// Returns:
+// - ReportCase: 更新後的案件
+// - bool: 這次檢舉是否觸發自動隱藏，呼叫者需要刪除訊息
+// - error: 驗證失敗、案件已處理或重複檢舉時返回錯誤
+func (q *ReportQueue) Report(msg Message, reporter, reason, details string) (ReportCase, bool, error) {
+	if err := validateReport(reason, details); err != nil {
+		return ReportCase{}, false, err
+	}
+	if msg.IsSystemMessage() || msg.User == reporter {
+		return ReportCase{}, false, ErrCannotReport
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now()
+	c, ok := q.cases[msg.ID]
+	if !ok {
+		c = &ReportCase{Message: msg, Status: ReportStatusOpen, History: []ReportAction{}, CreatedAt: now}
+		q.cases[msg.ID] = c
+	}
+	if c.closed() {
+		return ReportCase{}, false, ErrReportClosed
+	}
+	for _, report := range c.Reports {
+		if report.Reporter == reporter {
+			return ReportCase{}, false, ErrAlreadyReported
+		}
+	}
+
+	c.Reports = append(c.Reports, Report{Reporter: reporter, Reason: reason, Details: details, ReportedAt: now})
+	hide := !c.Hidden && q.hideThreshold > 0 && len(c.Reports) >= q.hideThreshold
+	if hide {
+		c.Hidden = true
+		c.record(ReportActionHidden, "System", "", now)
+	}
+	return c.clone(), hide, nil
+}
+
+// Get 依訊息 ID 取得案件
+//
+// Returns:
+// - ReportCase: 案件
+// - bool: 是否存在
+func (q *ReportQueue) Get(id string) (ReportCase, bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	c, ok := q.cases[id]
+	if !ok {
+		return ReportCase{}, false
+	}
+	return c.clone(), true
+}
+
+// List 列出指定頻道的案件
+//
+// Parameters:
+// - channels: 要列出的頻道
+// - status: 要列出的狀態，空字串表示全部
+//
+// Returns:
+// - []ReportCase: 依第一次檢舉時間由舊到新排序的案件
+func (q *ReportQueue) List(channels []string, status string) []ReportCase {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	cases := []ReportCase{}
+	for _, c := range q.cases {
+		if !containsString(channels, c.Message.Channel) {
+			continue
+		}
+		if status != "" && c.Status != status {
+			continue
+		}
+		cases = append(cases, c.clone())
+	}
+	sort.Slice(cases, func(i, j int) bool {
+		return cases[i].CreatedAt.Before(cases[j].CreatedAt)
+	})
+	return cases
+}
+
+// Claim 由管理員認領案件
+//
+// Design considerations:
+// - 認領者重複認領時不新增處理紀錄
+//
+// Parameters:
+// - id: 訊息 ID
+// - moderator: 管理員用戶名
+//
+// Returns:
+// - ReportCase: 更新後的案件
+// - error: 找不到、已處理或已被其他管理員認領時返回錯誤
+func (q *ReportQueue) Claim(id, moderator string) (ReportCase, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	c, err := q.openCase(id, moderator)
+	if err != nil {
+		return ReportCase{}, err
+	}
+	if c.ClaimedBy != moderator {
+		c.Status = ReportStatusClaimed
+		c.ClaimedBy = moderator
+		c.record(ReportActionClaimed, moderator, "", q.now())
+	}
+	return c.clone(), nil
+}
+
+// Resolve 確認檢舉成立並結案
+//
+// Parameters:
+// - id: 訊息 ID
+// - moderator: 管理員用戶名
+// - note: 管理員備註
+//
+// Returns:
+// - ReportCase: 更新後的案件，呼叫者需要刪除仍顯示中的訊息
+// - error: 找不到、已處理或已被其他管理員認領時返回錯誤
+func (q *ReportQueue) Resolve(id, moderator, note string) (ReportCase, error) {
+	return q.finish(id, moderator, note, ReportStatusResolved, ReportActionResolved)
+}
+
+// Dismiss 駁回檢舉並結案
+//
+// Parameters:
+// - id: 訊息 ID
+// - moderator: 管理員用戶名
+// - note: 管理員備註
+//
+// Returns:
+// - ReportCase: 更新後的案件，Hidden 為 true 時呼叫者需要恢復訊息
+// - error: 找不到、已處理或已被其他管理員認領時返回錯誤
+func (q *ReportQueue) Dismiss(id, moderator, note string) (ReportCase, error) {
+	return q.finish(id, moderator, note, ReportStatusDismissed, ReportActionDismissed)
+}
+
+// finish 以指定狀態結案
+func (q *ReportQueue) finish(id, moderator, note, status, action string) (ReportCase, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	c, err := q.openCase(id, moderator)
+	if err != nil {
+		return ReportCase{}, err
+	}
+	c.Status = status
+	c.ClaimedBy = moderator
+	c.record(action, moderator, note, q.now())
+	result := c.clone()
+	if status == ReportStatusDismissed {
+		c.Hidden = false
+	}
+	return result, nil
+}
+
+// keepHidden 保存隱藏的訊息與其狀態，供駁回檢舉時恢復
+func (q *ReportQueue) keepHidden(hidden hiddenMessage) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.hidden[hidden.message.ID] = hidden
+}
+
+// takeHidden 取出並移除保存的隱藏訊息
+//
+// Returns:
+// - hiddenMessage: 隱藏的訊息與其狀態
+// - bool: 是否有保存
+func (q *ReportQueue) takeHidden(id string) (hiddenMessage, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	hidden, ok := q.hidden[id]
+	delete(q.hidden, id)
+	return hidden, ok
+}
+
+// openCase 取得可以由管理員處理的案件，呼叫者需持有寫入鎖
+func (q *ReportQueue) openCase(id, moderator string) (*ReportCase, error) {
+	c, ok := q.cases[id]
+	if !ok {
+		return nil, ErrReportNotFound
+	}
+	if c.closed() {
+		return nil, ErrReportClosed
+	}
+	if c.ClaimedBy != "" && c.ClaimedBy != moderator {
+		return nil, ErrReportClaimed
+	}
+	return c, nil
+}
+
+// findReportableMessage 在帳號可讀取的頻道中查找訊息
+//
+// Parameters:
+// - account: 檢舉者
+// - id: 訊息 ID
+//
+// Returns:
+// - Message: 找到的訊息
+// - bool: 是否找到
+func findReportableMessage(account *Account, id string) (Message, bool) {
+	for _, channel := range readableChannels(account) {
+		if msg, ok := messageStore.FindMessage(channel, id); ok {
+			return msg, true
+		}
+	}
+	return Message{}, false
+}
+
+// submitReport 檢舉訊息，達到門檻時自動隱藏
+//
+// Parameters:
+// - account: 檢舉者
+// - msg: 被檢舉的訊息
+// - reason: 檢舉原因分類
+// - details: 補充說明
+//
+// Returns:
+// - ReportCase: 更新後的案件
+// - error: 檢舉被拒絕時返回錯誤
+func submitReport(account *Account, msg Message, reason, details string) (ReportCase, error) {
+	c, hide, err := reportQueue.Report(msg, account.Username, reason, strings.TrimSpace(details))
+	if err != nil {
+		return ReportCase{}, err
+	}
+	metrics.Inc(MetricMessagesReported)
+	log.Printf(LogMessageReported, account.Username, msg.ID, reason, len(c.Reports))
+
+	if hide {
+		hideReportedMessage(msg.Channel, msg.ID)
+		metrics.Inc(MetricMessagesHidden)
+		log.Printf(LogReportedMessageHidden, msg.ID, len(c.Reports))
+	}
+	return c, nil
+}
+
+// hideReportedMessage 隱藏達到檢舉門檻的訊息
+//
+// Design considerations:
+// - 與刪除相同，訊息從歷史、搜尋、提及收件匣、投票與釘選中移除並廣播 delete 事件
+// - 移除前保存投票票數、釘選與提及收件匣，駁回檢舉時由 restoreReportedMessage 恢復
+//
+// Parameters:
+// - channel: 頻道名稱
+// - id: 訊息 ID
+func hideReportedMessage(channel, id string) {
+	msg, ok := messageStore.RemoveMessage(channel, id)
+	if !ok {
+		return
+	}
+
+	hidden := hiddenMessage{
+		message:  msg,
+		poll:     pollStore.Detach(id),
+		mentions: mentionInbox.Remove(id),
+	}
+	for _, pin := range pinStore.List(channel) {
+		if pin.Message.ID == id {
+			hidden.pin = &pin
+			break
+		}
+	}
+	reportQueue.keepHidden(hidden)
+	broadcastEvents(cleanupRemovedMessage(msg, DeletionReasonReported))
+}
+
+// restoreReportedMessage 恢復因檢舉而隱藏的訊息
+//
+// Design considerations:
+// - 訊息依時間放回歷史與搜尋索引，投票票數、釘選與提及收件匣恢復為隱藏前的狀態
+// - 廣播 restore 事件而非原訊息，客戶端不會把它當作新訊息；釘選有恢復時一併廣播 pin 事件
+//
+// Parameters:
+// - id: 訊息 ID
+func restoreReportedMessage(id string) {
+	hidden, ok := reportQueue.takeHidden(id)
+	if !ok {
+		return
+	}
+
+	msg := hidden.message
+	messageStore.RestoreMessage(msg)
+	pollStore.Reattach(id, hidden.poll)
+	mentionInbox.Restore(hidden.mentions, msg)
+	events := []Message{NewRestoreMessage(msg)}
+	if hidden.pin != nil {
+		if pins, changed := pinStore.Restore(msg.Channel, *hidden.pin); changed {
+			event := PinEvent{Action: PinActionPin, MessageID: id, Username: "System", Pins: pins}
+			events = append(events, NewPinMessage(event, msg.Channel))
+		}
+	}
+	broadcastEvents(events)
+}
+
+// decideReport 由管理員認領、確認或駁回檢舉
+//
+// Design considerations:
+// - 確認時刪除仍顯示中的訊息，已隱藏的訊息維持刪除並捨棄保存的狀態
+// - 駁回時恢復被自動隱藏的訊息，連同投票、釘選與提及狀態
+//
+// Parameters:
+// - moderator: 執行者用戶名
+// - id: 訊息 ID
+// - action: claimed、resolved 或 dismissed
+// - note: 管理員備註
+//
+// Returns:
+// - ReportCase: 更新後的案件
+// - error: 案件無法處理時返回錯誤
+func decideReport(moderator, id, action, note string) (ReportCase, error) {
+	var c ReportCase
+	var err error
+	switch action {
+	case ReportActionClaimed:
+		c, err = reportQueue.Claim(id, moderator)
+	case ReportActionResolved:
+		c, err = reportQueue.Resolve(id, moderator, note)
+	case ReportActionDismissed:
+		c, err = reportQueue.Dismiss(id, moderator, note)
+	}
+	if err != nil {
+		return ReportCase{}, err
+	}
+	log.Printf(LogReportDecision, moderator, id, action)
+
+	switch {
+	case action == ReportActionResolved && !c.Hidden:
+		removeMessage(c.Message.Channel, id, DeletionReasonModerated)
+	case action == ReportActionResolved:
+		reportQueue.takeHidden(id)
+	case action == ReportActionDismissed && c.Hidden:
+		restoreReportedMessage(id)
+		c.Hidden = false
+	}
+	return c, nil
+}
+
+// reportStatusCode 將檢舉相關錯誤對應到 HTTP 狀態碼
+func reportStatusCode(err error) int {
+	switch {
+	case errors.Is(err, ErrReportNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrAlreadyReported), errors.Is(err, ErrReportClosed), errors.Is(err, ErrReportClaimed):
+		return http.StatusConflict
+	case errors.Is(err, ErrCannotReport):
+		return http.StatusForbidden
+	default:
+		return http.StatusBadRequest
+	}
+}
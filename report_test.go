@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestReportQueue 測試檢舉的驗證、自動隱藏與處理紀錄
+func TestReportQueue(t *testing.T) {
+	queue := NewReportQueue(2)
+	msg := NewMessage("bob", "買賣帳號請私訊", "general")
+
+	t.Run("驗證", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			reporter string
+			reason   string
+			details  string
+			want     error
+		}{
+			{"未知原因", "alice", "rude", "", ErrInvalidReportReason},
+			{"other 需要說明", "alice", ReportReasonOther, "", ErrReportDetailsRequired},
+			{"說明過長", "alice", ReportReasonSpam, strings.Repeat("長", DefaultReportDetailsMaxLength+1), ErrReportDetailsTooLong},
+			{"檢舉自己", "bob", ReportReasonSpam, "", ErrCannotReport},
+		}
+		for _, tt := range tests {
+			if _, _, err := queue.Report(msg, tt.reporter, tt.reason, tt.details); !errors.Is(err, tt.want) {
+				t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+			}
+		}
+	})
+
+	t.Run("達到門檻時隱藏", func(t *testing.T) {
+		if _, hide, err := queue.Report(msg, "alice", ReportReasonSpam, ""); err != nil || hide {
+			t.Fatalf("Expected first report to be accepted without hiding, got %v %v", hide, err)
+		}
+		if _, _, err := queue.Report(msg, "alice", ReportReasonSpam, ""); !errors.Is(err, ErrAlreadyReported) {
+			t.Errorf("Expected duplicate report to be rejected, got %v", err)
+		}
+		reported, hide, err := queue.Report(msg, "charlie", ReportReasonOther, "詐騙")
+		if err != nil || !hide || !reported.Hidden || len(reported.Reports) != 2 {
+			t.Errorf("Expected second distinct report to hide the message, got %+v %v %v", reported, hide, err)
+		}
+	})
+
+	t.Run("認領與駁回", func(t *testing.T) {
+		if _, err := queue.Claim(msg.ID, "alice"); err != nil {
+			t.Fatalf("Expected claim to succeed, got %v", err)
+		}
+		if _, err := queue.Dismiss(msg.ID, "dave", ""); !errors.Is(err, ErrReportClaimed) {
+			t.Errorf("Expected claimed report to be locked to alice, got %v", err)
+		}
+		reported, err := queue.Dismiss(msg.ID, "alice", "只是廣告")
+		if err != nil || reported.Status != ReportStatusDismissed || !reported.Hidden {
+			t.Fatalf("Expected dismissal to report the hidden message, got %+v %v", reported, err)
+		}
+
+		var actions []string
+		for _, action := range reported.History {
+			actions = append(actions, action.Action)
+		}
+		if strings.Join(actions, ",") != "hidden,claimed,dismissed" || reported.History[2].Note != "只是廣告" {
+			t.Errorf("Expected audit trail of decisions, got %+v", reported.History)
+		}
+		if _, _, err := queue.Report(msg, "dave", ReportReasonSpam, ""); !errors.Is(err, ErrReportClosed) {
+			t.Errorf("Expected closed report to reject new reports, got %v", err)
+		}
+	})
+}
+
+// TestReportAPI 測試檢舉 API 與審核流程
+func TestReportAPI(t *testing.T) {
+	hub = *newTestHub(0)
+	messageStore = make(MessageStore)
+	messageIndex = NewSearchIndex()
+	reportQueue = NewReportQueue(1)
+
+	request := func(method, path, username, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.SetBasicAuth(username, "password123")
+		rr := httptest.NewRecorder()
+		setupRoutes().ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := NewMessage("charlie", "第一則", "general")
+	first.Timestamp = time.Now().Add(-2 * time.Minute)
+	second := NewMessage("charlie", "第二則", "general")
+	second.Timestamp = time.Now().Add(-time.Minute)
+	messageStore.AddMessage(first)
+	messageStore.AddMessage(second)
+
+	t.Run("檢舉並自動隱藏", func(t *testing.T) {
+		if rr := request("POST", "/api/messages/"+first.ID+"/report", "bob", `{"reason":"spam"}`); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 for unreadable channel, got %d", rr.Code)
+		}
+		rr := request("POST", "/api/messages/"+first.ID+"/report", "alice", `{"reason":"spam"}`)
+		var response struct {
+			Hidden bool `json:"hidden"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		if rr.Code != http.StatusCreated || !response.Hidden {
+			t.Fatalf("Expected 201 with hidden message, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if _, ok := messageStore.FindMessage("general", first.ID); ok {
+			t.Error("Expected hidden message to be removed from history")
+		}
+	})
+
+	t.Run("非管理員不能查看佇列", func(t *testing.T) {
+		if rr := request("GET", "/api/moderation/reports", "bob", ""); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected 403, got %d", rr.Code)
+		}
+	})
+
+	t.Run("駁回時恢復訊息", func(t *testing.T) {
+		if rr := request("POST", "/api/moderation/reports/"+first.ID+"/dismiss", "alice", `{"note":"誤報"}`); rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		messages := messageStore.GetRecentMessages("general", 10)
+		if len(messages) != 2 || messages[0].ID != first.ID {
+			t.Errorf("Expected message to be restored in its original position, got %+v", messages)
+		}
+	})
+
+	t.Run("確認時刪除訊息", func(t *testing.T) {
+		reportQueue.hideThreshold = 0
+		request("POST", "/api/messages/"+second.ID+"/report", "alice", `{"reason":"harassment"}`)
+		if rr := request("POST", "/api/moderation/reports/"+second.ID+"/claim", "alice", ""); rr.Code != http.StatusOK {
+			t.Fatalf("Expected claim to succeed, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if rr := request("POST", "/api/moderation/reports/"+second.ID+"/resolve", "alice", ""); rr.Code != http.StatusOK {
+			t.Fatalf("Expected resolve to succeed, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if _, ok := messageStore.FindMessage("general", second.ID); ok {
+			t.Error("Expected resolved message to be removed")
+		}
+		if rr := request("POST", "/api/moderation/reports/"+second.ID+"/dismiss", "alice", ""); rr.Code != http.StatusConflict {
+			t.Errorf("Expected 409 for closed report, got %d", rr.Code)
+		}
+
+		rr := request("GET", "/api/moderation/reports?status=all", "alice", "")
+		var response struct {
+			Reports []ReportCase `json:"reports"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		if len(response.Reports) != 2 || response.Reports[1].Status != ReportStatusResolved {
+			t.Errorf("Expected both reports in the queue, got %+v", response.Reports)
+		}
+	})
+}
+
+// TestDismissReportRestoresPoll 測試駁回檢舉時恢復投票、釘選與提及狀態
+func TestDismissReportRestoresPoll(t *testing.T) {
+	hub = *newTestHub(0)
+	messageStore = make(MessageStore)
+	messageIndex = NewSearchIndex()
+	reportQueue = NewReportQueue(1)
+	pollStore = NewPollStore()
+	pinStore = NewPinStore(DefaultPinLimit)
+	mentionInbox = NewMentionInbox(DefaultMentionInboxLimit)
+	defer func() { reportQueue = NewReportQueue(DefaultReportHideThreshold) }()
+
+	request := func(path, username, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", path, strings.NewReader(body))
+		req.SetBasicAuth(username, "password123")
+		rr := httptest.NewRecorder()
+		setupRoutes().ServeHTTP(rr, req)
+		return rr
+	}
+
+	msg := NewMessage("charlie", "@alice 午餐吃什麼？", "general")
+	msg.Type = MessageTypePoll
+	msg.Poll = &Poll{Question: "午餐吃什麼？", Options: []PollOption{{Text: "麵"}, {Text: "飯"}}}
+	if err := pollStore.Create(&msg); err != nil {
+		t.Fatalf("Failed to create poll: %v", err)
+	}
+	messageStore.AddMessage(msg)
+	mentionInbox.Add("alice", msg)
+	alice, _ := findAccount("alice")
+	if _, _, err := pinStore.Pin(&alice, "general", msg.ID); err != nil {
+		t.Fatalf("Failed to pin poll: %v", err)
+	}
+	if _, err := castVote("alice", []string{"general"}, &PollVote{PollID: msg.ID, Options: []int{1}}); err != nil {
+		t.Fatalf("Failed to vote: %v", err)
+	}
+
+	if rr := request("/api/messages/"+msg.ID+"/report", "alice", `{"reason":"spam"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if _, _, _, err := pollStore.Get(msg.ID, "alice"); err == nil {
+		t.Error("Expected hidden poll to stop accepting votes")
+	}
+	if len(pinStore.List("general")) != 0 || len(mentionInbox.List("alice", 10)) != 0 {
+		t.Error("Expected hidden message to be unpinned and removed from mentions")
+	}
+
+	client := newTestClient("bob", "general")
+	hub.register <- client
+	collectMessages(client, 50*time.Millisecond)
+
+	if rr := request("/api/moderation/reports/"+msg.ID+"/dismiss", "alice", ""); rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	poll, _, choice, err := pollStore.Get(msg.ID, "alice")
+	if err != nil || poll.Options[1].Votes != 1 || len(choice) != 1 || choice[0] != 1 {
+		t.Errorf("Expected poll votes to be restored, got %+v %v %v", poll, choice, err)
+	}
+	if restored, ok := messageStore.FindMessage("general", msg.ID); !ok || restored.Poll == nil || restored.Poll.Options[1].Votes != 1 {
+		t.Errorf("Expected poll message with votes in history, got %+v", restored)
+	}
+	if pins := pinStore.List("general"); len(pins) != 1 || pins[0].Message.ID != msg.ID || pins[0].PinnedBy != "alice" {
+		t.Errorf("Expected pin to be restored, got %+v", pins)
+	}
+	if mentions := mentionInbox.List("alice", 10); len(mentions) != 1 || mentions[0].ID != msg.ID {
+		t.Errorf("Expected mention to be restored, got %+v", mentions)
+	}
+
+	var restoreEvents int
+	for _, event := range collectMessages(client, 100*time.Millisecond) {
+		if event.ID == msg.ID {
+			t.Errorf("Expected restore event instead of the original message, got %+v", event)
+		}
+		if event.Type == MessageTypeRestore && event.Restored != nil && event.Restored.ID == msg.ID {
+			restoreEvents++
+		}
+	}
+	if restoreEvents != 1 {
+		t.Errorf("Expected one restore event, got %d", restoreEvents)
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 角色管理相關錯誤
+var (
+	ErrInvalidRole       = errors.New(ErrorInvalidRole)
+	ErrRoleForbidden     = errors.New(ErrorRoleForbidden)
+	ErrRoleNotFound      = errors.New(ErrorRoleNotFound)
+	ErrInvalidRoleTarget = errors.New(ErrorInvalidRoleTarget)
+	ErrSendForbidden     = errors.New(ErrorSendForbidden)
+)
+
+// RoleGrant 代表授予用戶的一個角色
+type RoleGrant struct {
+	Channel   string    `json:"channel,omitempty"`   // 頻道名稱，空字串表示伺服器範圍
+	Username  string    `json:"username"`            // 用戶名稱
+	Role      string    `json:"role"`                // 角色名稱
+	GrantedBy string    `json:"grantedBy,omitempty"` // 授予者，啟動時的預設角色為空
+	GrantedAt time.Time `json:"grantedAt"`           // 授予時間
+}
+
+// RoleStore 管理伺服器與頻道範圍的角色
+//
+// Responsible for:
+// - 記錄用戶在整個伺服器或個別頻道被授予的角色
+// - 提供 effectiveRole 計算有效角色所需的查詢
+//
+// Design considerations:
+// - 伺服器範圍以 RoleScopeServer 為頻道鍵，與頻道角色共用同一個結構
+// - 每個範圍每位用戶只有一個角色，重複授予以最後一次為準
+// - 帳號所屬頻道的 member 角色不寫入，由 effectiveRole 推導
+//
+// Usage context:
+// - 全域 roleStore 實例，啟動時以設定的 roles 初始化（預設為 DefaultRoleGrants）
+// - 角色 API 寫入，authorize 查詢
+type RoleStore struct {
+	mu     sync.RWMutex
+	grants map[string]map[string]RoleGrant
+	now    func() time.Time
+}
+
+// NewRoleStore 建立角色名單
+//
+// Parameters:
+// - seeds: 初始角色
+//
+// Returns:
+// - *RoleStore: 包含初始角色的名單
+func NewRoleStore(seeds []RoleGrant) *RoleStore {
+	store := &RoleStore{
+		grants: make(map[string]map[string]RoleGrant),
+		now:    time.Now,
+	}
+	for _, grant := range seeds {
+		store.Grant(grant)
+	}
+	return store
+}
+
+// Grant 新增或取代角色
+//
+// Parameters:
+// - grant: 角色紀錄，GrantedAt 由此設置
+//
+// Returns:
+// - RoleGrant: 寫入的角色紀錄
+func (rs *RoleStore) Grant(grant RoleGrant) RoleGrant {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	grant.GrantedAt = rs.now()
+	if rs.grants[grant.Channel] == nil {
+		rs.grants[grant.Channel] = make(map[string]RoleGrant)
+	}
+	rs.grants[grant.Channel][grant.Username] = grant
+	return grant
+}
+
+// Revoke 撤銷用戶在指定範圍的角色
+//
+// Returns:
+// - RoleGrant: 被撤銷的角色紀錄
+// - bool: 用戶原本是否有角色
+func (rs *RoleStore) Revoke(channel, username string) (RoleGrant, bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	grant, ok := rs.grants[channel][username]
+	if ok {
+		delete(rs.grants[channel], username)
+		if len(rs.grants[channel]) == 0 {
+			delete(rs.grants, channel)
+		}
+	}
+	return grant, ok
+}
+
+// Role 返回用戶在指定範圍被授予的角色，沒有時返回空字串
+func (rs *RoleStore) Role(channel, username string) string {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	return rs.grants[channel][username].Role
+}
+
+// List 返回指定範圍的所有角色
+//
+// Design considerations:
+// - 依角色由高到低排列，同一角色依用戶名稱排列
+func (rs *RoleStore) List(channel string) []RoleGrant {
+	rs.mu.RLock()
+	grants := make([]RoleGrant, 0, len(rs.grants[channel]))
+	for _, grant := range rs.grants[channel] {
+		grants = append(grants, grant)
+	}
+	rs.mu.RUnlock()
+
+	sort.Slice(grants, func(i, j int) bool {
+		if ri, rj := roleRank(grants[i].Role), roleRank(grants[j].Role); ri != rj {
+			return ri > rj
+		}
+		return grants[i].Username < grants[j].Username
+	})
+	return grants
+}
+
+// Channels 返回有角色紀錄的頻道，不包含伺服器範圍
+func (rs *RoleStore) Channels() []string {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	channels := make([]string, 0, len(rs.grants))
+	for channel := range rs.grants {
+		if channel != RoleScopeServer {
+			channels = append(channels, channel)
+		}
+	}
+	return channels
+}
+
+// roleRank 返回角色的等級，未知角色或沒有角色時為 0
+func roleRank(role string) int {
+	for i, r := range DefaultRoles {
+		if r == role {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// effectiveRole 計算帳號在指定範圍的有效角色
+//
+// Design considerations:
+// - 伺服器範圍只看伺服器角色
+// - 頻道範圍取伺服器角色與頻道角色中較高者
+// - 沒有頻道角色時，帳號在所屬頻道視為 member；明確授予的頻道角色可以降為 guest
+//
+// Parameters:
+// - account: 已驗證的帳號
+// - channel: 頻道名稱，RoleScopeServer 表示伺服器範圍
+//
+// Returns:
+// - string: 有效角色，沒有任何角色時為空字串
+func effectiveRole(account *Account, channel string) string {
+	role := roleStore.Role(RoleScopeServer, account.Username)
+	if channel == RoleScopeServer {
+		return role
+	}
+
+	channelRole := roleStore.Role(channel, account.Username)
+	if channelRole == "" && channel == account.Channel {
+		channelRole = RoleMember
+	}
+	if roleRank(channelRole) > roleRank(role) {
+		role = channelRole
+	}
+	return role
+}
+
+// authorize 檢查帳號在指定範圍是否具有權限
+//
+// Responsible for:
+// - 所有 API、WebSocket 與指令的權限檢查入口
+// - 以 effectiveRole 取得角色後查詢 DefaultRolePermissions 權限矩陣
+//
+// Parameters:
+// - account: 已驗證的帳號
+// - permission: Permission* 常數
+// - channel: 頻道名稱，RoleScopeServer 表示伺服器範圍
+//
+// Returns:
+// - bool: 是否具有權限
+func authorize(account *Account, permission, channel string) bool {
+	return containsString(DefaultRolePermissions[effectiveRole(account, channel)], permission)
+}
+
+// readableChannels 返回帳號可以讀取的頻道
+//
+// Design considerations:
+// - 候選頻道來自 knownChannels
+// - 所屬頻道排在最前面，其餘依名稱排列
+// - 搜尋等跨頻道查詢以此限制結果範圍
+//
+// Parameters:
+//
+//	account: 已驗證的帳號
+//
+// Returns:
+//
+//	[]string: 可讀取的頻道列表
+func readableChannels(account *Account) []string {
+	var channels []string
+	for _, channel := range knownChannels() {
+		if channel != account.Channel && authorize(account, PermissionReadChannel, channel) {
+			channels = append(channels, channel)
+		}
+	}
+	if authorize(account, PermissionReadChannel, account.Channel) {
+		channels = append([]string{account.Channel}, channels...)
+	}
+	return channels
+}
+
+// knownChannels 返回伺服器已知的所有頻道
+//
+// Design considerations:
+// - 包含帳號所屬頻道、有角色紀錄的頻道與已有訊息的頻道
+// - 依名稱排列
+//
+// Returns:
+// - []string: 頻道列表
+func knownChannels() []string {
+	candidates := make(map[string]bool)
+	for _, account := range getTestAccounts() {
+		candidates[account.Channel] = true
+	}
+	for _, channel := range roleStore.Channels() {
+		candidates[channel] = true
+	}
+	messageStoreMu.RLock()
+	for channel := range messageStore {
+		candidates[channel] = true
+	}
+	messageStoreMu.RUnlock()
+
+	channels := make([]string, 0, len(candidates))
+	for channel := range candidates {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// validRole 檢查角色名稱是否存在
+func validRole(role string) bool {
+	return containsString(DefaultRoles, role)
+}
+
+// roleTarget 檢查角色管理權限並找出目標用戶
+//
+// Design considerations:
+// - 需要該範圍的 PermissionManageRoles 權限
+// - 只能授予或撤銷低於自己的角色，owner 可以管理其他 owner
+// - 不能修改自己的角色，避免唯一的管理者把自己降級
+//
+// Parameters:
+// - actor: 執行者
+// - channel: 頻道名稱，RoleScopeServer 表示伺服器範圍
+// - username: 目標用戶，可帶 @ 前綴
+// - role: 要授予的角色，撤銷時為空字串
+//
+// Returns:
+// - Account: 目標帳號
+// - error: 無權限、用戶不存在或修改自己時返回對應錯誤
+func roleTarget(actor *Account, channel, username, role string) (Account, error) {
+	if !authorize(actor, PermissionManageRoles, channel) {
+		return Account{}, ErrRoleForbidden
+	}
+	target, ok := findAccount(strings.TrimPrefix(username, "@"))
+	if !ok {
+		return Account{}, ErrUserNotFound
+	}
+	if target.Username == actor.Username {
+		return Account{}, ErrInvalidRoleTarget
+	}
+
+	actorRole := effectiveRole(actor, channel)
+	limit := roleRank(actorRole)
+	if actorRole == RoleOwner {
+		limit++
+	}
+	if roleRank(role) >= limit || roleRank(roleStore.Role(channel, target.Username)) >= limit {
+		return Account{}, ErrRoleForbidden
+	}
+	return target, nil
+}
+
+// grantUserRole 授予用戶角色
+//
+// Parameters:
+// - actor: 執行者
+// - channel: 頻道名稱，RoleScopeServer 表示伺服器範圍
+// - username: 目標用戶
+// - role: 角色名稱
+//
+// Returns:
+// - RoleGrant: 寫入的角色紀錄
+// - error: 角色不存在、無權限或用戶不存在時返回對應錯誤
+func grantUserRole(actor *Account, channel, username, role string) (RoleGrant, error) {
+	if !validRole(role) {
+		return RoleGrant{}, ErrInvalidRole
+	}
+	target, err := roleTarget(actor, channel, username, role)
+	if err != nil {
+		return RoleGrant{}, err
+	}
+
+	grant := roleStore.Grant(RoleGrant{
+		Channel:   channel,
+		Username:  target.Username,
+		Role:      role,
+		GrantedBy: actor.Username,
+	})
+	log.Printf(LogRoleGranted, target.Username, actor.Username, role, channel)
+	return grant, nil
+}
+
+// revokeUserRole 撤銷用戶在指定範圍的角色
+//
+// Returns:
+// - error: 無權限、用戶不存在或用戶沒有角色時返回對應錯誤
+func revokeUserRole(actor *Account, channel, username string) error {
+	target, err := roleTarget(actor, channel, username, "")
+	if err != nil {
+		return err
+	}
+	grant, ok := roleStore.Revoke(channel, target.Username)
+	if !ok {
+		return ErrRoleNotFound
+	}
+	log.Printf(LogRoleRevoked, target.Username, actor.Username, grant.Role, channel)
+	return nil
+}
+
+// roleStatusCode 將角色管理錯誤轉換為 HTTP 狀態碼
+func roleStatusCode(err error) int {
+	switch {
+	case errors.Is(err, ErrRoleForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, ErrUserNotFound), errors.Is(err, ErrRoleNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestAuthorize 測試有效角色的計算與權限矩陣
+func TestAuthorize(t *testing.T) {
+	roleStore = NewRoleStore([]RoleGrant{
+		{Channel: "general", Username: "alice", Role: RoleOwner},
+		{Channel: "tech", Username: "bob", Role: RoleGuest},
+		{Channel: "tech", Username: "charlie", Role: RoleModerator},
+	})
+	defer func() { roleStore = NewRoleStore(DefaultRoleGrants) }()
+
+	alice, _ := findAccount("alice")
+	bob, _ := findAccount("bob")
+	charlie, _ := findAccount("charlie")
+
+	tests := []struct {
+		name       string
+		account    Account
+		permission string
+		channel    string
+		want       bool
+	}{
+		{"所屬頻道預設為 member", charlie, PermissionSendMessage, "random", true},
+		{"member 不能管理", charlie, PermissionModerate, "random", false},
+		{"沒有角色的頻道不能讀取", alice, PermissionReadChannel, "tech", false},
+		{"頻道角色可以降為 guest", bob, PermissionSendMessage, "tech", false},
+		{"guest 可以讀取", bob, PermissionReadChannel, "tech", true},
+		{"頻道 moderator 可以處置", charlie, PermissionSanction, "tech", true},
+		{"moderator 不能管理角色", charlie, PermissionManageRoles, "tech", false},
+		{"頻道 owner 可以管理角色", alice, PermissionManageRoles, "general", true},
+		{"頻道角色不適用於伺服器範圍", alice, PermissionSanction, RoleScopeServer, false},
+	}
+	for _, tt := range tests {
+		if got := authorize(&tt.account, tt.permission, tt.channel); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+
+	t.Run("伺服器角色在所有頻道生效", func(t *testing.T) {
+		roleStore.Grant(RoleGrant{Username: "alice", Role: RoleAdmin})
+		if !authorize(&alice, PermissionModerate, "tech") || !authorize(&alice, PermissionManageRoles, RoleScopeServer) {
+			t.Error("Expected server admin to moderate every channel")
+		}
+		if role := effectiveRole(&alice, "general"); role != RoleOwner {
+			t.Errorf("Expected the higher channel role to win, got %q", role)
+		}
+		if channels := readableChannels(&alice); len(channels) != 3 || channels[0] != "general" {
+			t.Errorf("Expected own channel first followed by every channel, got %v", channels)
+		}
+	})
+}
+
+// TestRoleAPI 測試角色的授予與撤銷
+func TestRoleAPI(t *testing.T) {
+	roleStore = NewRoleStore(DefaultRoleGrants)
+	defer func() { roleStore = NewRoleStore(DefaultRoleGrants) }()
+
+	request := func(method, path, username, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.SetBasicAuth(username, "password123")
+		rr := httptest.NewRecorder()
+		setupRoutes().ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("授予角色", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			username string
+			path     string
+			body     string
+			want     int
+		}{
+			{"沒有管理角色", "bob", "/api/channels/general/roles/charlie", `{"role":"member"}`, http.StatusForbidden},
+			{"未知角色", "alice", "/api/channels/general/roles/bob", `{"role":"king"}`, http.StatusBadRequest},
+			{"修改自己", "alice", "/api/channels/general/roles/alice", `{"role":"guest"}`, http.StatusBadRequest},
+			{"用戶不存在", "alice", "/api/channels/general/roles/dave", `{"role":"member"}`, http.StatusNotFound},
+			{"伺服器範圍需要伺服器角色", "alice", "/api/roles/bob", `{"role":"member"}`, http.StatusForbidden},
+			{"授予 admin", "alice", "/api/channels/general/roles/bob", `{"role":"admin"}`, http.StatusOK},
+			{"admin 只能授予較低的角色", "bob", "/api/channels/general/roles/charlie", `{"role":"admin"}`, http.StatusForbidden},
+			{"admin 授予 moderator", "bob", "/api/channels/general/roles/charlie", `{"role":"moderator"}`, http.StatusOK},
+		}
+		for _, tt := range tests {
+			if rr := request("PUT", tt.path, tt.username, tt.body); rr.Code != tt.want {
+				t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.want, rr.Code, rr.Body.String())
+			}
+		}
+
+		charlie, _ := findAccount("charlie")
+		if !authorize(&charlie, PermissionSanction, "general") {
+			t.Error("Expected charlie to moderate general after the grant")
+		}
+	})
+
+	t.Run("列出角色", func(t *testing.T) {
+		rr := request("GET", "/api/channels/general/roles", "charlie", "")
+		var response struct {
+			Roles []RoleGrant `json:"roles"`
+			Role  string      `json:"role"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		if rr.Code != http.StatusOK || len(response.Roles) != 3 || response.Roles[0].Username != "alice" || response.Role != RoleModerator {
+			t.Errorf("Expected roles ordered by rank, got %d %+v", rr.Code, response)
+		}
+		if rr := request("GET", "/api/channels/tech/roles", "charlie", ""); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected 403 for unreadable channel, got %d", rr.Code)
+		}
+	})
+
+	t.Run("撤銷角色", func(t *testing.T) {
+		if rr := request("DELETE", "/api/channels/general/roles/bob", "charlie", ""); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected moderator to be unable to revoke, got %d", rr.Code)
+		}
+		if rr := request("DELETE", "/api/channels/general/roles/alice", "bob", ""); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected admin to be unable to revoke the owner, got %d", rr.Code)
+		}
+		if rr := request("DELETE", "/api/channels/general/roles/bob", "alice", ""); rr.Code != http.StatusNoContent {
+			t.Fatalf("Expected 204, got %d", rr.Code)
+		}
+		if rr := request("DELETE", "/api/channels/general/roles/bob", "alice", ""); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 for missing role, got %d", rr.Code)
+		}
+		bob, _ := findAccount("bob")
+		if authorize(&bob, PermissionReadChannel, "general") {
+			t.Error("Expected bob to lose access to general")
+		}
+	})
+}
@@ -15,7 +15,7 @@ import (
 //
 // Design considerations:
 // - 使用 Gorilla Mux 路由器支援更靈活的路由配置
-// - REST API 端點支援 CORS 所需的 OPTIONS 方法
+// - CORS 標頭與所有路由的 OPTIONS 預檢由外層的 corsMiddleware 統一處理，路由只註冊實際的方法
 // - 靜態檔案服務使用 PathPrefix 處理所有未匹配的路徑
 //
 // Process flow:
@@ -23,7 +23,7 @@ import (
 // 2. 註冊所有 REST API 端點及其處理函式
 // 3. 註冊 WebSocket 端點
 // 4. 設置靜態檔案服務作為後備處理
-// 5. 返回配置完成的路由器
+// 5. 以 corsMiddleware 包裝後返回
 //
 // Usage context:
 // - 主程式啟動時調用以設置路由
@@ -31,16 +31,72 @@ import (
 //
 // Returns:
 //
-//	*mux.Router: 配置完成的路由器實例
-func setupRoutes() *mux.Router {
+//	http.Handler: 配置完成並加上 CORS 處理的路由器
+func setupRoutes() http.Handler {
 	r := mux.NewRouter()
 
 	// REST API 路由
 	r.HandleFunc("/api/messages", getMessages).Methods("GET")
-	r.HandleFunc("/api/messages", sendMessage).Methods("POST", "OPTIONS")
+	r.HandleFunc("/api/messages", sendMessage).Methods("POST")
+	r.HandleFunc("/api/messages/{id}/report", reportMessage).Methods("POST")
 	r.HandleFunc("/api/users", getOnlineUsers).Methods("GET")
+	r.HandleFunc("/api/presence", getPresence).Methods("GET")
+	r.HandleFunc("/api/channels/{channel}/presence", getChannelPresence).Methods("GET")
+	r.HandleFunc("/api/channels/{channel}/settings", getChannelSettings).Methods("GET")
+	r.HandleFunc("/api/channels/{channel}/settings", updateChannelSettings).Methods("PUT")
+	r.HandleFunc("/api/channels/{channel}/pins", getChannelPins).Methods("GET")
+	r.HandleFunc("/api/channels/{channel}/pins/{id}", pinMessage).Methods("PUT")
+	r.HandleFunc("/api/channels/{channel}/pins/{id}", unpinMessage).Methods("DELETE")
+	r.HandleFunc("/api/search", searchMessages).Methods("GET")
+	r.HandleFunc("/api/mentions", getMentions).Methods("GET")
+	r.HandleFunc("/api/polls/{id}", getPoll).Methods("GET")
+	r.HandleFunc("/api/polls/{id}/votes", votePoll).Methods("POST")
+	r.HandleFunc("/api/polls/{id}/close", closePoll).Methods("POST")
+	r.HandleFunc("/api/scheduled", createScheduledMessage).Methods("POST")
+	r.HandleFunc("/api/scheduled", listScheduledMessages).Methods("GET")
+	r.HandleFunc("/api/scheduled/{id}", updateScheduledMessage).Methods("PUT")
+	r.HandleFunc("/api/scheduled/{id}", cancelScheduledMessage).Methods("DELETE")
+	r.HandleFunc("/api/moderation/flags", getFlaggedMessages).Methods("GET")
+	r.HandleFunc("/api/moderation/flags/{id}", reviewFlaggedMessage).Methods("POST")
+	r.HandleFunc("/api/moderation/reports", getReports).Methods("GET")
+	r.HandleFunc("/api/moderation/reports/{id}", getReport).Methods("GET")
+	r.HandleFunc("/api/moderation/reports/{id}/{action:claim|resolve|dismiss}", reviewReport).Methods("POST")
+	r.HandleFunc("/api/channels/{channel}/mutes", muteChannelUser).Methods("POST")
+	r.HandleFunc("/api/channels/{channel}/mutes/{username}", unmuteChannelUser).Methods("DELETE")
+	r.HandleFunc("/api/channels/{channel}/kicks", kickChannelUser).Methods("POST")
+	r.HandleFunc("/api/channels/{channel}/bans", getBans).Methods("GET")
+	r.HandleFunc("/api/channels/{channel}/bans", createBan).Methods("POST")
+	r.HandleFunc("/api/channels/{channel}/bans/{username}", deleteBan).Methods("DELETE")
+	r.HandleFunc("/api/bans", getBans).Methods("GET")
+	r.HandleFunc("/api/bans", createBan).Methods("POST")
+	r.HandleFunc("/api/bans/{username}", deleteBan).Methods("DELETE")
+	r.HandleFunc("/api/channels/{channel}/roles", getRoles).Methods("GET")
+	r.HandleFunc("/api/channels/{channel}/roles/{username}", updateRole).Methods("PUT")
+	r.HandleFunc("/api/channels/{channel}/roles/{username}", deleteRole).Methods("DELETE")
+	r.HandleFunc("/api/roles", getRoles).Methods("GET")
+	r.HandleFunc("/api/roles/{username}", updateRole).Methods("PUT")
+	r.HandleFunc("/api/roles/{username}", deleteRole).Methods("DELETE")
+	r.HandleFunc("/api/metrics", getMetrics).Methods("GET")
+	r.HandleFunc("/api/admin/connections", getAdminConnections).Methods("GET")
+	r.HandleFunc("/api/admin/connections/{id}", deleteAdminConnection).Methods("DELETE")
+	r.HandleFunc("/api/admin/channels/{channel}/clear", clearAdminChannel).Methods("POST")
+	r.HandleFunc("/api/admin/broadcast", postAdminBroadcast).Methods("POST")
+	r.HandleFunc("/api/admin/config", getAdminConfig).Methods("GET")
+	r.HandleFunc("/api/admin/config", patchAdminConfig).Methods("PATCH")
+	r.HandleFunc("/api/admin/config/version", getAdminConfigVersion).Methods("GET")
+	r.HandleFunc("/api/admin/audit", getAdminAudit).Methods("GET")
+	r.HandleFunc("/api/admin/audit/export", exportAdminAudit).Methods("GET")
+	r.HandleFunc("/api/admin/audit/verify", verifyAdminAudit).Methods("GET")
 	r.HandleFunc("/api/accounts", getAccounts).Methods("GET")
-	r.HandleFunc("/api/login", loginAccount).Methods("POST", "OPTIONS")
+	r.HandleFunc("/api/login", loginAccount).Methods("POST")
+	r.HandleFunc("/api/uploads", uploadFile).Methods("POST")
+	r.HandleFunc("/api/uploads/sessions", createUploadSession).Methods("POST")
+	r.HandleFunc("/api/uploads/sessions/{id}", getUploadSession).Methods("GET", "HEAD")
+	r.HandleFunc("/api/uploads/sessions/{id}", uploadChunk).Methods("PUT")
+	r.HandleFunc("/api/uploads/sessions/{id}", cancelUploadSession).Methods("DELETE")
+	r.HandleFunc("/api/uploads/sessions/{id}/complete", completeUploadSession).Methods("POST")
+	r.HandleFunc("/api/uploads/{id}", downloadUpload).Methods("GET")
+	r.HandleFunc("/api/uploads/{id}/thumbnails/{size:[0-9]+}", downloadThumbnail).Methods("GET")
 
 	// WebSocket 路由
 	r.HandleFunc("/ws", handleWebSocket)
@@ -48,5 +104,5 @@ func setupRoutes() *mux.Router {
 	// 靜態文件服務（可選，用於測試前端）
 	r.PathPrefix("/").Handler(http.FileServer(http.Dir("./static/")))
 
-	return r
+	return corsMiddleware(r)
 }
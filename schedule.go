@@ -0,0 +1,325 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 排程訊息相關錯誤
+var (
+	ErrScheduleNotFound        = errors.New(ErrorScheduleNotFound)
+	ErrScheduleContentRequired = errors.New(ErrorScheduleContentRequired)
+	ErrScheduleSendAtInPast    = errors.New(ErrorScheduleSendAtInPast)
+)
+
+// ScheduledMessage 代表一則等待發送的排程訊息
+//
+// Design considerations:
+// - 只保存作者、頻道與內容，ID 與時間戳在實際發送時才產生
+// - @提及在發送時才解析與通知
+type ScheduledMessage struct {
+	ID        string    `json:"id"`        // 排程 ID
+	Author    string    `json:"author"`    // 作者用戶名
+	Channel   string    `json:"channel"`   // 發送頻道
+	Content   string    `json:"content"`   // 訊息內容
+	SendAt    time.Time `json:"sendAt"`    // 預定發送時間
+	CreatedAt time.Time `json:"createdAt"` // 建立時間
+	UpdatedAt time.Time `json:"updatedAt"` // 最後修改時間
+}
+
+// ScheduledMessageUpdate 代表排程訊息的部分更新
+type ScheduledMessageUpdate struct {
+	Content *string    `json:"content"`
+	SendAt  *time.Time `json:"sendAt"`
+}
+
+// Scheduler 管理排程訊息並在預定時間發送
+//
+// Responsible for:
+// - 建立、列出、修改、取消排程訊息
+// - 將排程寫入磁碟，伺服器重新啟動後繼續發送
+// - 定期檢查並發送到期的訊息
+//
+// Design considerations:
+// - 每則排程一個 .json 檔案，與分段上傳的中繼資料相同做法
+// - 到期訊息透過 publishMessage 發送，與一般訊息走相同的儲存與廣播流程
+// - 伺服器停機期間到期的訊息在啟動後第一次檢查時補發
+// - 只有作者可以存取自己的排程，其他人視為不存在
+// - 時鐘可注入，測試時不需要實際等待
+//
+// Usage context:
+// - 全域 scheduler 實例供排程 API 使用
+// - 程式啟動時啟動檢查迴圈
+type Scheduler struct {
+	mu   sync.Mutex
+	dir  string
+	jobs map[string]*ScheduledMessage
+	now  func() time.Time
+}
+
+// NewScheduler 建立新的排程器
+//
+// Parameters:
+// - dir: 排程檔案存放目錄
+//
+// Returns:
+// - *Scheduler: 初始化完成的排程器
+func NewScheduler(dir string) *Scheduler {
+	return &Scheduler{
+		dir:  dir,
+		jobs: make(map[string]*ScheduledMessage),
+		now:  time.Now,
+	}
+}
+
+// Load 從磁碟恢復尚未發送的排程
+//
+// Returns:
+// - error: 讀取目錄失敗時的錯誤，目錄不存在時不視為錯誤
+func (s *Scheduler) Load() error {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			log.Printf(LogScheduleLoadError, entry.Name(), err)
+			continue
+		}
+
+		var job ScheduledMessage
+		if err := json.Unmarshal(data, &job); err != nil {
+			log.Printf(LogScheduleLoadError, entry.Name(), err)
+			continue
+		}
+		s.jobs[job.ID] = &job
+	}
+	return nil
+}
+
+// Schedule 建立新的排程訊息
+//
+// Parameters:
+// - author: 作者用戶名
+// - channel: 發送頻道
+// - content: 訊息內容
+// - sendAt: 預定發送時間，必須晚於目前時間
+//
+// Returns:
+// - ScheduledMessage: 新建立的排程
+// - error: 內容為空、時間已過或寫入失敗時的錯誤
+func (s *Scheduler) Schedule(author, channel, content string, sendAt time.Time) (ScheduledMessage, error) {
+	if strings.TrimSpace(content) == "" {
+		return ScheduledMessage{}, ErrScheduleContentRequired
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if !sendAt.After(now) {
+		return ScheduledMessage{}, ErrScheduleSendAtInPast
+	}
+
+	job := &ScheduledMessage{
+		ID:        generateUploadID(),
+		Author:    author,
+		Channel:   channel,
+		Content:   content,
+		SendAt:    sendAt,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.persistLocked(job); err != nil {
+		return ScheduledMessage{}, err
+	}
+	s.jobs[job.ID] = job
+	return *job, nil
+}
+
+// List 列出作者尚未發送的排程
+//
+// Parameters:
+// - author: 作者用戶名
+//
+// Returns:
+// - []ScheduledMessage: 依預定時間排序的排程
+func (s *Scheduler) List(author string) []ScheduledMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := []ScheduledMessage{}
+	for _, job := range s.jobs {
+		if job.Author == author {
+			jobs = append(jobs, *job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].SendAt.Before(jobs[j].SendAt)
+	})
+	return jobs
+}
+
+// Update 修改排程的內容或發送時間
+//
+// Parameters:
+// - id: 排程 ID
+// - author: 請求者用戶名
+// - update: 要修改的欄位
+//
+// Returns:
+// - ScheduledMessage: 修改後的排程
+// - error: 排程不存在、內容為空、時間已過或寫入失敗時的錯誤
+func (s *Scheduler) Update(id, author string, update ScheduledMessageUpdate) (ScheduledMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok || job.Author != author {
+		return ScheduledMessage{}, ErrScheduleNotFound
+	}
+
+	updated := *job
+	if update.Content != nil {
+		if strings.TrimSpace(*update.Content) == "" {
+			return ScheduledMessage{}, ErrScheduleContentRequired
+		}
+		updated.Content = *update.Content
+	}
+	if update.SendAt != nil {
+		if !update.SendAt.After(s.now()) {
+			return ScheduledMessage{}, ErrScheduleSendAtInPast
+		}
+		updated.SendAt = *update.SendAt
+	}
+	updated.UpdatedAt = s.now()
+
+	if err := s.persistLocked(&updated); err != nil {
+		return ScheduledMessage{}, err
+	}
+	s.jobs[id] = &updated
+	return updated, nil
+}
+
+// Cancel 取消排程
+//
+// Parameters:
+// - id: 排程 ID
+// - author: 請求者用戶名
+//
+// Returns:
+// - error: 排程不存在時返回 ErrScheduleNotFound
+func (s *Scheduler) Cancel(id, author string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok || job.Author != author {
+		return ErrScheduleNotFound
+	}
+	delete(s.jobs, id)
+	os.Remove(s.jobPath(id))
+	return nil
+}
+
+// DeliverDue 發送所有到期的排程訊息
+//
+// Process flow:
+// 1. 取出所有到期的排程並從列表移除，之後無法再修改或取消
+// 2. 依預定時間順序建立訊息並刪除排程檔案
+// 3. 透過 prepareMessage 驗證與審核後以 publishMessage 發送，被拒絕（例如作者被禁言）的排程直接捨棄
+//
+// Returns:
+// - int: 發送的訊息數量
+func (s *Scheduler) DeliverDue() int {
+	s.mu.Lock()
+	now := s.now()
+	var due []ScheduledMessage
+	for id, job := range s.jobs {
+		if !job.SendAt.After(now) {
+			due = append(due, *job)
+			delete(s.jobs, id)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].SendAt.Before(due[j].SendAt)
+	})
+	delivered := 0
+	for _, job := range due {
+		msg := NewMessage(job.Author, job.Content, job.Channel)
+		msg.Timestamp = now
+		os.Remove(s.jobPath(job.ID))
+		if err := prepareMessage(&msg); err != nil {
+			log.Printf(LogScheduleRejected, job.ID, err)
+			continue
+		}
+		publishMessage(msg)
+		delivered++
+		log.Printf(LogScheduleDelivered, job.ID, job.Author, job.Channel)
+	}
+	return delivered
+}
+
+// run 定期發送到期的排程訊息
+//
+// Parameters:
+// - interval: 檢查間隔
+func (s *Scheduler) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.DeliverDue()
+	}
+}
+
+// persistLocked 將排程寫入磁碟，呼叫者需持有鎖
+func (s *Scheduler) persistLocked(job *ScheduledMessage) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.jobPath(job.ID), data, 0o644)
+}
+
+// jobPath 返回排程檔案路徑
+func (s *Scheduler) jobPath(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// scheduleStatusCode 將排程錯誤對應到 HTTP 狀態碼
+func scheduleStatusCode(err error) int {
+	switch {
+	case errors.Is(err, ErrScheduleNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrScheduleContentRequired), errors.Is(err, ErrScheduleSendAtInPast):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestScheduler 測試排程訊息的建立、修改、取消與發送
+func TestScheduler(t *testing.T) {
+	hub = *newTestHub(0)
+	messageStore = make(MessageStore)
+	messageIndex = NewSearchIndex()
+
+	now := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	s := NewScheduler(t.TempDir())
+	s.now = func() time.Time { return now }
+
+	t.Run("時間已過", func(t *testing.T) {
+		if _, err := s.Schedule("alice", "general", "公告", now); !errors.Is(err, ErrScheduleSendAtInPast) {
+			t.Errorf("Expected ErrScheduleSendAtInPast, got %v", err)
+		}
+	})
+
+	first, _ := s.Schedule("alice", "general", "第一則", now.Add(time.Hour))
+	second, _ := s.Schedule("alice", "general", "第二則", now.Add(2*time.Hour))
+	cancelled, _ := s.Schedule("alice", "general", "取消", now.Add(time.Hour))
+
+	t.Run("只有作者可以修改與取消", func(t *testing.T) {
+		content := "偷改"
+		if _, err := s.Update(first.ID, "bob", ScheduledMessageUpdate{Content: &content}); !errors.Is(err, ErrScheduleNotFound) {
+			t.Errorf("Expected ErrScheduleNotFound, got %v", err)
+		}
+		if err := s.Cancel(first.ID, "bob"); !errors.Is(err, ErrScheduleNotFound) {
+			t.Errorf("Expected ErrScheduleNotFound, got %v", err)
+		}
+	})
+
+	t.Run("修改與取消", func(t *testing.T) {
+		sendAt := now.Add(30 * time.Minute)
+		updated, err := s.Update(second.ID, "alice", ScheduledMessageUpdate{SendAt: &sendAt})
+		if err != nil || !updated.SendAt.Equal(sendAt) {
+			t.Fatalf("Expected sendAt to be updated, got %v (%v)", updated.SendAt, err)
+		}
+		if err := s.Cancel(cancelled.ID, "alice"); err != nil {
+			t.Fatal(err)
+		}
+
+		jobs := s.List("alice")
+		if len(jobs) != 2 || jobs[0].ID != second.ID {
+			t.Errorf("Expected 2 jobs ordered by sendAt, got %+v", jobs)
+		}
+	})
+
+	t.Run("重新載入", func(t *testing.T) {
+		reloaded := NewScheduler(s.dir)
+		if err := reloaded.Load(); err != nil {
+			t.Fatal(err)
+		}
+		if jobs := reloaded.List("alice"); len(jobs) != 2 {
+			t.Errorf("Expected 2 jobs after reload, got %d", len(jobs))
+		}
+	})
+
+	t.Run("到期發送", func(t *testing.T) {
+		if delivered := s.DeliverDue(); delivered != 0 {
+			t.Fatalf("Expected nothing due yet, got %d", delivered)
+		}
+
+		now = now.Add(45 * time.Minute)
+		if delivered := s.DeliverDue(); delivered != 1 {
+			t.Fatalf("Expected 1 message delivered, got %d", delivered)
+		}
+		stored := messageStore["general"]
+		if len(stored) != 1 || stored[0].Content != "第二則" || stored[0].User != "alice" {
+			t.Errorf("Expected delivered message in store, got %+v", stored)
+		}
+		if !stored[0].Timestamp.Equal(now) {
+			t.Errorf("Expected timestamp %v, got %v", now, stored[0].Timestamp)
+		}
+		if len(s.List("alice")) != 1 {
+			t.Error("Expected delivered job to be removed")
+		}
+	})
+}
+
+// TestScheduledMessageAPI 測試排程訊息 API
+func TestScheduledMessageAPI(t *testing.T) {
+	scheduler = NewScheduler(t.TempDir())
+	router := setupRoutes()
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"content": "週會提醒",
+		"sendAt":  time.Now().Add(time.Hour),
+	})
+	req, _ := http.NewRequest("POST", "/api/scheduled", bytes.NewReader(body))
+	req.SetBasicAuth("alice", "password123")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var response struct {
+		Scheduled ScheduledMessage `json:"scheduled"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	if response.Scheduled.Channel != "general" {
+		t.Errorf("Expected job in caller's channel, got '%s'", response.Scheduled.Channel)
+	}
+
+	req, _ = http.NewRequest("DELETE", "/api/scheduled/"+response.Scheduled.ID, nil)
+	req.SetBasicAuth("bob", "password123")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 when cancelling another user's job, got %d", rr.Code)
+	}
+
+	req, _ = http.NewRequest("DELETE", "/api/scheduled/"+response.Scheduled.ID, nil)
+	req.SetBasicAuth("alice", "password123")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", rr.Code)
+	}
+}
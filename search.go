@@ -0,0 +1,421 @@
+package main
+
+import (
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// searchToken 代表斷詞結果中的一個詞彙
+type searchToken struct {
+	text  string // 小寫化的詞彙
+	start int    // 在原文中的起始位置（rune）
+	end   int    // 在原文中的結束位置（rune，不含）
+}
+
+// isCJK 檢查字元是否為中日韓文字
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
+
+// tokenize 將文字切分為搜尋詞彙
+//
+// Responsible for:
+// - 英數字以連續字元為一個詞彙，並轉為小寫
+// - 中日韓文字沒有空白分隔，使用相鄰兩字（bigram）作為詞彙
+//
+// Design considerations:
+// - 建立索引時（forIndex）額外產生單字詞彙，讓單字查詢也能命中
+// - 查詢時只使用 bigram，例如「加入」只會命中連續出現的「加入」而非分開的「加」「入」
+// - 連續中文只有一個字時兩種模式都使用單字
+// - 保留每個詞彙在原文的位置，供結果標示使用
+//
+// Parameters:
+// - text: 要斷詞的文字
+// - forIndex: true 表示建立索引，false 表示解析查詢
+//
+// Returns:
+// - []searchToken: 詞彙列表
+func tokenize(text string, forIndex bool) []searchToken {
+	runes := []rune(text)
+	var tokens []searchToken
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case isCJK(r):
+			j := i
+			for j < len(runes) && isCJK(runes[j]) {
+				j++
+			}
+			if j-i == 1 {
+				tokens = append(tokens, searchToken{text: string(runes[i:j]), start: i, end: j})
+			} else {
+				for k := i; k < j; k++ {
+					if forIndex {
+						tokens = append(tokens, searchToken{text: string(runes[k]), start: k, end: k + 1})
+					}
+					if k+1 < j {
+						tokens = append(tokens, searchToken{text: string(runes[k : k+2]), start: k, end: k + 2})
+					}
+				}
+			}
+			i = j
+
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			j := i
+			for j < len(runes) && !isCJK(runes[j]) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j])) {
+				j++
+			}
+			tokens = append(tokens, searchToken{text: strings.ToLower(string(runes[i:j])), start: i, end: j})
+			i = j
+
+		default:
+			i++
+		}
+	}
+	return tokens
+}
+
+// SearchQuery 代表一次搜尋的條件
+//
+// Design considerations:
+// - Channels 為呼叫者可讀取的頻道，結果一律限制在其中
+// - 其他篩選條件為空值時不套用
+type SearchQuery struct {
+	Text     string    // 搜尋文字
+	Channels []string  // 可搜尋的頻道
+	User     string    // 發送者
+	Type     string    // 訊息類型
+	From     time.Time // 起始時間（含）
+	To       time.Time // 結束時間（不含）
+	Offset   int       // 分頁起點
+	Limit    int       // 每頁數量
+}
+
+// SearchHit 代表一筆搜尋結果
+type SearchHit struct {
+	Message   Message  `json:"message"`   // 命中的訊息
+	Highlight string   `json:"highlight"` // 以 <mark> 標示命中詞彙的內容（已跳脫 HTML）
+	Matches   [][2]int `json:"matches"`   // 命中範圍（rune 位置，[起點, 終點)）
+}
+
+// SearchResult 代表一頁搜尋結果
+type SearchResult struct {
+	Total   int         `json:"total"`   // 符合條件的總數
+	Offset  int         `json:"offset"`  // 分頁起點
+	Limit   int         `json:"limit"`   // 每頁數量
+	Results []SearchHit `json:"results"` // 本頁結果
+}
+
+// SearchIndex 訊息內容的記憶體倒排索引
+//
+// Responsible for:
+// - 在訊息存入 MessageStore 時建立索引
+// - 依詞彙查詢訊息並套用篩選條件
+// - 產生命中標示與分頁結果
+//
+// Design considerations:
+// - postings 以詞彙對應訊息 ID 集合，多個詞彙取交集（AND 查詢）
+// - docs 保存訊息複本，查詢時不需要再存取 MessageStore
+// - 使用 RWMutex，查詢可以併發進行
+//
+// Usage context:
+// - 全域 messageIndex 實例，由 MessageStore 維護
+// - GET /api/search 處理器
+type SearchIndex struct {
+	mu       sync.RWMutex
+	postings map[string]map[string]bool
+	docs     map[string]Message
+}
+
+// NewSearchIndex 建立空的搜尋索引
+//
+// Returns:
+// - *SearchIndex: 空的索引
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{
+		postings: make(map[string]map[string]bool),
+		docs:     make(map[string]Message),
+	}
+}
+
+// Index 將訊息加入索引
+//
+// Parameters:
+// - msg: 要索引的訊息，同 ID 的舊內容會被取代
+func (idx *SearchIndex) Index(msg Message) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.removeLocked(msg.ID)
+	idx.docs[msg.ID] = msg
+	for _, token := range tokenize(msg.Content, true) {
+		if idx.postings[token.text] == nil {
+			idx.postings[token.text] = make(map[string]bool)
+		}
+		idx.postings[token.text][msg.ID] = true
+	}
+}
+
+// Remove 從索引移除訊息
+//
+// Parameters:
+// - id: 訊息 ID
+func (idx *SearchIndex) Remove(id string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.removeLocked(id)
+}
+
+// RemoveChannel 從索引移除頻道的所有訊息
+//
+// Parameters:
+// - channel: 頻道名稱
+func (idx *SearchIndex) RemoveChannel(channel string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for id, msg := range idx.docs {
+		if msg.Channel == channel {
+			idx.removeLocked(id)
+		}
+	}
+}
+
+// Reset 清空索引
+func (idx *SearchIndex) Reset() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.postings = make(map[string]map[string]bool)
+	idx.docs = make(map[string]Message)
+}
+
+// Search 執行搜尋
+//
+// Process flow:
+// 1. 將查詢文字斷詞，沒有詞彙時返回空結果
+// 2. 從最少命中的詞彙開始取交集
+// 3. 套用頻道、用戶、類型、時間範圍篩選，並略過已過期的訊息
+// 4. 依時間由新到舊排序並分頁
+// 5. 為本頁結果產生命中標示
+//
+// Parameters:
+// - query: 搜尋條件
+//
+// Returns:
+// - SearchResult: 一頁搜尋結果
+func (idx *SearchIndex) Search(query SearchQuery) SearchResult {
+	result := SearchResult{Offset: query.Offset, Limit: query.Limit, Results: []SearchHit{}}
+
+	terms := uniqueTokenTexts(tokenize(query.Text, false))
+	if len(terms) == 0 {
+		return result
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	// 從最短的 posting list 開始取交集
+	sort.Slice(terms, func(i, j int) bool {
+		return len(idx.postings[terms[i]]) < len(idx.postings[terms[j]])
+	})
+
+	channels := make(map[string]bool, len(query.Channels))
+	for _, channel := range query.Channels {
+		channels[channel] = true
+	}
+
+	now := time.Now()
+	var matches []Message
+	for id := range idx.postings[terms[0]] {
+		msg := idx.docs[id]
+		if !channels[msg.Channel] || msg.IsExpired(now) || !matchesFilters(msg, query) {
+			continue
+		}
+
+		found := true
+		for _, term := range terms[1:] {
+			if !idx.postings[term][id] {
+				found = false
+				break
+			}
+		}
+		if found {
+			matches = append(matches, msg)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Timestamp.Equal(matches[j].Timestamp) {
+			return matches[i].ID > matches[j].ID
+		}
+		return matches[i].Timestamp.After(matches[j].Timestamp)
+	})
+
+	result.Total = len(matches)
+	if query.Offset >= len(matches) {
+		return result
+	}
+	end := min(len(matches), query.Offset+query.Limit)
+
+	termSet := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		termSet[term] = true
+	}
+	for _, msg := range matches[query.Offset:end] {
+		result.Results = append(result.Results, highlight(msg, termSet))
+	}
+	return result
+}
+
+// removeLocked 移除訊息的所有索引資料，呼叫者需持有寫鎖
+func (idx *SearchIndex) removeLocked(id string) {
+	msg, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+
+	for _, token := range tokenize(msg.Content, true) {
+		if postings := idx.postings[token.text]; postings != nil {
+			delete(postings, id)
+			if len(postings) == 0 {
+				delete(idx.postings, token.text)
+			}
+		}
+	}
+	delete(idx.docs, id)
+}
+
+// matchesFilters 檢查訊息是否符合用戶、類型和時間範圍篩選
+func matchesFilters(msg Message, query SearchQuery) bool {
+	if query.User != "" && msg.User != query.User {
+		return false
+	}
+	if query.Type != "" && msg.Type != query.Type {
+		return false
+	}
+	if !query.From.IsZero() && msg.Timestamp.Before(query.From) {
+		return false
+	}
+	if !query.To.IsZero() && !msg.Timestamp.Before(query.To) {
+		return false
+	}
+	return true
+}
+
+// uniqueTokenTexts 取出不重複的詞彙文字
+func uniqueTokenTexts(tokens []searchToken) []string {
+	seen := make(map[string]bool)
+	var texts []string
+	for _, token := range tokens {
+		if !seen[token.text] {
+			seen[token.text] = true
+			texts = append(texts, token.text)
+		}
+	}
+	return texts
+}
+
+// highlight 標示訊息內容中命中的詞彙
+//
+// Design considerations:
+// - 命中範圍以原文斷詞的位置計算，重疊的 bigram 會合併為一段
+// - 輸出前跳脫 HTML，只有 <mark> 標籤是伺服器加入的
+//
+// Parameters:
+// - msg: 命中的訊息
+// - terms: 查詢詞彙集合
+//
+// Returns:
+// - SearchHit: 含標示結果的搜尋結果
+func highlight(msg Message, terms map[string]bool) SearchHit {
+	var ranges [][2]int
+	for _, token := range tokenize(msg.Content, true) {
+		if !terms[token.text] {
+			continue
+		}
+		if n := len(ranges); n > 0 && token.start <= ranges[n-1][1] {
+			ranges[n-1][1] = max(ranges[n-1][1], token.end)
+			continue
+		}
+		ranges = append(ranges, [2]int{token.start, token.end})
+	}
+
+	runes := []rune(msg.Content)
+	var b strings.Builder
+	last := 0
+	for _, r := range ranges {
+		b.WriteString(html.EscapeString(string(runes[last:r[0]])))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(string(runes[r[0]:r[1]])))
+		b.WriteString("</mark>")
+		last = r[1]
+	}
+	b.WriteString(html.EscapeString(string(runes[last:])))
+
+	if ranges == nil {
+		ranges = [][2]int{}
+	}
+	return SearchHit{Message: msg, Highlight: b.String(), Matches: ranges}
+}
+
+// parseSearchTime 解析搜尋的時間範圍參數
+//
+// Design considerations:
+// - 接受 RFC 3339 時間戳或 YYYY-MM-DD 日期（UTC）
+// - 作為結束時間的日期會延後一天，讓 to=2024-01-01 包含當天的訊息
+//
+// Parameters:
+// - value: 參數值，空字串表示不限制
+// - endOfRange: 是否為範圍的結束時間
+//
+// Returns:
+// - time.Time: 解析結果，未指定時為零值
+// - error: 格式錯誤時返回錯誤
+func parseSearchTime(value string, endOfRange bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfRange {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// parseIntParam 解析非負整數的查詢參數（例如分頁參數）
+//
+// Parameters:
+// - value: 參數值，空字串時使用預設值
+// - fallback: 預設值
+//
+// Returns:
+// - int: 解析結果
+// - error: 不是非負整數時返回錯誤
+func parseIntParam(value string, fallback int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, strconv.ErrSyntax
+	}
+	return n, nil
+}
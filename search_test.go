@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestTokenize 測試中英文斷詞
+func TestTokenize(t *testing.T) {
+	texts := func(tokens []searchToken) []string {
+		var result []string
+		for _, token := range tokens {
+			result = append(result, token.text)
+		}
+		return result
+	}
+
+	t.Run("英文轉小寫", func(t *testing.T) {
+		got := texts(tokenize("Hello, Flutter-Chat 2024!", false))
+		want := []string{"hello", "flutter", "chat", "2024"}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("中文查詢使用 bigram", func(t *testing.T) {
+		got := texts(tokenize("加入頻道", false))
+		want := []string{"加入", "入頻", "頻道"}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("中文索引包含單字", func(t *testing.T) {
+		got := texts(tokenize("你好", true))
+		want := []string{"你", "你好", "好"}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("中英混合", func(t *testing.T) {
+		got := texts(tokenize("alice加入了general頻道", false))
+		want := []string{"alice", "加入", "入了", "general", "頻道"}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+	})
+}
+
+// TestSearchIndex 測試索引查詢、篩選與分頁
+func TestSearchIndex(t *testing.T) {
+	idx := NewSearchIndex()
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	add := func(id, user, content, channel string, offset time.Duration) {
+		idx.Index(Message{ID: id, User: user, Content: content, Channel: channel,
+			Type: MessageTypeText, Timestamp: base.Add(offset)})
+	}
+	add("1", "alice", "今天的部署完成了", "general", 0)
+	add("2", "bob", "部署失敗，請檢查 log", "general", time.Hour)
+	add("3", "alice", "Deploy finished", "general", 2*time.Hour)
+	add("4", "bob", "部署到 tech 頻道", "tech", 3*time.Hour)
+
+	general := []string{"general"}
+
+	t.Run("中文查詢", func(t *testing.T) {
+		result := idx.Search(SearchQuery{Text: "部署", Channels: general, Limit: 10})
+		if result.Total != 2 {
+			t.Fatalf("Expected 2 results, got %d", result.Total)
+		}
+		if result.Results[0].Message.ID != "2" {
+			t.Errorf("Expected newest first, got %s", result.Results[0].Message.ID)
+		}
+	})
+
+	t.Run("不相鄰的字不算命中", func(t *testing.T) {
+		result := idx.Search(SearchQuery{Text: "部完", Channels: general, Limit: 10})
+		if result.Total != 0 {
+			t.Errorf("Expected 0 results, got %d", result.Total)
+		}
+	})
+
+	t.Run("英文不分大小寫", func(t *testing.T) {
+		result := idx.Search(SearchQuery{Text: "DEPLOY", Channels: general, Limit: 10})
+		if result.Total != 1 || result.Results[0].Message.ID != "3" {
+			t.Errorf("Expected message 3, got %+v", result.Results)
+		}
+	})
+
+	t.Run("限制可讀取頻道", func(t *testing.T) {
+		result := idx.Search(SearchQuery{Text: "tech", Channels: general, Limit: 10})
+		if result.Total != 0 {
+			t.Errorf("Expected no results outside readable channels, got %d", result.Total)
+		}
+	})
+
+	t.Run("用戶與時間篩選", func(t *testing.T) {
+		result := idx.Search(SearchQuery{Text: "部署", Channels: general, User: "alice", Limit: 10})
+		if result.Total != 1 || result.Results[0].Message.ID != "1" {
+			t.Errorf("Expected message 1 for user filter, got %+v", result.Results)
+		}
+
+		result = idx.Search(SearchQuery{Text: "部署", Channels: general, From: base.Add(30 * time.Minute), Limit: 10})
+		if result.Total != 1 || result.Results[0].Message.ID != "2" {
+			t.Errorf("Expected message 2 for date filter, got %+v", result.Results)
+		}
+	})
+
+	t.Run("分頁", func(t *testing.T) {
+		result := idx.Search(SearchQuery{Text: "部署", Channels: general, Offset: 1, Limit: 1})
+		if result.Total != 2 || len(result.Results) != 1 || result.Results[0].Message.ID != "1" {
+			t.Errorf("Expected second page to contain message 1, got %+v", result)
+		}
+	})
+
+	t.Run("移除訊息", func(t *testing.T) {
+		idx.Remove("2")
+		result := idx.Search(SearchQuery{Text: "部署", Channels: general, Limit: 10})
+		if result.Total != 1 {
+			t.Errorf("Expected 1 result after removal, got %d", result.Total)
+		}
+	})
+}
+
+// TestHighlight 測試命中標示
+func TestHighlight(t *testing.T) {
+	msg := Message{Content: "<b>alice</b> 加入了 general 頻道"}
+	hit := highlight(msg, map[string]bool{"加入": true, "入了": true, "alice": true})
+
+	want := "&lt;b&gt;<mark>alice</mark>&lt;/b&gt; <mark>加入了</mark> general 頻道"
+	if hit.Highlight != want {
+		t.Errorf("Expected highlight %q, got %q", want, hit.Highlight)
+	}
+	if len(hit.Matches) != 2 || hit.Matches[1] != [2]int{13, 16} {
+		t.Errorf("Expected merged match [13 16], got %v", hit.Matches)
+	}
+}
+
+// TestSearchAPI 測試搜尋 API 的驗證與頻道權限
+func TestSearchAPI(t *testing.T) {
+	messageStore = make(MessageStore)
+	messageIndex = NewSearchIndex()
+	messageStore.AddMessage(NewJoinMessage("alice", "general"))
+	messageStore.AddMessage(NewJoinMessage("bob", "tech"))
+	router := setupRoutes()
+
+	search := func(query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/search?"+query, nil)
+		req.SetBasicAuth("alice", "password123")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("搜尋可讀取的頻道", func(t *testing.T) {
+		rr := search("q=%E5%8A%A0%E5%85%A5")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var result SearchResult
+		if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		if result.Total != 1 || result.Results[0].Message.Channel != "general" {
+			t.Errorf("Expected only the general join message, got %+v", result)
+		}
+	})
+
+	t.Run("不可讀取的頻道", func(t *testing.T) {
+		if rr := search("q=bob&channel=tech"); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected 403, got %d", rr.Code)
+		}
+	})
+
+	t.Run("參數錯誤", func(t *testing.T) {
+		for _, query := range []string{"", "q=a&from=yesterday", "q=a&limit=-1"} {
+			if rr := search(query); rr.Code != http.StatusBadRequest {
+				t.Errorf("Expected 400 for %q, got %d", query, rr.Code)
+			}
+		}
+	})
+
+	t.Run("未驗證", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/api/search?q=test", nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401, got %d", rr.Code)
+		}
+	})
+
+	t.Run("清空頻道時移除索引", func(t *testing.T) {
+		messageStore.ClearChannel("general")
+		rr := search("q=alice")
+		var result SearchResult
+		json.Unmarshal(rr.Body.Bytes(), &result)
+		if result.Total != 0 {
+			t.Errorf("Expected cleared channel to be removed from the index, got %d", result.Total)
+		}
+	})
+}
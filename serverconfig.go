@@ -0,0 +1,524 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ServerConfig 啟動時載入的伺服器設定
+//
+// Responsible for:
+// - 集中保存原本寫死在 config.go 的埠號、WebSocket 參數、緩衝區大小、在線狀態、檢舉、釘選與上傳設定，以及測試帳號
+// - 提供 --print-config 輸出與設定檔相同格式的 JSON
+//
+// Design considerations:
+// - 預設值來自 config.go 的 Default* 常數，未設定的欄位維持預設
+// - 埠號、TLS、讀取限制、緩衝區、在線狀態、釘選與上傳設定只在啟動時生效；歷史數量、檢舉隱藏門檻、速率限制、審核詞彙、Origin 與帳號可以重新載入（見 ConfigManager）
+//
+// Usage context:
+// - 由全域 configManager 保存，Hub、WebSocket 升級器與處理器透過 configManager.Get 讀取
+type ServerConfig struct {
+	Host                string          `json:"host"`                // 啟動資訊顯示的主機名稱
+	Port                int             `json:"port"`                // HTTP 監聽埠
+	ReadLimit           int64           `json:"readLimit"`           // WebSocket 單則訊息的大小上限（位元組）
+	ReadTimeout         int             `json:"readTimeout"`         // WebSocket 讀取逾時（秒）
+	HistoryLimit        int             `json:"historyLimit"`        // GET /api/messages 預設返回的歷史訊息數量
+	ClientSendBuffer    int             `json:"clientSendBuffer"`    // 每個連接的發送佇列大小
+	HubBroadcastBuffer  int             `json:"hubBroadcastBuffer"`  // Hub 廣播佇列大小
+	PresenceAwayTimeout int             `json:"presenceAwayTimeout"` // 無活動多久後標記為 away（秒）
+	PresenceGracePeriod int             `json:"presenceGracePeriod"` // 斷線後延遲發送離開訊息的寬限期（秒），0 表示立即發送
+	ReportHideThreshold int             `json:"reportHideThreshold"` // 自動隱藏訊息所需的檢舉者數量，0 表示不自動隱藏，可再由管理 API 修改
+	PinLimit            int             `json:"pinLimit"`            // 每個頻道最多釘選的訊息數
+	Uploads             UploadConfig    `json:"uploads"`             // 上傳檔案與分段上傳的目錄與大小上限
+	AllowAllOrigins     bool            `json:"allowAllOrigins"`     // REST API 與 WebSocket 是否接受任何 Origin，關閉時只接受 AllowedOrigins（WebSocket 另外接受同源）
+	AllowedOrigins      []string        `json:"allowedOrigins"`      // AllowAllOrigins 關閉時接受的 Origin，例如 http://localhost:3000
+	CORS                CORSConfig      `json:"cors"`                // 跨域請求的方法、標頭、憑證與預檢快取
+	TLS                 TLSConfig       `json:"tls"`                 // HTTPS 憑證、HTTP 轉址與自簽開發憑證
+	Accounts            []Account       `json:"accounts"`            // 測試帳號
+	Roles               []RoleSeed      `json:"roles"`               // 啟動時授予的角色，例如伺服器範圍的 admin
+	RateLimits          RateLimitConfig `json:"rateLimits"`          // 訊息、登入與 WebSocket 連接的速率限制
+	MaskedWords         []string        `json:"maskedWords"`         // 遮蔽的詞彙
+	FlaggedWords        []string        `json:"flaggedWords"`        // 需要人工審核的詞彙
+	BlockedDomains      []string        `json:"blockedDomains"`      // 封鎖的連結網域
+
+	File string `json:"-"` // 載入的設定檔路徑，未使用設定檔時為空字串
+}
+
+// CORSConfig 跨域請求設定，允許的 Origin 與 WebSocket 共用 ServerConfig.AllowedOrigins
+type CORSConfig struct {
+	AllowedMethods   []string `json:"allowedMethods"`   // 預檢回應的 Access-Control-Allow-Methods
+	AllowedHeaders   []string `json:"allowedHeaders"`   // 預檢回應的 Access-Control-Allow-Headers
+	ExposedHeaders   []string `json:"exposedHeaders"`   // 回應的 Access-Control-Expose-Headers
+	AllowCredentials bool     `json:"allowCredentials"` // 是否允許攜帶 Cookie 與 Authorization 等憑證
+	MaxAge           int      `json:"maxAge"`           // 預檢結果的快取時間（秒），0 表示不快取
+}
+
+// TLSConfig HTTPS 設定，憑證檔與自簽開發憑證二擇一，都未設定時使用 HTTP
+type TLSConfig struct {
+	CertFile     string `json:"certFile"`     // PEM 格式的憑證（可包含中繼憑證）
+	KeyFile      string `json:"keyFile"`      // PEM 格式的私鑰
+	Dev          bool   `json:"dev"`          // 產生並快取涵蓋 localhost 與內網 IP 的自簽憑證
+	DevCertDir   string `json:"devCertDir"`   // 自簽開發憑證的快取目錄
+	RedirectPort int    `json:"redirectPort"` // 將 HTTP 請求轉址到 HTTPS 的監聽埠，0 表示不啟用
+}
+
+// UploadConfig 上傳設定，目錄與大小上限只在啟動時生效
+type UploadConfig struct {
+	Dir            string `json:"dir"`            // 上傳檔案的存放目錄
+	MaxSize        int64  `json:"maxSize"`        // POST /api/uploads 單一檔案的大小上限（位元組）
+	SessionDir     string `json:"sessionDir"`     // 分段上傳進行中的暫存目錄
+	ChunkedMaxSize int64  `json:"chunkedMaxSize"` // 分段上傳單一檔案的大小上限（位元組）
+	ChunkMaxSize   int64  `json:"chunkMaxSize"`   // 分段上傳單一分段的大小上限（位元組）
+}
+
+// RoleSeed 啟動時授予的角色
+type RoleSeed struct {
+	Username string `json:"username"`          // 用戶名稱
+	Role     string `json:"role"`              // 角色名稱
+	Channel  string `json:"channel,omitempty"` // 頻道名稱，省略時為伺服器範圍
+}
+
+// enabled 返回是否使用 HTTPS
+func (c TLSConfig) enabled() bool {
+	return c.Dev || c.CertFile != ""
+}
+
+// defaultServerConfig 返回以 config.go 預設值建立的伺服器設定
+func defaultServerConfig() ServerConfig {
+	return ServerConfig{
+		Host:                DefaultServerHost,
+		Port:                DefaultServerPort,
+		ReadLimit:           DefaultReadLimit,
+		ReadTimeout:         DefaultReadTimeout,
+		HistoryLimit:        DefaultHistoryLimit,
+		ClientSendBuffer:    DefaultClientSendBuffer,
+		HubBroadcastBuffer:  DefaultHubBroadcastBuffer,
+		PresenceAwayTimeout: DefaultPresenceAwayTimeout,
+		PresenceGracePeriod: DefaultPresenceGracePeriod,
+		ReportHideThreshold: DefaultReportHideThreshold,
+		PinLimit:            DefaultPinLimit,
+		AllowAllOrigins:     DefaultAllowAllOrigins,
+		AllowedOrigins:      []string{},
+		Accounts:            append([]Account(nil), DefaultTestAccounts...),
+		Roles:               roleSeeds(DefaultRoleGrants),
+		RateLimits:          DefaultRateLimits,
+		MaskedWords:         append([]string(nil), DefaultModerationMaskedWords...),
+		FlaggedWords:        append([]string(nil), DefaultModerationFlaggedWords...),
+		BlockedDomains:      append([]string(nil), DefaultModerationBlockedDomains...),
+		CORS: CORSConfig{
+			AllowedMethods:   append([]string(nil), DefaultCORSAllowedMethods...),
+			AllowedHeaders:   append([]string(nil), DefaultCORSAllowedHeaders...),
+			ExposedHeaders:   append([]string(nil), DefaultCORSExposedHeaders...),
+			AllowCredentials: DefaultCORSAllowCredentials,
+			MaxAge:           DefaultCORSMaxAge,
+		},
+		TLS: TLSConfig{
+			DevCertDir: DefaultTLSDevCertDir,
+		},
+		Uploads: UploadConfig{
+			Dir:            DefaultUploadDir,
+			MaxSize:        DefaultUploadMaxSize,
+			SessionDir:     DefaultUploadSessionDir,
+			ChunkedMaxSize: DefaultChunkedUploadMaxSize,
+			ChunkMaxSize:   DefaultUploadChunkMaxSize,
+		},
+	}
+}
+
+// roleSeeds 將角色紀錄轉換為設定中的格式
+func roleSeeds(grants []RoleGrant) []RoleSeed {
+	seeds := []RoleSeed{}
+	for _, grant := range grants {
+		seeds = append(seeds, RoleSeed{Username: grant.Username, Role: grant.Role, Channel: grant.Channel})
+	}
+	return seeds
+}
+
+// roleGrants 返回設定的角色，用於初始化 roleStore
+func (c ServerConfig) roleGrants() []RoleGrant {
+	var grants []RoleGrant
+	for _, seed := range c.Roles {
+		grants = append(grants, RoleGrant{Channel: seed.Channel, Username: seed.Username, Role: seed.Role})
+	}
+	return grants
+}
+
+// runtimeConfig 以伺服器設定取代執行期設定中可重新載入的欄位
+//
+// Parameters:
+// - current: 目前的執行期設定，伺服器設定沒有的欄位沿用此值
+//
+// Returns:
+// - RuntimeConfig: 要套用的執行期設定
+func (c ServerConfig) runtimeConfig(current RuntimeConfig) RuntimeConfig {
+	current.HistoryLimit = c.HistoryLimit
+	current.ReportHideThreshold = c.ReportHideThreshold
+	current.RateLimits = c.RateLimits
+	current.MaskedWords = c.MaskedWords
+	current.FlaggedWords = c.FlaggedWords
+	current.BlockedDomains = c.BlockedDomains
+	return current
+}
+
+// Validate 檢查伺服器設定
+//
+// Design considerations:
+// - 一次回報所有錯誤，方便啟動失敗時一次修正
+//
+// Returns:
+// - error: 所有不合法欄位的錯誤，合法時為 nil
+func (c ServerConfig) Validate() error {
+	var errs []error
+	if c.Host == "" {
+		errs = append(errs, fmt.Errorf(ErrorConfigRequired, "host"))
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf(ErrorConfigRange, "port", 1, 65535, c.Port))
+	}
+	if c.HistoryLimit < 1 || c.HistoryLimit > DefaultMaxHistoryLimit {
+		errs = append(errs, fmt.Errorf(ErrorConfigRange, "historyLimit", 1, DefaultMaxHistoryLimit, c.HistoryLimit))
+	}
+	if c.PresenceGracePeriod < 0 || c.PresenceGracePeriod > DefaultMaxPresenceGracePeriod {
+		errs = append(errs, fmt.Errorf(ErrorConfigRange, "presenceGracePeriod", 0, DefaultMaxPresenceGracePeriod, c.PresenceGracePeriod))
+	}
+	for _, field := range []struct {
+		name  string
+		value int64
+	}{
+		{"readLimit", c.ReadLimit},
+		{"readTimeout", int64(c.ReadTimeout)},
+		{"clientSendBuffer", int64(c.ClientSendBuffer)},
+		{"hubBroadcastBuffer", int64(c.HubBroadcastBuffer)},
+		{"presenceAwayTimeout", int64(c.PresenceAwayTimeout)},
+		{"pinLimit", int64(c.PinLimit)},
+		{"uploads.maxSize", c.Uploads.MaxSize},
+		{"uploads.chunkedMaxSize", c.Uploads.ChunkedMaxSize},
+		{"uploads.chunkMaxSize", c.Uploads.ChunkMaxSize},
+	} {
+		if field.value <= 0 {
+			errs = append(errs, fmt.Errorf(ErrorConfigPositive, field.name, field.value))
+		}
+	}
+	if c.ReportHideThreshold < 0 {
+		errs = append(errs, fmt.Errorf(ErrorConfigNonNegative, "reportHideThreshold", c.ReportHideThreshold))
+	}
+	if c.Uploads.Dir == "" {
+		errs = append(errs, fmt.Errorf(ErrorConfigRequired, "uploads.dir"))
+	}
+	if c.Uploads.SessionDir == "" {
+		errs = append(errs, fmt.Errorf(ErrorConfigRequired, "uploads.sessionDir"))
+	}
+
+	if len(c.CORS.AllowedMethods) == 0 {
+		errs = append(errs, fmt.Errorf(ErrorConfigRequired, "cors.allowedMethods"))
+	}
+	if c.AllowAllOrigins && c.CORS.AllowCredentials {
+		errs = append(errs, errors.New(ErrorConfigCORSCredentials))
+	}
+	if c.CORS.MaxAge < 0 || c.CORS.MaxAge > DefaultCORSMaxAgeLimit {
+		errs = append(errs, fmt.Errorf(ErrorConfigRange, "cors.maxAge", 0, DefaultCORSMaxAgeLimit, c.CORS.MaxAge))
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		errs = append(errs, errors.New(ErrorConfigTLSPair))
+	}
+	if c.TLS.Dev && c.TLS.CertFile != "" {
+		errs = append(errs, errors.New(ErrorConfigTLSConflict))
+	}
+	if c.TLS.Dev && c.TLS.DevCertDir == "" {
+		errs = append(errs, fmt.Errorf(ErrorConfigRequired, "tls.devCertDir"))
+	}
+	if c.TLS.RedirectPort != 0 {
+		if c.TLS.RedirectPort < 1 || c.TLS.RedirectPort > 65535 {
+			errs = append(errs, fmt.Errorf(ErrorConfigRange, "tls.redirectPort", 1, 65535, c.TLS.RedirectPort))
+		} else if c.TLS.RedirectPort == c.Port {
+			errs = append(errs, fmt.Errorf(ErrorConfigTLSRedirectPort, c.TLS.RedirectPort))
+		}
+		if !c.TLS.enabled() {
+			errs = append(errs, errors.New(ErrorConfigTLSRedirect))
+		}
+	}
+	if err := validateRateLimits(c.RateLimits); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(c.Accounts) == 0 {
+		errs = append(errs, fmt.Errorf(ErrorConfigRequired, "accounts"))
+	}
+	seen := make(map[string]bool)
+	for i, account := range c.Accounts {
+		if account.Username == "" || account.Password == "" || account.Channel == "" {
+			errs = append(errs, fmt.Errorf(ErrorConfigAccountIncomplete, i+1))
+			continue
+		}
+		if seen[account.Username] {
+			errs = append(errs, fmt.Errorf(ErrorConfigAccountDuplicate, account.Username))
+		}
+		seen[account.Username] = true
+	}
+	for i, seed := range c.Roles {
+		if seed.Username == "" {
+			errs = append(errs, fmt.Errorf(ErrorConfigRoleUsername, i+1))
+		}
+		if !validRole(seed.Role) {
+			errs = append(errs, fmt.Errorf(ErrorConfigRoleInvalid, i+1, seed.Role))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// configSetting 可由環境變數與命令列參數設定的欄位
+type configSetting struct {
+	name    string // 命令列參數名稱，環境變數為 ConfigEnvPrefix 加上大寫並以底線分隔的名稱
+	usage   string // 命令列說明
+	boolean bool   // 命令列參數不帶值時視為 true
+	set     func(c *ServerConfig, value string) error
+}
+
+// env 返回設定對應的環境變數名稱
+func (s configSetting) env() string {
+	return ConfigEnvPrefix + strings.ToUpper(strings.ReplaceAll(s.name, "-", "_"))
+}
+
+// configSettings 所有可由環境變數與命令列參數設定的欄位
+var configSettings = []configSetting{
+	{name: "host", usage: "啟動資訊顯示的主機名稱", set: func(c *ServerConfig, v string) error {
+		c.Host = v
+		return nil
+	}},
+	{name: "port", usage: "HTTP 監聽埠", set: intSetting(func(c *ServerConfig) *int { return &c.Port })},
+	{name: "read-limit", usage: "WebSocket 單則訊息的大小上限（位元組）", set: int64Setting(func(c *ServerConfig) *int64 { return &c.ReadLimit })},
+	{name: "read-timeout", usage: "WebSocket 讀取逾時（秒）", set: intSetting(func(c *ServerConfig) *int { return &c.ReadTimeout })},
+	{name: "history-limit", usage: "預設返回的歷史訊息數量", set: intSetting(func(c *ServerConfig) *int { return &c.HistoryLimit })},
+	{name: "client-send-buffer", usage: "每個連接的發送佇列大小", set: intSetting(func(c *ServerConfig) *int { return &c.ClientSendBuffer })},
+	{name: "hub-broadcast-buffer", usage: "Hub 廣播佇列大小", set: intSetting(func(c *ServerConfig) *int { return &c.HubBroadcastBuffer })},
+	{name: "presence-away-timeout", usage: "無活動多久後標記為 away（秒）", set: intSetting(func(c *ServerConfig) *int { return &c.PresenceAwayTimeout })},
+	{name: "presence-grace-period", usage: "斷線後延遲發送離開訊息的寬限期（秒），0 表示立即發送", set: intSetting(func(c *ServerConfig) *int { return &c.PresenceGracePeriod })},
+	{name: "report-hide-threshold", usage: "自動隱藏訊息所需的檢舉者數量，0 表示不自動隱藏", set: intSetting(func(c *ServerConfig) *int { return &c.ReportHideThreshold })},
+	{name: "pin-limit", usage: "每個頻道最多釘選的訊息數", set: intSetting(func(c *ServerConfig) *int { return &c.PinLimit })},
+	{name: "upload-dir", usage: "上傳檔案的存放目錄", set: func(c *ServerConfig, v string) error {
+		c.Uploads.Dir = v
+		return nil
+	}},
+	{name: "upload-max-size", usage: "單一上傳檔案的大小上限（位元組）", set: int64Setting(func(c *ServerConfig) *int64 { return &c.Uploads.MaxSize })},
+	{name: "upload-session-dir", usage: "分段上傳的暫存目錄", set: func(c *ServerConfig, v string) error {
+		c.Uploads.SessionDir = v
+		return nil
+	}},
+	{name: "chunked-upload-max-size", usage: "分段上傳單一檔案的大小上限（位元組）", set: int64Setting(func(c *ServerConfig) *int64 { return &c.Uploads.ChunkedMaxSize })},
+	{name: "upload-chunk-max-size", usage: "分段上傳單一分段的大小上限（位元組）", set: int64Setting(func(c *ServerConfig) *int64 { return &c.Uploads.ChunkMaxSize })},
+	{name: "allow-all-origins", usage: "WebSocket 是否接受任何 Origin", boolean: true, set: func(c *ServerConfig, v string) (err error) {
+		c.AllowAllOrigins, err = strconv.ParseBool(v)
+		return err
+	}},
+	{name: "allowed-origins", usage: "allow-all-origins 關閉時接受的 Origin，以逗號分隔", set: func(c *ServerConfig, v string) error {
+		c.AllowedOrigins = splitList(v)
+		return nil
+	}},
+	{name: "cors-methods", usage: "跨域請求允許的方法，以逗號分隔", set: func(c *ServerConfig, v string) error {
+		c.CORS.AllowedMethods = splitList(v)
+		return nil
+	}},
+	{name: "cors-headers", usage: "跨域請求允許的標頭，以逗號分隔", set: func(c *ServerConfig, v string) error {
+		c.CORS.AllowedHeaders = splitList(v)
+		return nil
+	}},
+	{name: "cors-credentials", usage: "跨域請求是否允許攜帶憑證", boolean: true, set: func(c *ServerConfig, v string) (err error) {
+		c.CORS.AllowCredentials, err = strconv.ParseBool(v)
+		return err
+	}},
+	{name: "cors-max-age", usage: "預檢結果的快取時間（秒）", set: intSetting(func(c *ServerConfig) *int { return &c.CORS.MaxAge })},
+	{name: "tls-cert", usage: "HTTPS 憑證檔（PEM），需與 tls-key 一起設定", set: func(c *ServerConfig, v string) error {
+		c.TLS.CertFile = v
+		return nil
+	}},
+	{name: "tls-key", usage: "HTTPS 私鑰檔（PEM）", set: func(c *ServerConfig, v string) error {
+		c.TLS.KeyFile = v
+		return nil
+	}},
+	{name: "tls-dev", usage: "產生並快取自簽開發憑證以使用 HTTPS", boolean: true, set: func(c *ServerConfig, v string) (err error) {
+		c.TLS.Dev, err = strconv.ParseBool(v)
+		return err
+	}},
+	{name: "tls-dev-dir", usage: "自簽開發憑證的快取目錄", set: func(c *ServerConfig, v string) error {
+		c.TLS.DevCertDir = v
+		return nil
+	}},
+	{name: "tls-redirect-port", usage: "將 HTTP 轉址到 HTTPS 的監聽埠，0 表示不啟用", set: intSetting(func(c *ServerConfig) *int { return &c.TLS.RedirectPort })},
+	{name: "accounts", usage: "測試帳號，格式為 username:password:channel 並以逗號分隔", set: func(c *ServerConfig, v string) (err error) {
+		c.Accounts, err = parseAccounts(v)
+		return err
+	}},
+	{name: "roles", usage: "啟動時授予的角色，格式為 username:role 或 username:role:channel 並以逗號分隔，省略頻道為伺服器範圍", set: func(c *ServerConfig, v string) (err error) {
+		c.Roles, err = parseRoles(v)
+		return err
+	}},
+}
+
+// intSetting 建立設定整數欄位的函式
+func intSetting(field func(c *ServerConfig) *int) func(c *ServerConfig, value string) error {
+	return func(c *ServerConfig, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+// int64Setting 建立設定 int64 欄位的函式
+func int64Setting(field func(c *ServerConfig) *int64) func(c *ServerConfig, value string) error {
+	return func(c *ServerConfig, value string) error {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+// splitList 解析以逗號分隔的列表，忽略空白項目
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseAccounts 解析 username:password:channel 並以逗號分隔的帳號列表
+func parseAccounts(value string) ([]Account, error) {
+	var accounts []Account
+	for _, entry := range strings.Split(value, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf(ErrorConfigAccountFormat, entry)
+		}
+		accounts = append(accounts, Account{Username: parts[0], Password: parts[1], Channel: parts[2]})
+	}
+	return accounts, nil
+}
+
+// parseRoles 解析 username:role[:channel] 並以逗號分隔的角色列表
+func parseRoles(value string) ([]RoleSeed, error) {
+	var seeds []RoleSeed
+	for _, entry := range strings.Split(value, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf(ErrorConfigRoleFormat, entry)
+		}
+		seed := RoleSeed{Username: parts[0], Role: parts[1]}
+		if len(parts) == 3 {
+			seed.Channel = parts[2]
+		}
+		seeds = append(seeds, seed)
+	}
+	return seeds, nil
+}
+
+// LoadServerConfig 合併設定檔、環境變數與命令列參數
+//
+// Responsible for:
+// - 依優先順序套用各來源的設定並驗證結果
+// - 解析 --config 與 --print-config
+//
+// Design considerations:
+// - 優先順序由低到高：config.go 預設值、JSON 設定檔、環境變數、命令列參數
+// - 設定檔路徑來自 --config 或 CHAT_CONFIG，未指定時不讀取設定檔
+// - 設定檔不允許未知欄位，避免拼錯的欄位被默默忽略
+// - 命令列參數先解析並暫存，等設定檔與環境變數套用後才覆蓋
+//
+// Process flow:
+// 1. 解析命令列參數，記錄明確指定的值
+// 2. 從預設值開始，依序套用設定檔、環境變數與命令列參數
+// 3. 驗證合併後的設定
+//
+// Parameters:
+// - args: 命令列參數，不含程式名稱
+// - getenv: 讀取環境變數的函式，通常為 os.Getenv
+// - output: 參數錯誤與 --help 的輸出目標
+//
+// Returns:
+// - ServerConfig: 合併後的設定
+// - bool: 是否指定了 --print-config
+// - error: 參數、設定檔、環境變數或驗證錯誤
+func LoadServerConfig(args []string, getenv func(string) string, output io.Writer) (ServerConfig, bool, error) {
+	fs := flag.NewFlagSet("flutter-chat-server", flag.ContinueOnError)
+	fs.SetOutput(output)
+	configPath := fs.String("config", getenv(ConfigFileEnv), "JSON 設定檔路徑")
+	printConfig := fs.Bool("print-config", false, "輸出合併後的設定並結束")
+
+	flagValues := make(map[string]string)
+	for _, setting := range configSettings {
+		name := setting.name
+		record := func(value string) error {
+			flagValues[name] = value
+			return nil
+		}
+		usage := fmt.Sprintf("%s（環境變數 %s）", setting.usage, setting.env())
+		if setting.boolean {
+			fs.BoolFunc(name, usage, record)
+		} else {
+			fs.Func(name, usage, record)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return ServerConfig{}, false, err
+	}
+
+	config := defaultServerConfig()
+	config.File = *configPath
+	if *configPath != "" {
+		if err := loadConfigFile(*configPath, &config); err != nil {
+			return ServerConfig{}, false, fmt.Errorf(ErrorConfigFile, *configPath, err)
+		}
+	}
+	for _, setting := range configSettings {
+		if value := getenv(setting.env()); value != "" {
+			if err := setting.set(&config, value); err != nil {
+				return ServerConfig{}, false, fmt.Errorf(ErrorConfigEnv, setting.env(), err)
+			}
+		}
+	}
+	for _, setting := range configSettings {
+		if value, ok := flagValues[setting.name]; ok {
+			if err := setting.set(&config, value); err != nil {
+				return ServerConfig{}, false, fmt.Errorf(ErrorConfigFlag, setting.name, err)
+			}
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return ServerConfig{}, false, fmt.Errorf(ErrorConfigInvalid, err)
+	}
+	return config, *printConfig, nil
+}
+
+// loadConfigFile 將 JSON 設定檔解碼到 config 上，未出現的欄位維持原值
+func loadConfigFile(path string, config *ServerConfig) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// 解碼陣列時會沿用既有的元素，省略 channel 的角色會留下預設角色的頻道，因此先清空再解碼
+	roles := config.Roles
+	config.Roles = nil
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(config)
+	if config.Roles == nil {
+		config.Roles = roles
+	}
+	return err
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLoadServerConfig 測試設定檔、環境變數與命令列參數的優先順序與驗證
+func TestLoadServerConfig(t *testing.T) {
+	writeConfig := func(t *testing.T, content string) string {
+		path := filepath.Join(t.TempDir(), "config.json")
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	env := func(values map[string]string) func(string) string {
+		return func(key string) string { return values[key] }
+	}
+
+	t.Run("未指定時使用預設值", func(t *testing.T) {
+		config, printOnly, err := LoadServerConfig(nil, env(nil), io.Discard)
+		if err != nil || printOnly {
+			t.Fatalf("Expected defaults, got %v (printOnly=%v)", err, printOnly)
+		}
+		if config.Port != DefaultServerPort || config.HistoryLimit != DefaultHistoryLimit || len(config.Accounts) != len(DefaultTestAccounts) {
+			t.Errorf("Expected default config, got %+v", config)
+		}
+	})
+
+	t.Run("命令列參數優先於環境變數與設定檔", func(t *testing.T) {
+		path := writeConfig(t, `{"port": 9000, "historyLimit": 20, "readLimit": 2048}`)
+		config, _, err := LoadServerConfig(
+			[]string{"--port", "9002"},
+			env(map[string]string{ConfigFileEnv: path, "CHAT_PORT": "9001", "CHAT_HISTORY_LIMIT": "30"}),
+			io.Discard,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Port != 9002 || config.HistoryLimit != 30 || config.ReadLimit != 2048 {
+			t.Errorf("Expected flag > env > file precedence, got %+v", config)
+		}
+		if config.ClientSendBuffer != DefaultClientSendBuffer {
+			t.Errorf("Expected unset fields to keep defaults, got %d", config.ClientSendBuffer)
+		}
+	})
+
+	t.Run("帳號與布林參數", func(t *testing.T) {
+		config, printOnly, err := LoadServerConfig(
+			[]string{"--allow-all-origins=false", "--print-config"},
+			env(map[string]string{"CHAT_ACCOUNTS": "dave:secret:ops, erin:secret:ops"}),
+			io.Discard,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !printOnly || config.AllowAllOrigins {
+			t.Errorf("Expected print-config and disabled origins, got %v %+v", printOnly, config)
+		}
+		if len(config.Accounts) != 2 || config.Accounts[1] != (Account{Username: "erin", Password: "secret", Channel: "ops"}) {
+			t.Errorf("Expected accounts from environment, got %+v", config.Accounts)
+		}
+	})
+
+	t.Run("啟動時授予的角色", func(t *testing.T) {
+		config, _, err := LoadServerConfig(nil, env(nil), io.Discard)
+		if err != nil || len(config.Roles) != len(DefaultRoleGrants) {
+			t.Fatalf("Expected default roles, got %+v (%v)", config.Roles, err)
+		}
+
+		path := writeConfig(t, `{"roles": [{"username": "bob", "role": "admin"}]}`)
+		config, _, err = LoadServerConfig([]string{"--config", path}, env(nil), io.Discard)
+		if err != nil || len(config.Roles) != 1 || config.Roles[0] != (RoleSeed{Username: "bob", Role: RoleAdmin}) {
+			t.Fatalf("Expected roles from file, got %+v (%v)", config.Roles, err)
+		}
+
+		config, _, err = LoadServerConfig([]string{"--config", path}, env(map[string]string{"CHAT_ROLES": "charlie:admin, bob:moderator:tech"}), io.Discard)
+		if err != nil || len(config.Roles) != 2 || config.Roles[1] != (RoleSeed{Username: "bob", Role: RoleModerator, Channel: "tech"}) {
+			t.Fatalf("Expected roles from environment, got %+v (%v)", config.Roles, err)
+		}
+
+		roleStore = NewRoleStore(config.roleGrants())
+		defer func() { roleStore = NewRoleStore(DefaultRoleGrants) }()
+		if roleStore.Role(RoleScopeServer, "charlie") != RoleAdmin || roleStore.Role("tech", "bob") != RoleModerator {
+			t.Errorf("Expected seeded roles, got %+v", roleStore.List(RoleScopeServer))
+		}
+		if !authorize(&Account{Username: "charlie", Channel: "random"}, PermissionAdminister, "general") {
+			t.Error("Expected seeded server admin to administer")
+		}
+	})
+
+	t.Run("在線狀態寬限期", func(t *testing.T) {
+		config, _, err := LoadServerConfig(nil, env(map[string]string{"CHAT_PRESENCE_GRACE_PERIOD": "30"}), io.Discard)
+		if err != nil || config.PresenceGracePeriod != 30 {
+			t.Fatalf("Expected grace period from environment, got %d (%v)", config.PresenceGracePeriod, err)
+		}
+		if h := newHub(config); h.leaveGracePeriod != 30*time.Second {
+			t.Errorf("Expected hub to use the configured grace period, got %v", h.leaveGracePeriod)
+		}
+
+		config, _, err = LoadServerConfig([]string{"--presence-grace-period", "0"}, env(nil), io.Discard)
+		if err != nil || newHub(config).leaveGracePeriod != 0 {
+			t.Errorf("Expected zero grace period to be allowed, got %d (%v)", config.PresenceGracePeriod, err)
+		}
+	})
+
+	t.Run("在線狀態、檢舉、釘選與上傳設定", func(t *testing.T) {
+		path := writeConfig(t, `{"presenceAwayTimeout": 60, "reportHideThreshold": 0, "uploads": {"dir": "/data/uploads", "maxSize": 2048}}`)
+		config, _, err := LoadServerConfig(
+			[]string{"--config", path, "--pin-limit", "5", "--upload-chunk-max-size", "1024"},
+			env(map[string]string{"CHAT_CHUNKED_UPLOAD_MAX_SIZE": "4096"}),
+			io.Discard,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := UploadConfig{Dir: "/data/uploads", MaxSize: 2048, SessionDir: DefaultUploadSessionDir, ChunkedMaxSize: 4096, ChunkMaxSize: 1024}
+		if config.Uploads != want || config.PresenceAwayTimeout != 60 || config.PinLimit != 5 || config.ReportHideThreshold != 0 {
+			t.Errorf("Expected settings from all sources, got %+v", config)
+		}
+		if runtime := config.runtimeConfig(defaultRuntimeConfig()); runtime.ReportHideThreshold != 0 {
+			t.Errorf("Expected report hide threshold in runtime config, got %d", runtime.ReportHideThreshold)
+		}
+	})
+
+	t.Run("回報所有驗證錯誤", func(t *testing.T) {
+		_, _, err := LoadServerConfig([]string{
+			"--port", "0", "--history-limit", "5000", "--presence-grace-period", "-1", "--presence-away-timeout", "0",
+			"--report-hide-threshold", "-1", "--pin-limit", "0", "--upload-dir", "", "--upload-max-size", "0",
+			"--accounts", "dave:secret:ops,dave:other:ops",
+		}, env(nil), io.Discard)
+		if err == nil {
+			t.Fatal("Expected validation error")
+		}
+		for _, want := range []string{
+			"port must be between", "historyLimit must be between", "presenceGracePeriod must be between", "presenceAwayTimeout must be positive",
+			"reportHideThreshold must not be negative", "pinLimit must be positive", "uploads.dir is required", "uploads.maxSize must be positive",
+			`"dave" is defined more than once`,
+		} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("Expected error to mention %q, got %v", want, err)
+			}
+		}
+	})
+
+	t.Run("來源格式錯誤", func(t *testing.T) {
+		tests := []struct {
+			name string
+			args []string
+			env  map[string]string
+			want string
+		}{
+			{"未知的設定檔欄位", []string{"--config", writeConfig(t, `{"prot": 1}`)}, nil, `unknown field "prot"`},
+			{"設定檔不存在", []string{"--config", filepath.Join(t.TempDir(), "missing.json")}, nil, "missing.json"},
+			{"環境變數不是整數", nil, map[string]string{"CHAT_PORT": "http"}, "CHAT_PORT"},
+			{"帳號格式錯誤", []string{"--accounts", "dave:secret"}, nil, "username:password:channel"},
+			{"角色格式錯誤", []string{"--roles", "dave"}, nil, "username:role"},
+			{"未知的角色", []string{"--roles", "dave:superuser"}, nil, `got "superuser"`},
+			{"未知的參數", []string{"--prot", "1"}, nil, "prot"},
+		}
+		for _, tt := range tests {
+			if _, _, err := LoadServerConfig(tt.args, env(tt.env), io.Discard); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+			}
+		}
+	})
+}
+
+// TestOriginAllowed 測試 WebSocket 升級的 Origin 檢查
+func TestOriginAllowed(t *testing.T) {
+	restricted := ServerConfig{AllowedOrigins: []string{"http://localhost:3000"}}
+	tests := []struct {
+		name   string
+		config ServerConfig
+		origin string
+		want   bool
+	}{
+		{"允許所有 Origin", ServerConfig{AllowAllOrigins: true}, "http://evil.example", true},
+		{"沒有 Origin 的非瀏覽器客戶端", restricted, "", true},
+		{"同源", restricted, "http://CHAT.local:8080", true},
+		{"允許清單", restricted, "http://localhost:3000", true},
+		{"其他 Origin", restricted, "http://evil.example", false},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", "http://chat.local:8080/ws", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if got := originAllowed(tt.config, req); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
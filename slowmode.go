@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// 慢速模式相關錯誤
+var (
+	ErrInvalidSlowMode      = errors.New(ErrorInvalidSlowMode)
+	ErrSlowModeNotPermitted = errors.New(ErrorSlowModeNotPermitted)
+)
+
+// SlowModeTracker 記錄用戶在各頻道最後一則訊息的時間
+//
+// Design considerations:
+// - 間隔存放在頻道設定中，這裡只記錄時間，間隔變更時立即生效
+// - 只記錄開啟慢速模式的頻道，關閉時清除該頻道的紀錄
+type SlowModeTracker struct {
+	mu   sync.Mutex
+	last map[string]map[string]time.Time
+	now  func() time.Time
+}
+
+// NewSlowModeTracker 建立慢速模式紀錄
+//
+// Returns:
+// - *SlowModeTracker: 空的紀錄
+func NewSlowModeTracker() *SlowModeTracker {
+	return &SlowModeTracker{
+		last: make(map[string]map[string]time.Time),
+		now:  time.Now,
+	}
+}
+
+// Remaining 計算用戶還需要等待多久才能再發送訊息
+//
+// Parameters:
+// - channel: 頻道名稱
+// - username: 用戶名
+// - interval: 頻道的慢速模式間隔
+//
+// Returns:
+// - time.Duration: 剩餘等待時間，可以發送時為 0
+func (t *SlowModeTracker) Remaining(channel, username string, interval time.Duration) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	last, ok := t.last[channel][username]
+	if !ok {
+		return 0
+	}
+	if remaining := last.Add(interval).Sub(t.now()); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// Record 記錄用戶在頻道發送了訊息
+//
+// Parameters:
+// - channel: 頻道名稱
+// - username: 用戶名
+func (t *SlowModeTracker) Record(channel, username string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.last[channel] == nil {
+		t.last[channel] = make(map[string]time.Time)
+	}
+	t.last[channel][username] = t.now()
+}
+
+// Reset 清除頻道的所有紀錄
+//
+// Parameters:
+// - channel: 頻道名稱
+func (t *SlowModeTracker) Reset(channel string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.last, channel)
+}
+
+// slowModeExempt 檢查發送者是否不受慢速模式限制
+//
+// Design considerations:
+// - 只有已驗證且具有頻道管理權限的帳號不受限制
+// - 不以訊息的 User 欄位查詢帳號，避免未驗證的請求冒用管理員名稱
+//
+// Parameters:
+// - account: 已驗證的發送者，未驗證時為 nil
+// - channel: 頻道名稱
+func slowModeExempt(account *Account, channel string) bool {
+	return account != nil && authorize(account, PermissionManageChannel, channel)
+}
+
+// checkSlowMode 檢查訊息是否違反頻道的慢速模式
+//
+// Parameters:
+// - account: 已驗證的發送者，未驗證時為 nil
+// - msg: 要發送的訊息
+//
+// Returns:
+// - error: 需要等待時返回範圍為 slow_mode 的 *RateLimitError，RetryAfter 為剩餘等待時間
+func checkSlowMode(account *Account, msg Message) error {
+	interval := channelSettings.Get(msg.Channel).SlowMode
+	if interval == 0 || slowModeExempt(account, msg.Channel) {
+		return nil
+	}
+
+	remaining := slowMode.Remaining(msg.Channel, msg.User, time.Duration(interval)*time.Second)
+	if remaining == 0 {
+		return nil
+	}
+	err := &RateLimitError{Scope: RateLimitScopeSlowMode, RetryAfter: remaining}
+	metrics.Inc(MetricRateLimitedPrefix + err.Scope)
+	log.Printf(LogRateLimited, msg.User, err)
+	return err
+}
+
+// recordSlowMode 在訊息發送成功後記錄時間
+//
+// Parameters:
+// - account: 已驗證的發送者，未驗證時為 nil
+// - msg: 已發送的訊息
+func recordSlowMode(account *Account, msg Message) {
+	if channelSettings.Get(msg.Channel).SlowMode == 0 || slowModeExempt(account, msg.Channel) {
+		return
+	}
+	slowMode.Record(msg.Channel, msg.User)
+}
+
+// setSlowMode 由頻道管理員開啟、調整或關閉慢速模式
+//
+// Process flow:
+// 1. 檢查管理權限與間隔範圍
+// 2. 套用到頻道設定，關閉時清除發送紀錄
+// 3. 間隔有變更時發布系統訊息
+//
+// Parameters:
+// - account: 執行者
+// - channel: 頻道名稱
+// - seconds: 間隔秒數，0 表示關閉
+//
+// Returns:
+// - ChannelSettings: 更新後的頻道設定
+// - error: 無權限時返回 ErrSlowModeNotPermitted，間隔不合法時返回 ErrInvalidSlowMode
+func setSlowMode(account *Account, channel string, seconds int) (ChannelSettings, error) {
+	if !authorize(account, PermissionManageChannel, channel) {
+		return ChannelSettings{}, ErrSlowModeNotPermitted
+	}
+	update := ChannelSettingsUpdate{SlowMode: &seconds}
+	if err := update.Validate(); err != nil {
+		return ChannelSettings{}, err
+	}
+
+	before := channelSettings.Get(channel).SlowMode
+	settings := channelSettings.Apply(channel, update)
+	log.Printf(LogChannelSettingsUpdated, channel, settings)
+	announceSlowMode(account.Username, channel, before, settings.SlowMode)
+	return settings, nil
+}
+
+// announceSlowMode 慢速模式變更時發布系統訊息
+//
+// Parameters:
+// - username: 執行者
+// - channel: 頻道名稱
+// - before: 變更前的間隔
+// - after: 變更後的間隔
+func announceSlowMode(username, channel string, before, after int) {
+	if before == after {
+		return
+	}
+	if after == 0 {
+		slowMode.Reset(channel)
+		publishMessage(NewSystemMessage(fmt.Sprintf(SystemMessageSlowModeOffTemplate, username), channel))
+		return
+	}
+	publishMessage(NewSystemMessage(fmt.Sprintf(SystemMessageSlowModeOnTemplate, username, after), channel))
+}
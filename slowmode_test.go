@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSlowModeTracker 測試剩餘等待時間的計算
+func TestSlowModeTracker(t *testing.T) {
+	now := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	tracker := NewSlowModeTracker()
+	tracker.now = func() time.Time { return now }
+
+	if remaining := tracker.Remaining("tech", "bob", 30*time.Second); remaining != 0 {
+		t.Errorf("Expected no wait before first message, got %v", remaining)
+	}
+	tracker.Record("tech", "bob")
+
+	now = now.Add(10 * time.Second)
+	if remaining := tracker.Remaining("tech", "bob", 30*time.Second); remaining != 20*time.Second {
+		t.Errorf("Expected 20s remaining, got %v", remaining)
+	}
+	if remaining := tracker.Remaining("tech", "bob", 5*time.Second); remaining != 0 {
+		t.Errorf("Expected shorter interval to take effect immediately, got %v", remaining)
+	}
+
+	tracker.Reset("tech")
+	if remaining := tracker.Remaining("tech", "bob", 30*time.Second); remaining != 0 {
+		t.Errorf("Expected reset to clear records, got %v", remaining)
+	}
+}
+
+// TestSlowModeAPI 測試慢速模式的設定與發送限制
+func TestSlowModeAPI(t *testing.T) {
+	hub = *newTestHub(0)
+	messageStore = make(MessageStore)
+	messageIndex = NewSearchIndex()
+	channelSettings = NewChannelSettingsStore()
+	slowMode = NewSlowModeTracker()
+	roleStore = NewRoleStore(append([]RoleGrant{{Channel: "general", Username: "bob", Role: RoleMember}}, DefaultRoleGrants...))
+	defer func() { roleStore = NewRoleStore(DefaultRoleGrants) }()
+
+	putSlowMode := func(username string, seconds int) int {
+		req, _ := http.NewRequest("PUT", "/api/channels/general/settings", strings.NewReader(`{"slowMode":`+strconv.Itoa(seconds)+`}`))
+		req.SetBasicAuth(username, "password123")
+		rr := httptest.NewRecorder()
+		setupRoutes().ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	t.Run("只有管理員可以設定", func(t *testing.T) {
+		if code := putSlowMode("bob", 30); code != http.StatusForbidden {
+			t.Errorf("Expected 403 for non-moderator, got %d", code)
+		}
+		if code := putSlowMode("alice", 30); code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", code)
+		}
+		messages := messageStore.GetRecentMessages("general", 1)
+		if len(messages) != 1 || !messages[0].IsSystemMessage() || !strings.Contains(messages[0].Content, "30 秒") {
+			t.Errorf("Expected slow mode announcement, got %+v", messages)
+		}
+	})
+
+	t.Run("非管理員需要等待", func(t *testing.T) {
+		if rr := postCommand("bob", "general", "第一則"); rr.Code != http.StatusOK {
+			t.Fatalf("Expected first message to be sent, got %d", rr.Code)
+		}
+		rr := postCommand("bob", "general", "第二則")
+		var response map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		if rr.Code != http.StatusTooManyRequests || response["code"] != ErrorCodeSlowMode || response["retryAfter"] != float64(30) {
+			t.Errorf("Expected 429 slow_mode with 30s wait, got %d %v", rr.Code, response)
+		}
+	})
+
+	t.Run("管理員不受限制", func(t *testing.T) {
+		postCommand("alice", "general", "公告一")
+		if rr := postCommand("alice", "general", "公告二"); rr.Code != http.StatusOK {
+			t.Errorf("Expected moderator to be exempt, got %d", rr.Code)
+		}
+	})
+
+	t.Run("未驗證的請求不能冒用管理員", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/api/messages", strings.NewReader(`{"user":"alice","channel":"general","content":"冒用"}`))
+		rr := httptest.NewRecorder()
+		setupRoutes().ServeHTTP(rr, req)
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("Expected unauthenticated request as alice to be rejected, got %d", rr.Code)
+		}
+	})
+
+	t.Run("以指令關閉", func(t *testing.T) {
+		if rr := postCommand("alice", "general", "/slowmode off"); rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if interval := channelSettings.Get("general").SlowMode; interval != 0 {
+			t.Errorf("Expected slow mode to be off, got %d", interval)
+		}
+		if rr := postCommand("bob", "general", "第二則"); rr.Code != http.StatusOK {
+			t.Errorf("Expected bob to send after slow mode is off, got %d", rr.Code)
+		}
+	})
+}
+
+// TestChannelSettingsAuthorization 測試所有頻道設定欄位都需要頻道管理員
+func TestChannelSettingsAuthorization(t *testing.T) {
+	channelSettings = NewChannelSettingsStore()
+	defer func() { channelSettings = NewChannelSettingsStore() }()
+
+	put := func(username, body string) int {
+		req, _ := http.NewRequest("PUT", "/api/channels/general/settings", strings.NewReader(body))
+		if username != "" {
+			req.SetBasicAuth(username, "password123")
+		}
+		rr := httptest.NewRecorder()
+		setupRoutes().ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	for _, body := range []string{`{"topic":"被改掉了"}`, `{"messageTTL":60}`, `{"persistPresence":false}`, `{}`} {
+		if code := put("", body); code != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401 without credentials, got %d", body, code)
+		}
+		if code := put("bob", body); code != http.StatusForbidden {
+			t.Errorf("%s: expected 403 for non-moderator, got %d", body, code)
+		}
+	}
+	if settings := channelSettings.Get("general"); settings.Topic != "" || settings.MessageTTL != 0 || !settings.PersistPresence {
+		t.Errorf("Expected settings to be unchanged, got %+v", settings)
+	}
+
+	if code := put("alice", `{"topic":"每週會議","messageTTL":60}`); code != http.StatusOK {
+		t.Fatalf("Expected 200 for channel owner, got %d", code)
+	}
+	if settings := channelSettings.Get("general"); settings.Topic != "每週會議" || settings.MessageTTL != 60 {
+		t.Errorf("Expected settings to be updated, got %+v", settings)
+	}
+
+	get := func(username string) int {
+		req, _ := http.NewRequest("GET", "/api/channels/general/settings", nil)
+		if username != "" {
+			req.SetBasicAuth(username, "password123")
+		}
+		rr := httptest.NewRecorder()
+		setupRoutes().ServeHTTP(rr, req)
+		return rr.Code
+	}
+	for username, want := range map[string]int{"": http.StatusUnauthorized, "bob": http.StatusForbidden, "alice": http.StatusOK} {
+		if code := get(username); code != want {
+			t.Errorf("GET as %q: expected %d, got %d", username, want, code)
+		}
+	}
+}
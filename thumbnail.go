@@ -0,0 +1,348 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Thumbnail 代表圖片附件的縮圖
+//
+// Design considerations:
+// - MaxEdge 為產生時的長邊上限，同時作為下載路徑的識別
+// - Width/Height 為縮圖實際尺寸，保持原圖比例
+type Thumbnail struct {
+	MaxEdge int    `json:"maxEdge"` // 長邊上限
+	Width   int    `json:"width"`   // 縮圖寬度
+	Height  int    `json:"height"`  // 縮圖高度
+	MIME    string `json:"mime"`    // 縮圖 MIME 類型
+	URL     string `json:"url"`     // 下載位置
+}
+
+// thumbnailSourceMIME 可產生縮圖的原圖類型（標準函式庫可解碼）
+var thumbnailSourceMIME = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+}
+
+// generateThumbnails 為圖片附件產生縮圖與 BlurHash 預覽
+//
+// Responsible for:
+// - 解碼原圖並依 DefaultThumbnailSizes 產生各尺寸縮圖
+// - 將縮圖存放在原圖旁（同一內容定址目錄）
+// - 計算 BlurHash 預覽字串供客戶端在載入前顯示
+//
+// Design considerations:
+// - 只使用標準函式庫的 image 套件，支援 PNG、JPEG、GIF（第一幀）
+// - 原圖比縮圖尺寸小時不產生該尺寸
+// - PNG/GIF 輸出 PNG 以保留透明度，JPEG 輸出 JPEG
+// - 像素數超過上限的圖片略過，避免解碼佔用過多記憶體
+// - 相同內容的縮圖已存在時直接共用
+//
+// Parameters:
+// - attachment: 已保存的圖片附件，會被補上縮圖與預覽資訊
+//
+// Returns:
+// - error: 解碼或寫入失敗時的錯誤
+func (s *UploadStore) generateThumbnails(attachment *Attachment) error {
+	if !thumbnailSourceMIME[attachment.MIME] {
+		return nil
+	}
+	if attachment.Width*attachment.Height > DefaultThumbnailMaxPixels {
+		return nil
+	}
+
+	file, err := os.Open(s.blobPath(attachment.Checksum))
+	if err != nil {
+		return err
+	}
+	src, _, err := image.Decode(file)
+	file.Close()
+	if err != nil {
+		return err
+	}
+
+	rgba := toRGBA(src)
+	thumbMIME := "image/png"
+	if attachment.MIME == "image/jpeg" {
+		thumbMIME = "image/jpeg"
+	}
+
+	for _, maxEdge := range DefaultThumbnailSizes {
+		width, height := fitWithin(rgba.Bounds().Dx(), rgba.Bounds().Dy(), maxEdge)
+		if width >= rgba.Bounds().Dx() && height >= rgba.Bounds().Dy() {
+			continue
+		}
+
+		path := s.thumbnailPath(attachment.Checksum, maxEdge, thumbMIME)
+		if _, err := os.Stat(path); err != nil {
+			if err := writeThumbnail(path, resizeBox(rgba, width, height), thumbMIME); err != nil {
+				return err
+			}
+		}
+
+		attachment.Thumbnails = append(attachment.Thumbnails, Thumbnail{
+			MaxEdge: maxEdge,
+			Width:   width,
+			Height:  height,
+			MIME:    thumbMIME,
+			URL:     fmt.Sprintf("%s/thumbnails/%d", attachment.URL, maxEdge),
+		})
+	}
+
+	// BlurHash 在極小的縮圖上計算即可
+	pw, ph := fitWithin(rgba.Bounds().Dx(), rgba.Bounds().Dy(), 32)
+	attachment.Placeholder = encodeBlurHash(resizeBox(rgba, pw, ph), DefaultBlurHashComponentsX, DefaultBlurHashComponentsY)
+	return nil
+}
+
+// OpenThumbnail 開啟附件指定尺寸的縮圖
+//
+// Parameters:
+// - id: 上傳識別碼
+// - maxEdge: 縮圖長邊上限
+//
+// Returns:
+// - *os.File: 縮圖內容，呼叫者負責關閉
+// - Thumbnail: 縮圖資訊
+// - Attachment: 原圖中繼資料
+// - error: 找不到時返回 ErrUploadNotFound
+func (s *UploadStore) OpenThumbnail(id string, maxEdge int) (*os.File, Thumbnail, Attachment, error) {
+	attachment, ok := s.Get(id)
+	if !ok {
+		return nil, Thumbnail{}, Attachment{}, ErrUploadNotFound
+	}
+
+	for _, thumbnail := range attachment.Thumbnails {
+		if thumbnail.MaxEdge != maxEdge {
+			continue
+		}
+		file, err := os.Open(s.thumbnailPath(attachment.Checksum, maxEdge, thumbnail.MIME))
+		if err != nil {
+			return nil, Thumbnail{}, Attachment{}, err
+		}
+		return file, thumbnail, attachment, nil
+	}
+	return nil, Thumbnail{}, Attachment{}, ErrUploadNotFound
+}
+
+// thumbnailPath 返回縮圖在原圖旁的存放路徑
+func (s *UploadStore) thumbnailPath(checksum string, maxEdge int, mimeType string) string {
+	ext := ".png"
+	if mimeType == "image/jpeg" {
+		ext = ".jpg"
+	}
+	return fmt.Sprintf("%s.thumb%d%s", s.blobPath(checksum), maxEdge, ext)
+}
+
+// writeThumbnail 將縮圖編碼後寫入檔案
+func writeThumbnail(path string, img image.Image, mimeType string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "thumb-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if mimeType == "image/jpeg" {
+		err = jpeg.Encode(tmp, img, &jpeg.Options{Quality: DefaultThumbnailJPEGQuality})
+	} else {
+		err = png.Encode(tmp, img)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// fitWithin 計算在長邊上限內保持比例的尺寸
+//
+// Parameters:
+// - width, height: 原始尺寸
+// - maxEdge: 長邊上限
+//
+// Returns:
+// - int, int: 縮放後的寬高（至少為 1）
+func fitWithin(width, height, maxEdge int) (int, int) {
+	if width <= maxEdge && height <= maxEdge {
+		return width, height
+	}
+	if width >= height {
+		return maxEdge, max(1, height*maxEdge/width)
+	}
+	return max(1, width*maxEdge/height), maxEdge
+}
+
+// toRGBA 將任意圖片轉換為從原點開始的 RGBA 圖片
+func toRGBA(src image.Image) *image.RGBA {
+	bounds := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+	return rgba
+}
+
+// resizeBox 以區域平均（box filter）縮小圖片
+//
+// Design considerations:
+// - 每個目標像素取對應來源區域的平均值，縮小時比最近鄰取樣平滑
+// - 只用於縮小，放大時等同最近鄰取樣
+//
+// Parameters:
+// - src: 來源圖片
+// - width, height: 目標尺寸
+//
+// Returns:
+// - *image.RGBA: 縮小後的圖片
+func resizeBox(src *image.RGBA, width, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+
+	for y := 0; y < height; y++ {
+		y0 := y * sh / height
+		y1 := max(y0+1, (y+1)*sh/height)
+		for x := 0; x < width; x++ {
+			x0 := x * sw / width
+			x1 := max(x0+1, (x+1)*sw/width)
+
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				offset := src.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += int(src.Pix[offset])
+					g += int(src.Pix[offset+1])
+					b += int(src.Pix[offset+2])
+					a += int(src.Pix[offset+3])
+					offset += 4
+					n++
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// blurHashCharacters BlurHash 使用的 base83 字元表
+const blurHashCharacters = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// encodeBlurHash 計算圖片的 BlurHash 字串
+//
+// Responsible for:
+// - 以 DCT 係數描述圖片的大致色彩分佈
+// - 輸出可由 Flutter flutter_blurhash 等套件解碼的字串
+//
+// Design considerations:
+// - 依照 BlurHash 規格實作，色彩在線性空間中計算
+// - 輸入應為已縮小的圖片，計算量與像素數成正比
+//
+// Parameters:
+// - img: 來源圖片
+// - componentsX, componentsY: 水平與垂直的係數數量（1-9）
+//
+// Returns:
+// - string: BlurHash 字串
+func encodeBlurHash(img *image.RGBA, componentsX, componentsY int) string {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	factors := make([][3]float64, 0, componentsX*componentsY)
+
+	for j := 0; j < componentsY; j++ {
+		for i := 0; i < componentsX; i++ {
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1.0
+			}
+
+			var r, g, b float64
+			for y := 0; y < height; y++ {
+				for x := 0; x < width; x++ {
+					basis := math.Cos(math.Pi*float64(i)*float64(x)/float64(width)) *
+						math.Cos(math.Pi*float64(j)*float64(y)/float64(height))
+					offset := img.PixOffset(x, y)
+					r += basis * sRGBToLinear(img.Pix[offset])
+					g += basis * sRGBToLinear(img.Pix[offset+1])
+					b += basis * sRGBToLinear(img.Pix[offset+2])
+				}
+			}
+
+			scale := normalisation / float64(width*height)
+			factors = append(factors, [3]float64{r * scale, g * scale, b * scale})
+		}
+	}
+
+	var hash strings.Builder
+	hash.WriteString(encodeBase83((componentsX-1)+(componentsY-1)*9, 1))
+
+	maximumValue := 1.0
+	if len(factors) > 1 {
+		actualMaximum := 0.0
+		for _, factor := range factors[1:] {
+			for _, component := range factor {
+				actualMaximum = math.Max(actualMaximum, math.Abs(component))
+			}
+		}
+		quantisedMaximum := int(math.Max(0, math.Min(82, math.Floor(actualMaximum*166-0.5))))
+		maximumValue = float64(quantisedMaximum+1) / 166
+		hash.WriteString(encodeBase83(quantisedMaximum, 1))
+	} else {
+		hash.WriteString(encodeBase83(0, 1))
+	}
+
+	dc := factors[0]
+	hash.WriteString(encodeBase83(linearToSRGB(dc[0])<<16+linearToSRGB(dc[1])<<8+linearToSRGB(dc[2]), 4))
+
+	for _, factor := range factors[1:] {
+		quantise := func(value float64) int {
+			return int(math.Max(0, math.Min(18, math.Floor(signPow(value/maximumValue, 0.5)*9+9.5))))
+		}
+		hash.WriteString(encodeBase83(quantise(factor[0])*19*19+quantise(factor[1])*19+quantise(factor[2]), 2))
+	}
+
+	return hash.String()
+}
+
+// encodeBase83 將數值編碼為指定長度的 base83 字串
+func encodeBase83(value, length int) string {
+	result := make([]byte, length)
+	for i := 1; i <= length; i++ {
+		digit := (value / int(math.Pow(83, float64(length-i)))) % 83
+		result[i-1] = blurHashCharacters[digit]
+	}
+	return string(result)
+}
+
+// sRGBToLinear 將 sRGB 值（0-255）轉換為線性值（0-1）
+func sRGBToLinear(value uint8) float64 {
+	v := float64(value) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// linearToSRGB 將線性值（0-1）轉換為 sRGB 值（0-255）
+func linearToSRGB(value float64) int {
+	v := math.Max(0, math.Min(1, value))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+// signPow 保留正負號的次方運算
+func signPow(value, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(value), exp), value)
+}
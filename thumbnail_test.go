@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestFitWithin 測試保持比例的尺寸計算
+func TestFitWithin(t *testing.T) {
+	tests := []struct {
+		width, height, maxEdge int
+		wantW, wantH           int
+	}{
+		{1000, 500, 160, 160, 80},
+		{500, 1000, 160, 80, 160},
+		{100, 50, 160, 100, 50},
+		{4000, 1, 160, 160, 1},
+	}
+
+	for _, test := range tests {
+		w, h := fitWithin(test.width, test.height, test.maxEdge)
+		if w != test.wantW || h != test.wantH {
+			t.Errorf("fitWithin(%d, %d, %d) = %dx%d, expected %dx%d",
+				test.width, test.height, test.maxEdge, w, h, test.wantW, test.wantH)
+		}
+	}
+}
+
+// TestEncodeBlurHash 測試 BlurHash 編碼
+func TestEncodeBlurHash(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 8; y++ {
+			img.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+
+	hash := encodeBlurHash(img, 4, 3)
+
+	// 1 字元尺寸旗標 + 1 字元最大值 + 4 字元 DC + 11 個 AC 各 2 字元
+	if len(hash) != 28 {
+		t.Fatalf("Expected 28 characters, got %d (%s)", len(hash), hash)
+	}
+	if hash[0] != 'L' {
+		t.Errorf("Expected size flag 'L' for 4x3 components, got '%c'", hash[0])
+	}
+	// 純紅色的 DC 值為 0xFF0000
+	if dc := hash[2:6]; dc != "TI:j" {
+		t.Errorf("Expected DC 'TI:j', got '%s'", dc)
+	}
+}
+
+// TestUploadGeneratesThumbnails 測試上傳圖片時產生縮圖
+func TestUploadGeneratesThumbnails(t *testing.T) {
+	uploadStore = NewUploadStore(t.TempDir(), 10<<20, DefaultUploadAllowedMIMETypes)
+
+	attachment, err := uploadStore.Save("wide.png", bytes.NewReader(encodeTestPNG(t, 600, 300)), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(attachment.Thumbnails) != 2 {
+		t.Fatalf("Expected 2 thumbnails, got %d", len(attachment.Thumbnails))
+	}
+	small := attachment.Thumbnails[0]
+	if small.Width != 160 || small.Height != 80 {
+		t.Errorf("Expected 160x80 thumbnail, got %dx%d", small.Width, small.Height)
+	}
+	if attachment.Placeholder == "" {
+		t.Error("Expected a BlurHash placeholder")
+	}
+
+	req, _ := http.NewRequest("GET", small.URL, nil)
+	req.SetBasicAuth("alice", "password123")
+	rr := httptest.NewRecorder()
+	setupRoutes().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+
+	decoded, _, err := image.Decode(rr.Body)
+	if err != nil {
+		t.Fatalf("Failed to decode thumbnail: %v", err)
+	}
+	if decoded.Bounds().Dx() != 160 {
+		t.Errorf("Expected decoded width 160, got %d", decoded.Bounds().Dx())
+	}
+
+	// 小圖不產生比原圖大的縮圖
+	tiny, _ := uploadStore.Save("tiny.png", bytes.NewReader(encodeTestPNG(t, 100, 100)), "alice")
+	if len(tiny.Thumbnails) != 0 {
+		t.Errorf("Expected no thumbnails for small image, got %d", len(tiny.Thumbnails))
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// newHTTPServer 依設定建立 HTTP 或 HTTPS 伺服器
+//
+// Responsible for:
+// - 建立監聽設定埠的 http.Server
+// - 啟用 TLS 時載入憑證檔或自簽開發憑證
+//
+// Design considerations:
+// - 在顯示啟動資訊前呼叫，憑證錯誤時直接結束，不會顯示錯誤的 https 網址
+// - HTTPS 時透過 ALPN 提供 HTTP/2 給 REST API
+// - WebSocket 升級需要 HTTP/1.1；Go 預設不啟用 HTTP/2 的 extended CONNECT，瀏覽器與 App 會另外以 HTTP/1.1 建立 WebSocket 連接
+// - TLS 設定只在啟動時生效，重新載入設定時只記錄需要重新啟動
+//
+// Parameters:
+// - config: 伺服器設定
+// - handler: 已加上 CORS 處理的路由器
+//
+// Returns:
+// - *http.Server: 尚未開始監聽的伺服器，TLSConfig 為 nil 表示使用 HTTP
+// - error: 憑證無法載入或產生時返回錯誤
+func newHTTPServer(config ServerConfig, handler http.Handler) (*http.Server, error) {
+	server := &http.Server{Addr: fmt.Sprintf(":%d", config.Port), Handler: handler}
+	if !config.TLS.enabled() {
+		return server, nil
+	}
+
+	var certificate tls.Certificate
+	var err error
+	if config.TLS.Dev {
+		certificate, err = devCertificate(config.TLS.DevCertDir, tlsHosts(config), time.Now())
+	} else {
+		certificate, err = tls.LoadX509KeyPair(config.TLS.CertFile, config.TLS.KeyFile)
+		if err != nil {
+			err = fmt.Errorf(ErrorTLSCertificate, err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	server.TLSConfig = &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	return server, nil
+}
+
+// listenAndServe 開始監聽，啟用 TLS 且設定轉址埠時另外啟動 HTTP 轉址監聽
+//
+// Parameters:
+// - server: newHTTPServer 建立的伺服器
+// - config: 伺服器設定
+//
+// Returns:
+// - error: 監聽失敗的原因
+func listenAndServe(server *http.Server, config ServerConfig) error {
+	if server.TLSConfig == nil {
+		return server.ListenAndServe()
+	}
+	if config.TLS.RedirectPort != 0 {
+		go func() {
+			log.Printf(LogTLSRedirectListening, config.TLS.RedirectPort, config.Port)
+			log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", config.TLS.RedirectPort), httpsRedirectHandler(config.Port)))
+		}()
+	}
+	return server.ListenAndServeTLS("", "")
+}
+
+// httpsRedirectHandler 將 HTTP 請求轉址到相同主機與路徑的 HTTPS 網址
+//
+// Design considerations:
+// - GET 與 HEAD 使用 301；其他方法使用 308，客戶端會以原本的方法與內容重送
+// - HTTPS 埠為 443 時網址省略埠號
+//
+// Parameters:
+// - httpsPort: HTTPS 監聽埠
+//
+// Returns:
+// - http.Handler: 轉址處理器
+func httpsRedirectHandler(httpsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.Trim(host, "[]")
+		if httpsPort != DefaultHTTPSPort {
+			host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+
+		status := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			status = http.StatusMovedPermanently
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), status)
+	})
+}
+
+// tlsHosts 返回自簽開發憑證要涵蓋的主機名稱與 IP
+//
+// Returns:
+// - []string: localhost、設定的主機名稱、迴路位址與啟動資訊顯示的內網 IP，已去除重複
+func tlsHosts(config ServerConfig) []string {
+	var hosts []string
+	for _, host := range []string{"localhost", config.Host, "127.0.0.1", "::1", lanIP()} {
+		if host != "" && !containsFold(hosts, host) {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// lanIP 返回本機的內網 IPv4 位址，供啟動資訊與自簽開發憑證使用
+//
+// Design considerations:
+// - 優先使用私有網段位址（手機通常與電腦在同一個區域網路）
+// - 找不到時返回空字串
+func lanIP() string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return ""
+	}
+	fallback := ""
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip := ipNet.IP.To4()
+		if ip == nil || !ip.IsGlobalUnicast() {
+			continue
+		}
+		if ip.IsPrivate() {
+			return ip.String()
+		}
+		if fallback == "" {
+			fallback = ip.String()
+		}
+	}
+	return fallback
+}
+
+// devCertificate 載入快取的自簽開發憑證，不存在、即將到期或未涵蓋所有主機時重新產生
+//
+// Design considerations:
+// - 快取在 dir 中，重新啟動時沿用相同憑證，手機只需要信任一次
+// - 內網 IP 改變（例如換了 Wi-Fi）時會重新產生，需要重新信任
+// - 憑證同時是 CA，可以安裝到 iOS 與 Android 的信任憑證中；名稱限制只允許涵蓋的主機，私鑰外洩時也不能簽發其他網站的憑證
+// - 日誌顯示 SHA-256 指紋，方便在手機上確認
+//
+// Parameters:
+// - dir: 快取目錄
+// - hosts: 憑證要涵蓋的主機名稱與 IP
+// - now: 目前時間
+//
+// Returns:
+// - tls.Certificate: 可以使用的憑證
+// - error: 無法寫入快取目錄或產生金鑰時返回錯誤
+func devCertificate(dir string, hosts []string, now time.Time) (tls.Certificate, error) {
+	certPath := filepath.Join(dir, DefaultTLSDevCertFile)
+	keyPath := filepath.Join(dir, DefaultTLSDevKeyFile)
+
+	if certificate, err := tls.LoadX509KeyPair(certPath, keyPath); err == nil && devCertificateUsable(certificate.Leaf, hosts, now) {
+		log.Printf(LogTLSDevCertLoaded, certPath, strings.Join(hosts, ", "), certificateFingerprint(certificate.Leaf))
+		return certificate, nil
+	}
+
+	certPEM, keyPEM, err := generateDevCertificate(hosts, now)
+	if err == nil {
+		err = os.MkdirAll(dir, 0700)
+	}
+	if err == nil {
+		err = os.WriteFile(keyPath, keyPEM, 0600)
+	}
+	if err == nil {
+		err = os.WriteFile(certPath, certPEM, 0644)
+	}
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf(ErrorTLSDevCertificate, dir, err)
+	}
+
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf(ErrorTLSDevCertificate, dir, err)
+	}
+	log.Printf(LogTLSDevCertGenerated, certPath, strings.Join(hosts, ", "), certificateFingerprint(certificate.Leaf))
+	return certificate, nil
+}
+
+// devCertificateUsable 檢查快取的憑證是否還能使用，沒有名稱限制的舊憑證需要重新產生
+func devCertificateUsable(leaf *x509.Certificate, hosts []string, now time.Time) bool {
+	if leaf == nil || !leaf.PermittedDNSDomainsCritical || now.Before(leaf.NotBefore) || now.AddDate(0, 0, DefaultTLSDevCertRenewAhead).After(leaf.NotAfter) {
+		return false
+	}
+	for _, host := range hosts {
+		if leaf.VerifyHostname(host) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// generateDevCertificate 產生涵蓋指定主機的自簽憑證
+//
+// Design considerations:
+// - 憑證是 CA，以標記為 critical 的名稱限制（PermittedDNSDomains 與 PermittedIPRanges）限定為 hosts，信任後只對這些主機有效
+//
+// Parameters:
+// - hosts: 主機名稱或 IP，IP 放在 IP SAN，其他放在 DNS SAN
+// - now: 憑證生效時間
+//
+// Returns:
+// - []byte: PEM 格式的憑證
+// - []byte: PEM 格式的 PKCS #8 私鑰
+// - error: 產生失敗時返回錯誤
+func generateDevCertificate(hosts []string, now time.Time) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Flutter Chat Server"}, CommonName: "Flutter Chat Server development certificate"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(0, 0, DefaultTLSDevCertValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+
+		PermittedDNSDomainsCritical: true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			template.PermittedIPRanges = append(template.PermittedIPRanges, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+			template.PermittedDNSDomains = append(template.PermittedDNSDomains, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// certificateFingerprint 返回憑證的 SHA-256 指紋，格式為以冒號分隔的十六進位
+func certificateFingerprint(leaf *x509.Certificate) string {
+	sum := sha256.Sum256(leaf.Raw)
+	return strings.ReplaceAll(fmt.Sprintf("% X", sum[:]), " ", ":")
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDevCertificate 測試自簽開發憑證的產生與快取
+func TestDevCertificate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "certs")
+	now := time.Now()
+	hosts := []string{"localhost", "127.0.0.1", "192.168.1.20"}
+
+	first, err := devCertificate(dir, hosts, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, host := range hosts {
+		if err := first.Leaf.VerifyHostname(host); err != nil {
+			t.Errorf("Expected certificate to cover %s: %v", host, err)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(dir, DefaultTLSDevKeyFile)); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected private key with mode 0600, got %v (%v)", info, err)
+	}
+
+	t.Run("名稱限制只允許涵蓋的主機", func(t *testing.T) {
+		leaf := first.Leaf
+		if !leaf.IsCA || !leaf.PermittedDNSDomainsCritical || len(leaf.PermittedDNSDomains) != 1 || leaf.PermittedDNSDomains[0] != "localhost" || len(leaf.PermittedIPRanges) != 2 {
+			t.Fatalf("Expected critical name constraints for %v, got %v %v", hosts, leaf.PermittedDNSDomains, leaf.PermittedIPRanges)
+		}
+		if ones, bits := leaf.PermittedIPRanges[0].Mask.Size(); ones != 32 || bits != 32 {
+			t.Errorf("Expected single-address IP range, got /%d of %d", ones, bits)
+		}
+
+		roots := x509.NewCertPool()
+		roots.AddCert(leaf)
+		if _, err := leaf.Verify(x509.VerifyOptions{Roots: roots, DNSName: "localhost"}); err != nil {
+			t.Errorf("Expected certificate to verify for localhost, got %v", err)
+		}
+
+		// 以開發 CA 的私鑰簽發其他網站的憑證時，名稱限制使驗證失敗
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(2),
+			DNSNames:     []string{"bank.example"},
+			NotBefore:    now.Add(-time.Hour),
+			NotAfter:     now.Add(time.Hour),
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		der, err := x509.CreateCertificate(rand.Reader, template, leaf, &key.PublicKey, first.PrivateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		forged, _ := x509.ParseCertificate(der)
+		if _, err := forged.Verify(x509.VerifyOptions{Roots: roots, DNSName: "bank.example"}); err == nil {
+			t.Error("Expected certificate for another host to be rejected")
+		}
+	})
+
+	t.Run("沿用快取的憑證", func(t *testing.T) {
+		cached, err := devCertificate(dir, hosts[:2], now)
+		if err != nil || certificateFingerprint(cached.Leaf) != certificateFingerprint(first.Leaf) {
+			t.Errorf("Expected cached certificate, got %v", err)
+		}
+	})
+
+	t.Run("內網 IP 改變時重新產生", func(t *testing.T) {
+		changed, err := devCertificate(dir, []string{"localhost", "10.0.0.5"}, now)
+		if err != nil || certificateFingerprint(changed.Leaf) == certificateFingerprint(first.Leaf) {
+			t.Fatalf("Expected new certificate, got %v", err)
+		}
+		if err := changed.Leaf.VerifyHostname("10.0.0.5"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("即將到期時重新產生", func(t *testing.T) {
+		current, _ := devCertificate(dir, hosts, now)
+		later := now.AddDate(0, 0, DefaultTLSDevCertValidity-DefaultTLSDevCertRenewAhead+1)
+		renewed, err := devCertificate(dir, hosts, later)
+		if err != nil || certificateFingerprint(renewed.Leaf) == certificateFingerprint(current.Leaf) {
+			t.Errorf("Expected renewed certificate, got %v", err)
+		}
+	})
+}
+
+// TestHTTPSServer 測試 HTTPS 伺服器、HTTP/2 與轉址
+func TestHTTPSServer(t *testing.T) {
+	config := defaultServerConfig()
+	config.TLS.Dev = true
+	config.TLS.DevCertDir = t.TempDir()
+	server, err := newHTTPServer(config, setupRoutes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("REST API 使用 HTTP/2", func(t *testing.T) {
+		ts := httptest.NewUnstartedServer(server.Handler)
+		ts.TLS = server.TLSConfig
+		ts.EnableHTTP2 = true
+		ts.StartTLS()
+		defer ts.Close()
+
+		pool := x509.NewCertPool()
+		pool.AddCert(server.TLSConfig.Certificates[0].Leaf)
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: pool, ServerName: "localhost"},
+			ForceAttemptHTTP2: true,
+		}}
+		resp, err := client.Get(ts.URL + "/api/metrics")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body)
+		if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 2 {
+			t.Errorf("Expected 200 over HTTP/2, got %d %s", resp.StatusCode, resp.Proto)
+		}
+	})
+
+	t.Run("HTTP 轉址到 HTTPS", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			method   string
+			target   string
+			port     int
+			status   int
+			location string
+		}{
+			{"GET 使用 301", "GET", "http://192.168.1.20:8081/api/messages?channel=general", 8443, http.StatusMovedPermanently, "https://192.168.1.20:8443/api/messages?channel=general"},
+			{"POST 使用 308", "POST", "http://chat.local/api/messages", 8443, http.StatusPermanentRedirect, "https://chat.local:8443/api/messages"},
+			{"預設埠省略", "GET", "http://chat.local:80/", DefaultHTTPSPort, http.StatusMovedPermanently, "https://chat.local/"},
+		}
+		for _, tt := range tests {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			rr := httptest.NewRecorder()
+			httpsRedirectHandler(tt.port).ServeHTTP(rr, req)
+			if rr.Code != tt.status || rr.Header().Get("Location") != tt.location {
+				t.Errorf("%s: expected %d %s, got %d %s", tt.name, tt.status, tt.location, rr.Code, rr.Header().Get("Location"))
+			}
+		}
+	})
+
+	t.Run("憑證檔不存在", func(t *testing.T) {
+		config := defaultServerConfig()
+		config.TLS.CertFile = filepath.Join(t.TempDir(), "missing.pem")
+		config.TLS.KeyFile = config.TLS.CertFile
+		if _, err := newHTTPServer(config, setupRoutes()); err == nil || !strings.Contains(err.Error(), "missing.pem") {
+			t.Errorf("Expected certificate error, got %v", err)
+		}
+	})
+}
+
+// TestTLSConfigValidate 測試 TLS 設定的驗證
+func TestTLSConfigValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		tls  TLSConfig
+		want string
+	}{
+		{"只有憑證", TLSConfig{CertFile: "cert.pem"}, ErrorConfigTLSPair},
+		{"憑證檔與開發憑證", TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem", Dev: true, DevCertDir: "certs"}, ErrorConfigTLSConflict},
+		{"未啟用 TLS 的轉址", TLSConfig{RedirectPort: 8081}, ErrorConfigTLSRedirect},
+		{"轉址埠與監聽埠相同", TLSConfig{Dev: true, DevCertDir: "certs", RedirectPort: DefaultServerPort}, "must differ from port"},
+	}
+	for _, tt := range tests {
+		config := defaultServerConfig()
+		config.TLS = tt.tls
+		if err := config.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	config, _, err := LoadServerConfig([]string{"--tls-dev", "--tls-redirect-port", "8081"}, func(string) string { return "" }, io.Discard)
+	if err != nil || !config.TLS.Dev || config.TLS.RedirectPort != 8081 || config.TLS.DevCertDir != DefaultTLSDevCertDir {
+		t.Errorf("Expected TLS flags to apply, got %+v (%v)", config.TLS, err)
+	}
+}
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 上傳相關錯誤
+var (
+	ErrUploadEmpty          = errors.New(ErrorUploadEmpty)
+	ErrUploadTooLarge       = errors.New(ErrorUploadTooLarge)
+	ErrUploadTypeNotAllowed = errors.New(ErrorUploadTypeNotAllowed)
+	ErrUploadNotFound       = errors.New(ErrorUploadNotFound)
+	ErrAttachmentRequired   = errors.New(ErrorAttachmentRequired)
+	ErrAttachmentNotImage   = errors.New(ErrorAttachmentNotImage)
+)
+
+// Attachment 代表已上傳檔案的中繼資料
+//
+// Responsible for:
+// - 描述上傳檔案的名稱、大小、類型和下載位置
+// - 圖片檔案額外提供寬高、縮圖和 BlurHash 預覽
+//
+// Design considerations:
+// - 檔案內容以 SHA-256 做內容定址存儲，Checksum 同時作為存儲鍵
+// - ID 與內容無關，同一內容多次上傳會有不同的中繼資料但共用同一份檔案
+// - 伺服器以自身記錄為準，客戶端在訊息中只需提供 ID
+//
+// Usage context:
+// - POST /api/uploads 的回應內容
+// - image/file 類型訊息的 attachment 欄位
+type Attachment struct {
+	ID         string    `json:"id"`               // 上傳識別碼
+	Name       string    `json:"name"`             // 原始檔名
+	Size       int64     `json:"size"`             // 檔案大小（位元組）
+	MIME       string    `json:"mime"`             // 偵測到的 MIME 類型
+	URL        string    `json:"url"`              // 下載位置
+	Width      int       `json:"width,omitempty"`  // 圖片寬度
+	Height     int       `json:"height,omitempty"` // 圖片高度
+	Checksum   string    `json:"checksum"`         // SHA-256 雜湊值
+	Uploader   string    `json:"uploader"`         // 上傳者
+	UploadedAt time.Time `json:"uploadedAt"`       // 上傳時間
+
+	Thumbnails  []Thumbnail `json:"thumbnails,omitempty"`  // 圖片縮圖
+	Placeholder string      `json:"placeholder,omitempty"` // BlurHash 預覽字串
+}
+
+// IsImage 檢查附件是否為圖片
+//
+// Returns:
+// - bool: true 如果 MIME 類型為 image/*
+func (a Attachment) IsImage() bool {
+	return strings.HasPrefix(a.MIME, "image/")
+}
+
+// UploadStore 管理上傳檔案的本地磁碟存儲
+//
+// Responsible for:
+// - 驗證上傳檔案的大小和 MIME 類型
+// - 以內容雜湊存放檔案並保存中繼資料
+// - 提供依 ID 查詢和讀取檔案
+//
+// Design considerations:
+// - 目錄結構：blobs/ 放檔案內容、meta/ 放中繼資料 JSON、tmp/ 放上傳中的暫存檔
+// - MIME 類型由檔案內容偵測，不信任客戶端提供的 Content-Type
+// - 中繼資料同時保存在記憶體和磁碟，重啟後可透過 Load 恢復
+//
+// Usage context:
+// - 全域 uploadStore 實例供上傳 API 與訊息驗證使用
+type UploadStore struct {
+	mu          sync.RWMutex
+	dir         string
+	maxSize     int64
+	allowedMIME map[string]bool
+	attachments map[string]Attachment
+}
+
+// NewUploadStore 建立新的上傳存儲
+//
+// Parameters:
+// - dir: 存儲根目錄
+// - maxSize: 單一檔案大小上限（位元組）
+// - allowedMIME: 允許的 MIME 類型列表
+//
+// Returns:
+// - *UploadStore: 初始化完成的上傳存儲
+func NewUploadStore(dir string, maxSize int64, allowedMIME []string) *UploadStore {
+	allowed := make(map[string]bool, len(allowedMIME))
+	for _, mimeType := range allowedMIME {
+		allowed[mimeType] = true
+	}
+
+	return &UploadStore{
+		dir:         dir,
+		maxSize:     maxSize,
+		allowedMIME: allowed,
+		attachments: make(map[string]Attachment),
+	}
+}
+
+// Load 從磁碟載入先前保存的中繼資料
+//
+// Returns:
+// - error: 讀取目錄失敗時的錯誤，目錄不存在時不視為錯誤
+func (s *UploadStore) Load() error {
+	entries, err := os.ReadDir(filepath.Join(s.dir, "meta"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(s.dir, "meta", entry.Name()))
+		if err != nil {
+			log.Printf(LogUploadMetadataError, entry.Name(), err)
+			continue
+		}
+
+		var attachment Attachment
+		if err := json.Unmarshal(data, &attachment); err != nil {
+			log.Printf(LogUploadMetadataError, entry.Name(), err)
+			continue
+		}
+		s.attachments[attachment.ID] = attachment
+	}
+	return nil
+}
+
+// Save 驗證並保存上傳檔案
+//
+// Responsible for:
+// - 偵測 MIME 類型並檢查是否在允許清單中
+// - 寫入暫存檔的同時計算 SHA-256 並檢查大小上限
+// - 將暫存檔移至內容定址路徑並保存中繼資料
+//
+// Process flow:
+// 1. 讀取檔案開頭偵測 MIME 類型
+// 2. 寫入暫存檔並計算雜湊
+// 3. 超過大小上限時刪除暫存檔並返回錯誤
+// 4. 移動到 blobs/ 下的雜湊路徑（內容已存在時直接共用）
+// 5. 圖片檔案讀取寬高並產生縮圖
+// 6. 保存中繼資料
+//
+// Parameters:
+// - name: 原始檔名
+// - r: 檔案內容
+// - uploader: 上傳者用戶名
+//
+// Returns:
+// - Attachment: 上傳檔案的中繼資料
+// - error: 驗證或寫入失敗時的錯誤
+func (s *UploadStore) Save(name string, r io.Reader, uploader string) (Attachment, error) {
+	return s.saveWithLimit(name, r, uploader, s.maxSize)
+}
+
+// saveWithLimit 以指定的大小上限保存上傳檔案
+//
+// Design considerations:
+// - 分段上傳的檔案上限與單次上傳不同，其餘驗證流程共用
+func (s *UploadStore) saveWithLimit(name string, r io.Reader, uploader string, maxSize int64) (Attachment, error) {
+	br := bufio.NewReaderSize(r, 512)
+	head, _ := br.Peek(512)
+	if len(head) == 0 {
+		return Attachment{}, ErrUploadEmpty
+	}
+
+	mimeType := detectMIMEType(head)
+	if !s.allowedMIME[mimeType] {
+		return Attachment{}, ErrUploadTypeNotAllowed
+	}
+
+	checksum, size, err := s.writeBlob(br, maxSize)
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	attachment := Attachment{
+		ID:         generateUploadID(),
+		Name:       sanitizeFileName(name),
+		Size:       size,
+		MIME:       mimeType,
+		Checksum:   checksum,
+		Uploader:   uploader,
+		UploadedAt: time.Now(),
+	}
+	attachment.URL = "/api/uploads/" + attachment.ID
+
+	if attachment.IsImage() {
+		if file, err := os.Open(s.blobPath(checksum)); err == nil {
+			if config, _, err := image.DecodeConfig(file); err == nil {
+				attachment.Width = config.Width
+				attachment.Height = config.Height
+			}
+			file.Close()
+		}
+
+		// 縮圖失敗不影響上傳本身，客戶端可退回使用原圖
+		if err := s.generateThumbnails(&attachment); err != nil {
+			log.Printf(LogThumbnailError, attachment.ID, err)
+		}
+	}
+
+	if err := s.put(attachment); err != nil {
+		return Attachment{}, err
+	}
+	return attachment, nil
+}
+
+// Get 依 ID 取得上傳檔案的中繼資料
+//
+// Parameters:
+// - id: 上傳識別碼
+//
+// Returns:
+// - Attachment: 中繼資料
+// - bool: 是否存在
+func (s *UploadStore) Get(id string) (Attachment, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	attachment, ok := s.attachments[id]
+	return attachment, ok
+}
+
+// Open 開啟上傳檔案的內容
+//
+// Parameters:
+// - id: 上傳識別碼
+//
+// Returns:
+// - *os.File: 檔案內容，呼叫者負責關閉
+// - Attachment: 中繼資料
+// - error: 找不到或開啟失敗時的錯誤
+func (s *UploadStore) Open(id string) (*os.File, Attachment, error) {
+	attachment, ok := s.Get(id)
+	if !ok {
+		return nil, Attachment{}, ErrUploadNotFound
+	}
+
+	file, err := os.Open(s.blobPath(attachment.Checksum))
+	if err != nil {
+		return nil, Attachment{}, err
+	}
+	return file, attachment, nil
+}
+
+// put 保存中繼資料到記憶體和磁碟
+func (s *UploadStore) put(attachment Attachment) error {
+	data, err := json.MarshalIndent(attachment, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	metaDir := filepath.Join(s.dir, "meta")
+	if err := os.MkdirAll(metaDir, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(metaDir, attachment.ID+".json"), data, 0o644); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.attachments[attachment.ID] = attachment
+	s.mu.Unlock()
+	return nil
+}
+
+// writeBlob 將內容寫入內容定址路徑
+//
+// Parameters:
+// - r: 檔案內容
+// - maxSize: 大小上限
+//
+// Returns:
+// - string: SHA-256 雜湊值（十六進位）
+// - int64: 檔案大小
+// - error: 超過大小上限或寫入失敗時的錯誤
+func (s *UploadStore) writeBlob(r io.Reader, maxSize int64) (string, int64, error) {
+	tmpDir := filepath.Join(s.dir, "tmp")
+	if err := os.MkdirAll(tmpDir, 0o755); err != nil {
+		return "", 0, err
+	}
+
+	tmp, err := os.CreateTemp(tmpDir, "upload-*")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), io.LimitReader(r, maxSize+1))
+	closeErr := tmp.Close()
+	if err != nil {
+		return "", 0, err
+	}
+	if closeErr != nil {
+		return "", 0, closeErr
+	}
+	if size > maxSize {
+		return "", 0, ErrUploadTooLarge
+	}
+
+	checksum := hex.EncodeToString(hasher.Sum(nil))
+	if err := s.storeBlob(tmp.Name(), checksum); err != nil {
+		return "", 0, err
+	}
+	return checksum, size, nil
+}
+
+// storeBlob 將暫存檔移到雜湊路徑，內容已存在時保留既有檔案
+func (s *UploadStore) storeBlob(tmpPath, checksum string) error {
+	blobPath := s.blobPath(checksum)
+	if _, err := os.Stat(blobPath); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(blobPath), 0o755); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, blobPath)
+}
+
+// blobPath 返回雜湊值對應的檔案路徑，使用前兩個字元分散目錄
+func (s *UploadStore) blobPath(checksum string) string {
+	return filepath.Join(s.dir, "blobs", checksum[:2], checksum)
+}
+
+// detectMIMEType 由檔案開頭偵測 MIME 類型（不含參數）
+func detectMIMEType(head []byte) string {
+	mimeType, _, err := mime.ParseMediaType(http.DetectContentType(head))
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return mimeType
+}
+
+// sanitizeFileName 移除檔名中的路徑部分
+func sanitizeFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" || name == "" {
+		return "upload"
+	}
+	return name
+}
+
+// generateUploadID 生成隨機的上傳識別碼
+func generateUploadID() string {
+	randomBytes := make([]byte, 16)
+	rand.Read(randomBytes)
+	return hex.EncodeToString(randomBytes)
+}
+
+// resolveAttachment 驗證並補齊訊息的附件資訊
+//
+// Responsible for:
+// - 確認 image/file 訊息引用了存在的上傳檔案
+// - 以伺服器記錄的中繼資料取代客戶端提供的內容
+// - 移除其他類型訊息上的附件欄位
+//
+// Parameters:
+// - msg: 要驗證的訊息，驗證成功時會被修改
+//
+// Returns:
+// - error: 驗證失敗的原因
+func resolveAttachment(msg *Message) error {
+	if msg.Type != MessageTypeImage && msg.Type != MessageTypeFile {
+		msg.Attachment = nil
+		return nil
+	}
+
+	if msg.Attachment == nil || msg.Attachment.ID == "" {
+		return ErrAttachmentRequired
+	}
+
+	attachment, ok := uploadStore.Get(msg.Attachment.ID)
+	if !ok {
+		return ErrUploadNotFound
+	}
+	if msg.Type == MessageTypeImage && !attachment.IsImage() {
+		return ErrAttachmentNotImage
+	}
+
+	msg.Attachment = &attachment
+	return nil
+}
+
+// uploadStatusCode 將上傳錯誤對應到 HTTP 狀態碼
+func uploadStatusCode(err error) int {
+	switch {
+	case errors.Is(err, ErrUploadTooLarge), errors.Is(err, ErrUploadChunkOverflow):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrUploadTypeNotAllowed):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, ErrUploadNotFound), errors.Is(err, ErrUploadSessionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrUploadOffsetMismatch), errors.Is(err, ErrUploadIncomplete), errors.Is(err, ErrUploadSessionBusy):
+		return http.StatusConflict
+	case errors.Is(err, ErrUploadChecksumMismatch):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, ErrUploadChecksumRequired), errors.Is(err, ErrUploadInvalidSize):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrUploadEmpty):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// contentDisposition 建立下載用的 Content-Disposition 標頭
+func contentDisposition(attachment Attachment) string {
+	disposition := "attachment"
+	if attachment.IsImage() {
+		disposition = "inline"
+	}
+	return fmt.Sprintf("%s; filename*=UTF-8''%s", disposition, encodeRFC5987(attachment.Name))
+}
+
+// encodeRFC5987 依 RFC 5987 編碼檔名
+func encodeRFC5987(name string) string {
+	var b strings.Builder
+	for _, c := range []byte(name) {
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// encodeTestPNG 產生指定大小的 PNG 圖片內容
+func encodeTestPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// TestUploadStoreSave 測試上傳檔案的保存與驗證
+func TestUploadStoreSave(t *testing.T) {
+	store := NewUploadStore(t.TempDir(), 1<<20, DefaultUploadAllowedMIMETypes)
+
+	t.Run("圖片", func(t *testing.T) {
+		attachment, err := store.Save("../../photo.png", bytes.NewReader(encodeTestPNG(t, 40, 30)), "alice")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if attachment.MIME != "image/png" {
+			t.Errorf("Expected MIME 'image/png', got '%s'", attachment.MIME)
+		}
+		if attachment.Width != 40 || attachment.Height != 30 {
+			t.Errorf("Expected 40x30, got %dx%d", attachment.Width, attachment.Height)
+		}
+		if attachment.Name != "photo.png" {
+			t.Errorf("Expected sanitized name 'photo.png', got '%s'", attachment.Name)
+		}
+		if _, ok := store.Get(attachment.ID); !ok {
+			t.Error("Expected attachment to be retrievable")
+		}
+	})
+
+	t.Run("相同內容共用檔案", func(t *testing.T) {
+		first, _ := store.Save("a.txt", strings.NewReader("hello"), "alice")
+		second, _ := store.Save("b.txt", strings.NewReader("hello"), "bob")
+		if first.ID == second.ID {
+			t.Error("Expected distinct upload IDs")
+		}
+		if first.Checksum != second.Checksum {
+			t.Error("Expected identical content to share a checksum")
+		}
+	})
+
+	t.Run("不允許的類型", func(t *testing.T) {
+		_, err := store.Save("app.exe", bytes.NewReader([]byte("MZ\x90\x00\x03\x00\x00\x00")), "alice")
+		if !errors.Is(err, ErrUploadTypeNotAllowed) {
+			t.Errorf("Expected ErrUploadTypeNotAllowed, got %v", err)
+		}
+	})
+
+	t.Run("超過大小上限", func(t *testing.T) {
+		_, err := store.Save("big.txt", strings.NewReader(strings.Repeat("a", 1<<20+1)), "alice")
+		if !errors.Is(err, ErrUploadTooLarge) {
+			t.Errorf("Expected ErrUploadTooLarge, got %v", err)
+		}
+	})
+
+	t.Run("重新載入中繼資料", func(t *testing.T) {
+		attachment, _ := store.Save("note.txt", strings.NewReader("note"), "alice")
+		reloaded := NewUploadStore(store.dir, 1<<20, DefaultUploadAllowedMIMETypes)
+		if err := reloaded.Load(); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := reloaded.Get(attachment.ID); !ok {
+			t.Error("Expected attachment to survive reload")
+		}
+	})
+}
+
+// TestResolveAttachment 測試圖片/檔案訊息的附件驗證
+func TestResolveAttachment(t *testing.T) {
+	uploadStore = NewUploadStore(t.TempDir(), 1<<20, DefaultUploadAllowedMIMETypes)
+	image, _ := uploadStore.Save("photo.png", bytes.NewReader(encodeTestPNG(t, 4, 4)), "alice")
+	text, _ := uploadStore.Save("note.txt", strings.NewReader("note"), "alice")
+
+	tests := []struct {
+		name    string
+		msg     Message
+		wantErr error
+	}{
+		{"圖片訊息", Message{Type: MessageTypeImage, Attachment: &Attachment{ID: image.ID}}, nil},
+		{"檔案訊息", Message{Type: MessageTypeFile, Attachment: &Attachment{ID: text.ID}}, nil},
+		{"缺少附件", Message{Type: MessageTypeFile}, ErrAttachmentRequired},
+		{"不存在的附件", Message{Type: MessageTypeFile, Attachment: &Attachment{ID: "missing"}}, ErrUploadNotFound},
+		{"圖片訊息引用非圖片", Message{Type: MessageTypeImage, Attachment: &Attachment{ID: text.ID}}, ErrAttachmentNotImage},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			msg := test.msg
+			err := resolveAttachment(&msg)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("Expected %v, got %v", test.wantErr, err)
+			}
+			if err == nil && msg.Attachment.Checksum == "" {
+				t.Error("Expected attachment metadata to be filled from the store")
+			}
+		})
+	}
+}
+
+// TestUploadAPI 測試上傳與下載 API
+func TestUploadAPI(t *testing.T) {
+	uploadStore = NewUploadStore(t.TempDir(), 1<<20, DefaultUploadAllowedMIMETypes)
+	router := setupRoutes()
+	content := encodeTestPNG(t, 8, 8)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("file", "photo.png")
+	part.Write(content)
+	writer.Close()
+
+	req, _ := http.NewRequest("POST", "/api/uploads", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected 401 without credentials, got %d", rr.Code)
+	}
+
+	body.Reset()
+	writer = multipart.NewWriter(&body)
+	part, _ = writer.CreateFormFile("file", "photo.png")
+	part.Write(content)
+	writer.Close()
+
+	req, _ = http.NewRequest("POST", "/api/uploads", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.SetBasicAuth("alice", "password123")
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var response struct {
+		Attachment Attachment `json:"attachment"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ = http.NewRequest("GET", response.Attachment.URL+"?username=bob&password=password123", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200 on download, got %d", rr.Code)
+	}
+	if !bytes.Equal(rr.Body.Bytes(), content) {
+		t.Error("Downloaded content does not match upload")
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("Expected Content-Type 'image/png', got '%s'", ct)
+	}
+}
@@ -1,9 +1,18 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
+
+	"github.com/gorilla/websocket"
 )
 
 // handleWebSocket 處理 WebSocket 連接請求
@@ -17,32 +26,58 @@ import (
 // - 在連接升級前進行帳號驗證以提高安全性
 // - 驗證失敗時發送錯誤訊息並關閉連接
 // - 成功連接後啟動讀寫 goroutines 處理訊息
+// - 每次連接嘗試的結果（成功、憑證錯誤、被限制或被封鎖）寫入稽核日誌
 //
 // Process flow:
-// 1. 升級 HTTP 連接為 WebSocket
-// 2. 從查詢參數獲取用戶名和密碼
-// 3. 驗證帳號憑證是否有效
-// 4. 建立 Client 實例並設置相關資訊
-// 5. 註冊客戶端到 Hub 進行管理
-// 6. 啟動 readPump 和 writePump goroutines
+// 1. 檢查來源 IP 的連接速率限制，超過時返回 429
+// 2. 升級 HTTP 連接為 WebSocket 並從查詢參數獲取用戶名和密碼
+// 3. 在登入保護下驗證帳號憑證，帳號被鎖定時返回 rate_limited 錯誤
+// 4. 被封鎖於頻道或伺服器的用戶返回 banned 錯誤並關閉連接
+// 5. 建立 Client 實例並設置相關資訊
+// 6. 註冊客戶端到 Hub 進行管理
+// 7. 啟動 readPump 和 writePump goroutines
 //
 // Usage context:
 // - 客戶端建立 WebSocket 連接時調用
 // - 路由器將 /ws 端點對應到此處理器
 func handleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := WebSocketUpgrader.Upgrade(w, r, nil)
+	username := r.URL.Query().Get("username")
+	password := r.URL.Query().Get("password")
+	ip := clientIP(r)
+
+	// 每次連接嘗試都寫入稽核日誌
+	audit := func(channel, outcome string, err error) {
+		auditLog.Record(AuditEntry{Actor: username, Action: AuditActionConnect, Channel: channel, IP: ip, Outcome: outcome, Details: auditDetails("", err)})
+	}
+
+	var limited *RateLimitError
+	if errors.As(rateLimits.AllowWebSocket(ip), &limited) {
+		audit("", AuditOutcomeDenied, limited)
+		w.Header().Set("Content-Type", "application/json")
+		writeRateLimitError(w, limited)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf(LogWebSocketUpgradeError, err)
 		return
 	}
 
-	username := r.URL.Query().Get("username")
-	password := r.URL.Query().Get("password")
-
-	// 驗證帳號
-	account, valid := validateAccount(username, password)
-	if !valid {
+	// 驗證帳號（連接已受 IP 限制，不再計入 IP 的登入嘗試次數；失敗只鎖定這個來源 IP）
+	account, err := rateLimits.Authenticate(clientIP(r), username, password)
+	if errors.As(err, &limited) {
+		audit("", AuditOutcomeDenied, err)
+		conn.WriteJSON(map[string]string{
+			"error": err.Error(),
+			"code":  ErrorCodeRateLimited,
+		})
+		conn.Close()
+		return
+	}
+	if err != nil {
 		log.Printf(LogInvalidAccount, username)
+		audit("", AuditOutcomeFailure, err)
 		conn.WriteJSON(map[string]string{
 			"error": ErrorInvalidAuth,
 		})
@@ -50,12 +85,25 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := checkBan(account.Channel, account.Username); err != nil {
+		log.Printf(LogBannedConnection, account.Username, account.Channel)
+		audit(account.Channel, AuditOutcomeDenied, err)
+		conn.WriteJSON(map[string]string{
+			"error": err.Error(),
+			"code":  ErrorCodeBanned,
+		})
+		conn.Close()
+		return
+	}
+
 	client := &Client{
 		conn:     conn,
-		send:     make(chan Message, DefaultClientSendBuffer),
+		send:     make(chan Message, configManager.Get().ClientSendBuffer),
 		username: account.Username,
 		channel:  account.Channel,
+		ip:       ip,
 	}
+	audit(account.Channel, AuditOutcomeSuccess, nil)
 
 	hub.register <- client
 
@@ -78,11 +126,15 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 // Process flow:
 // 1. 設置連接參數（讀取限制、超時、Pong 處理器）
 // 2. 進入無限迴圈讀取訊息
-// 3. 解析 JSON 格式的訊息
-// 4. 設置訊息屬性（ID、時間戳、用戶、頻道）
-// 5. 存儲訊息到對應頻道
-// 6. 廣播訊息給其他客戶端
-// 7. 發生錯誤時退出迴圈並清理連接
+// 3. 解析 JSON 格式的訊息，只能由伺服器產生的類型（例如 system）回覆 invalid_message_type 錯誤並略過
+// 4. 設置訊息屬性（ID、時間戳、用戶、頻道）並更新活動時間，超過速率限制時回覆 rate_limited 錯誤並略過
+// 5. 被封鎖的用戶回覆 banned 錯誤並略過，不處理投票與指令
+// 6. vote 訊息只更新投票結果，不寫入歷史
+// 7. 以 / 開頭的文字訊息交給指令註冊表處理，只有產生訊息的指令（例如 /me）繼續往下
+// 8. 檢查慢速模式，並透過 prepareMessage 檢查禁言、驗證附件與投票並執行內容審核，失敗時回覆 error 事件給發送者並略過此訊息
+// 9. 存儲訊息到對應頻道
+// 10. 廣播訊息給其他客戶端並通知被提及的用戶
+// 11. 發生錯誤時退出迴圈並清理連接
 //
 // Usage context:
 // - 客戶端連接建立後在獨立 goroutine 中運行
@@ -92,10 +144,12 @@ func (c *Client) readPump() {
 		c.conn.Close()
 	}()
 
-	c.conn.SetReadLimit(DefaultReadLimit)
-	c.conn.SetReadDeadline(time.Now().Add(DefaultReadTimeout * time.Second))
+	config := configManager.Get()
+	readTimeout := time.Duration(config.ReadTimeout) * time.Second
+	c.conn.SetReadLimit(config.ReadLimit)
+	c.conn.SetReadDeadline(time.Now().Add(readTimeout))
 	c.conn.SetPongHandler(func(string) error {
-		c.conn.SetReadDeadline(time.Now().Add(DefaultReadTimeout * time.Second))
+		c.conn.SetReadDeadline(time.Now().Add(readTimeout))
 		return nil
 	})
 
@@ -107,19 +161,106 @@ func (c *Client) readPump() {
 			break
 		}
 
+		// 只能由伺服器產生的類型（例如 system）會略過後續的檢查，在處理前拒絕
+		if err := checkClientMessageType(msg); err != nil {
+			hub.sendToClient(c, NewErrorMessage(ErrorCodeInvalidType, err.Error(), c.channel))
+			continue
+		}
+
 		msg.ID = generateMessageID()
 		msg.Timestamp = time.Now()
 		msg.User = c.username
 		msg.Channel = c.channel
+		presenceTracker.Touch(c.username)
+
+		// 速率限制、封鎖與角色的發送權限（例如 guest 只能讀取），投票與指令也一併拒絕
+		account, known := findAccount(c.username)
+		if !known {
+			hub.sendToClient(c, NewErrorMessage(ErrorCodeSendForbidden, ErrorSendForbidden, c.channel))
+			continue
+		}
+		if err := checkSender(&account, c.channel); err != nil {
+			hub.sendToClient(c, newSendErrorMessage(err, c.channel))
+			continue
+		}
+
+		// 投票不是聊天訊息，更新票數後不寫入歷史
+		if msg.Type == MessageTypeVote {
+			if _, err := castVote(c.username, []string{c.channel}, msg.Vote); err != nil {
+				hub.sendToClient(c, NewErrorMessage(ErrorCodeInvalidVote, err.Error(), c.channel))
+			}
+			continue
+		}
+
+		// 斜線指令在寫入歷史前攔截，回覆只發送給執行者
+		if name, rawArgs, ok := extractCommand(&msg); ok {
+			next, handled := c.runCommand(name, rawArgs, msg)
+			if handled {
+				continue
+			}
+			msg = next
+		}
+
+		if err := checkSlowMode(&account, msg); err != nil {
+			hub.sendToClient(c, newSendErrorMessage(err, c.channel))
+			continue
+		}
+
+		// 驗證、審核並補齊訊息內容，失敗時只回覆發送者
+		if err := prepareMessage(&msg); err != nil {
+			log.Printf(LogMessageRejected, c.username, err)
+			hub.sendToClient(c, NewErrorMessage(rejectionCode(err), err.Error(), c.channel))
+			continue
+		}
+		recordSlowMode(&account, msg)
 
 		// 儲存訊息到對應 channel 的 messageStore
 		messageStore.AddMessage(msg)
 
 		// 廣播訊息到所有客戶端
 		hub.broadcast <- msg
+		notifyMentions(msg)
 	}
 }
 
+// runCommand 執行客戶端送出的斜線指令
+//
+// Design considerations:
+// - 錯誤與私人回覆只發送給這個連接
+// - 指令產生的訊息返回給 readPump，與一般訊息走相同的驗證、儲存與廣播流程
+//
+// Parameters:
+// - name: 指令名稱
+// - rawArgs: 指令參數的原始文字
+// - msg: 原始訊息
+//
+// Returns:
+// - Message: 指令產生、需要繼續發送的訊息
+// - bool: true 表示指令已處理完畢，沒有訊息需要發送
+func (c *Client) runCommand(name, rawArgs string, msg Message) (Message, bool) {
+	account, _ := findAccount(c.username)
+	result, err := commandRegistry.Execute(CommandContext{
+		Account: &account,
+		Channel: c.channel,
+		Name:    name,
+		RawArgs: rawArgs,
+		Message: msg,
+		IP:      c.ip,
+	})
+	if err != nil {
+		hub.sendToClient(c, NewErrorMessage(commandErrorCode(err), err.Error(), c.channel))
+		return Message{}, true
+	}
+
+	if result.Reply != "" {
+		hub.sendToClient(c, NewCommandReplyMessage(result.Reply, c.channel))
+	}
+	if result.Message == nil {
+		return Message{}, true
+	}
+	return *result.Message, false
+}
+
 // writePump 處理發送給客戶端的訊息
 //
 // Responsible for:
@@ -136,7 +277,8 @@ func (c *Client) readPump() {
 // 1. 進入無限迴圈監聽 send channel
 // 2. 收到訊息時序列化為 JSON 並發送
 // 3. 發送失敗時記錄錯誤並退出
-// 4. 退出時關閉 WebSocket 連接
+// 4. 被 Hub 強制斷線時送出帶有關閉原因的 close frame
+// 5. 退出時關閉 WebSocket 連接
 //
 // Usage context:
 // - 客戶端連接建立後在獨立 goroutine 中運行
@@ -150,6 +292,68 @@ func (c *Client) writePump() {
 			return
 		}
 	}
+
+	// send 已由 Hub 關閉；closeReason 在關閉前設置，此處讀取不會競爭
+	if c.closeReason != "" {
+		c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, c.closeReason))
+	}
+}
+
+// newWebSocketUpgrader 建立 WebSocket 升級器
+//
+// Design considerations:
+// - 每次升級時以 configManager 目前的設定檢查 Origin，重新載入後立即生效
+func newWebSocketUpgrader() websocket.Upgrader {
+	return websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			return originAllowed(configManager.Get(), r)
+		},
+	}
+}
+
+// originAllowed 檢查 WebSocket 升級請求的 Origin
+//
+// Design considerations:
+// - 與 CORS 共用 corsOriginAllowed 的允許清單，AllowAllOrigins 為 true 時接受任何 Origin（開發用）
+// - 沒有 Origin 標頭的請求不是來自瀏覽器（例如手機 App），一律接受
+// - 另外接受與 Host 相同的 Origin，比對不分大小寫
+//
+// Parameters:
+// - config: 伺服器設定
+// - r: 升級請求
+//
+// Returns:
+// - bool: 是否接受
+func originAllowed(config ServerConfig, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || corsOriginAllowed(config, origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// newHub 建立 WebSocket 連接管理中心
+//
+// Parameters:
+// - config: 伺服器設定，決定廣播佇列大小與離開訊息的寬限期
+//
+// Returns:
+// - *Hub: 尚未運行的 Hub，需要在 goroutine 中呼叫 run
+func newHub(config ServerConfig) *Hub {
+	return &Hub{
+		clients:    make(map[*Client]bool),
+		broadcast:  make(chan Message, config.HubBroadcastBuffer),
+		register:   make(chan *Client),
+		unregister: make(chan *Client),
+		direct:     make(chan directMessage, config.HubBroadcastBuffer),
+		kick:       make(chan kickRequest),
+		inspect:    make(chan chan []ConnectionInfo),
+
+		leaveGracePeriod: time.Duration(config.PresenceGracePeriod) * time.Second,
+		pendingLeaves:    make(map[presenceKey]*pendingLeave),
+		leaveExpired:     make(chan *pendingLeave),
+	}
 }
 
 // Hub.run 運行 WebSocket 連接管理中心
@@ -161,16 +365,20 @@ func (c *Client) writePump() {
 //
 // Design considerations:
 // - 使用 select 語句處理多個 channel 的事件
-// - 註冊和取消註冊時發送系統通知
+// - 只有用戶在頻道內的第一個/最後一個裝置才觸發加入/離開通知
+// - 離開通知延遲寬限期發送，快速重連時加入與離開互相抵銷
 // - 廣播時只發送給相同頻道的客戶端
 // - 發送失敗時自動清理斷開的連接
 //
 // Process flow:
-// 1. 進入無限迴圈監聽三個主要 channel
-// 2. 處理客戶端註冊：加入 clients map，發送歡迎訊息
-// 3. 處理客戶端取消註冊：移除並發送離開訊息
-// 4. 處理訊息廣播：只發送給相同頻道的客戶端
-// 5. 發送失敗時自動清理斷開的客戶端
+// 1. 進入無限迴圈監聽 register、unregister、leaveExpired、direct、kick、broadcast
+// 2. 處理客戶端註冊：加入 clients map，更新在線狀態，必要時發送加入訊息
+// 3. 處理客戶端取消註冊：移除、更新在線狀態並排程離開訊息
+// 4. 處理寬限期到期：發送仍未被取消的離開訊息
+// 5. 處理私人訊息：只發送給指定的連接或用戶
+// 6. 處理強制斷線：通知並移除被踢出或封鎖的客戶端
+// 7. 處理訊息廣播：只發送給相同頻道的客戶端
+// 8. 發送失敗時自動清理斷開的客戶端
 //
 // Usage context:
 // - 程式啟動時在獨立 goroutine 中運行
@@ -179,29 +387,35 @@ func (h *Hub) run() {
 	for {
 		select {
 		case client := <-h.register:
+			client.id = generateConnectionID()
+			client.connectedAt = time.Now()
 			h.clients[client] = true
+			presenceTracker.Connect(client)
 			log.Printf(LogUserConnected, client.username, client.channel)
-
-			// 發送歡迎消息
-			welcomeMsg := NewJoinMessage(client.username, client.channel)
-
-			// 儲存系統訊息到對應 channel
-			messageStore.AddMessage(welcomeMsg)
-			h.broadcast <- welcomeMsg
+			h.handleArrival(client)
 
 		case client := <-h.unregister:
 			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
+				h.removeClient(client)
 				log.Printf(LogUserDisconnected, client.username, client.channel)
+			}
 
-				// 發送離線消息
-				leaveMsg := NewLeaveMessage(client.username, client.channel)
-				// 儲存系統訊息到對應 channel
-				messageStore.AddMessage(leaveMsg)
-				h.broadcast <- leaveMsg
+		case pending := <-h.leaveExpired:
+			// 寬限期內未重新連線，正式發送離開訊息
+			if h.pendingLeaves[pending.key] == pending {
+				delete(h.pendingLeaves, pending.key)
+				h.announcePresence(NewLeaveMessage(pending.key.username, pending.key.channel))
 			}
 
+		case dm := <-h.direct:
+			h.deliverDirect(dm)
+
+		case req := <-h.kick:
+			req.done <- h.closeClients(req)
+
+		case done := <-h.inspect:
+			done <- h.snapshot()
+
 		case message := <-h.broadcast:
 			// 只廣播給相同 channel 的客戶端
 			log.Printf(LogBroadcastToChannel, message.Channel, message.User, message.Content)
@@ -213,8 +427,7 @@ func (h *Hub) run() {
 						broadcastCount++
 						log.Printf(LogMessageSentToUser, client.username, client.channel)
 					default:
-						close(client.send)
-						delete(h.clients, client)
+						h.removeClient(client)
 						log.Printf(LogClientRemoved, client.username)
 					}
 				}
@@ -223,3 +436,474 @@ func (h *Hub) run() {
 		}
 	}
 }
+
+// publishMessage 儲存訊息並廣播給頻道內的客戶端
+//
+// Design considerations:
+// - 供伺服器端產生的訊息（例如分段上傳完成後的檔案訊息）使用
+// - 廣播在獨立 goroutine 中進行，避免呼叫者阻塞於 broadcast channel
+// - 訊息帶有 Mentions 時通知被提及的用戶
+//
+// Parameters:
+// - msg: 已設置 ID、時間戳與頻道的訊息
+func publishMessage(msg Message) {
+	messageStore.AddMessage(msg)
+	go func() {
+		hub.broadcast <- msg
+	}()
+	notifyMentions(msg)
+}
+
+// ErrServerOnlyMessageType 客戶端發送了只能由伺服器產生的訊息類型
+var ErrServerOnlyMessageType = errors.New(ErrorServerOnlyMessageType)
+
+// checkClientMessageType 拒絕客戶端發送只能由伺服器產生的訊息類型
+//
+// Design considerations:
+// - 系統訊息會略過封鎖、禁言、慢速模式與內容審核，客戶端偽造時可以繞過所有限制
+// - WebSocket 與 REST API 收到訊息後、執行任何檢查前呼叫
+// - /me 產生的 action 訊息由指令處理後才設置類型，不受影響
+//
+// Parameters:
+// - msg: 客戶端發送的訊息
+//
+// Returns:
+// - error: 類型只能由伺服器產生時返回 ErrServerOnlyMessageType
+func checkClientMessageType(msg Message) error {
+	for _, messageType := range ServerOnlyMessageTypes {
+		if msg.Type == messageType {
+			return ErrServerOnlyMessageType
+		}
+	}
+	return nil
+}
+
+// messageRejection 代表訊息在發送前的驗證中被拒絕
+//
+// Design considerations:
+// - 保留原始錯誤供 errors.Is 判斷，並附上回覆給 WebSocket 客戶端的錯誤代碼
+type messageRejection struct {
+	code string
+	err  error
+}
+
+func (e *messageRejection) Error() string { return e.err.Error() }
+func (e *messageRejection) Unwrap() error { return e.err }
+
+// checkSender 檢查帳號目前是否可以在頻道發送訊息
+//
+// Responsible for:
+// - 集中 WebSocket、REST API 與分段上傳共用的發送者檢查
+//
+// Design considerations:
+// - 依序檢查速率限制、封鎖與角色的發送權限，沒有角色視為沒有權限
+// - 慢速模式需要在指令處理後以最終訊息檢查，由 checkSlowMode 另外處理
+//
+// Parameters:
+// - account: 已驗證的發送者
+// - channel: 頻道名稱
+//
+// Returns:
+// - error: 超過速率限制時返回 *RateLimitError，封鎖或沒有權限時返回 *messageRejection
+func checkSender(account *Account, channel string) error {
+	if err := rateLimits.AllowMessage(account.Username, channel); err != nil {
+		return err
+	}
+	if err := checkBan(channel, account.Username); err != nil {
+		return &messageRejection{code: ErrorCodeBanned, err: err}
+	}
+	if !authorize(account, PermissionSendMessage, channel) {
+		return &messageRejection{code: ErrorCodeSendForbidden, err: ErrSendForbidden}
+	}
+	return nil
+}
+
+// writeSendError 將發送者檢查或慢速模式的錯誤寫入 HTTP 回應
+//
+// Parameters:
+// - w: HTTP 回應
+// - err: checkSender 或 checkSlowMode 返回的錯誤
+func writeSendError(w http.ResponseWriter, err error) {
+	var limited *RateLimitError
+	if errors.As(err, &limited) {
+		writeRateLimitError(w, limited)
+		return
+	}
+	w.WriteHeader(rejectionStatusCode(err))
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error(), "code": rejectionCode(err)})
+}
+
+// newSendErrorMessage 建立發送者檢查或慢速模式失敗時回覆給 WebSocket 發送者的錯誤事件
+//
+// Parameters:
+// - err: checkSender 或 checkSlowMode 返回的錯誤
+// - channel: 頻道名稱
+//
+// Returns:
+// - Message: error 事件，速率限制時附上 retryAfter
+func newSendErrorMessage(err error, channel string) Message {
+	var limited *RateLimitError
+	if errors.As(err, &limited) {
+		return newRateLimitMessage(limited, channel)
+	}
+	return NewErrorMessage(rejectionCode(err), err.Error(), channel)
+}
+
+// prepareMessage 在訊息寫入歷史前完成所有驗證與處理
+//
+// Responsible for:
+// - 集中所有發送路徑（WebSocket、REST API、分段上傳、排程）共用的處理流程
+// - 被標記的訊息加入審核佇列
+//
+// Design considerations:
+// - 內容審核在登記投票前執行，被拒絕的訊息不會留下投票狀態
+// - 提及與格式依審核修改後的內容解析
+//
+// Process flow:
+// 1. 設置暱稱並檢查封鎖與禁言
+// 2. 驗證附件與存活時間
+// 3. 執行內容審核流程，可能修改內容或拒絕訊息
+// 4. 驗證並登記投票
+// 5. 解析 @提及與訊息格式
+// 6. 有標記時加入審核佇列
+//
+// Parameters:
+// - msg: 已設置 ID、時間戳、用戶與頻道的訊息，會直接修改
+//
+// Returns:
+// - error: 訊息被拒絕時返回 *messageRejection
+func prepareMessage(msg *Message) error {
+	msg.Nick = nicknames.Get(msg.User)
+	if err := checkBanned(*msg); err != nil {
+		return &messageRejection{code: ErrorCodeBanned, err: err}
+	}
+	if err := checkMuted(*msg); err != nil {
+		return &messageRejection{code: ErrorCodeMuted, err: err}
+	}
+	if err := resolveAttachment(msg); err != nil {
+		return &messageRejection{code: ErrorCodeInvalidAttachment, err: err}
+	}
+	if err := applyMessageTTL(msg); err != nil {
+		return &messageRejection{code: ErrorCodeInvalidTTL, err: err}
+	}
+	flags, err := moderationPipeline.Moderate(msg)
+	if err != nil {
+		return &messageRejection{code: ErrorCodeMessageRejected, err: err}
+	}
+	if err := resolvePoll(msg); err != nil {
+		return &messageRejection{code: ErrorCodeInvalidPoll, err: err}
+	}
+	resolveMentions(msg)
+	resolveFormatting(msg)
+	moderationQueue.Add(*msg, flags)
+	return nil
+}
+
+// rejectionCode 取得訊息被拒絕時回覆給客戶端的錯誤代碼
+func rejectionCode(err error) string {
+	var rejection *messageRejection
+	if errors.As(err, &rejection) {
+		return rejection.code
+	}
+	return ErrorCodeMessageRejected
+}
+
+// rejectionStatusCode 將訊息被拒絕的錯誤對應到 HTTP 狀態碼
+func rejectionStatusCode(err error) int {
+	if errors.Is(err, ErrUserMuted) || errors.Is(err, ErrUserBanned) || errors.Is(err, ErrUserBannedFromServer) || errors.Is(err, ErrSendForbidden) {
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
+
+// directMessage 代表只發送給特定接收者的訊息
+//
+// Design considerations:
+// - client 不為 nil 時只發送給該連接（例如錯誤回覆給發送者）
+// - 否則發送給 username 的所有連接，不限頻道
+type directMessage struct {
+	client   *Client
+	username string
+	message  Message
+}
+
+// sendToClient 將私人訊息排入指定連接的發送佇列
+//
+// Design considerations:
+// - 透過 Hub 迴圈轉送，避免在 readPump 中直接寫入可能已被關閉的 send channel
+//
+// Parameters:
+// - client: 接收的連接
+// - message: 要發送的訊息
+func (h *Hub) sendToClient(client *Client, message Message) {
+	h.direct <- directMessage{client: client, message: message}
+}
+
+// sendToUser 將私人訊息發送給用戶的所有連接
+//
+// Parameters:
+// - username: 接收的用戶名
+// - message: 要發送的訊息
+func (h *Hub) sendToUser(username string, message Message) {
+	h.direct <- directMessage{username: username, message: message}
+}
+
+// deliverDirect 在 Hub 迴圈中投遞私人訊息
+//
+// Parameters:
+// - dm: 要投遞的私人訊息
+func (h *Hub) deliverDirect(dm directMessage) {
+	for client := range h.clients {
+		if client != dm.client && (dm.client != nil || client.username != dm.username) {
+			continue
+		}
+		select {
+		case client.send <- dm.message:
+			log.Printf(LogMessageSentToUser, client.username, client.channel)
+		default:
+			h.removeClient(client)
+			log.Printf(LogClientRemoved, client.username)
+		}
+	}
+}
+
+// kickRequest 代表一次強制斷線請求
+//
+// Design considerations:
+// - channel 為空字串時關閉用戶在所有頻道的連接（全伺服器封鎖）
+// - id 不為空時只關閉該連接，忽略 username 與 channel（管理 API 強制斷線）
+// - done 回傳被關閉的連接數，讓呼叫者決定是否發布系統訊息
+type kickRequest struct {
+	id       string
+	username string
+	channel  string
+	code     string
+	text     string
+	done     chan int
+}
+
+// disconnect 強制關閉用戶的連接
+//
+// Design considerations:
+// - 透過 Hub 迴圈處理，等待完成後才返回，呼叫者可以取得實際關閉的連接數
+//
+// Parameters:
+// - username: 用戶名
+// - channel: 頻道名稱，空字串表示所有頻道
+// - code: 斷線前發送的 error 事件代碼，同時作為 close frame 的原因
+// - text: 斷線前發送給用戶的說明
+//
+// Returns:
+// - int: 被關閉的連接數
+func (h *Hub) disconnect(username, channel, code, text string) int {
+	done := make(chan int, 1)
+	h.kick <- kickRequest{username: username, channel: channel, code: code, text: text, done: done}
+	return <-done
+}
+
+// closeClients 在 Hub 迴圈中關閉符合條件的連接
+//
+// Process flow:
+// 1. 找出用戶在指定頻道（或所有頻道）的連接
+// 2. 嘗試將 error 事件排入發送佇列，佇列已滿時直接略過
+// 3. 設置關閉原因並移除客戶端，writePump 送完剩餘訊息後關閉連接
+//
+// Parameters:
+// - req: 強制斷線請求
+//
+// Returns:
+// - int: 被關閉的連接數
+func (h *Hub) closeClients(req kickRequest) int {
+	closed := 0
+	for client := range h.clients {
+		if req.id != "" {
+			if client.id != req.id {
+				continue
+			}
+		} else if client.username != req.username || (req.channel != "" && client.channel != req.channel) {
+			continue
+		}
+		select {
+		case client.send <- NewErrorMessage(req.code, req.text, client.channel):
+		default:
+		}
+		client.closeReason = req.code
+		h.removeClient(client)
+		closed++
+	}
+	return closed
+}
+
+// disconnectConnection 強制關閉指定 ID 的連接
+//
+// Parameters:
+// - id: 連接 ID
+// - code: 斷線前發送的 error 事件代碼，同時作為 close frame 的原因
+// - text: 斷線前發送給用戶的說明
+//
+// Returns:
+// - bool: 是否找到並關閉連接
+func (h *Hub) disconnectConnection(id, code, text string) bool {
+	done := make(chan int, 1)
+	h.kick <- kickRequest{id: id, code: code, text: text, done: done}
+	return <-done > 0
+}
+
+// connectionIDCounter 用於生成連接 ID 的計數器
+var connectionIDCounter int64
+
+// generateConnectionID 生成伺服器生命週期內唯一的連接 ID
+func generateConnectionID() string {
+	return "conn_" + strconv.FormatInt(atomic.AddInt64(&connectionIDCounter, 1), 10)
+}
+
+// ConnectionInfo 代表一個 WebSocket 連接的狀態快照
+type ConnectionInfo struct {
+	ID            string    `json:"id"`            // 連接 ID
+	Username      string    `json:"username"`      // 用戶名稱
+	Channel       string    `json:"channel"`       // 所屬頻道
+	ConnectedAt   time.Time `json:"connectedAt"`   // 連接時間
+	QueueDepth    int       `json:"queueDepth"`    // 發送佇列中等待的訊息數
+	QueueCapacity int       `json:"queueCapacity"` // 發送佇列容量
+}
+
+// connections 返回所有連接的狀態快照
+//
+// Design considerations:
+// - 透過 Hub 迴圈建立快照，與 register、unregister 不會同時存取 clients
+// - 依連接時間排列，最早的連接在前
+//
+// Returns:
+// - []ConnectionInfo: 連接快照
+func (h *Hub) connections() []ConnectionInfo {
+	done := make(chan []ConnectionInfo, 1)
+	h.inspect <- done
+	return <-done
+}
+
+// snapshot 在 Hub 迴圈中建立連接快照
+func (h *Hub) snapshot() []ConnectionInfo {
+	connections := make([]ConnectionInfo, 0, len(h.clients))
+	for client := range h.clients {
+		connections = append(connections, ConnectionInfo{
+			ID:            client.id,
+			Username:      client.username,
+			Channel:       client.channel,
+			ConnectedAt:   client.connectedAt,
+			QueueDepth:    len(client.send),
+			QueueCapacity: cap(client.send),
+		})
+	}
+	sort.Slice(connections, func(i, j int) bool {
+		if !connections[i].ConnectedAt.Equal(connections[j].ConnectedAt) {
+			return connections[i].ConnectedAt.Before(connections[j].ConnectedAt)
+		}
+		return connections[i].ID < connections[j].ID
+	})
+	return connections
+}
+
+// presenceKey 識別用戶在特定頻道的在場狀態
+type presenceKey struct {
+	username string
+	channel  string
+}
+
+// pendingLeave 代表一則等待寬限期結束的離開通知
+type pendingLeave struct {
+	key   presenceKey
+	timer *time.Timer
+}
+
+// handleArrival 處理用戶連接後的加入通知
+//
+// Responsible for:
+// - 只在用戶於該頻道的第一個裝置連接時發送加入訊息
+// - 寬限期內重新連線時取消待發送的離開訊息，且不再發送加入訊息
+//
+// Parameters:
+// - client: 剛註冊的客戶端
+func (h *Hub) handleArrival(client *Client) {
+	key := presenceKey{username: client.username, channel: client.channel}
+
+	if pending, ok := h.pendingLeaves[key]; ok {
+		pending.timer.Stop()
+		delete(h.pendingLeaves, key)
+		log.Printf(LogReconnectCoalesced, client.username, client.channel)
+		return
+	}
+
+	if h.countConnections(key) > 1 {
+		return
+	}
+
+	h.announcePresence(NewJoinMessage(client.username, client.channel))
+}
+
+// removeClient 移除客戶端並處理離開通知
+//
+// Responsible for:
+// - 從 clients map 移除客戶端並關閉其發送佇列
+// - 更新在線狀態
+// - 該用戶在頻道內的最後一個裝置離線時，延遲寬限期後發送離開訊息
+//
+// Design considerations:
+// - 寬限期為 0 時立即發送離開訊息
+// - 寬限期到期後透過 leaveExpired 回到 Hub 迴圈處理，避免跨 goroutine 存取 Hub 狀態
+//
+// Parameters:
+// - client: 要移除的客戶端
+func (h *Hub) removeClient(client *Client) {
+	delete(h.clients, client)
+	close(client.send)
+	presenceTracker.Disconnect(client)
+
+	key := presenceKey{username: client.username, channel: client.channel}
+	if h.countConnections(key) > 0 {
+		return
+	}
+
+	if h.leaveGracePeriod <= 0 {
+		h.announcePresence(NewLeaveMessage(client.username, client.channel))
+		return
+	}
+
+	pending := &pendingLeave{key: key}
+	pending.timer = time.AfterFunc(h.leaveGracePeriod, func() {
+		h.leaveExpired <- pending
+	})
+	h.pendingLeaves[key] = pending
+}
+
+// countConnections 計算用戶在指定頻道的連接數
+//
+// Parameters:
+// - key: 用戶與頻道
+//
+// Returns:
+// - int: 目前已註冊的連接數
+func (h *Hub) countConnections(key presenceKey) int {
+	count := 0
+	for client := range h.clients {
+		if client.username == key.username && client.channel == key.channel {
+			count++
+		}
+	}
+	return count
+}
+
+// announcePresence 發送加入/離開系統訊息
+//
+// Responsible for:
+// - 根據頻道設定決定是否寫入歷史記錄
+// - 廣播訊息給頻道內的客戶端
+//
+// Parameters:
+// - msg: 加入或離開的系統訊息
+func (h *Hub) announcePresence(msg Message) {
+	if channelSettings.Get(msg.Channel).PersistPresence {
+		// 儲存系統訊息到對應 channel
+		messageStore.AddMessage(msg)
+	}
+	h.broadcast <- msg
+}
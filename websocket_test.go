@@ -0,0 +1,196 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newTestHub 建立並啟動測試用的 Hub
+func newTestHub(gracePeriod time.Duration) *Hub {
+	h := &Hub{
+		clients:          make(map[*Client]bool),
+		broadcast:        make(chan Message, 256),
+		register:         make(chan *Client),
+		unregister:       make(chan *Client),
+		direct:           make(chan directMessage, 256),
+		kick:             make(chan kickRequest),
+		inspect:          make(chan chan []ConnectionInfo),
+		leaveGracePeriod: gracePeriod,
+		pendingLeaves:    make(map[presenceKey]*pendingLeave),
+		leaveExpired:     make(chan *pendingLeave),
+	}
+	go h.run()
+	return h
+}
+
+// newTestClient 建立不含 WebSocket 連接的測試客戶端
+func newTestClient(username, channel string) *Client {
+	return &Client{
+		send:     make(chan Message, 64),
+		username: username,
+		channel:  channel,
+	}
+}
+
+// collectSystemMessages 在指定時間內收集客戶端收到的系統訊息內容
+func collectSystemMessages(client *Client, wait time.Duration) []string {
+	var contents []string
+	timeout := time.After(wait)
+	for {
+		select {
+		case msg := <-client.send:
+			if msg.Type == MessageTypeSystem {
+				contents = append(contents, msg.Content)
+			}
+		case <-timeout:
+			return contents
+		}
+	}
+}
+
+// collectMessages 在指定時間內收集客戶端收到的所有訊息
+func collectMessages(client *Client, wait time.Duration) []Message {
+	var messages []Message
+	timeout := time.After(wait)
+	for {
+		select {
+		case msg := <-client.send:
+			messages = append(messages, msg)
+		case <-timeout:
+			return messages
+		}
+	}
+}
+
+// TestHubCoalescesQuickReconnect 測試寬限期內重連不會產生加入/離開訊息
+func TestHubCoalescesQuickReconnect(t *testing.T) {
+	messageStore = make(MessageStore)
+	presenceTracker = NewPresenceTracker(time.Minute)
+	channelSettings = NewChannelSettingsStore()
+	h := newTestHub(100 * time.Millisecond)
+
+	observer := newTestClient("bob", "general")
+	h.register <- observer
+	collectSystemMessages(observer, 20*time.Millisecond)
+
+	phone := newTestClient("alice", "general")
+	web := newTestClient("alice", "general")
+	h.register <- phone
+	h.register <- web
+	h.unregister <- phone
+	h.unregister <- web
+
+	reconnect := newTestClient("alice", "general")
+	h.register <- reconnect
+
+	got := collectSystemMessages(observer, 200*time.Millisecond)
+	if len(got) != 1 || got[0] != "alice 加入了 general 頻道" {
+		t.Fatalf("Expected a single join message, got %v", got)
+	}
+
+	h.unregister <- reconnect
+	got = collectSystemMessages(observer, 50*time.Millisecond)
+	if len(got) != 0 {
+		t.Fatalf("Expected leave to wait for grace period, got %v", got)
+	}
+
+	got = collectSystemMessages(observer, 200*time.Millisecond)
+	if len(got) != 1 || got[0] != "alice 離開了 general 頻道" {
+		t.Fatalf("Expected a single leave message after grace period, got %v", got)
+	}
+}
+
+// TestHubSkipsPresenceHistory 測試頻道設定可讓加入/離開訊息不寫入歷史
+func TestHubSkipsPresenceHistory(t *testing.T) {
+	messageStore = make(MessageStore)
+	presenceTracker = NewPresenceTracker(time.Minute)
+	channelSettings = NewChannelSettingsStore()
+	persist := false
+	channelSettings.Apply("tech", ChannelSettingsUpdate{PersistPresence: &persist})
+	h := newTestHub(0)
+
+	observer := newTestClient("charlie", "tech")
+	h.register <- observer
+	collectSystemMessages(observer, 20*time.Millisecond)
+
+	client := newTestClient("bob", "tech")
+	h.register <- client
+	h.unregister <- client
+
+	got := collectSystemMessages(observer, 100*time.Millisecond)
+	if len(got) != 2 {
+		t.Errorf("Expected join and leave to be broadcast, got %v", got)
+	}
+	// 觀察者自己的加入訊息也不應寫入
+	if count := messageStore.GetChannelMessageCount("tech"); count != 0 {
+		t.Errorf("Expected no persisted presence messages, got %d", count)
+	}
+}
+
+// TestServerOnlyMessageTypes 測試客戶端不能以只能由伺服器產生的訊息類型繞過禁言等檢查
+func TestServerOnlyMessageTypes(t *testing.T) {
+	hub = *newTestHub(0)
+	messageStore = make(MessageStore)
+	messageIndex = NewSearchIndex()
+	channelSettings = NewChannelSettingsStore()
+	muteStore = NewMuteStore()
+	defer func() { muteStore = NewMuteStore() }()
+	muteStore.Mute("general", "bob", time.Hour)
+
+	t.Run("REST API", func(t *testing.T) {
+		for _, messageType := range ServerOnlyMessageTypes {
+			req, _ := http.NewRequest("POST", "/api/messages", strings.NewReader(`{"type":"`+messageType+`","channel":"general","content":"偽造"}`))
+			req.SetBasicAuth("bob", "password123")
+			rr := httptest.NewRecorder()
+			setupRoutes().ServeHTTP(rr, req)
+			if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), ErrorCodeInvalidType) {
+				t.Errorf("%s: expected 400 %s, got %d %s", messageType, ErrorCodeInvalidType, rr.Code, rr.Body.String())
+			}
+		}
+		for _, msg := range messageStore.GetRecentMessages("general", 10) {
+			if msg.Content == "偽造" {
+				t.Errorf("Expected forged message not to be stored, got %+v", msg)
+			}
+		}
+	})
+
+	t.Run("WebSocket", func(t *testing.T) {
+		server := httptest.NewServer(setupRoutes())
+		defer server.Close()
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws?username=charlie&password=password123", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		if err := conn.WriteJSON(Message{Type: MessageTypeSystem, Content: "偽造的系統公告"}); err != nil {
+			t.Fatal(err)
+		}
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		for {
+			var msg Message
+			if err := conn.ReadJSON(&msg); err != nil {
+				t.Fatalf("Expected invalid type error, got %v", err)
+			}
+			if msg.Content == "偽造的系統公告" {
+				t.Fatal("Expected forged system message not to be broadcast")
+			}
+			if msg.Type == MessageTypeError {
+				if msg.Error == nil || msg.Error.Code != ErrorCodeInvalidType {
+					t.Errorf("Expected %s, got %+v", ErrorCodeInvalidType, msg.Error)
+				}
+				break
+			}
+		}
+		for _, msg := range messageStore.GetRecentMessages("random", 10) {
+			if msg.Content == "偽造的系統公告" {
+				t.Error("Expected forged system message not to be stored")
+			}
+		}
+	})
+}